@@ -1,6 +1,9 @@
 package main
 
 import (
+	"context"
+
+	"patient-chatbot/internal/auth"
 	"patient-chatbot/internal/client/llm"
 	"patient-chatbot/internal/client/vectordb"
 	"patient-chatbot/internal/config"
@@ -17,7 +20,8 @@ import (
 )
 
 type Server struct {
-	router *gin.Engine
+	router  *gin.Engine
+	service *service.Service
 }
 
 func NewServer(cfg *config.Config) *Server {
@@ -27,24 +31,41 @@ func NewServer(cfg *config.Config) *Server {
 	r.Use(middleware.RequestID())
 
 	r.Use(gin.Recovery())
-	r.Use(cors.Default())
+	corsConfig := cors.DefaultConfig()
+	corsConfig.AllowAllOrigins = true
+	corsConfig.AddAllowHeaders("Authorization")
+	r.Use(cors.New(corsConfig))
 
 	r.Use(logger.Init())
 
-	repository := repository.NewRepository(cfg.DBURL)
-	llmClient := llm.NewLLMClient(cfg, repository)
-	vectordbClient, err := vectordb.NewVectordbClient(cfg)
+	adminOnly := middleware.RequireRole(utils.Bundle, string(repository.UserRoleAdmin))
+
+	repo := repository.NewRepository(cfg.DBURL)
+	llmClient, err := llm.New(cfg)
+	if err != nil {
+		log.Error().Msg("Failed to create LLM client: " + err.Error())
+	}
+	vectorStore, err := vectordb.New(cfg)
 	if err != nil {
-		log.Error().Msg("Failed to create vectordb client: " + err.Error())
+		log.Fatal().Msg("Failed to create vector store: " + err.Error())
 	}
-	chatService := service.NewService(cfg, llmClient, vectordbClient, repository)
+	tokenManager := auth.NewTokenManager(cfg)
+	chatService := service.NewService(cfg, llmClient, vectorStore, repo, tokenManager)
 	h := handler.NewHandler(chatService)
 
-	handler.RegisterRoutes(r, h)
+	handler.RegisterRoutes(
+		r,
+		h,
+		middleware.Auth(utils.Bundle, tokenManager),
+		adminOnly,
+		middleware.RequireBootstrapToken(utils.Bundle, cfg.BootstrapToken),
+	)
 
-	return &Server{router: r}
+	return &Server{router: r, service: chatService}
 }
 
 func (s *Server) Run() error {
+	go s.service.RunIngestionWorkers(context.Background())
+	go s.service.RunNotificationScheduler(context.Background())
 	return s.router.Run(":8080")
 }
@@ -0,0 +1,107 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"patient-chatbot/internal/config"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+type TokenType string
+
+const (
+	TokenTypeAccess  TokenType = "access"
+	TokenTypeRefresh TokenType = "refresh"
+)
+
+var ErrInvalidToken = errors.New("invalid token")
+
+type Claims struct {
+	Type  TokenType `json:"typ"`
+	OrgID string    `json:"org,omitempty"`
+	Role  string    `json:"role,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// UserID returns the subject of the token as a UUID.
+func (c *Claims) UserID() (uuid.UUID, error) {
+	return uuid.Parse(c.Subject)
+}
+
+// OrganizationID returns the tenant the access token was issued for.
+func (c *Claims) OrganizationID() (uuid.UUID, error) {
+	return uuid.Parse(c.OrgID)
+}
+
+// TokenID returns the jti of the token as a UUID.
+func (c *Claims) TokenID() (uuid.UUID, error) {
+	return uuid.Parse(c.ID)
+}
+
+type TokenManager struct {
+	secret          []byte
+	accessTokenTTL  time.Duration
+	refreshTokenTTL time.Duration
+}
+
+func NewTokenManager(cfg *config.Config) *TokenManager {
+	return &TokenManager{
+		secret:          []byte(cfg.JWTSecret),
+		accessTokenTTL:  cfg.AccessTokenTTL,
+		refreshTokenTTL: cfg.RefreshTokenTTL,
+	}
+}
+
+// IssueAccessToken signs a short-lived token used on every authenticated request.
+// It carries the caller's tenant and role so handlers don't need a database round-trip.
+func (m *TokenManager) IssueAccessToken(userID uuid.UUID, orgID uuid.UUID, role string) (string, time.Time, error) {
+	claims := m.claims(userID, uuid.New(), TokenTypeAccess, m.accessTokenTTL)
+	claims.OrgID = orgID.String()
+	claims.Role = role
+	return m.sign(claims)
+}
+
+// IssueRefreshToken signs a long-lived token identified by tokenID, which the caller persists so it can be revoked.
+func (m *TokenManager) IssueRefreshToken(userID uuid.UUID, tokenID uuid.UUID) (string, time.Time, error) {
+	return m.sign(m.claims(userID, tokenID, TokenTypeRefresh, m.refreshTokenTTL))
+}
+
+func (m *TokenManager) claims(userID uuid.UUID, tokenID uuid.UUID, tokenType TokenType, ttl time.Duration) Claims {
+	now := time.Now()
+	return Claims{
+		Type: tokenType,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        tokenID.String(),
+			Subject:   userID.String(),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+		},
+	}
+}
+
+func (m *TokenManager) sign(claims Claims) (string, time.Time, error) {
+	signed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(m.secret)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("sign token: %w", err)
+	}
+	return signed, claims.ExpiresAt.Time, nil
+}
+
+// Parse validates the signature, expiry and type of a token and returns its claims.
+func (m *TokenManager) Parse(token string, tokenType TokenType) (*Claims, error) {
+	var claims Claims
+	_, err := jwt.ParseWithClaims(token, &claims, func(t *jwt.Token) (interface{}, error) {
+		return m.secret, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithExpirationRequired())
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+	if claims.Type != tokenType {
+		return nil, fmt.Errorf("%w: expected %s token", ErrInvalidToken, tokenType)
+	}
+	return &claims, nil
+}
@@ -0,0 +1,329 @@
+// Package chunker splits extracted document text into overlapping chunks for embedding.
+package chunker
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	DefaultSize    = 800
+	DefaultOverlap = 120
+
+	// maxHeadingRunes and maxHeadingWords bound what an unmarked line may look like to be
+	// taken as a heading.
+	maxHeadingRunes = 80
+	maxHeadingWords = 10
+)
+
+// Options are measured in characters (runes). Size is the longest chunk produced; Overlap is
+// how much trailing text of a chunk is repeated at the start of the next one in the same
+// section, in whole sentences.
+type Options struct {
+	Size    int
+	Overlap int
+}
+
+// Chunker splits text at sentence boundaries into chunks of at most Size characters. It is
+// stateless and safe for concurrent use.
+type Chunker struct {
+	size    int
+	overlap int
+}
+
+// New returns a Chunker. A zero Size means DefaultSize. Overlap is capped at half the chunk
+// size so that every chunk makes progress.
+func New(opts Options) *Chunker {
+	if opts.Size <= 0 {
+		opts.Size = DefaultSize
+	}
+	return &Chunker{size: opts.Size, overlap: min(max(opts.Overlap, 0), opts.Size/2)}
+}
+
+// section is a heading and the paragraphs under it, each paragraph a list of lines.
+type section struct {
+	heading    string
+	paragraphs [][]string
+}
+
+// unit is a piece of text that is never split across chunks: a sentence, a list item or a
+// table row. sep is what joins it to the unit before it.
+type unit struct {
+	text string
+	sep  string
+}
+
+// Split returns the chunks of text. Chunks never cross a heading, and every chunk of a
+// section starts with the section's heading, so retrieved chunks keep their context.
+// Sentences longer than a chunk are split between words.
+func (c *Chunker) Split(text string) []string {
+	var chunks []string
+	for _, s := range parseSections(text) {
+		chunks = append(chunks, c.splitSection(s)...)
+	}
+	return chunks
+}
+
+func (c *Chunker) splitSection(s section) []string {
+	var units []unit
+	for _, paragraph := range s.paragraphs {
+		units = append(units, paragraphUnits(paragraph)...)
+	}
+	if len(units) == 0 {
+		if s.heading == "" {
+			return nil
+		}
+		return []string{s.heading}
+	}
+
+	prefix := ""
+	if s.heading != "" && utf8.RuneCountInString(s.heading) <= c.size/2 {
+		prefix = s.heading + "\n"
+	} else if s.heading != "" {
+		units = append([]unit{{text: s.heading}}, units...)
+		units[1].sep = "\n"
+	}
+	room := c.size - utf8.RuneCountInString(prefix)
+	units = splitLongUnits(units, room)
+
+	var chunks []string
+	var current []unit
+	length := 0
+	for _, u := range units {
+		n := utf8.RuneCountInString(u.text)
+		if len(current) > 0 && length+utf8.RuneCountInString(u.sep)+n > room {
+			chunks = append(chunks, prefix+joinUnits(current))
+			current = c.overlapUnits(current, room-n-1)
+			length = unitsLength(current)
+		}
+		if len(current) > 0 {
+			length += utf8.RuneCountInString(u.sep)
+		}
+		current = append(current, u)
+		length += n
+	}
+	return append(chunks, prefix+joinUnits(current))
+}
+
+// overlapUnits returns the trailing units of a finished chunk to repeat at the start of the
+// next one: as many whole units as fit in the overlap and in limit.
+func (c *Chunker) overlapUnits(units []unit, limit int) []unit {
+	budget := min(c.overlap, limit)
+	length := 0
+	start := len(units)
+	for start > 0 {
+		n := utf8.RuneCountInString(units[start-1].text)
+		if start < len(units) {
+			n += utf8.RuneCountInString(units[start].sep)
+		}
+		if length+n > budget {
+			break
+		}
+		length += n
+		start--
+	}
+	return append([]unit(nil), units[start:]...)
+}
+
+func joinUnits(units []unit) string {
+	var b strings.Builder
+	for i, u := range units {
+		if i > 0 {
+			b.WriteString(u.sep)
+		}
+		b.WriteString(u.text)
+	}
+	return b.String()
+}
+
+func unitsLength(units []unit) int {
+	n := 0
+	for i, u := range units {
+		if i > 0 {
+			n += utf8.RuneCountInString(u.sep)
+		}
+		n += utf8.RuneCountInString(u.text)
+	}
+	return n
+}
+
+// paragraphUnits splits a paragraph into sentences. Lines wrapped within a sentence are
+// joined back together; list items and table rows are kept whole.
+func paragraphUnits(lines []string) []unit {
+	var units []unit
+	var prose []string
+	endProse := func() {
+		for i, sentence := range Sentences(strings.Join(prose, " ")) {
+			sep := " "
+			if i == 0 {
+				sep = "\n"
+			}
+			units = append(units, unit{text: sentence, sep: sep})
+		}
+		prose = nil
+	}
+	for _, line := range lines {
+		if isListItem(line) || strings.Contains(line, " | ") {
+			endProse()
+			units = append(units, unit{text: line, sep: "\n"})
+			continue
+		}
+		prose = append(prose, line)
+	}
+	endProse()
+	return units
+}
+
+// splitLongUnits breaks units longer than limit between words, or between characters for
+// a single word that is itself too long.
+func splitLongUnits(units []unit, limit int) []unit {
+	var out []unit
+	for _, u := range units {
+		if utf8.RuneCountInString(u.text) <= limit {
+			out = append(out, u)
+			continue
+		}
+
+		sep := u.sep
+		var piece strings.Builder
+		pieceLen := 0
+		flush := func() {
+			if pieceLen > 0 {
+				out = append(out, unit{text: piece.String(), sep: sep})
+				sep = " "
+				piece.Reset()
+				pieceLen = 0
+			}
+		}
+		for _, word := range strings.Fields(u.text) {
+			for utf8.RuneCountInString(word) > limit {
+				flush()
+				runes := []rune(word)
+				out = append(out, unit{text: string(runes[:limit]), sep: sep})
+				sep = ""
+				word = string(runes[limit:])
+			}
+			n := utf8.RuneCountInString(word)
+			if pieceLen > 0 && pieceLen+1+n > limit {
+				flush()
+			}
+			if pieceLen > 0 {
+				piece.WriteByte(' ')
+				pieceLen++
+			}
+			piece.WriteString(word)
+			pieceLen += n
+		}
+		flush()
+	}
+	return out
+}
+
+// parseSections groups the paragraphs of text (separated by blank lines) under the heading
+// that precedes them. Consecutive headings are kept together, e.g. a chapter title followed
+// by a section title.
+func parseSections(text string) []section {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+
+	var sections []section
+	current := section{}
+	var paragraph []string
+	endParagraph := func() {
+		if len(paragraph) == 0 {
+			return
+		}
+		if heading, ok := headingText(paragraph); ok {
+			if len(current.paragraphs) > 0 {
+				sections = append(sections, current)
+				current = section{heading: heading}
+			} else if current.heading != "" {
+				current.heading += "\n" + heading
+			} else {
+				current.heading = heading
+			}
+		} else {
+			current.paragraphs = append(current.paragraphs, paragraph)
+		}
+		paragraph = nil
+	}
+
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.Join(strings.Fields(line), " ")
+		if line == "" {
+			endParagraph()
+			continue
+		}
+		if strings.HasPrefix(line, "#") && markdownHeading(line) != "" {
+			// A Markdown heading is a paragraph of its own even without blank lines around it.
+			endParagraph()
+			paragraph = []string{line}
+			endParagraph()
+			continue
+		}
+		paragraph = append(paragraph, line)
+	}
+	endParagraph()
+
+	if current.heading != "" || len(current.paragraphs) > 0 {
+		sections = append(sections, current)
+	}
+	return sections
+}
+
+// headingText reports whether a paragraph is a heading: a Markdown heading, or a single
+// short line that does not read like a sentence or a list item.
+func headingText(paragraph []string) (string, bool) {
+	if len(paragraph) != 1 {
+		return "", false
+	}
+	line := paragraph[0]
+	if heading := markdownHeading(line); heading != "" {
+		return heading, true
+	}
+
+	if utf8.RuneCountInString(line) > maxHeadingRunes || len(strings.Fields(line)) > maxHeadingWords {
+		return "", false
+	}
+	if isListItem(line) || strings.Contains(line, " | ") {
+		return "", false
+	}
+	last, _ := utf8.DecodeLastRuneInString(line)
+	if isTerminator(last) || isCloser(last) || last == ',' || last == '،' || last == ';' {
+		return "", false
+	}
+	if !hasLetter(line) {
+		return "", false
+	}
+	return line, true
+}
+
+// markdownHeading returns the text of a "# Heading" line, or "" if line is not one.
+func markdownHeading(line string) string {
+	rest := strings.TrimLeft(line, "#")
+	if len(line)-len(rest) > 6 || !strings.HasPrefix(rest, " ") {
+		return ""
+	}
+	return strings.TrimSpace(rest)
+}
+
+func isListItem(line string) bool {
+	if strings.HasPrefix(line, "- ") || strings.HasPrefix(line, "* ") || strings.HasPrefix(line, "• ") {
+		return true
+	}
+	// Numbered items such as "1. " or "2) ".
+	i := 0
+	for i < len(line) && line[i] >= '0' && line[i] <= '9' {
+		i++
+	}
+	return i > 0 && i+1 < len(line) && (line[i] == '.' || line[i] == ')') && line[i+1] == ' '
+}
+
+func hasLetter(s string) bool {
+	for _, r := range s {
+		if unicode.IsLetter(r) {
+			return true
+		}
+	}
+	return false
+}
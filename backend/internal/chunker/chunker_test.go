@@ -0,0 +1,114 @@
+package chunker
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestSentences(t *testing.T) {
+	cases := []struct {
+		text string
+		want []string
+	}{
+		{
+			"Cravings pass. Drink water! Why wait? Start now",
+			[]string{"Cravings pass.", "Drink water!", "Why wait?", "Start now"},
+		},
+		{
+			"Ask Dr. Smith about e.g. patches. Take 2.5 mg daily. J. Doe agrees.",
+			[]string{"Ask Dr. Smith about e.g. patches.", "Take 2.5 mg daily.", "J. Doe agrees."},
+		},
+		{
+			`He said "stop." Then he did. see also the leaflet.`,
+			[]string{`He said "stop."`, "Then he did. see also the leaflet."},
+		},
+		{
+			"الرغبة في التدخين تزول خلال دقائق. هل جربت المشي؟ اشرب الماء!",
+			[]string{"الرغبة في التدخين تزول خلال دقائق.", "هل جربت المشي؟", "اشرب الماء!"},
+		},
+	}
+	for _, c := range cases {
+		if got := Sentences(c.text); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("Sentences(%q) = %q, want %q", c.text, got, c.want)
+		}
+	}
+}
+
+func TestSplitKeepsShortDocumentWhole(t *testing.T) {
+	text := "Coping with cravings\n\nNicotine cravings usually pass\nwithin five minutes.\n\nExercise improves lung capacity."
+	got := New(Options{}).Split(text)
+	want := []string{"Coping with cravings\nNicotine cravings usually pass within five minutes.\nExercise improves lung capacity."}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
+
+func TestSplitStartsNewChunkAtHeadings(t *testing.T) {
+	text := "# Symptoms\nCoughing increases at first.\n\n## Treatment\n- Nicotine patch\n- Counselling\n\nWeek | Goal\n1 | Quit"
+	got := New(Options{}).Split(text)
+	want := []string{
+		"Symptoms\nCoughing increases at first.",
+		"Treatment\n- Nicotine patch\n- Counselling\nWeek | Goal\n1 | Quit",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
+
+func TestSplitRespectsSizeAndOverlap(t *testing.T) {
+	var sentences []string
+	for i := 0; i < 40; i++ {
+		sentences = append(sentences, "Each smoke-free day lowers your risk a little more.")
+	}
+	text := "Benefits\n\n" + strings.Join(sentences, " ")
+
+	chunks := New(Options{Size: 200, Overlap: 60}).Split(text)
+	if len(chunks) < 10 {
+		t.Fatalf("got %d chunks, want the whole text split into many", len(chunks))
+	}
+	total := 0
+	for i, chunk := range chunks {
+		if n := utf8.RuneCountInString(chunk); n > 200 {
+			t.Fatalf("chunk %d has %d characters, want at most 200", i, n)
+		}
+		if !strings.HasPrefix(chunk, "Benefits\n") {
+			t.Fatalf("chunk %d lost its heading: %q", i, chunk)
+		}
+		body := strings.TrimPrefix(chunk, "Benefits\n")
+		if !strings.HasSuffix(body, ".") || !strings.HasPrefix(body, "Each") {
+			t.Fatalf("chunk %d does not break at sentence boundaries: %q", i, chunk)
+		}
+		total += strings.Count(body, "Each")
+	}
+	if total <= 40 {
+		t.Fatalf("expected consecutive chunks to overlap, got %d sentences for 40", total)
+	}
+}
+
+func TestSplitBreaksOverlongSentences(t *testing.T) {
+	text := strings.Repeat("word ", 100) + strings.Repeat("x", 70)
+	chunks := New(Options{Size: 50}).Split(text)
+	for i, chunk := range chunks {
+		if n := utf8.RuneCountInString(chunk); n > 50 {
+			t.Fatalf("chunk %d has %d characters: %q", i, n, chunk)
+		}
+	}
+	if joined := strings.Join(chunks, " "); strings.Count(joined, "word") != 100 || strings.Count(joined, "x") != 70 {
+		t.Fatalf("text was lost while splitting: %q", chunks)
+	}
+}
+
+func TestSplitArabic(t *testing.T) {
+	text := "التعامل مع الرغبة\n\n" + strings.Repeat("الرغبة في التدخين تزول خلال دقائق. ", 20)
+	chunks := New(Options{Size: 150, Overlap: 0}).Split(text)
+	if len(chunks) < 2 {
+		t.Fatalf("got %d chunks", len(chunks))
+	}
+	for i, chunk := range chunks {
+		if !strings.HasPrefix(chunk, "التعامل مع الرغبة\n") || !strings.HasSuffix(chunk, "دقائق.") {
+			t.Fatalf("chunk %d = %q", i, chunk)
+		}
+	}
+}
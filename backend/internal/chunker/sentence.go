@@ -0,0 +1,93 @@
+package chunker
+
+import (
+	"strings"
+	"unicode"
+)
+
+// abbreviations are words that end with a period without ending the sentence.
+var abbreviations = map[string]bool{
+	"e.g": true, "i.e": true, "vs": true, "dr": true, "mr": true, "mrs": true, "ms": true,
+	"prof": true, "st": true, "no": true, "fig": true, "approx": true,
+}
+
+// isTerminator reports whether r ends a sentence: Latin punctuation plus the Arabic question
+// mark (؟) and full stop (۔).
+func isTerminator(r rune) bool {
+	switch r {
+	case '.', '!', '?', '…', '؟', '۔':
+		return true
+	}
+	return false
+}
+
+// isCloser reports whether r may follow a terminator as part of the same sentence, like the
+// quote in `He said "stop."`.
+func isCloser(r rune) bool {
+	switch r {
+	case '"', '\'', ')', ']', '”', '’', '»':
+		return true
+	}
+	return false
+}
+
+// Sentences splits a line of text into sentences. A sentence ends at a terminator followed
+// by whitespace, unless the period belongs to an abbreviation or initial, or the next word
+// starts with a lowercase Latin letter. Arabic has no letter case, so Arabic text is split at
+// every terminator followed by whitespace.
+func Sentences(text string) []string {
+	runes := []rune(text)
+	var sentences []string
+	start := 0
+	for i := 0; i < len(runes); i++ {
+		if !isTerminator(runes[i]) {
+			continue
+		}
+
+		end := i + 1
+		for end < len(runes) && (isTerminator(runes[end]) || isCloser(runes[end])) {
+			end++
+		}
+		next := end
+		for next < len(runes) && unicode.IsSpace(runes[next]) {
+			next++
+		}
+		if next == end || next == len(runes) {
+			// No whitespace after it (a decimal, a URL), or the end of the text.
+			i = end - 1
+			continue
+		}
+		if runes[i] == '.' && isAbbreviation(runes[start:i]) {
+			i = end - 1
+			continue
+		}
+		if unicode.IsLower(runes[next]) && unicode.Is(unicode.Latin, runes[next]) {
+			i = end - 1
+			continue
+		}
+
+		if sentence := strings.TrimSpace(string(runes[start:end])); sentence != "" {
+			sentences = append(sentences, sentence)
+		}
+		start = next
+		i = next - 1
+	}
+	if sentence := strings.TrimSpace(string(runes[start:])); sentence != "" {
+		sentences = append(sentences, sentence)
+	}
+	return sentences
+}
+
+// isAbbreviation reports whether the word right before a period is an abbreviation or a
+// single-letter initial.
+func isAbbreviation(before []rune) bool {
+	start := len(before)
+	for start > 0 && !unicode.IsSpace(before[start-1]) && before[start-1] != '(' {
+		start--
+	}
+	word := strings.ToLower(string(before[start:]))
+	if len([]rune(word)) == 1 && unicode.IsLetter([]rune(word)[0]) {
+		return true
+	}
+	return abbreviations[word]
+}
@@ -0,0 +1,105 @@
+package calendar
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+var ErrUnauthorized = errors.New("caldav: credentials were rejected")
+
+// CalDAVClient writes events to one calendar collection on a CalDAV (RFC 4791) server, such
+// as https://dav.example.com/calendars/clinic/appointments/. Each event is stored as its
+// own resource named after its UID, so writing an event again replaces it.
+type CalDAVClient struct {
+	collectionURL string
+	username      string
+	password      string
+	httpClient    *http.Client
+}
+
+// NewCalDAVClient sends its requests with httpClient, which should refuse internal
+// addresses when collectionURL comes from a tenant; see package egress.
+func NewCalDAVClient(collectionURL string, username string, password string, httpClient *http.Client) *CalDAVClient {
+	return &CalDAVClient{
+		collectionURL: strings.TrimSuffix(collectionURL, "/") + "/",
+		username:      username,
+		password:      password,
+		httpClient:    httpClient,
+	}
+}
+
+// Check confirms that the collection exists and the credentials are accepted.
+func (c *CalDAVClient) Check(ctx context.Context) error {
+	body := `<?xml version="1.0" encoding="utf-8"?><propfind xmlns="DAV:"><prop><resourcetype/></prop></propfind>`
+	res, err := c.do(ctx, "PROPFIND", c.collectionURL, strings.NewReader(body), map[string]string{
+		"Content-Type": "application/xml; charset=utf-8",
+		"Depth":        "0",
+	})
+	if err != nil {
+		return err
+	}
+	if res != http.StatusMultiStatus && res != http.StatusOK {
+		return fmt.Errorf("caldav: PROPFIND returned %d", res)
+	}
+	return nil
+}
+
+// PutEvent creates or replaces an event.
+func (c *CalDAVClient) PutEvent(ctx context.Context, event Event) error {
+	res, err := c.do(ctx, http.MethodPut, c.eventURL(event.UID), bytes.NewReader(Encode("", []Event{event})), map[string]string{
+		"Content-Type": "text/calendar; charset=utf-8",
+	})
+	if err != nil {
+		return err
+	}
+	if res != http.StatusCreated && res != http.StatusNoContent && res != http.StatusOK {
+		return fmt.Errorf("caldav: PUT returned %d", res)
+	}
+	return nil
+}
+
+// DeleteEvent removes an event. Deleting one that is not there is not an error.
+func (c *CalDAVClient) DeleteEvent(ctx context.Context, uid string) error {
+	res, err := c.do(ctx, http.MethodDelete, c.eventURL(uid), nil, nil)
+	if err != nil {
+		return err
+	}
+	if res != http.StatusNoContent && res != http.StatusOK && res != http.StatusNotFound {
+		return fmt.Errorf("caldav: DELETE returned %d", res)
+	}
+	return nil
+}
+
+func (c *CalDAVClient) eventURL(uid string) string {
+	return c.collectionURL + url.PathEscape(uid) + ".ics"
+}
+
+// do sends a request and returns the response status.
+func (c *CalDAVClient) do(ctx context.Context, method string, target string, body io.Reader, headers map[string]string) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, method, target, body)
+	if err != nil {
+		return 0, fmt.Errorf("caldav: %w", err)
+	}
+	req.SetBasicAuth(c.username, c.password)
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+
+	res, err := c.httpClient.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("caldav: %s: %w", method, err)
+	}
+	defer res.Body.Close()
+	io.Copy(io.Discard, res.Body)
+
+	if res.StatusCode == http.StatusUnauthorized || res.StatusCode == http.StatusForbidden {
+		return 0, ErrUnauthorized
+	}
+	return res.StatusCode, nil
+}
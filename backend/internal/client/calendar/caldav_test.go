@@ -0,0 +1,65 @@
+package calendar
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCalDAVClientWritesAndDeletesEvents(t *testing.T) {
+	server := NewStandIn("clinic", "secret")
+	defer server.Close()
+	client := NewCalDAVClient(server.URL, "clinic", "secret", &http.Client{Timeout: 5 * time.Second})
+	ctx := context.Background()
+
+	if err := client.Check(ctx); err != nil {
+		t.Fatalf("Check: %v", err)
+	}
+
+	start := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+	event := Event{UID: "appt-1@patient-chatbot", Summary: "Consultation", Start: start, End: start.Add(time.Hour), Stamp: start}
+	if err := client.PutEvent(ctx, event); err != nil {
+		t.Fatalf("PutEvent: %v", err)
+	}
+	event.Summary = "Consultation (moved)"
+	if err := client.PutEvent(ctx, event); err != nil {
+		t.Fatalf("PutEvent again: %v", err)
+	}
+	stored, ok := server.Event(event.UID)
+	if !ok || !strings.Contains(stored, "SUMMARY:Consultation (moved)") || server.Len() != 1 {
+		t.Fatalf("expected one updated event, got %d: %q", server.Len(), stored)
+	}
+
+	if err := client.DeleteEvent(ctx, event.UID); err != nil {
+		t.Fatalf("DeleteEvent: %v", err)
+	}
+	if err := client.DeleteEvent(ctx, event.UID); err != nil {
+		t.Fatalf("deleting a missing event should succeed: %v", err)
+	}
+	if server.Len() != 0 {
+		t.Fatalf("expected no events, got %d", server.Len())
+	}
+}
+
+func TestCalDAVClientReportsFailures(t *testing.T) {
+	server := NewStandIn("clinic", "secret")
+	defer server.Close()
+	ctx := context.Background()
+
+	wrong := NewCalDAVClient(server.URL, "clinic", "wrong", &http.Client{Timeout: 5 * time.Second})
+	if err := wrong.Check(ctx); !errors.Is(err, ErrUnauthorized) {
+		t.Fatalf("expected ErrUnauthorized, got %v", err)
+	}
+
+	client := NewCalDAVClient(server.URL, "clinic", "secret", &http.Client{Timeout: 5 * time.Second})
+	server.FailNext(http.StatusInternalServerError)
+	if err := client.PutEvent(ctx, Event{UID: "x", Start: time.Now(), End: time.Now()}); err == nil {
+		t.Fatal("expected a server error to be reported")
+	}
+	if err := client.PutEvent(ctx, Event{UID: "x", Start: time.Now(), End: time.Now()}); err != nil {
+		t.Fatalf("PutEvent after recovery: %v", err)
+	}
+}
@@ -0,0 +1,110 @@
+package calendar
+
+import (
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	prodID = "-//patient-chatbot//calendar//EN"
+	// maxLineOctets is the longest content line RFC 5545 allows before folding.
+	maxLineOctets = 75
+)
+
+type EventStatus string
+
+const (
+	EventStatusConfirmed EventStatus = "CONFIRMED"
+	EventStatusCancelled EventStatus = "CANCELLED"
+)
+
+// Event is a calendar event. An all-day event only uses the dates of Start and End, and
+// End is the day after it ends, as in iCalendar.
+type Event struct {
+	UID         string
+	Summary     string
+	Description string
+	Location    string
+	Start       time.Time
+	End         time.Time
+	AllDay      bool
+	Status      EventStatus
+	// Stamp is when the event was last changed.
+	Stamp time.Time
+}
+
+// Encode renders events as an iCalendar (RFC 5545) object named name.
+func Encode(name string, events []Event) []byte {
+	var b strings.Builder
+	writeLine(&b, "BEGIN:VCALENDAR")
+	writeLine(&b, "VERSION:2.0")
+	writeLine(&b, "PRODID:"+prodID)
+	writeLine(&b, "CALSCALE:GREGORIAN")
+	if name != "" {
+		writeLine(&b, "X-WR-CALNAME:"+escapeText(name))
+	}
+	for _, event := range events {
+		writeEvent(&b, event)
+	}
+	writeLine(&b, "END:VCALENDAR")
+	return []byte(b.String())
+}
+
+func writeEvent(b *strings.Builder, event Event) {
+	writeLine(b, "BEGIN:VEVENT")
+	writeLine(b, "UID:"+event.UID)
+	writeLine(b, "DTSTAMP:"+formatTime(event.Stamp))
+	if event.AllDay {
+		writeLine(b, "DTSTART;VALUE=DATE:"+event.Start.Format("20060102"))
+		writeLine(b, "DTEND;VALUE=DATE:"+event.End.Format("20060102"))
+	} else {
+		writeLine(b, "DTSTART:"+formatTime(event.Start))
+		writeLine(b, "DTEND:"+formatTime(event.End))
+	}
+	writeLine(b, "SUMMARY:"+escapeText(event.Summary))
+	if event.Description != "" {
+		writeLine(b, "DESCRIPTION:"+escapeText(event.Description))
+	}
+	if event.Location != "" {
+		writeLine(b, "LOCATION:"+escapeText(event.Location))
+	}
+	if event.Status != "" {
+		writeLine(b, "STATUS:"+string(event.Status))
+	}
+	writeLine(b, "END:VEVENT")
+}
+
+func formatTime(t time.Time) string {
+	return t.UTC().Format("20060102T150405Z")
+}
+
+func escapeText(text string) string {
+	return strings.NewReplacer(
+		`\`, `\\`,
+		";", `\;`,
+		",", `\,`,
+		"\r\n", `\n`,
+		"\n", `\n`,
+	).Replace(text)
+}
+
+// writeLine writes a content line, folding it onto continuation lines that start with a
+// space so that no line is longer than maxLineOctets. Lines are only split between
+// characters, never inside a multi-byte one.
+func writeLine(b *strings.Builder, line string) {
+	limit := maxLineOctets
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+		b.WriteString(line[:cut])
+		b.WriteString("\r\n ")
+		line = line[cut:]
+		// The leading space of a continuation line counts towards its length.
+		limit = maxLineOctets - 1
+	}
+	b.WriteString(line)
+	b.WriteString("\r\n")
+}
@@ -0,0 +1,67 @@
+package calendar
+
+import (
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+func TestEncodeEscapesAndFoldsLines(t *testing.T) {
+	start := time.Date(2026, 3, 2, 9, 30, 0, 0, time.FixedZone("AST", 3*60*60))
+	events := []Event{
+		{
+			UID:         "a1@patient-chatbot",
+			Summary:     "Follow-up; bring results, please",
+			Description: "Line one\nLine two with a back\\slash",
+			Start:       start,
+			End:         start.Add(30 * time.Minute),
+			Status:      EventStatusConfirmed,
+			Stamp:       start,
+		},
+		{
+			UID:     "m1@patient-chatbot",
+			Summary: strings.Repeat("مرحلة ", 20),
+			Start:   time.Date(2026, 3, 3, 0, 0, 0, 0, time.UTC),
+			End:     time.Date(2026, 3, 4, 0, 0, 0, 0, time.UTC),
+			AllDay:  true,
+			Stamp:   start,
+		},
+	}
+	out := string(Encode("My calendar", events))
+
+	if !strings.HasPrefix(out, "BEGIN:VCALENDAR\r\nVERSION:2.0\r\n") || !strings.HasSuffix(out, "END:VCALENDAR\r\n") {
+		t.Fatalf("unexpected framing:\n%s", out)
+	}
+	for _, want := range []string{
+		"DTSTART:20260302T063000Z\r\n",
+		"DTEND:20260302T070000Z\r\n",
+		`SUMMARY:Follow-up\; bring results\, please` + "\r\n",
+		`DESCRIPTION:Line one\nLine two with a back\\slash` + "\r\n",
+		"DTSTART;VALUE=DATE:20260303\r\n",
+		"DTEND;VALUE=DATE:20260304\r\n",
+		"STATUS:CONFIRMED\r\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %q in:\n%s", want, out)
+		}
+	}
+
+	var unfolded strings.Builder
+	for i, line := range strings.Split(strings.TrimSuffix(out, "\r\n"), "\r\n") {
+		if len(line) > maxLineOctets {
+			t.Errorf("line %d is %d octets long", i, len(line))
+		}
+		if !utf8.ValidString(line) {
+			t.Errorf("line %d splits a character: %q", i, line)
+		}
+		if strings.HasPrefix(line, " ") {
+			unfolded.WriteString(line[1:])
+		} else {
+			unfolded.WriteString("\n" + line)
+		}
+	}
+	if !strings.Contains(unfolded.String(), "SUMMARY:"+strings.Repeat("مرحلة ", 20)) {
+		t.Errorf("folded summary does not unfold to the original:\n%s", unfolded.String())
+	}
+}
@@ -0,0 +1,125 @@
+package calendar
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+)
+
+// StandIn is an in-process CalDAV server for tests and local development. It serves one
+// calendar collection at URL, accepts the configured basic-auth credentials, and keeps the
+// events written to it in memory. Failures can be injected with FailNext.
+type StandIn struct {
+	URL      string
+	Username string
+	Password string
+
+	mu       sync.Mutex
+	server   *httptest.Server
+	events   map[string]string
+	failures []int
+}
+
+// NewStandIn starts a stand-in server. Callers must Close it.
+func NewStandIn(username string, password string) *StandIn {
+	s := &StandIn{Username: username, Password: password, events: map[string]string{}}
+	s.server = httptest.NewServer(http.HandlerFunc(s.serve))
+	s.URL = s.server.URL + "/calendars/clinic/"
+	return s
+}
+
+func (s *StandIn) Close() {
+	s.server.Close()
+}
+
+// FailNext makes the next request fail with status.
+func (s *StandIn) FailNext(status int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures = append(s.failures, status)
+}
+
+// Event returns the iCalendar object stored for uid.
+func (s *StandIn) Event(uid string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	event, ok := s.events[uid]
+	return event, ok
+}
+
+// Len returns how many events are stored.
+func (s *StandIn) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.events)
+}
+
+func (s *StandIn) serve(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.failures) > 0 {
+		status := s.failures[0]
+		s.failures = s.failures[1:]
+		w.WriteHeader(status)
+		return
+	}
+	if username, password, ok := r.BasicAuth(); !ok || username != s.Username || password != s.Password {
+		w.Header().Set("WWW-Authenticate", `Basic realm="caldav"`)
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	const collection = "/calendars/clinic/"
+	if r.URL.Path == collection && r.Method == "PROPFIND" {
+		w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+		w.WriteHeader(http.StatusMultiStatus)
+		io.WriteString(w, `<?xml version="1.0" encoding="utf-8"?><multistatus xmlns="DAV:" xmlns:C="urn:ietf:params:xml:ns:caldav"><response><href>`+collection+`</href><propstat><prop><resourcetype><collection/><C:calendar/></resourcetype></prop><status>HTTP/1.1 200 OK</status></propstat></response></multistatus>`)
+		return
+	}
+	name, ok := strings.CutPrefix(r.URL.Path, collection)
+	uid, isEvent := strings.CutSuffix(name, ".ics")
+	if !ok || !isEvent || uid == "" || strings.Contains(uid, "/") {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPut:
+		if !strings.HasPrefix(r.Header.Get("Content-Type"), "text/calendar") {
+			w.WriteHeader(http.StatusUnsupportedMediaType)
+			return
+		}
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		_, exists := s.events[uid]
+		s.events[uid] = string(body)
+		if exists {
+			w.WriteHeader(http.StatusNoContent)
+		} else {
+			w.WriteHeader(http.StatusCreated)
+		}
+	case http.MethodGet:
+		event, exists := s.events[uid]
+		if !exists {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+		io.WriteString(w, event)
+	case http.MethodDelete:
+		if _, exists := s.events[uid]; !exists {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		delete(s.events, uid)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
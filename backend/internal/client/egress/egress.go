@@ -0,0 +1,76 @@
+// Package egress builds HTTP clients for requests to URLs that tenants configure, such as
+// CalDAV servers and notification webhooks, so they cannot be pointed at the server's own
+// network.
+package egress
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"syscall"
+	"time"
+)
+
+// ErrAddressNotAllowed is returned when a request would connect to a loopback, private,
+// link-local or otherwise non-public address that is not on the allowlist.
+var ErrAddressNotAllowed = errors.New("address not allowed")
+
+// sharedAddressSpace is carrier-grade NAT (RFC 6598), which netip does not count as private.
+var sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+// Guard decides which addresses tenant-configured URLs may reach. The zero Guard allows
+// public addresses only.
+type Guard struct {
+	allowed []netip.Prefix
+}
+
+// NewGuard allows public addresses and those in allowed, e.g. the network of an
+// on-premises CalDAV server.
+func NewGuard(allowed []netip.Prefix) *Guard {
+	return &Guard{allowed: allowed}
+}
+
+// Allows reports whether addr may be connected to.
+func (g *Guard) Allows(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	for _, prefix := range g.allowed {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return addr.IsGlobalUnicast() && !addr.IsPrivate() && !sharedAddressSpace.Contains(addr)
+}
+
+// HTTPClient returns a client that refuses to connect to addresses the guard does not
+// allow. The check runs on the resolved address of every connection, redirects included,
+// so a hostname that resolves to an internal address is refused too. Proxies from the
+// environment are not used, since they would connect on the client's behalf.
+func (g *Guard) HTTPClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: timeout,
+		Control: func(network string, address string, conn syscall.RawConn) error {
+			addrPort, err := netip.ParseAddrPort(address)
+			if err != nil {
+				return fmt.Errorf("egress: %w", err)
+			}
+			if !g.Allows(addrPort.Addr()) {
+				return fmt.Errorf("egress: %w: %s", ErrAddressNotAllowed, addrPort.Addr())
+			}
+			return nil
+		},
+	}
+	transport := &http.Transport{
+		DialContext: func(ctx context.Context, network string, address string) (net.Conn, error) {
+			return dialer.DialContext(ctx, network, address)
+		},
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          10,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: time.Second,
+	}
+	return &http.Client{Timeout: timeout, Transport: transport}
+}
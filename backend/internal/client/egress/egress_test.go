@@ -0,0 +1,59 @@
+package egress
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+	"time"
+)
+
+func TestGuardAllowsOnlyPublicAddresses(t *testing.T) {
+	guard := NewGuard(nil)
+	for addr, want := range map[string]bool{
+		"93.184.216.34":    true,
+		"2606:4700::1111":  true,
+		"127.0.0.1":        false,
+		"::1":              false,
+		"10.1.2.3":         false,
+		"172.16.0.1":       false,
+		"192.168.1.1":      false,
+		"169.254.169.254":  false,
+		"fe80::1":          false,
+		"fd00::1":          false,
+		"100.64.0.1":       false,
+		"0.0.0.0":          false,
+		"::ffff:127.0.0.1": false,
+		"224.0.0.1":        false,
+	} {
+		if got := guard.Allows(netip.MustParseAddr(addr)); got != want {
+			t.Errorf("Allows(%s) = %v, want %v", addr, got, want)
+		}
+	}
+}
+
+func TestGuardAllowlist(t *testing.T) {
+	guard := NewGuard([]netip.Prefix{netip.MustParsePrefix("10.20.0.0/16"), netip.MustParsePrefix("192.168.1.5/32")})
+	for addr, want := range map[string]bool{"10.20.3.4": true, "10.21.0.1": false, "192.168.1.5": true, "192.168.1.6": false} {
+		if got := guard.Allows(netip.MustParseAddr(addr)); got != want {
+			t.Errorf("Allows(%s) = %v, want %v", addr, got, want)
+		}
+	}
+}
+
+func TestHTTPClientRefusesInternalAddresses(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	_, err := NewGuard(nil).HTTPClient(time.Second).Get(server.URL)
+	if !errors.Is(err, ErrAddressNotAllowed) {
+		t.Fatalf("err = %v, want ErrAddressNotAllowed", err)
+	}
+
+	res, err := NewGuard([]netip.Prefix{netip.MustParsePrefix("127.0.0.1/32")}).HTTPClient(time.Second).Get(server.URL)
+	if err != nil {
+		t.Fatalf("Get with the server allowlisted: %v", err)
+	}
+	res.Body.Close()
+}
@@ -0,0 +1,175 @@
+package llm
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"patient-chatbot/internal/dto"
+)
+
+const (
+	ProviderGroq = "groq"
+	ProviderFake = "fake"
+)
+
+// FakeClient is an in-process Client for offline development and tests. Replies can be
+// scripted with QueueChat/QueueDescription; once the queues are empty it answers from the
+// supplied context and titles documents with their first line, so the whole upload→chat
+// flow works without network access. Scripted tool calls are run against the tools passed
+// to Chat, like LLMClient does.
+type FakeClient struct {
+	mu             sync.Mutex
+	chatReplies    []fakeChatReply
+	descriptions   []fakeDescription
+	transcriptions []string
+	calls          []FakeCall
+}
+
+type fakeChatReply struct {
+	answer string
+	calls  []ToolCall
+	err    error
+}
+
+type fakeDescription struct {
+	result *DocumentDescription
+	err    error
+}
+
+// FakeCall records the arguments of a Chat or ChatStream call, and the results of the
+// tools it ran, keyed by tool name.
+type FakeCall struct {
+	Messages    []dto.Message
+	Chunks      []string
+	Lang        string
+	Options     ChatOptions
+	Tools       []string
+	ToolResults map[string]string
+}
+
+func NewFakeClient() *FakeClient {
+	return &FakeClient{}
+}
+
+// QueueChat scripts the next Chat or ChatStream reply, made after running calls.
+func (f *FakeClient) QueueChat(answer string, calls ...ToolCall) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.chatReplies = append(f.chatReplies, fakeChatReply{answer: answer, calls: calls})
+}
+
+// QueueChatError makes the next Chat or ChatStream call fail with err, after running calls.
+func (f *FakeClient) QueueChatError(err error, calls ...ToolCall) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.chatReplies = append(f.chatReplies, fakeChatReply{calls: calls, err: err})
+}
+
+// QueueDescription scripts the next DescribeDocument reply.
+func (f *FakeClient) QueueDescription(description *DocumentDescription, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.descriptions = append(f.descriptions, fakeDescription{result: description, err: err})
+}
+
+// QueueTranscription scripts the text returned by the next TranscribePage call.
+func (f *FakeClient) QueueTranscription(text string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.transcriptions = append(f.transcriptions, text)
+}
+
+// Calls returns the chat calls received so far.
+func (f *FakeClient) Calls() []FakeCall {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]FakeCall(nil), f.calls...)
+}
+
+func (f *FakeClient) Chat(ctx context.Context, messages []dto.Message, chunks []string, lang string, tools []Tool, options ChatOptions) (*ChatResult, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	call := FakeCall{Messages: messages, Chunks: chunks, Lang: lang, Options: options, ToolResults: map[string]string{}}
+	for _, tool := range tools {
+		call.Tools = append(call.Tools, tool.Name)
+	}
+	f.calls = append(f.calls, call)
+
+	if len(f.chatReplies) > 0 {
+		reply := f.chatReplies[0]
+		f.chatReplies = f.chatReplies[1:]
+		result := &ChatResult{Answer: reply.answer}
+		for _, toolCall := range reply.calls {
+			content, ok := runTool(ctx, tools, toolCall)
+			call.ToolResults[toolCall.Name] = content
+			toolCall.Failed = !ok
+			result.ToolCalls = append(result.ToolCalls, toolCall)
+		}
+		return result, reply.err
+	}
+
+	if len(chunks) == 0 {
+		return &ChatResult{Answer: catalogueFor(lang).OutOfScopeRefusal}, nil
+	}
+	return &ChatResult{Answer: "Based on our guidelines: " + strings.Join(chunks, " ")}, nil
+}
+
+// ChatStream streams the Chat reply word by word.
+func (f *FakeClient) ChatStream(ctx context.Context, messages []dto.Message, chunks []string, lang string, tools []Tool, options ChatOptions, onDelta func(string) error) (*ChatResult, error) {
+	result, err := f.Chat(ctx, messages, chunks, lang, tools, options)
+	if err != nil {
+		return result, err
+	}
+
+	words := strings.SplitAfter(result.Answer, " ")
+	for _, word := range words {
+		if err := onDelta(word); err != nil {
+			return result, err
+		}
+	}
+	return result, nil
+}
+
+// DescribeDocument titles the document with its first line and summarises it with the
+// paragraph that follows.
+func (f *FakeClient) DescribeDocument(ctx context.Context, text string) (*DocumentDescription, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if len(f.descriptions) > 0 {
+		reply := f.descriptions[0]
+		f.descriptions = f.descriptions[1:]
+		return reply.result, reply.err
+	}
+
+	var paragraphs []string
+	for _, paragraph := range strings.Split(text, "\n\n") {
+		if paragraph = strings.TrimSpace(paragraph); paragraph != "" {
+			paragraphs = append(paragraphs, paragraph)
+		}
+	}
+
+	description := &DocumentDescription{Title: "Untitled document", Category: "General"}
+	if len(paragraphs) > 0 {
+		title, _, _ := strings.Cut(paragraphs[0], "\n")
+		description.Title = strings.TrimSpace(strings.TrimLeft(title, "#"))
+	}
+	if len(paragraphs) > 1 {
+		description.Summary = paragraphs[1]
+	}
+	return description, nil
+}
+
+func (f *FakeClient) TranscribePage(ctx context.Context, image string) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if len(f.transcriptions) == 0 {
+		return "", nil
+	}
+	text := f.transcriptions[0]
+	f.transcriptions = f.transcriptions[1:]
+	return text, nil
+}
@@ -1,252 +1,260 @@
 package llm
 
 import (
-	"bytes"
 	"context"
-	"encoding/json"
-	"fmt"
-	"io"
-	"net/http"
 	"patient-chatbot/internal/config"
 	"patient-chatbot/internal/dto"
-	"patient-chatbot/internal/repository"
-	"regexp"
 	"strings"
 
-	"github.com/google/uuid"
 	"github.com/rs/zerolog/log"
 )
 
 const (
-	CHAT_SYSTEM_PROMPT_EN_QUITTING_COACH = `
-	You are “Hamad,” AI Quitting Coach.
-	1. Always try to answer only questions about smoking cessation, coping strategies, cravings, milestones, and progress tracking.
-	2. Use the provided context snippets first—if they fully answer the user's query, respond only with them.
-	3. If no snippet applies:
-	• If the user's question is about general quitting best practices (coping tips, motivational advice), you may answer from your broader coaching knowledge—start such answers with “Note: based on my coaching expertise—”.
-	• Otherwise, for any question outside smoking-cessation scope (e.g. “Who won the World Cup 2022?”), respond exactly:
-		“I'm sorry, I don't have enough information on that topic right now. Let's focus on your quitting journey.”
-
-	4. For any request requiring personalized medical advice (complex health conditions, dosing), respond exactly:
-	“I'm sorry, I don't have enough information right now. Please consult a healthcare professional.”
-
-	5. Keep responses concise, upbeat, and empathetic—no role restatements, no greetings, no lengthy disclaimers.
-
-	6. After your reply, on its own line output exactly one JSON object with these properties—always resetting to null/false unless the user's current message explicitly mentions them:
-	{"daysSmokeFree":<int|null>,"moneySaved":<int|null>,"mentionedDaysSmokeFree":<bool>,"mentionedMoneySaved":<bool>}
-
-	Examples
-	User: “Who won the World Cup 2022?”
-	I'm sorry, I don't have enough information on that topic right now. Let's focus on your quitting journey.
-	{"daysSmokeFree":null,"moneySaved":null,"mentionedDaysSmokeFree":false,"mentionedMoneySaved":false}
-	User: “I didn't smoke for 2 days and saved 60 SAR.”
-
-	Great job on two days smoke-free—every hour counts toward your long-term success!
-	{"daysSmokeFree":2,"moneySaved":60,"mentionedDaysSmokeFree":true,"mentionedMoneySaved":true}
-	User: “What's my next tip?”
-	
-	Note: based on my coaching expertise—try a 2-minute walk to reset when a craving hits.
-	{"daysSmokeFree":null,"moneySaved":null,"mentionedDaysSmokeFree":false,"mentionedMoneySaved":false}
-	If you can't comply, respond exactly:
-
-	ERROR: Unable to comply with instructions.
-	`
-	CHAT_SYSTEM_PROMPT_AR = `
-	أنت حمد، مساعد طبي محترف ورحيم.
-	استخدم مقتطفات السياق المقدمة فقط للإجابة—لا تضف أي تحية أو مقدمة أو أسئلة متابعة.
-	إذا لم يحتوي السياق على المعلومات المطلوبة، أجب تمامًا:
-	“عذرًا، ليس لدي هذه المعلومة الآن. يُرجى استشارة مقدم الرعاية الصحية الخاص بك.”
-	حافظ على الإجابات واضحة ودقيقة ومتعاطفة.
-	لا تقدّم نصائح طبية تتجاوز نطاق السياق؛ بل شجّع المستخدمين على طلب المساعدة المتخصصة عند الحاجة.
-	نسّق إجابتك في فقرة ودودة واحدة فقط. عند الاقتضاء، استشهد بالمقتطف المستخدم (مثلاً: “بناءً على إرشاداتنا: …”).
-	إذا لم تستطع الالتزام بهذه التعليمات حرفيًا، أجب:
-	“خطأ: غير قادر على تنفيذ التعليمات.”
-	`
-	EXTRACT_SYSTEM_PROMPT = `
-	You are a medical assistant. You will be given plain text of a medical document.
-	1. Generate a concise **title** (3-7 words).  
-	2. Propose one **category** describing the document.  
-	3. Split the document into chunks of roughly 300-500 characters each, cutting only at sentence boundaries.  
-	- If a heading (e.g. “Symptoms”) would otherwise stand alone, attach it to the following sentence.  
-	- Do not emit any chunk shorter than one complete sentence.  
-	4. Output exactly this JSON object (compact, no line breaks)
-	Make sure all newlines inside strings are escaped as \n, double quotes are escaped as \", backslashes as \\, and any Unicode codepoint uses exactly four hex digits (e.g. \u00A0, not \u00a F)
-	{"title":"…","category":"…","chunks":["…","…",…]}
+	DESCRIBE_SYSTEM_PROMPT = `
+	You are a medical assistant. You will be given the text of a medical document, possibly cut short.
+	1. Generate a concise **title** (3-7 words).
+	2. Propose one **category** describing the document.
+	3. Write a one or two sentence **summary** of the document.
+	Write all three in the language of the document.
+	Output exactly this JSON object (compact, no line breaks), escaping double quotes and backslashes inside strings:
+	{"title":"…","category":"…","summary":"…"}
 	Don't output anything else (no commentary or headings).
 	`
+	TRANSCRIBE_SYSTEM_PROMPT = `
+	You are given an image of one page of a medical document.
+	Transcribe all of its text exactly as written, in reading order, keeping headings and list items on their own lines.
+	Do not translate, summarize or correct anything. Output only the transcribed text, or nothing if the page has no text.
+	`
 )
 
-type LLMClient struct {
-	cfg  *config.Config
-	repo *repository.Repository
+// describeSampleRunes is how much of a document DescribeDocument sends to the model.
+const describeSampleRunes = 6000
+
+// Client is implemented by LLMClient and by FakeClient for offline development and tests.
+type Client interface {
+	// Chat answers the last message. The model may call tools along the way; their results
+	// are fed back to it before it answers. If the chat fails after tools have run, the
+	// result is returned with the error and holds those calls and any text written so far.
+	// options can replace the built-in prompt and sampling settings.
+	Chat(ctx context.Context, messages []dto.Message, chunks []string, lang string, tools []Tool, options ChatOptions) (*ChatResult, error)
+	ChatStream(ctx context.Context, messages []dto.Message, chunks []string, lang string, tools []Tool, options ChatOptions, onDelta func(string) error) (*ChatResult, error)
+	// DescribeDocument titles, categorises and summarises a document from its text.
+	DescribeDocument(ctx context.Context, text string) (*DocumentDescription, error)
+	// TranscribePage returns the text in a page image, given as a data URL.
+	TranscribePage(ctx context.Context, image string) (string, error)
 }
 
-func NewLLMClient(cfg *config.Config, repo *repository.Repository) *LLMClient {
-	return &LLMClient{cfg: cfg, repo: repo}
+// ChatResult is the answer shown to the user and the tool calls the model made, in order.
+type ChatResult struct {
+	Answer    string
+	ToolCalls []ToolCall
 }
 
-func (l *LLMClient) Chat(ctx context.Context, messages []dto.Message, chunks []string, lang string) (string, error) {
-	var sysBuf bytes.Buffer
-	if lang == "en" {
-		sysBuf.WriteString(CHAT_SYSTEM_PROMPT_EN_QUITTING_COACH)
-	} else {
-		sysBuf.WriteString(CHAT_SYSTEM_PROMPT_AR)
-	}
-
-	if len(chunks) > 0 {
-		sysBuf.WriteString("Context:\n")
-		for _, chunkText := range chunks {
-			sysBuf.WriteString("- " + chunkText + "\n")
-		}
-	}
-
-	msgs := []ChatMessageBlock{
-		{Role: "system", Content: sysBuf.String()},
-	}
+// LLMClient implements Client on top of a Provider, choosing the model for each role.
+type LLMClient struct {
+	provider Provider
+	models   Models
+}
 
-	for _, message := range messages {
-		if strings.TrimSpace(message.Content) != "" {
-			msgs = append(msgs, ChatMessageBlock{
-				Role:    message.Role,
-				Content: message.Content,
-			})
-			continue
-		}
-		if len(msgs) > 0 {
-			msgs = msgs[:len(msgs)-1]
-		}
-	}
+func NewLLMClient(provider Provider, models Models) *LLMClient {
+	return &LLMClient{provider: provider, models: models}
+}
 
-	reqBody := ChatRequest{
-		Messages:            msgs,
-		Temperature:         0,
-		MaxCompletionTokens: 1024,
-		TopP:                1.0,
-		Stream:              false,
-		Stop:                []string{"ERROR"},
+// New builds the LLM client selected by LLM_PROVIDER.
+func New(cfg *config.Config) (Client, error) {
+	if cfg.LLMProvider == ProviderFake {
+		return NewFakeClient(), nil
 	}
 
-	if lang == "en" {
-		reqBody.Model = l.cfg.LLMModel
-	} else {
-		reqBody.Model = l.cfg.ArabicLLMModel
+	provider, err := NewProvider(cfg)
+	if err != nil {
+		return nil, err
 	}
+	return NewLLMClient(provider, modelsFromConfig(cfg)), nil
+}
 
-	payload, err := json.Marshal(reqBody)
+func (l *LLMClient) Chat(ctx context.Context, messages []dto.Message, chunks []string, lang string, tools []Tool, options ChatOptions) (*ChatResult, error) {
+	req, err := l.buildChatRequest(messages, chunks, lang, tools, options)
 	if err != nil {
-		return "", fmt.Errorf("marshal chat request: %w", err)
+		return nil, err
 	}
+	return l.chat(ctx, req, lang, tools, nil)
+}
 
-	resp, err := CallGroqAPI(ctx, l.cfg, payload)
+// ChatStream relays the answer to onDelta as the model generates it. Text the model writes
+// before calling a tool is relayed too, separated from the rest of the answer by a blank
+// line.
+func (l *LLMClient) ChatStream(ctx context.Context, messages []dto.Message, chunks []string, lang string, tools []Tool, options ChatOptions, onDelta func(string) error) (*ChatResult, error) {
+	req, err := l.buildChatRequest(messages, chunks, lang, tools, options)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
+	return l.chat(ctx, req, lang, tools, onDelta)
+}
+
+// chat goes back and forth with the model until it answers without calling a tool, running
+// the tools it calls in between. It streams when onDelta is set. An empty answer is replaced
+// by the fallback for lang. On error it still returns what the chat got through.
+func (l *LLMClient) chat(ctx context.Context, req CompletionRequest, lang string, tools []Tool, onDelta func(string) error) (*ChatResult, error) {
+	result := &ChatResult{}
+	var answer strings.Builder
+	for round := 1; ; round++ {
+		if round == maxToolRounds {
+			req.Tools = nil
+		}
 
-	log.Info().Msg("Groq LLM Response: " + resp)
+		completion, err := l.complete(ctx, req, &answer, onDelta)
+		if err != nil {
+			result.Answer = strings.TrimSpace(answer.String())
+			return result, err
+		}
+		if len(completion.ToolCalls) == 0 || req.Tools == nil {
+			break
+		}
 
-	normalized := regexp.MustCompile(`\n{2,}`).ReplaceAllString(resp, "\n")
+		for _, call := range completion.ToolCalls {
+			log.Info().Msgf("LLM tool call: %s %s", call.Name, call.Arguments)
+		}
+		req.Messages = append(req.Messages, runTools(ctx, tools, completion)...)
+		result.ToolCalls = append(result.ToolCalls, completion.ToolCalls...)
+	}
 
-	jsonResp := strings.Split(normalized, "\n")
-	if len(jsonResp) == 1 { // @NOTE: might enter here if LLM is not able to comply with instructions
-		return "I'm sorry, I don't have enough information right now. Please consult your healthcare provider.", nil
+	result.Answer = strings.TrimSpace(answer.String())
+	log.Info().Msg("LLM Response: " + result.Answer)
+	if result.Answer == "" {
+		result.Answer = noAnswer(lang)
+		if onDelta != nil {
+			if err := onDelta(result.Answer); err != nil {
+				return result, err
+			}
+		}
 	}
+	return result, nil
+}
 
-	var quittingCoachResponse QuittingCoachResponse
-	if err := json.Unmarshal([]byte(jsonResp[1]), &quittingCoachResponse); err != nil {
-		return "", fmt.Errorf("unmarshal quitting coach response: %w", err)
+// complete runs one round of a chat and adds its text to answer, streaming it to onDelta if
+// set.
+func (l *LLMClient) complete(ctx context.Context, req CompletionRequest, answer *strings.Builder, onDelta func(string) error) (*Completion, error) {
+	if onDelta == nil {
+		completion, err := l.provider.Complete(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+		appendParagraph(answer, completion.Content)
+		return completion, nil
 	}
 
-	go func() {
-		if quittingCoachResponse.MentionedDaysSmokeFree {
-			err := l.repo.UpsertProgressStatus(context.Background(), uuid.MustParse("d1fc8771-bac7-4080-913b-2b25e4ab4957"), repository.ProgressEventStatusSlip) // @TODO: get user id from context or drill from handler
-			if err != nil {
-				log.Error().Msgf("update days smoke free: %v", err)
+	started := false
+	return l.provider.Stream(ctx, req, func(delta string) error {
+		if !started {
+			delta = strings.TrimLeft(delta, " \n")
+			if delta == "" {
+				return nil
 			}
-		}
-		if quittingCoachResponse.MentionedMoneySaved {
-			err := l.repo.UpsertProgressMoneySaved(context.Background(), uuid.MustParse("d1fc8771-bac7-4080-913b-2b25e4ab4957"), quittingCoachResponse.MoneySaved) // @TODO: get user id from context or drill from handler
-			if err != nil {
-				log.Error().Msgf("update money saved: %v", err)
+			if answer.Len() > 0 {
+				delta = "\n\n" + delta
 			}
+			started = true
 		}
-	}()
-
-	return jsonResp[0], nil
+		answer.WriteString(delta)
+		return onDelta(delta)
+	})
 }
 
-func (l *LLMClient) ExtractText(ctx context.Context, encodedFile string, isText bool) (*ExtractTextResponse, error) {
-	systemPromptBlock := ExtractTextContentBlock{
-		Type: "text",
-		Text: EXTRACT_SYSTEM_PROMPT,
+// appendParagraph adds text to answer as a new paragraph.
+func appendParagraph(answer *strings.Builder, text string) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return
 	}
-
-	dataBlock := ExtractTextContentBlock{}
-	if isText {
-		dataBlock.Type = "text"
-		dataBlock.Text = encodedFile
-	} else {
-		dataBlock.Type = "image_url"
-		dataBlock.ImageURL = &ImageBlock{
-			URL: encodedFile,
-		}
+	if answer.Len() > 0 {
+		answer.WriteString("\n\n")
 	}
+	answer.WriteString(text)
+}
 
-	msgs := []ExtractTextMessageBlock{
-		{Role: "user", Content: []ExtractTextContentBlock{systemPromptBlock, dataBlock}},
+// buildChatRequest uses options.SystemPrompt if set, and otherwise the built-in prompt for
+// lang with the retrieved chunks as context.
+func (l *LLMClient) buildChatRequest(messages []dto.Message, chunks []string, lang string, tools []Tool, options ChatOptions) (CompletionRequest, error) {
+	systemPrompt := options.SystemPrompt
+	if systemPrompt == "" {
+		data := DefaultPromptData(lang)
+		data.Context = chunks
+		var err error
+		systemPrompt, err = RenderPrompt(DefaultPromptTemplate(lang), data)
+		if err != nil {
+			return CompletionRequest{}, err
+		}
 	}
-
-	reqBody := ExtractTextRequest{
-		Model:               l.cfg.MULTIMODAL_LLM_MODEL,
-		Messages:            msgs,
-		Temperature:         1.0,
-		MaxCompletionTokens: 1024,
-		TopP:                1.0,
-		Stream:              false,
-		Stop:                nil,
+	maxTokens := options.MaxTokens
+	if maxTokens <= 0 {
+		maxTokens = DefaultChatMaxTokens
 	}
 
-	payload, err := json.Marshal(reqBody)
-	if err != nil {
-		return nil, fmt.Errorf("marshal extract text request: %w", err)
-	}
-	res, err := CallGroqAPI(ctx, l.cfg, payload)
-	if err != nil {
-		return nil, err
+	msgs := []CompletionMessage{
+		{Role: "system", Content: systemPrompt},
 	}
 
-	var extractTextResponse ExtractTextResponse
-	if err := json.Unmarshal([]byte(res), &extractTextResponse); err != nil {
-		return nil, fmt.Errorf("unmarshal extract text response: %w", err)
+	for _, message := range messages {
+		if strings.TrimSpace(message.Content) != "" {
+			msgs = append(msgs, CompletionMessage{
+				Role:    string(message.Role),
+				Content: message.Content,
+			})
+			continue
+		}
+		if len(msgs) > 0 {
+			msgs = msgs[:len(msgs)-1]
+		}
 	}
 
-	return &extractTextResponse, nil
+	return CompletionRequest{
+		Model:       l.models.ChatModel(lang),
+		Messages:    msgs,
+		Temperature: options.Temperature,
+		MaxTokens:   maxTokens,
+		TopP:        1.0,
+		Stop:        []string{"ERROR"},
+		Tools:       tools,
+	}, nil
 }
 
-func CallGroqAPI(ctx context.Context, cfg *config.Config, payload []byte) (string, error) {
-	req, err := http.NewRequestWithContext(ctx, "POST", "https://api.groq.com/openai/v1/chat/completions", bytes.NewReader(payload))
-	if err != nil {
-		return "", fmt.Errorf("new chat request: %w", err)
+// DescribeDocument sends only the beginning of long documents; it is enough to title them
+// and keeps the request within the model's context window.
+func (l *LLMClient) DescribeDocument(ctx context.Context, text string) (*DocumentDescription, error) {
+	if runes := []rune(text); len(runes) > describeSampleRunes {
+		text = string(runes[:describeSampleRunes])
 	}
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+cfg.GroqAPIKey)
 
-	resp, err := http.DefaultClient.Do(req)
+	var description DocumentDescription
+	err := l.completeJSON(ctx, CompletionRequest{
+		Model: l.models.Extraction,
+		Messages: []CompletionMessage{
+			{Role: "user", Content: DESCRIBE_SYSTEM_PROMPT + "\n" + text},
+		},
+		Temperature: 0,
+		MaxTokens:   512,
+		TopP:        1.0,
+	}, describeSchema, &description)
 	if err != nil {
-		return "", fmt.Errorf("chat API call: %w", err)
+		return nil, err
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return "", fmt.Errorf("chat API error [%d]: %s", resp.StatusCode, string(body))
-	}
+	return &description, nil
+}
 
-	var cr ChatResponse
-	if err := json.NewDecoder(resp.Body).Decode(&cr); err != nil {
-		return "", fmt.Errorf("decode chat response: %w", err)
-	}
-	if len(cr.Choices) == 0 {
-		return "", fmt.Errorf("no choices in chat response")
+func (l *LLMClient) TranscribePage(ctx context.Context, image string) (string, error) {
+	completion, err := l.provider.Complete(ctx, CompletionRequest{
+		Model: l.models.Extraction,
+		Messages: []CompletionMessage{
+			{Role: "user", Content: TRANSCRIBE_SYSTEM_PROMPT, Images: []string{image}},
+		},
+		Temperature: 0,
+		MaxTokens:   4096,
+		TopP:        1.0,
+	})
+	if err != nil {
+		return "", err
 	}
-	return cr.Choices[0].Message.Content, nil
+	return strings.TrimSpace(completion.Content), nil
 }
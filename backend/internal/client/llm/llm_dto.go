@@ -1,51 +1,82 @@
 package llm
 
-import "patient-chatbot/internal/dto"
+import (
+	"encoding/json"
+
+	"patient-chatbot/internal/dto"
+)
 
 type ChatMessageBlock struct {
-	Role    dto.Role `json:"role"`
-	Content string   `json:"content"`
+	Role      dto.Role       `json:"role"`
+	Content   string         `json:"content"`
+	ToolCalls []ChatToolCall `json:"tool_calls"`
 }
 
+// ChatRequest is the body of an OpenAI-compatible chat completion. Message content is
+// either a string or, for multimodal requests, a list of ContentBlocks.
 type ChatRequest struct {
-	Model               string             `json:"model"`
-	Messages            []ChatMessageBlock `json:"messages"`
-	Temperature         float32            `json:"temperature"`
-	MaxCompletionTokens int                `json:"max_completion_tokens"`
-	TopP                float32            `json:"top_p"`
-	Stream              bool               `json:"stream"`
-	Stop                interface{}        `json:"stop"`
+	Model               string               `json:"model"`
+	Messages            []ChatRequestMessage `json:"messages"`
+	Temperature         float32              `json:"temperature"`
+	MaxCompletionTokens int                  `json:"max_completion_tokens"`
+	TopP                float32              `json:"top_p"`
+	Stream              bool                 `json:"stream"`
+	Stop                interface{}          `json:"stop"`
+	ResponseFormat      *ResponseFormat      `json:"response_format,omitempty"`
+	Tools               []ChatTool           `json:"tools,omitempty"`
+}
+
+type ResponseFormat struct {
+	Type string `json:"type"`
+}
+
+type ChatRequestMessage struct {
+	Role       string         `json:"role"`
+	Content    interface{}    `json:"content"`
+	ToolCalls  []ChatToolCall `json:"tool_calls,omitempty"`
+	ToolCallID string         `json:"tool_call_id,omitempty"`
+}
+
+// ChatTool declares a function the model may call. Ollama accepts the same shape.
+type ChatTool struct {
+	Type     string           `json:"type"`
+	Function ChatToolFunction `json:"function"`
+}
+
+type ChatToolFunction struct {
+	Name        string      `json:"name"`
+	Description string      `json:"description"`
+	Parameters  interface{} `json:"parameters"`
+}
+
+// ChatToolCall is a call in an assistant message. When streamed, a call arrives in pieces
+// that share an Index: the first carries the ID and name, the rest more of the arguments.
+type ChatToolCall struct {
+	Index    *int                 `json:"index,omitempty"`
+	ID       string               `json:"id,omitempty"`
+	Type     string               `json:"type,omitempty"`
+	Function ChatToolCallFunction `json:"function"`
+}
+
+type ChatToolCallFunction struct {
+	Name      string `json:"name,omitempty"`
+	Arguments string `json:"arguments"`
 }
 
 type ImageBlock struct {
 	URL string `json:"url"`
 }
 
-type ExtractTextContentBlock struct {
+type ContentBlock struct {
 	Type     string      `json:"type"`
 	Text     string      `json:"text,omitempty"`
 	ImageURL *ImageBlock `json:"image_url,omitempty"`
 }
 
-type ExtractTextMessageBlock struct {
-	Role    string                    `json:"role"`
-	Content []ExtractTextContentBlock `json:"content"`
-}
-
-type ExtractTextRequest struct {
-	Model               string                    `json:"model"`
-	Messages            []ExtractTextMessageBlock `json:"messages"`
-	Temperature         float32                   `json:"temperature"`
-	MaxCompletionTokens int                       `json:"max_completion_tokens"`
-	TopP                float32                   `json:"top_p"`
-	Stream              bool                      `json:"stream"`
-	Stop                interface{}               `json:"stop"`
-}
-
-type ExtractTextResponse struct {
-	Title    string   `json:"title"`
-	Category string   `json:"category"`
-	Chunks   []string `json:"chunks"`
+type DocumentDescription struct {
+	Title    string `json:"title"`
+	Category string `json:"category"`
+	Summary  string `json:"summary"`
 }
 
 type ChatChoice struct {
@@ -56,9 +87,58 @@ type ChatResponse struct {
 	Choices []ChatChoice `json:"choices"`
 }
 
-type QuittingCoachResponse struct {
-	DaysSmokeFree          int  `json:"daysSmokeFree"`
-	MoneySaved             int  `json:"moneySaved"`
-	MentionedDaysSmokeFree bool `json:"mentionedDaysSmokeFree"`
-	MentionedMoneySaved    bool `json:"mentionedMoneySaved"`
+type ChatStreamDelta struct {
+	Content   string         `json:"content"`
+	ToolCalls []ChatToolCall `json:"tool_calls"`
+}
+
+type ChatStreamChoice struct {
+	Delta        ChatStreamDelta `json:"delta"`
+	FinishReason *string         `json:"finish_reason"`
+}
+
+type ChatStreamChunk struct {
+	Choices []ChatStreamChoice `json:"choices"`
+}
+
+type OllamaMessage struct {
+	Role      string           `json:"role"`
+	Content   string           `json:"content"`
+	Images    []string         `json:"images,omitempty"`
+	ToolCalls []OllamaToolCall `json:"tool_calls,omitempty"`
+	ToolName  string           `json:"tool_name,omitempty"`
+}
+
+// OllamaToolCall is a call in an assistant message. Unlike OpenAI, Ollama sends the
+// arguments as a JSON object rather than a string, and calls have no IDs.
+type OllamaToolCall struct {
+	Function OllamaToolCallFunction `json:"function"`
+}
+
+type OllamaToolCallFunction struct {
+	Name      string          `json:"name"`
+	Arguments json.RawMessage `json:"arguments"`
+}
+
+type OllamaOptions struct {
+	Temperature float32  `json:"temperature"`
+	TopP        float32  `json:"top_p"`
+	NumPredict  int      `json:"num_predict,omitempty"`
+	Stop        []string `json:"stop,omitempty"`
+}
+
+type OllamaChatRequest struct {
+	Model    string          `json:"model"`
+	Messages []OllamaMessage `json:"messages"`
+	Stream   bool            `json:"stream"`
+	Format   string          `json:"format,omitempty"`
+	Tools    []ChatTool      `json:"tools,omitempty"`
+	Options  OllamaOptions   `json:"options"`
+}
+
+// OllamaChatResponse is both the non-streaming response and each line of a streamed one.
+type OllamaChatResponse struct {
+	Message OllamaMessage `json:"message"`
+	Done    bool          `json:"done"`
+	Error   string        `json:"error"`
 }
@@ -0,0 +1,164 @@
+package llm
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// OllamaProvider talks to Ollama's native /api/chat endpoint, which streams
+// newline-delimited JSON and takes images as bare base64 strings.
+type OllamaProvider struct {
+	baseURL    string
+	httpClient *http.Client
+}
+
+func NewOllamaProvider(baseURL string) *OllamaProvider {
+	return &OllamaProvider{
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		httpClient: http.DefaultClient,
+	}
+}
+
+func (p *OllamaProvider) Complete(ctx context.Context, req CompletionRequest) (*Completion, error) {
+	resp, err := p.do(ctx, req, false)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var cr OllamaChatResponse
+	if err := json.NewDecoder(resp.Body).Decode(&cr); err != nil {
+		return nil, fmt.Errorf("decode ollama response: %w", err)
+	}
+	if cr.Error != "" {
+		return nil, fmt.Errorf("ollama error: %s", cr.Error)
+	}
+	return &Completion{Content: cr.Message.Content, ToolCalls: ollamaToolCalls(nil, cr.Message.ToolCalls)}, nil
+}
+
+// Stream relays content as it arrives. Ollama sends each tool call whole, in one chunk.
+func (p *OllamaProvider) Stream(ctx context.Context, req CompletionRequest, onDelta func(string) error) (*Completion, error) {
+	resp, err := p.do(ctx, req, true)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	completion := &Completion{}
+	var content strings.Builder
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+
+		var chunk OllamaChatResponse
+		if err := json.Unmarshal(line, &chunk); err != nil {
+			return nil, fmt.Errorf("decode ollama stream chunk: %w", err)
+		}
+		if chunk.Error != "" {
+			return nil, fmt.Errorf("ollama error: %s", chunk.Error)
+		}
+		completion.ToolCalls = ollamaToolCalls(completion.ToolCalls, chunk.Message.ToolCalls)
+		if chunk.Message.Content != "" {
+			content.WriteString(chunk.Message.Content)
+			if err := onDelta(chunk.Message.Content); err != nil {
+				return nil, err
+			}
+		}
+		if chunk.Done {
+			break
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read ollama stream: %w", err)
+	}
+	completion.Content = content.String()
+	return completion, nil
+}
+
+// ollamaToolCalls appends Ollama's tool calls to calls. Ollama does not identify calls, so
+// they are numbered in the order they arrive.
+func ollamaToolCalls(calls []ToolCall, received []OllamaToolCall) []ToolCall {
+	for _, call := range received {
+		calls = append(calls, ToolCall{
+			ID:        fmt.Sprintf("call_%d", len(calls)),
+			Name:      call.Function.Name,
+			Arguments: string(call.Function.Arguments),
+		})
+	}
+	return calls
+}
+
+func (p *OllamaProvider) do(ctx context.Context, req CompletionRequest, stream bool) (*http.Response, error) {
+	msgs := make([]OllamaMessage, len(req.Messages))
+	for i, message := range req.Messages {
+		msgs[i] = OllamaMessage{Role: message.Role, Content: message.Content, ToolName: message.ToolName}
+		for _, image := range message.Images {
+			msgs[i].Images = append(msgs[i].Images, stripDataURL(image))
+		}
+		for _, call := range message.ToolCalls {
+			arguments := json.RawMessage(call.Arguments)
+			if !json.Valid(arguments) {
+				arguments = json.RawMessage("{}")
+			}
+			msgs[i].ToolCalls = append(msgs[i].ToolCalls, OllamaToolCall{
+				Function: OllamaToolCallFunction{Name: call.Name, Arguments: arguments},
+			})
+		}
+	}
+
+	var format string
+	if req.JSON {
+		format = "json"
+	}
+
+	payload, err := json.Marshal(OllamaChatRequest{
+		Model:    req.Model,
+		Messages: msgs,
+		Stream:   stream,
+		Format:   format,
+		Tools:    chatTools(req.Tools),
+		Options: OllamaOptions{
+			Temperature: req.Temperature,
+			TopP:        req.TopP,
+			NumPredict:  req.MaxTokens,
+			Stop:        req.Stop,
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("marshal ollama request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", p.baseURL+"/api/chat", bytes.NewReader(payload))
+	if err != nil {
+		return nil, fmt.Errorf("new ollama request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	resp, err := p.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("ollama API call: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		return nil, newAPIError("ollama", resp)
+	}
+	return resp, nil
+}
+
+// stripDataURL returns the base64 payload of a data URL; Ollama does not accept the prefix.
+func stripDataURL(image string) string {
+	if _, data, ok := strings.Cut(image, ";base64,"); ok && strings.HasPrefix(image, "data:") {
+		return data
+	}
+	return image
+}
@@ -0,0 +1,220 @@
+package llm
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// OpenAIProvider talks to any server exposing the OpenAI chat completions API: Groq, OpenAI,
+// vLLM, LM Studio or Azure OpenAI. For Azure, baseURL is the deployment URL
+// (https://<resource>.openai.azure.com/openai/deployments/<deployment>) and apiVersion is set,
+// which also switches authentication to the api-key header.
+type OpenAIProvider struct {
+	baseURL    string
+	apiKey     string
+	apiVersion string
+	httpClient *http.Client
+}
+
+func NewOpenAIProvider(baseURL string, apiKey string, apiVersion string) *OpenAIProvider {
+	return &OpenAIProvider{
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		apiKey:     apiKey,
+		apiVersion: apiVersion,
+		httpClient: http.DefaultClient,
+	}
+}
+
+func (p *OpenAIProvider) Complete(ctx context.Context, req CompletionRequest) (*Completion, error) {
+	resp, err := p.do(ctx, req, false)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var cr ChatResponse
+	if err := json.NewDecoder(resp.Body).Decode(&cr); err != nil {
+		return nil, fmt.Errorf("decode chat response: %w", err)
+	}
+	if len(cr.Choices) == 0 {
+		return nil, fmt.Errorf("no choices in chat response")
+	}
+
+	message := cr.Choices[0].Message
+	completion := &Completion{Content: message.Content}
+	for _, call := range message.ToolCalls {
+		completion.ToolCalls = append(completion.ToolCalls, ToolCall{
+			ID:        call.ID,
+			Name:      call.Function.Name,
+			Arguments: call.Function.Arguments,
+		})
+	}
+	return completion, nil
+}
+
+func (p *OpenAIProvider) Stream(ctx context.Context, req CompletionRequest, onDelta func(string) error) (*Completion, error) {
+	resp, err := p.do(ctx, req, true)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var content strings.Builder
+	var calls []ToolCall
+	done := func() *Completion {
+		return &Completion{Content: content.String(), ToolCalls: calls}
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data: ")
+		if !ok {
+			continue
+		}
+		if data == "[DONE]" {
+			return done(), nil
+		}
+
+		var chunk ChatStreamChunk
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return nil, fmt.Errorf("decode chat stream chunk: %w", err)
+		}
+		if len(chunk.Choices) == 0 {
+			continue
+		}
+		delta := chunk.Choices[0].Delta
+		calls = appendToolCallDeltas(calls, delta.ToolCalls)
+		if delta.Content == "" {
+			continue
+		}
+		content.WriteString(delta.Content)
+		if err := onDelta(delta.Content); err != nil {
+			return nil, err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read chat stream: %w", err)
+	}
+	return done(), nil
+}
+
+// appendToolCallDeltas merges streamed pieces of tool calls into calls, by index.
+func appendToolCallDeltas(calls []ToolCall, deltas []ChatToolCall) []ToolCall {
+	for _, delta := range deltas {
+		index := len(calls)
+		if delta.Index != nil {
+			index = *delta.Index
+		}
+		for len(calls) <= index {
+			calls = append(calls, ToolCall{})
+		}
+		if delta.ID != "" {
+			calls[index].ID = delta.ID
+		}
+		if delta.Function.Name != "" {
+			calls[index].Name = delta.Function.Name
+		}
+		calls[index].Arguments += delta.Function.Arguments
+	}
+	return calls
+}
+
+func (p *OpenAIProvider) do(ctx context.Context, req CompletionRequest, stream bool) (*http.Response, error) {
+	payload, err := json.Marshal(p.buildRequest(req, stream))
+	if err != nil {
+		return nil, fmt.Errorf("marshal chat request: %w", err)
+	}
+
+	endpoint := p.baseURL + "/chat/completions"
+	if p.apiVersion != "" {
+		endpoint += "?api-version=" + url.QueryEscape(p.apiVersion)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return nil, fmt.Errorf("new chat request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	if stream {
+		httpReq.Header.Set("Accept", "text/event-stream")
+	}
+	switch {
+	case p.apiKey == "":
+	case p.apiVersion != "":
+		httpReq.Header.Set("api-key", p.apiKey)
+	default:
+		httpReq.Header.Set("Authorization", "Bearer "+p.apiKey)
+	}
+
+	resp, err := p.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("chat API call: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		return nil, newAPIError("chat", resp)
+	}
+	return resp, nil
+}
+
+func (p *OpenAIProvider) buildRequest(req CompletionRequest, stream bool) ChatRequest {
+	msgs := make([]ChatRequestMessage, len(req.Messages))
+	for i, message := range req.Messages {
+		if len(message.ToolCalls) > 0 {
+			msgs[i] = ChatRequestMessage{Role: message.Role, ToolCalls: make([]ChatToolCall, len(message.ToolCalls))}
+			if message.Content != "" {
+				msgs[i].Content = message.Content
+			}
+			for j, call := range message.ToolCalls {
+				if strings.TrimSpace(call.Arguments) == "" {
+					call.Arguments = "{}"
+				}
+				msgs[i].ToolCalls[j] = ChatToolCall{
+					ID:       call.ID,
+					Type:     "function",
+					Function: ChatToolCallFunction{Name: call.Name, Arguments: call.Arguments},
+				}
+			}
+			continue
+		}
+		if len(message.Images) == 0 {
+			msgs[i] = ChatRequestMessage{Role: message.Role, Content: message.Content, ToolCallID: message.ToolCallID}
+			continue
+		}
+
+		blocks := []ContentBlock{{Type: "text", Text: message.Content}}
+		for _, image := range message.Images {
+			blocks = append(blocks, ContentBlock{Type: "image_url", ImageURL: &ImageBlock{URL: image}})
+		}
+		msgs[i] = ChatRequestMessage{Role: message.Role, Content: blocks}
+	}
+
+	var stop interface{}
+	if len(req.Stop) > 0 {
+		stop = req.Stop
+	}
+
+	var format *ResponseFormat
+	if req.JSON {
+		format = &ResponseFormat{Type: "json_object"}
+	}
+
+	return ChatRequest{
+		Model:               req.Model,
+		Messages:            msgs,
+		Temperature:         req.Temperature,
+		MaxCompletionTokens: req.MaxTokens,
+		TopP:                req.TopP,
+		Stream:              stream,
+		Stop:                stop,
+		ResponseFormat:      format,
+		Tools:               chatTools(req.Tools),
+	}
+}
@@ -0,0 +1,160 @@
+package llm
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path"
+	"text/template"
+
+	"patient-chatbot/internal/locales"
+)
+
+const (
+	ToneEmpathetic   = "empathetic"
+	ToneMotivational = "motivational"
+	ToneProfessional = "professional"
+	ToneFriendly     = "friendly"
+
+	// DefaultChatMaxTokens caps answers unless an organization sets its own limit.
+	DefaultChatMaxTokens = 1024
+)
+
+// Tones are the response tones an organization can pick, each described to the model in
+// the language of the chat.
+var Tones = []string{ToneEmpathetic, ToneMotivational, ToneProfessional, ToneFriendly}
+
+// promptCatalogue is one language's entry in the prompt catalogue under
+// internal/locales/prompts. Every language follows the same rules and refers to the same
+// PromptData fields and tools; only the wording differs.
+type promptCatalogue struct {
+	Template          string            `json:"-"`
+	PersonaName       string            `json:"persona_name"`
+	OutOfScopeRefusal string            `json:"out_of_scope_refusal"`
+	MedicalRefusal    string            `json:"medical_refusal"`
+	NoAnswer          string            `json:"no_answer"`
+	Tones             map[string]string `json:"tones"`
+}
+
+// promptLanguages are the languages in the catalogue. Chats in any other language use the
+// Arabic entry, like the rest of the app.
+var promptLanguages = []string{"en", "ar"}
+
+var catalogues = mustLoadCatalogues()
+
+func mustLoadCatalogues() map[string]promptCatalogue {
+	catalogues := make(map[string]promptCatalogue, len(promptLanguages))
+	for _, lang := range promptLanguages {
+		catalogue, err := loadCatalogue(lang)
+		if err != nil {
+			panic(err)
+		}
+		catalogues[lang] = catalogue
+	}
+	return catalogues
+}
+
+func loadCatalogue(lang string) (promptCatalogue, error) {
+	var catalogue promptCatalogue
+	defaults, err := locales.Prompts.ReadFile(path.Join("prompts", lang, "defaults.json"))
+	if err != nil {
+		return catalogue, fmt.Errorf("llm: read %s prompt defaults: %w", lang, err)
+	}
+	if err := json.Unmarshal(defaults, &catalogue); err != nil {
+		return catalogue, fmt.Errorf("llm: parse %s prompt defaults: %w", lang, err)
+	}
+	text, err := locales.Prompts.ReadFile(path.Join("prompts", lang, "chat.tmpl"))
+	if err != nil {
+		return catalogue, fmt.Errorf("llm: read %s prompt template: %w", lang, err)
+	}
+	catalogue.Template = string(text)
+	return catalogue, nil
+}
+
+// catalogueFor returns the catalogue entry for lang.
+func catalogueFor(lang string) promptCatalogue {
+	if lang == "en" {
+		return catalogues["en"]
+	}
+	return catalogues["ar"]
+}
+
+// ChatOptions tune a chat for an organization. The zero value uses the built-in prompt for
+// the language and the default sampling settings.
+type ChatOptions struct {
+	// SystemPrompt replaces the built-in prompt. It is sent as is, so retrieved context is
+	// only included if the prompt was rendered with it.
+	SystemPrompt string
+	Temperature  float32
+	MaxTokens    int
+}
+
+// PromptData is what chat prompt templates are executed with.
+type PromptData struct {
+	PersonaName string
+	// Tone describes the response tone in the language of the chat.
+	Tone              string
+	OutOfScopeRefusal string
+	MedicalRefusal    string
+	// Context holds the guideline snippets retrieved for the user's message.
+	Context  []string
+	Progress PromptProgress
+}
+
+// PromptProgress is the user's quitting progress. QuitDate is formatted as 2006-01-02 and
+// empty unless HasQuitDate.
+type PromptProgress struct {
+	HasQuitDate        bool
+	QuitDate           string
+	DaysSinceQuit      int
+	TotalDaysSmokeFree int
+	StreakDays         int
+	MoneySaved         int
+}
+
+// DefaultPromptTemplate returns the built-in chat prompt template for lang.
+func DefaultPromptTemplate(lang string) string {
+	return catalogueFor(lang).Template
+}
+
+// DefaultPromptData returns the built-in persona, tone and refusals in lang, with no context
+// or progress.
+func DefaultPromptData(lang string) PromptData {
+	catalogue := catalogueFor(lang)
+	return PromptData{
+		PersonaName:       catalogue.PersonaName,
+		Tone:              ToneDescription(ToneEmpathetic, lang),
+		OutOfScopeRefusal: catalogue.OutOfScopeRefusal,
+		MedicalRefusal:    catalogue.MedicalRefusal,
+	}
+}
+
+// ToneDescription describes tone in lang, falling back to the empathetic tone for unknown
+// ones.
+func ToneDescription(tone string, lang string) string {
+	tones := catalogueFor(lang).Tones
+	if description, ok := tones[tone]; ok {
+		return description
+	}
+	return tones[ToneEmpathetic]
+}
+
+// noAnswer is returned in lang when the model stopped at "ERROR" because it could not
+// comply with the instructions.
+func noAnswer(lang string) string {
+	return catalogueFor(lang).NoAnswer
+}
+
+// RenderPrompt executes a chat prompt template. Referring to a field PromptData does not
+// have fails when the template is executed, not when it is parsed.
+func RenderPrompt(text string, data PromptData) (string, error) {
+	tmpl, err := template.New("prompt").Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("llm: parse prompt: %w", err)
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("llm: render prompt: %w", err)
+	}
+	return buf.String(), nil
+}
@@ -0,0 +1,194 @@
+package llm
+
+import (
+	"context"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+
+	"patient-chatbot/internal/dto"
+)
+
+func TestDefaultPromptsRender(t *testing.T) {
+	for _, lang := range []string{"en", "ar"} {
+		data := DefaultPromptData(lang)
+		data.Context = []string{"Cravings pass within minutes."}
+		data.Progress = PromptProgress{HasQuitDate: true, QuitDate: "2026-01-10", DaysSinceQuit: 5, StreakDays: 4}
+
+		prompt, err := RenderPrompt(DefaultPromptTemplate(lang), data)
+		if err != nil {
+			t.Fatalf("%s: %v", lang, err)
+		}
+		for _, want := range []string{data.PersonaName, data.Tone, data.OutOfScopeRefusal, data.MedicalRefusal, "- Cravings pass within minutes.", "2026-01-10"} {
+			if !strings.Contains(prompt, want) {
+				t.Errorf("%s prompt is missing %q", lang, want)
+			}
+		}
+		if strings.Contains(prompt, "{{") {
+			t.Errorf("%s prompt has unrendered actions", lang)
+		}
+	}
+}
+
+func TestRenderPromptRejectsUnknownFields(t *testing.T) {
+	if _, err := RenderPrompt("You are {{.Name}}.", DefaultPromptData("en")); err == nil {
+		t.Fatal("expected an error for a field PromptData does not have")
+	}
+	if _, err := RenderPrompt("You are {{.PersonaName", DefaultPromptData("en")); err == nil {
+		t.Fatal("expected a parse error")
+	}
+}
+
+func TestChatUsesOptions(t *testing.T) {
+	provider := &scriptedProvider{replies: []string{"Hi.", "Hi."}}
+	client := NewLLMClient(provider, Models{ChatEN: "chat"})
+	messages := []dto.Message{{Role: "user", Content: "hello"}}
+
+	if _, err := client.Chat(context.Background(), messages, []string{"A snippet."}, "en", nil, ChatOptions{}); err != nil {
+		t.Fatalf("Chat: %v", err)
+	}
+	defaults := provider.requests[0]
+	if defaults.MaxTokens != DefaultChatMaxTokens || defaults.Temperature != 0 {
+		t.Fatalf("request = %+v, want the default sampling settings", defaults)
+	}
+	if system := defaults.Messages[0].Content; !strings.Contains(system, "Hamad") || !strings.Contains(system, "- A snippet.") {
+		t.Fatalf("system prompt = %q, want the built-in prompt with the context", system)
+	}
+
+	options := ChatOptions{SystemPrompt: "You are Noor.", Temperature: 0.7, MaxTokens: 200}
+	if _, err := client.Chat(context.Background(), messages, []string{"A snippet."}, "en", nil, options); err != nil {
+		t.Fatalf("Chat: %v", err)
+	}
+	custom := provider.requests[1]
+	if custom.Messages[0].Content != "You are Noor." || custom.Temperature != 0.7 || custom.MaxTokens != 200 {
+		t.Fatalf("request = %+v, want the options", custom)
+	}
+}
+
+var (
+	templateFields = regexp.MustCompile(`\.[A-Z][\w.]*`)
+	templateAction = regexp.MustCompile(`\{\{[^}]*\}\}`)
+	toolNames      = regexp.MustCompile(`\b[a-z]+(?:_[a-z]+)+\b`)
+	ruleNumbers    = regexp.MustCompile(`(?m)^\d+\. `)
+)
+
+// promptContract is what a prompt template promises the code around it, independent of its
+// wording.
+type promptContract struct {
+	Fields []string
+	Tools  []string
+	Rules  []string
+}
+
+func contractOf(text string) promptContract {
+	var contract promptContract
+	for _, action := range templateAction.FindAllString(text, -1) {
+		contract.Fields = append(contract.Fields, templateFields.FindAllString(action, -1)...)
+	}
+	contract.Tools = toolNames.FindAllString(templateAction.ReplaceAllString(text, ""), -1)
+	contract.Rules = ruleNumbers.FindAllString(text, -1)
+	for _, list := range [][]string{contract.Fields, contract.Tools} {
+		sort.Strings(list)
+	}
+	contract.Fields = compactStrings(contract.Fields)
+	contract.Tools = compactStrings(contract.Tools)
+	return contract
+}
+
+func compactStrings(list []string) []string {
+	var out []string
+	for i, s := range list {
+		if i == 0 || s != list[i-1] {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+func TestPromptCataloguesShareTheSameContract(t *testing.T) {
+	english := contractOf(catalogueFor("en").Template)
+	if len(english.Fields) == 0 || len(english.Tools) == 0 || len(english.Rules) == 0 {
+		t.Fatalf("english contract = %+v, want fields, tools and rules", english)
+	}
+
+	for _, lang := range promptLanguages {
+		catalogue := catalogueFor(lang)
+		if contract := contractOf(catalogue.Template); !reflect.DeepEqual(contract, english) {
+			t.Errorf("%s prompt contract = %+v, want %+v", lang, contract, english)
+		}
+		// The chat request stops at "ERROR", so every language must use it for refusals to
+		// comply rather than a translation.
+		if !strings.Contains(catalogue.Template, "\nERROR: Unable to comply with instructions.") {
+			t.Errorf("%s prompt does not end non-compliant answers with ERROR", lang)
+		}
+		if catalogue.PersonaName == "" || catalogue.OutOfScopeRefusal == "" || catalogue.MedicalRefusal == "" || catalogue.NoAnswer == "" {
+			t.Errorf("%s catalogue has empty defaults: %+v", lang, catalogue)
+		}
+		if len(catalogue.Tones) != len(Tones) {
+			t.Errorf("%s catalogue has tones %v, want %v", lang, catalogue.Tones, Tones)
+		}
+		for _, tone := range Tones {
+			if catalogue.Tones[tone] == "" {
+				t.Errorf("%s catalogue does not describe the %s tone", lang, tone)
+			}
+		}
+	}
+	if catalogueFor("fr").Template != catalogueFor("ar").Template {
+		t.Error("expected unknown languages to use the arabic prompt")
+	}
+}
+
+func TestChatHandlesAnswersTheSameWayInEveryLanguage(t *testing.T) {
+	for _, lang := range promptLanguages {
+		t.Run(lang, func(t *testing.T) {
+			multiline := "First, breathe slowly.\n\n- Drink water\n- Take a short walk"
+			provider := &scriptedProvider{
+				replies: []string{"", multiline, ""},
+				calls:   map[int][]ToolCall{0: {{ID: "1", Name: "log_money_saved", Arguments: `{"amount":60}`}}},
+			}
+			client := NewLLMClient(provider, Models{ChatEN: "chat-en", ChatAR: "chat-ar"})
+			messages := []dto.Message{{Role: "user", Content: "..."}}
+
+			var saved []int
+			result, err := client.Chat(context.Background(), messages, nil, lang, []Tool{moneyTool(&saved)}, ChatOptions{})
+			if err != nil {
+				t.Fatalf("Chat: %v", err)
+			}
+			if result.Answer != multiline || len(result.ToolCalls) != 1 || len(saved) != 1 {
+				t.Fatalf("result = %+v, saved %v; want the multi-line answer after the tool ran", result, saved)
+			}
+			if system := provider.requests[0].Messages[0].Content; system != mustRender(t, lang) {
+				t.Errorf("system prompt is not the %s catalogue prompt", lang)
+			}
+			if stop := provider.requests[0].Stop; len(stop) != 1 || stop[0] != "ERROR" {
+				t.Errorf("stop = %v", stop)
+			}
+
+			var streamed strings.Builder
+			result, err = client.ChatStream(context.Background(), messages, nil, lang, nil, ChatOptions{}, func(delta string) error {
+				streamed.WriteString(delta)
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("ChatStream: %v", err)
+			}
+			if result.Answer != noAnswer(lang) || streamed.String() != noAnswer(lang) {
+				t.Fatalf("answer %q, streamed %q; want the %s fallback", result.Answer, streamed.String(), lang)
+			}
+		})
+	}
+	if noAnswer("en") == noAnswer("ar") {
+		t.Error("expected the fallback answer to be translated")
+	}
+}
+
+func mustRender(t *testing.T, lang string) string {
+	t.Helper()
+	prompt, err := RenderPrompt(DefaultPromptTemplate(lang), DefaultPromptData(lang))
+	if err != nil {
+		t.Fatalf("%s: %v", lang, err)
+	}
+	return prompt
+}
@@ -0,0 +1,185 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"patient-chatbot/internal/client/resilience"
+	"patient-chatbot/internal/config"
+)
+
+const (
+	ProviderOpenAI = "openai"
+	ProviderOllama = "ollama"
+
+	groqBaseURL          = "https://api.groq.com/openai/v1"
+	defaultOllamaBaseURL = "http://localhost:11434"
+)
+
+// Provider sends completion requests to a model server. Implementations translate the
+// provider-neutral CompletionRequest into their own wire format.
+type Provider interface {
+	Complete(ctx context.Context, req CompletionRequest) (*Completion, error)
+	// Stream calls onDelta with each piece of content as it is generated and returns the
+	// whole completion once the model is done.
+	Stream(ctx context.Context, req CompletionRequest, onDelta func(string) error) (*Completion, error)
+}
+
+type CompletionRequest struct {
+	Model       string
+	Messages    []CompletionMessage
+	Temperature float32
+	TopP        float32
+	MaxTokens   int
+	Stop        []string
+	// JSON asks for a reply that is a single JSON object, using the provider's JSON mode.
+	JSON bool
+	// Tools are the functions the model may call instead of answering.
+	Tools []Tool
+}
+
+// CompletionMessage is a single turn. Images are data URLs ("data:<mime>;base64,...") and
+// are only sent to the multimodal model. An assistant turn that called tools carries
+// ToolCalls, and each result is sent back as a "tool" turn naming the call it answers.
+type CompletionMessage struct {
+	Role       string
+	Content    string
+	Images     []string
+	ToolCalls  []ToolCall
+	ToolCallID string
+	ToolName   string
+}
+
+// Completion is the model's reply: text, tool calls for the caller to run, or both.
+type Completion struct {
+	Content   string
+	ToolCalls []ToolCall
+}
+
+// Models names the model used for each role, so a deployment can mix e.g. a small English
+// chat model with a larger Arabic one and a vision model for document extraction.
+type Models struct {
+	ChatEN     string
+	ChatAR     string
+	Extraction string
+}
+
+// ChatModel returns the chat model for lang.
+func (m Models) ChatModel(lang string) string {
+	if lang == "en" {
+		return m.ChatEN
+	}
+	return m.ChatAR
+}
+
+func modelsFromConfig(cfg *config.Config) Models {
+	return Models{
+		ChatEN:     cfg.LLMModel,
+		ChatAR:     cfg.ArabicLLMModel,
+		Extraction: cfg.MULTIMODAL_LLM_MODEL,
+	}
+}
+
+// NewProvider builds the provider selected by LLM_PROVIDER, wrapped with the configured
+// timeouts, retries and circuit breaker.
+func NewProvider(cfg *config.Config) (Provider, error) {
+	provider, err := newBaseProvider(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return NewResilientProvider(provider, resilience.NewExecutor("llm", resilience.Policy{
+		Timeout:          cfg.LLMTimeout,
+		MaxAttempts:      cfg.LLMMaxAttempts,
+		BaseDelay:        cfg.RetryBaseDelay,
+		MaxDelay:         cfg.RetryMaxDelay,
+		FailureThreshold: cfg.BreakerFailureThreshold,
+		Cooldown:         cfg.BreakerCooldown,
+	})), nil
+}
+
+// newBaseProvider builds the bare provider. Groq is an OpenAI-compatible provider with a
+// fixed base URL.
+func newBaseProvider(cfg *config.Config) (Provider, error) {
+	switch cfg.LLMProvider {
+	case ProviderGroq:
+		return NewOpenAIProvider(groqBaseURL, cfg.GroqAPIKey, ""), nil
+	case ProviderOpenAI:
+		return NewOpenAIProvider(cfg.LLMBaseURL, cfg.LLMAPIKey, cfg.LLMAPIVersion), nil
+	case ProviderOllama:
+		baseURL := cfg.LLMBaseURL
+		if baseURL == "" {
+			baseURL = defaultOllamaBaseURL
+		}
+		return NewOllamaProvider(baseURL), nil
+	default:
+		return nil, fmt.Errorf("unknown LLM provider %q", cfg.LLMProvider)
+	}
+}
+
+// APIError is a non-200 response from a provider. 429 and 5xx responses are transient.
+type APIError struct {
+	Provider   string
+	StatusCode int
+	Body       string
+	Wait       time.Duration
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("%s API error [%d]: %s", e.Provider, e.StatusCode, e.Body)
+}
+
+func (e *APIError) Transient() bool {
+	return e.StatusCode == http.StatusTooManyRequests || e.StatusCode >= 500
+}
+
+// RetryAfter is the delay the provider asked for in its Retry-After header, if any.
+func (e *APIError) RetryAfter() time.Duration {
+	return e.Wait
+}
+
+func newAPIError(provider string, resp *http.Response) *APIError {
+	body, _ := io.ReadAll(resp.Body)
+	return &APIError{
+		Provider:   provider,
+		StatusCode: resp.StatusCode,
+		Body:       string(body),
+		Wait:       resilience.ParseRetryAfter(resp.Header.Get("Retry-After"), time.Now()),
+	}
+}
+
+// resilientProvider retries transient provider failures and stops calling a provider that
+// keeps failing.
+type resilientProvider struct {
+	provider Provider
+	executor *resilience.Executor
+}
+
+func NewResilientProvider(provider Provider, executor *resilience.Executor) Provider {
+	return &resilientProvider{provider: provider, executor: executor}
+}
+
+func (r *resilientProvider) Complete(ctx context.Context, req CompletionRequest) (*Completion, error) {
+	var resp *Completion
+	err := r.executor.Do(ctx, func(ctx context.Context) error {
+		var err error
+		resp, err = r.provider.Complete(ctx, req)
+		return err
+	})
+	return resp, err
+}
+
+func (r *resilientProvider) Stream(ctx context.Context, req CompletionRequest, onDelta func(string) error) (*Completion, error) {
+	var resp *Completion
+	err := r.executor.Stream(ctx, func(ctx context.Context, progress func()) error {
+		var err error
+		resp, err = r.provider.Stream(ctx, req, func(delta string) error {
+			progress()
+			return onDelta(delta)
+		})
+		return err
+	})
+	return resp, err
+}
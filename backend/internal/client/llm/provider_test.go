@@ -0,0 +1,211 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestOpenAIProviderUsesAzureAuthWhenAPIVersionIsSet(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/openai/deployments/chat/chat/completions" || r.URL.Query().Get("api-version") != "2024-06-01" {
+			t.Errorf("unexpected URL %s", r.URL)
+		}
+		if r.Header.Get("api-key") != "secret" || r.Header.Get("Authorization") != "" {
+			t.Errorf("unexpected auth headers %v", r.Header)
+		}
+		io.WriteString(w, `{"choices":[{"message":{"role":"assistant","content":"hi"}}]}`)
+	}))
+	defer server.Close()
+
+	provider := NewOpenAIProvider(server.URL+"/openai/deployments/chat/", "secret", "2024-06-01")
+	got, err := provider.Complete(context.Background(), CompletionRequest{Model: "m", Messages: []CompletionMessage{{Role: "user", Content: "hello"}}})
+	if err != nil {
+		t.Fatalf("Complete: %v", err)
+	}
+	if got.Content != "hi" {
+		t.Fatalf("got %q", got.Content)
+	}
+}
+
+func TestOpenAIProviderSendsImagesAsContentBlocks(t *testing.T) {
+	var body struct {
+		Messages []struct {
+			Content json.RawMessage `json:"content"`
+		} `json:"messages"`
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer key" {
+			t.Errorf("unexpected Authorization %q", r.Header.Get("Authorization"))
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		io.WriteString(w, `{"choices":[{"message":{"content":"{}"}}]}`)
+	}))
+	defer server.Close()
+
+	provider := NewOpenAIProvider(server.URL, "key", "")
+	_, err := provider.Complete(context.Background(), CompletionRequest{Messages: []CompletionMessage{
+		{Role: "system", Content: "plain"},
+		{Role: "user", Content: "describe", Images: []string{"data:image/png;base64,AAAA"}},
+	}})
+	if err != nil {
+		t.Fatalf("Complete: %v", err)
+	}
+	if string(body.Messages[0].Content) != `"plain"` {
+		t.Fatalf("text message content = %s", body.Messages[0].Content)
+	}
+	if !strings.Contains(string(body.Messages[1].Content), `"image_url":{"url":"data:image/png;base64,AAAA"}`) {
+		t.Fatalf("image message content = %s", body.Messages[1].Content)
+	}
+}
+
+func TestOllamaProviderStreamsNDJSON(t *testing.T) {
+	var request OllamaChatRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/chat" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		json.NewDecoder(r.Body).Decode(&request)
+		io.WriteString(w, `{"message":{"role":"assistant","content":"Hel"},"done":false}`+"\n")
+		io.WriteString(w, `{"message":{"role":"assistant","content":"lo"},"done":false}`+"\n")
+		io.WriteString(w, `{"message":{"role":"assistant","content":""},"done":true}`+"\n")
+	}))
+	defer server.Close()
+
+	var got strings.Builder
+	provider := NewOllamaProvider(server.URL)
+	completion, err := provider.Stream(context.Background(), CompletionRequest{
+		Model:     "llama3",
+		Messages:  []CompletionMessage{{Role: "user", Content: "hi", Images: []string{"data:image/png;base64,AAAA"}}},
+		MaxTokens: 64,
+	}, func(delta string) error {
+		got.WriteString(delta)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Stream: %v", err)
+	}
+
+	if got.String() != "Hello" || completion.Content != "Hello" {
+		t.Fatalf("got %q, completion %q", got.String(), completion.Content)
+	}
+	if !request.Stream || request.Options.NumPredict != 64 || request.Messages[0].Images[0] != "AAAA" {
+		t.Fatalf("unexpected request %+v", request)
+	}
+}
+
+func TestProvidersRequestJSONMode(t *testing.T) {
+	var body map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&body)
+		if r.URL.Path == "/api/chat" {
+			io.WriteString(w, `{"message":{"content":"{}"},"done":true}`)
+			return
+		}
+		io.WriteString(w, `{"choices":[{"message":{"content":"{}"}}]}`)
+	}))
+	defer server.Close()
+
+	req := CompletionRequest{Messages: []CompletionMessage{{Role: "user", Content: "json please"}}, JSON: true}
+
+	if _, err := NewOpenAIProvider(server.URL, "", "").Complete(context.Background(), req); err != nil {
+		t.Fatalf("openai Complete: %v", err)
+	}
+	if format, _ := body["response_format"].(map[string]interface{}); format["type"] != "json_object" {
+		t.Fatalf("openai response_format = %v", body["response_format"])
+	}
+
+	if _, err := NewOllamaProvider(server.URL).Complete(context.Background(), req); err != nil {
+		t.Fatalf("ollama Complete: %v", err)
+	}
+	if body["format"] != "json" {
+		t.Fatalf("ollama format = %v", body["format"])
+	}
+}
+
+func TestOpenAIProviderToolCalls(t *testing.T) {
+	var body struct {
+		Tools    []ChatTool           `json:"tools"`
+		Messages []ChatRequestMessage `json:"messages"`
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&body)
+		if r.Header.Get("Accept") != "text/event-stream" {
+			io.WriteString(w, `{"choices":[{"message":{"content":null,"tool_calls":[{"id":"call_1","type":"function","function":{"name":"log_craving","arguments":"{\"intensity\":7}"}}]}}]}`)
+			return
+		}
+		io.WriteString(w, `data: {"choices":[{"delta":{"tool_calls":[{"index":0,"id":"call_2","type":"function","function":{"name":"report_slip","arguments":""}}]}}]}`+"\n\n")
+		io.WriteString(w, `data: {"choices":[{"delta":{"tool_calls":[{"index":0,"function":{"arguments":"{\"daysAgo\":"}}]}}]}`+"\n\n")
+		io.WriteString(w, `data: {"choices":[{"delta":{"tool_calls":[{"index":0,"function":{"arguments":"1}"}}]}}]}`+"\n\n")
+		io.WriteString(w, "data: [DONE]\n\n")
+	}))
+	defer server.Close()
+
+	provider := NewOpenAIProvider(server.URL, "", "")
+	req := CompletionRequest{
+		Messages: []CompletionMessage{
+			{Role: "user", Content: "craving"},
+			{Role: "assistant", ToolCalls: []ToolCall{{ID: "call_0", Name: "get_my_progress"}}},
+			{Role: "tool", Content: `{"streak":3}`, ToolCallID: "call_0", ToolName: "get_my_progress"},
+		},
+		Tools: []Tool{{Name: "log_craving", Description: "Log a craving.", Parameters: Schema{"intensity": {Type: JSONInteger, Required: true}}}},
+	}
+
+	completion, err := provider.Complete(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Complete: %v", err)
+	}
+	if len(completion.ToolCalls) != 1 || completion.ToolCalls[0] != (ToolCall{ID: "call_1", Name: "log_craving", Arguments: `{"intensity":7}`}) {
+		t.Fatalf("tool calls = %+v", completion.ToolCalls)
+	}
+	if len(body.Tools) != 1 || body.Tools[0].Type != "function" || body.Tools[0].Function.Name != "log_craving" {
+		t.Fatalf("tools = %+v", body.Tools)
+	}
+	if call := body.Messages[1]; call.Content != nil || call.ToolCalls[0].Function.Arguments != "{}" {
+		t.Fatalf("assistant message = %+v", call)
+	}
+	if result := body.Messages[2]; result.ToolCallID != "call_0" || result.Content != `{"streak":3}` {
+		t.Fatalf("tool message = %+v", result)
+	}
+
+	completion, err = provider.Stream(context.Background(), req, func(string) error {
+		t.Error("no content expected")
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Stream: %v", err)
+	}
+	if len(completion.ToolCalls) != 1 || completion.ToolCalls[0] != (ToolCall{ID: "call_2", Name: "report_slip", Arguments: `{"daysAgo":1}`}) {
+		t.Fatalf("streamed tool calls = %+v", completion.ToolCalls)
+	}
+}
+
+func TestOllamaProviderToolCalls(t *testing.T) {
+	var request OllamaChatRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&request)
+		io.WriteString(w, `{"message":{"role":"assistant","content":"","tool_calls":[{"function":{"name":"log_money_saved","arguments":{"amount":25}}}]},"done":true}`)
+	}))
+	defer server.Close()
+
+	completion, err := NewOllamaProvider(server.URL).Complete(context.Background(), CompletionRequest{
+		Messages: []CompletionMessage{
+			{Role: "assistant", ToolCalls: []ToolCall{{ID: "call_0", Name: "get_my_progress", Arguments: "{}"}}},
+			{Role: "tool", Content: `{"streak":3}`, ToolCallID: "call_0", ToolName: "get_my_progress"},
+		},
+		Tools: []Tool{{Name: "log_money_saved", Parameters: Schema{"amount": {Type: JSONInteger, Required: true}}}},
+	})
+	if err != nil {
+		t.Fatalf("Complete: %v", err)
+	}
+	if len(completion.ToolCalls) != 1 || completion.ToolCalls[0].Name != "log_money_saved" || completion.ToolCalls[0].Arguments != `{"amount":25}` {
+		t.Fatalf("tool calls = %+v", completion.ToolCalls)
+	}
+	if len(request.Tools) != 1 || request.Messages[0].ToolCalls[0].Function.Name != "get_my_progress" || request.Messages[1].ToolName != "get_my_progress" {
+		t.Fatalf("unexpected request %+v", request)
+	}
+}
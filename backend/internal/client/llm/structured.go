@@ -0,0 +1,218 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+)
+
+// ErrInvalidOutput means the model still did not produce the requested JSON after being
+// re-prompted.
+var ErrInvalidOutput = errors.New("model output does not match the expected format")
+
+const REPAIR_PROMPT = `Your previous reply could not be used: %s.
+Reply with only the JSON object, exactly as specified, and nothing else.`
+
+type JSONType string
+
+const (
+	JSONString  JSONType = "string"
+	JSONInteger JSONType = "integer"
+	JSONBoolean JSONType = "boolean"
+)
+
+// Property describes one field of a structured response or tool call. Required string
+// fields must also be non-empty. Description is only shown to the model, for tool
+// parameters.
+type Property struct {
+	Type        JSONType
+	Required    bool
+	Nullable    bool
+	Description string
+}
+
+// Schema describes the JSON object a structured response or tool call must be. Fields the
+// schema does not mention are ignored.
+type Schema map[string]Property
+
+var describeSchema = Schema{
+	"title":    {Type: JSONString, Required: true},
+	"category": {Type: JSONString, Required: true},
+	"summary":  {Type: JSONString},
+}
+
+// names returns the schema's property names in order, so that errors and generated JSON
+// Schemas are deterministic.
+func (s Schema) names() []string {
+	names := make([]string, 0, len(s))
+	for name := range s {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// JSONSchema renders the schema as a JSON Schema object, as providers expect for tool
+// parameters.
+func (s Schema) JSONSchema() map[string]interface{} {
+	properties := make(map[string]interface{}, len(s))
+	required := []string{}
+	for _, name := range s.names() {
+		property := s[name]
+		definition := map[string]interface{}{"type": string(property.Type)}
+		if property.Nullable {
+			definition["type"] = []string{string(property.Type), "null"}
+		}
+		if property.Description != "" {
+			definition["description"] = property.Description
+		}
+		properties[name] = definition
+		if property.Required {
+			required = append(required, name)
+		}
+	}
+	return map[string]interface{}{
+		"type":       "object",
+		"properties": properties,
+		"required":   required,
+	}
+}
+
+// Validate reports the first way in which data does not match the schema.
+func (s Schema) Validate(data []byte) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var object map[string]interface{}
+	if err := decoder.Decode(&object); err != nil || object == nil {
+		return fmt.Errorf("not a JSON object")
+	}
+
+	for _, name := range s.names() {
+		property := s[name]
+		value, ok := object[name]
+		switch {
+		case !ok && property.Required:
+			return fmt.Errorf("%q is missing", name)
+		case !ok:
+			continue
+		case value == nil && property.Nullable:
+			continue
+		case value == nil:
+			return fmt.Errorf("%q must not be null", name)
+		}
+
+		switch property.Type {
+		case JSONString:
+			text, ok := value.(string)
+			if !ok {
+				return fmt.Errorf("%q must be a string", name)
+			}
+			if property.Required && strings.TrimSpace(text) == "" {
+				return fmt.Errorf("%q must not be empty", name)
+			}
+		case JSONInteger:
+			number, ok := value.(json.Number)
+			if !ok {
+				return fmt.Errorf("%q must be an integer", name)
+			}
+			if _, err := number.Int64(); err != nil {
+				return fmt.Errorf("%q must be an integer", name)
+			}
+		case JSONBoolean:
+			if _, ok := value.(bool); !ok {
+				return fmt.Errorf("%q must be true or false", name)
+			}
+		}
+	}
+	return nil
+}
+
+// LastJSONObject finds the last top-level JSON object in text, e.g. the trailer after a
+// free-text answer or a reply wrapped in a Markdown code fence. rest is the text around the
+// object, trimmed, with any code fence removed.
+func LastJSONObject(text string) (object string, rest string, ok bool) {
+	start, end := -1, -1
+	for i := 0; i < len(text); i++ {
+		if text[i] != '{' {
+			continue
+		}
+		decoder := json.NewDecoder(strings.NewReader(text[i:]))
+		var raw json.RawMessage
+		if err := decoder.Decode(&raw); err != nil {
+			continue
+		}
+		start, end = i, i+int(decoder.InputOffset())
+		i = end - 1
+	}
+	if start < 0 {
+		return "", strings.TrimSpace(text), false
+	}
+
+	before := strings.TrimSpace(text[:start])
+	for _, fence := range []string{"```json", "```"} {
+		if trimmed, ok := strings.CutSuffix(before, fence); ok {
+			before = strings.TrimSpace(trimmed)
+			break
+		}
+	}
+	after := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(text[end:]), "```"))
+	return text[start:end], strings.TrimSpace(before + "\n" + after), true
+}
+
+// decodeStructured extracts the last JSON object from text, validates it against schema and
+// unmarshals it into out.
+func decodeStructured(text string, schema Schema, out interface{}) error {
+	object, _, ok := LastJSONObject(text)
+	if !ok {
+		return fmt.Errorf("no JSON object found")
+	}
+	if err := schema.Validate([]byte(object)); err != nil {
+		return err
+	}
+	if err := json.Unmarshal([]byte(object), out); err != nil {
+		return fmt.Errorf("unmarshal: %w", err)
+	}
+	return nil
+}
+
+// completeJSON sends req in the provider's JSON mode and decodes the reply into out,
+// re-prompting once if it does not match schema.
+func (l *LLMClient) completeJSON(ctx context.Context, req CompletionRequest, schema Schema, out interface{}) error {
+	req.JSON = true
+	reply, err := l.provider.Complete(ctx, req)
+	if err != nil {
+		return err
+	}
+	err = decodeStructured(reply.Content, schema, out)
+	if err == nil {
+		return nil
+	}
+	return l.reprompt(ctx, req, reply.Content, err, schema, out)
+}
+
+// reprompt shows the model its unusable reply and what was wrong with it, and asks once more
+// for just the JSON object.
+func (l *LLMClient) reprompt(ctx context.Context, req CompletionRequest, reply string, problem error, schema Schema, out interface{}) error {
+	log.Warn().Msgf("re-prompting %s for valid JSON: %s", req.Model, problem.Error())
+
+	req.JSON = true
+	req.Stop = nil
+	req.Messages = append(append([]CompletionMessage(nil), req.Messages...),
+		CompletionMessage{Role: "assistant", Content: reply},
+		CompletionMessage{Role: "user", Content: fmt.Sprintf(REPAIR_PROMPT, problem.Error())},
+	)
+	repaired, err := l.provider.Complete(ctx, req)
+	if err != nil {
+		return err
+	}
+	if err := decodeStructured(repaired.Content, schema, out); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidOutput, err)
+	}
+	return nil
+}
@@ -0,0 +1,151 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"patient-chatbot/internal/dto"
+)
+
+// scriptedProvider returns queued replies in order and records the requests it receives.
+// Replies are plain text; calls, if set, is returned as the tool calls of the reply with the
+// same index.
+type scriptedProvider struct {
+	replies  []string
+	calls    map[int][]ToolCall
+	requests []CompletionRequest
+}
+
+func (p *scriptedProvider) Complete(ctx context.Context, req CompletionRequest) (*Completion, error) {
+	// Later rounds append to the same slice, so keep a copy of what was sent.
+	req.Messages = append([]CompletionMessage(nil), req.Messages...)
+	p.requests = append(p.requests, req)
+	if len(p.replies) == 0 {
+		return nil, errors.New("no scripted reply")
+	}
+	reply := p.replies[0]
+	p.replies = p.replies[1:]
+	return &Completion{Content: reply, ToolCalls: p.calls[len(p.requests)-1]}, nil
+}
+
+func (p *scriptedProvider) Stream(ctx context.Context, req CompletionRequest, onDelta func(string) error) (*Completion, error) {
+	completion, err := p.Complete(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if completion.Content == "" {
+		return completion, nil
+	}
+	for _, word := range strings.SplitAfter(completion.Content, " ") {
+		if err := onDelta(word); err != nil {
+			return nil, err
+		}
+	}
+	return completion, nil
+}
+
+func TestLastJSONObject(t *testing.T) {
+	cases := []struct {
+		text, object, rest string
+	}{
+		{
+			"First paragraph.\n\nSecond paragraph.\n{\"a\":1}",
+			`{"a":1}`, "First paragraph.\n\nSecond paragraph.",
+		},
+		{
+			"Use {curly} words. {\"a\":{\"b\":\"}\"}}",
+			`{"a":{"b":"}"}}`, "Use {curly} words.",
+		},
+		{
+			"```json\n{\"title\":\"x\"}\n```",
+			`{"title":"x"}`, "",
+		},
+		{
+			`{"a":1} then {"b":2}`,
+			`{"b":2}`, `{"a":1} then`,
+		},
+	}
+	for _, c := range cases {
+		object, rest, ok := LastJSONObject(c.text)
+		if !ok || object != c.object || rest != c.rest {
+			t.Errorf("LastJSONObject(%q) = %q, %q, %v; want %q, %q", c.text, object, rest, ok, c.object, c.rest)
+		}
+	}
+
+	if _, rest, ok := LastJSONObject("no json here {"); ok || rest != "no json here {" {
+		t.Errorf("expected no object, got rest %q ok %v", rest, ok)
+	}
+}
+
+func TestSchemaValidate(t *testing.T) {
+	schema := Schema{
+		"days":      {Type: JSONInteger, Nullable: true},
+		"confirmed": {Type: JSONBoolean, Required: true},
+		"note":      {Type: JSONString},
+	}
+	cases := []struct {
+		json string
+		ok   bool
+	}{
+		{`{"days":2,"confirmed":true}`, true},
+		{`{"days":null,"confirmed":false,"note":"x","extra":1}`, true},
+		{`{"days":"2","confirmed":true}`, false},
+		{`{"days":2.5,"confirmed":true}`, false},
+		{`{"days":2}`, false},
+		{`{"days":2,"confirmed":null}`, false},
+		{`{"confirmed":true,"note":3}`, false},
+		{`[1,2]`, false},
+	}
+	for _, c := range cases {
+		if err := schema.Validate([]byte(c.json)); (err == nil) != c.ok {
+			t.Errorf("Validate(%s) = %v, want ok=%v", c.json, err, c.ok)
+		}
+	}
+
+	if err := describeSchema.Validate([]byte(`{"title":" ","category":"General"}`)); err == nil {
+		t.Error("expected an empty title to be rejected")
+	}
+}
+
+func TestChatKeepsMultiParagraphAnswers(t *testing.T) {
+	provider := &scriptedProvider{replies: []string{"Great job on two days.\n\nKeep drinking water when cravings hit."}}
+	client := NewLLMClient(provider, Models{ChatEN: "chat"})
+
+	result, err := client.Chat(context.Background(), []dto.Message{{Role: "user", Content: "2 days!"}}, nil, "en", nil, ChatOptions{})
+	if err != nil {
+		t.Fatalf("Chat: %v", err)
+	}
+	if result.Answer != "Great job on two days.\n\nKeep drinking water when cravings hit." {
+		t.Fatalf("answer = %q", result.Answer)
+	}
+}
+
+func TestDescribeDocumentRepromptsOnce(t *testing.T) {
+	provider := &scriptedProvider{replies: []string{
+		`{"title":"","category":"Guide"}`,
+		"Here you go:\n```json\n{\"title\":\"Coping with cravings\",\"category\":\"Guide\",\"summary\":\"Tips.\"}\n```",
+	}}
+	client := NewLLMClient(provider, Models{Extraction: "vision"})
+
+	description, err := client.DescribeDocument(context.Background(), "Coping with cravings")
+	if err != nil {
+		t.Fatalf("DescribeDocument: %v", err)
+	}
+	if description.Title != "Coping with cravings" || description.Summary != "Tips." {
+		t.Fatalf("description = %+v", description)
+	}
+	if !provider.requests[0].JSON {
+		t.Fatal("expected JSON mode")
+	}
+
+	provider = &scriptedProvider{replies: []string{"nope", "still nope"}}
+	client = NewLLMClient(provider, Models{Extraction: "vision"})
+	if _, err := client.DescribeDocument(context.Background(), "text"); !errors.Is(err, ErrInvalidOutput) {
+		t.Fatalf("err = %v, want ErrInvalidOutput", err)
+	}
+	if len(provider.requests) != 2 {
+		t.Fatalf("expected exactly one re-prompt, got %d calls", len(provider.requests))
+	}
+}
@@ -0,0 +1,119 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+)
+
+// maxToolRounds bounds how many requests a chat makes to the model. The last one offers no
+// tools, so the model has to answer.
+const maxToolRounds = 4
+
+// ErrInvalidToolArguments is returned by a Tool's Run when the arguments are well-formed
+// but make no sense, e.g. a negative amount. The message is shown to the model so it can
+// correct the call; any other error is logged and reported to the model as a plain failure.
+var ErrInvalidToolArguments = errors.New("invalid arguments")
+
+// Tool is a function the model may call during a chat. Providers only see Name,
+// Description and Parameters; Run is called with arguments that already match Parameters,
+// and its result is sent back to the model as JSON.
+type Tool struct {
+	Name        string
+	Description string
+	Parameters  Schema
+	Run         func(ctx context.Context, arguments json.RawMessage) (interface{}, error)
+}
+
+// ToolCall is the model asking for a tool to be run. Arguments is a JSON object. Failed is
+// set once the call has run if its result was an error.
+type ToolCall struct {
+	ID        string
+	Name      string
+	Arguments string
+	Failed    bool
+}
+
+func chatTools(tools []Tool) []ChatTool {
+	if len(tools) == 0 {
+		return nil
+	}
+	out := make([]ChatTool, len(tools))
+	for i, tool := range tools {
+		out[i] = ChatTool{
+			Type: "function",
+			Function: ChatToolFunction{
+				Name:        tool.Name,
+				Description: tool.Description,
+				Parameters:  tool.Parameters.JSONSchema(),
+			},
+		}
+	}
+	return out
+}
+
+// runTools runs the calls in completion, marking those that failed, and returns the
+// messages that record them: the assistant turn with the calls, then one "tool" turn with
+// each result.
+func runTools(ctx context.Context, tools []Tool, completion *Completion) []CompletionMessage {
+	messages := []CompletionMessage{{Role: "assistant", Content: completion.Content, ToolCalls: completion.ToolCalls}}
+	for i, call := range completion.ToolCalls {
+		content, ok := runTool(ctx, tools, call)
+		completion.ToolCalls[i].Failed = !ok
+		messages = append(messages, CompletionMessage{
+			Role:       "tool",
+			Content:    content,
+			ToolCallID: call.ID,
+			ToolName:   call.Name,
+		})
+	}
+	return messages
+}
+
+// runTool runs a single call and returns its result as JSON, and whether it succeeded.
+// Failures are returned to the model as {"error": "..."} rather than failing the chat.
+func runTool(ctx context.Context, tools []Tool, call ToolCall) (string, bool) {
+	var tool *Tool
+	for i := range tools {
+		if tools[i].Name == call.Name {
+			tool = &tools[i]
+			break
+		}
+	}
+	if tool == nil {
+		return toolError(fmt.Sprintf("there is no tool named %q", call.Name)), false
+	}
+
+	arguments := strings.TrimSpace(call.Arguments)
+	if arguments == "" {
+		arguments = "{}"
+	}
+	if err := tool.Parameters.Validate([]byte(arguments)); err != nil {
+		return toolError("invalid arguments: " + err.Error()), false
+	}
+
+	result, err := tool.Run(ctx, json.RawMessage(arguments))
+	if errors.Is(err, ErrInvalidToolArguments) {
+		return toolError(err.Error()), false
+	}
+	if err != nil {
+		log.Error().Msg("error: " + call.Name + ": " + err.Error())
+		return toolError("the tool failed, tell the user it could not be saved and to try again later"), false
+	}
+
+	content, err := json.Marshal(result)
+	if err != nil {
+		log.Error().Msg("error: " + call.Name + ": " + err.Error())
+		return toolError("the tool failed"), false
+	}
+	return string(content), true
+}
+
+func toolError(message string) string {
+	content, _ := json.Marshal(map[string]string{"error": message})
+	return string(content)
+}
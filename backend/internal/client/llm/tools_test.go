@@ -0,0 +1,178 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"patient-chatbot/internal/dto"
+)
+
+func moneyTool(saved *[]int) Tool {
+	return Tool{
+		Name:        "log_money_saved",
+		Description: "Record money saved.",
+		Parameters:  Schema{"amount": {Type: JSONInteger, Required: true}},
+		Run: func(ctx context.Context, arguments json.RawMessage) (interface{}, error) {
+			var args struct{ Amount int }
+			if err := json.Unmarshal(arguments, &args); err != nil {
+				return nil, err
+			}
+			if args.Amount <= 0 {
+				return nil, fmt.Errorf("%w: amount must be positive", ErrInvalidToolArguments)
+			}
+			*saved = append(*saved, args.Amount)
+			return map[string]int{"totalMoneySaved": args.Amount}, nil
+		},
+	}
+}
+
+func TestChatRunsToolsAndFeedsResultsBack(t *testing.T) {
+	provider := &scriptedProvider{
+		replies: []string{"", "Well done, that's 60 SAR saved!"},
+		calls:   map[int][]ToolCall{0: {{ID: "call_1", Name: "log_money_saved", Arguments: `{"amount":60}`}}},
+	}
+	client := NewLLMClient(provider, Models{ChatEN: "chat"})
+
+	var saved []int
+	result, err := client.Chat(context.Background(), []dto.Message{{Role: "user", Content: "I saved 60 SAR"}}, nil, "en", []Tool{moneyTool(&saved)}, ChatOptions{})
+	if err != nil {
+		t.Fatalf("Chat: %v", err)
+	}
+	if result.Answer != "Well done, that's 60 SAR saved!" || len(result.ToolCalls) != 1 {
+		t.Fatalf("result = %+v", result)
+	}
+	if len(saved) != 1 || saved[0] != 60 {
+		t.Fatalf("tool ran with %v", saved)
+	}
+
+	if len(provider.requests[0].Tools) != 1 {
+		t.Fatalf("expected the tool to be offered, got %+v", provider.requests[0].Tools)
+	}
+	messages := provider.requests[1].Messages
+	call, reply := messages[len(messages)-2], messages[len(messages)-1]
+	if call.Role != "assistant" || len(call.ToolCalls) != 1 {
+		t.Fatalf("unexpected assistant turn %+v", call)
+	}
+	if reply.Role != "tool" || reply.ToolCallID != "call_1" || reply.Content != `{"totalMoneySaved":60}` {
+		t.Fatalf("unexpected tool turn %+v", reply)
+	}
+}
+
+func TestChatReportsToolErrorsToTheModel(t *testing.T) {
+	provider := &scriptedProvider{
+		replies: []string{"", "", "How much did you save?"},
+		calls: map[int][]ToolCall{
+			0: {{ID: "a", Name: "log_money_saved", Arguments: `{"amount":"lots"}`}},
+			1: {{ID: "b", Name: "log_money_saved", Arguments: `{"amount":-5}`}, {ID: "c", Name: "delete_everything"}},
+		},
+	}
+	client := NewLLMClient(provider, Models{ChatEN: "chat"})
+
+	var saved []int
+	if _, err := client.Chat(context.Background(), []dto.Message{{Role: "user", Content: "I saved lots"}}, nil, "en", []Tool{moneyTool(&saved)}, ChatOptions{}); err != nil {
+		t.Fatalf("Chat: %v", err)
+	}
+	if len(saved) != 0 {
+		t.Fatalf("tool should not have run, got %v", saved)
+	}
+
+	messages := provider.requests[2].Messages
+	for _, want := range []string{`"amount\" must be an integer`, "amount must be positive", "no tool named"} {
+		found := false
+		for _, message := range messages {
+			if message.Role == "tool" && strings.Contains(message.Content, want) {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("no tool result mentions %q in %+v", want, messages)
+		}
+	}
+}
+
+func TestChatReturnsToolCallsThatRanBeforeAnError(t *testing.T) {
+	provider := &scriptedProvider{
+		replies: []string{"Noting it.", ""},
+		calls: map[int][]ToolCall{
+			0: {{ID: "a", Name: "log_money_saved", Arguments: `{"amount":60}`}},
+			1: {{ID: "b", Name: "log_money_saved", Arguments: `{"amount":-5}`}},
+		},
+	}
+	client := NewLLMClient(provider, Models{ChatEN: "chat"})
+
+	var saved []int
+	result, err := client.Chat(context.Background(), []dto.Message{{Role: "user", Content: "I saved 60 SAR"}}, nil, "en", []Tool{moneyTool(&saved)}, ChatOptions{})
+	if err == nil {
+		t.Fatal("expected the third round to fail")
+	}
+	if result == nil || result.Answer != "Noting it." || len(result.ToolCalls) != 2 {
+		t.Fatalf("result = %+v", result)
+	}
+	if result.ToolCalls[0].Failed || !result.ToolCalls[1].Failed {
+		t.Fatalf("expected only the second call to be marked failed: %+v", result.ToolCalls)
+	}
+}
+
+func TestChatStopsOfferingToolsAfterMaxRounds(t *testing.T) {
+	provider := &scriptedProvider{calls: map[int][]ToolCall{}}
+	for i := 0; i < maxToolRounds; i++ {
+		provider.replies = append(provider.replies, "")
+		provider.calls[i] = []ToolCall{{ID: "x", Name: "log_money_saved", Arguments: `{"amount":1}`}}
+	}
+	client := NewLLMClient(provider, Models{ChatEN: "chat"})
+
+	var saved []int
+	result, err := client.Chat(context.Background(), []dto.Message{{Role: "user", Content: "hi"}}, nil, "en", []Tool{moneyTool(&saved)}, ChatOptions{})
+	if err != nil {
+		t.Fatalf("Chat: %v", err)
+	}
+	if len(provider.requests) != maxToolRounds || provider.requests[maxToolRounds-1].Tools != nil {
+		t.Fatalf("expected %d requests, the last without tools; got %d", maxToolRounds, len(provider.requests))
+	}
+	if len(saved) != maxToolRounds-1 || result.Answer != noAnswer("en") {
+		t.Fatalf("saved %v, answer %q", saved, result.Answer)
+	}
+}
+
+func TestChatStreamSeparatesTextAroundToolCalls(t *testing.T) {
+	provider := &scriptedProvider{
+		replies: []string{"Let me note that.", "Done, keep it up!"},
+		calls:   map[int][]ToolCall{0: {{ID: "1", Name: "log_money_saved", Arguments: `{"amount":10}`}}},
+	}
+	client := NewLLMClient(provider, Models{ChatEN: "chat"})
+
+	var streamed strings.Builder
+	var saved []int
+	result, err := client.ChatStream(context.Background(), []dto.Message{{Role: "user", Content: "saved 10"}}, nil, "en", []Tool{moneyTool(&saved)}, ChatOptions{}, func(delta string) error {
+		streamed.WriteString(delta)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ChatStream: %v", err)
+	}
+	want := "Let me note that.\n\nDone, keep it up!"
+	if streamed.String() != want || result.Answer != want {
+		t.Fatalf("streamed %q, answer %q", streamed.String(), result.Answer)
+	}
+	if len(saved) != 1 {
+		t.Fatalf("tool ran %d times", len(saved))
+	}
+}
+
+func TestSchemaJSONSchema(t *testing.T) {
+	schema := Schema{
+		"intensity": {Type: JSONInteger, Required: true, Description: "1 to 10"},
+		"trigger":   {Type: JSONString, Nullable: true},
+	}
+	got, err := json.Marshal(schema.JSONSchema())
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	want := `{"properties":{"intensity":{"description":"1 to 10","type":"integer"},"trigger":{"type":["string","null"]}},"required":["intensity"],"type":"object"}`
+	if string(got) != want {
+		t.Fatalf("got %s", got)
+	}
+}
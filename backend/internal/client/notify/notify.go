@@ -0,0 +1,34 @@
+// Package notify delivers notifications to patients through pluggable channels.
+package notify
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrNoRecipient means the channel has nowhere to send the message, e.g. an email without an
+// address. Retrying will not help.
+var ErrNoRecipient = errors.New("notify: message has no recipient for this channel")
+
+// Message is one notification to one patient. Body is plain text in the patient's language.
+type Message struct {
+	ID             string
+	Kind           string
+	OrganizationID string
+	UserID         string
+	Email          string
+	Lang           string
+	Subject        string
+	Body           string
+	// Data carries the structured details behind the text, e.g. the appointment ID, for
+	// channels that pass them on.
+	Data   map[string]interface{}
+	SentAt time.Time
+}
+
+// Channel sends messages somewhere: a mail server, a webhook, a test recorder.
+type Channel interface {
+	Name() string
+	Send(ctx context.Context, message Message) error
+}
@@ -0,0 +1,50 @@
+package notify
+
+import (
+	"context"
+	"sync"
+)
+
+// Recorder is a Channel that keeps the messages sent to it, for tests and local development.
+// Failures can be scripted with FailNext.
+type Recorder struct {
+	name string
+
+	mu       sync.Mutex
+	messages []Message
+	failures []error
+}
+
+func NewRecorder(name string) *Recorder {
+	return &Recorder{name: name}
+}
+
+func (r *Recorder) Name() string {
+	return r.name
+}
+
+func (r *Recorder) Send(ctx context.Context, message Message) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.failures) > 0 {
+		err := r.failures[0]
+		r.failures = r.failures[1:]
+		return err
+	}
+	r.messages = append(r.messages, message)
+	return nil
+}
+
+// FailNext makes the next Send fail with err.
+func (r *Recorder) FailNext(err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.failures = append(r.failures, err)
+}
+
+// Messages returns the messages sent so far.
+func (r *Recorder) Messages() []Message {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Message(nil), r.messages...)
+}
@@ -0,0 +1,122 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"mime"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strconv"
+	"time"
+)
+
+// SMTPChannel emails messages through a mail server. It upgrades the connection with
+// STARTTLS when the server offers it and only authenticates over an encrypted connection,
+// or to a server on localhost.
+type SMTPChannel struct {
+	host     string
+	port     int
+	username string
+	password string
+	from     mail.Address
+	timeout  time.Duration
+}
+
+func NewSMTPChannel(host string, port int, username string, password string, from string, timeout time.Duration) (*SMTPChannel, error) {
+	address, err := mail.ParseAddress(from)
+	if err != nil {
+		return nil, fmt.Errorf("smtp: invalid from address: %w", err)
+	}
+	return &SMTPChannel{host: host, port: port, username: username, password: password, from: *address, timeout: timeout}, nil
+}
+
+func (s *SMTPChannel) Name() string {
+	return "email"
+}
+
+func (s *SMTPChannel) Send(ctx context.Context, message Message) error {
+	if message.Email == "" {
+		return ErrNoRecipient
+	}
+
+	deadline := time.Now().Add(s.timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	dialer := net.Dialer{Deadline: deadline}
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(s.host, strconv.Itoa(s.port)))
+	if err != nil {
+		return fmt.Errorf("smtp: %w", err)
+	}
+	defer conn.Close()
+	if err := conn.SetDeadline(deadline); err != nil {
+		return fmt.Errorf("smtp: %w", err)
+	}
+
+	client, err := smtp.NewClient(conn, s.host)
+	if err != nil {
+		return fmt.Errorf("smtp: %w", err)
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: s.host}); err != nil {
+			return fmt.Errorf("smtp: starttls: %w", err)
+		}
+	}
+	if s.username != "" {
+		// PlainAuth refuses to send credentials unencrypted except to localhost.
+		if err := client.Auth(smtp.PlainAuth("", s.username, s.password, s.host)); err != nil {
+			return fmt.Errorf("smtp: auth: %w", err)
+		}
+	}
+	if err := client.Mail(s.from.Address); err != nil {
+		return fmt.Errorf("smtp: mail: %w", err)
+	}
+	if err := client.Rcpt(message.Email); err != nil {
+		return fmt.Errorf("smtp: rcpt: %w", err)
+	}
+	w, err := client.Data()
+	if err != nil {
+		return fmt.Errorf("smtp: data: %w", err)
+	}
+	if _, err := w.Write(s.compose(message)); err != nil {
+		return fmt.Errorf("smtp: data: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("smtp: data: %w", err)
+	}
+	return client.Quit()
+}
+
+// compose renders a plain-text UTF-8 email. The body is base64-encoded so Arabic text and
+// long lines survive any relay.
+func (s *SMTPChannel) compose(message Message) []byte {
+	var b bytes.Buffer
+	sentAt := message.SentAt
+	if sentAt.IsZero() {
+		sentAt = time.Now()
+	}
+	b.WriteString("From: " + s.from.String() + "\r\n")
+	b.WriteString("To: " + message.Email + "\r\n")
+	b.WriteString("Subject: " + mime.QEncoding.Encode("utf-8", message.Subject) + "\r\n")
+	b.WriteString("Date: " + sentAt.Format(time.RFC1123Z) + "\r\n")
+	if message.ID != "" {
+		b.WriteString("Message-ID: <" + message.ID + "@" + s.host + ">\r\n")
+	}
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("Content-Transfer-Encoding: base64\r\n\r\n")
+
+	encoded := base64.StdEncoding.EncodeToString([]byte(message.Body))
+	for len(encoded) > 76 {
+		b.WriteString(encoded[:76] + "\r\n")
+		encoded = encoded[76:]
+	}
+	b.WriteString(encoded + "\r\n")
+	return b.Bytes()
+}
@@ -0,0 +1,102 @@
+package notify
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"errors"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// serveSMTP accepts one connection, speaks just enough SMTP to take a message, and sends the
+// message's data on the returned channel.
+func serveSMTP(t *testing.T) (string, <-chan string) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	data := make(chan string, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		reply := func(line string) { conn.Write([]byte(line + "\r\n")) }
+
+		reply("220 localhost ESMTP")
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			switch command := strings.ToUpper(strings.TrimSpace(line)); {
+			case strings.HasPrefix(command, "EHLO"):
+				reply("250 localhost")
+			case strings.HasPrefix(command, "MAIL"), strings.HasPrefix(command, "RCPT"):
+				reply("250 OK")
+			case command == "DATA":
+				reply("354 go ahead")
+				var message strings.Builder
+				for {
+					line, err := r.ReadString('\n')
+					if err != nil || line == ".\r\n" {
+						break
+					}
+					message.WriteString(line)
+				}
+				data <- message.String()
+				reply("250 queued")
+			case command == "QUIT":
+				reply("221 bye")
+				return
+			default:
+				reply("502 unsupported")
+			}
+		}
+	}()
+	return listener.Addr().String(), data
+}
+
+func TestSMTPChannelSendsUTF8Email(t *testing.T) {
+	addr, data := serveSMTP(t)
+	host, port, _ := net.SplitHostPort(addr)
+	portNumber, _ := strconv.Atoi(port)
+
+	channel, err := NewSMTPChannel(host, portNumber, "", "", "Clinic <noreply@example.com>", 5*time.Second)
+	if err != nil {
+		t.Fatalf("NewSMTPChannel: %v", err)
+	}
+	body := "هل كنت بلا تدخين أمس؟"
+	if err := channel.Send(context.Background(), Message{Email: "patient@example.com", Subject: "تسجيل يومي", Body: body}); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	message := <-data
+	headers, encoded, _ := strings.Cut(message, "\r\n\r\n")
+	if !strings.Contains(headers, "To: patient@example.com") || !strings.Contains(headers, "Subject: =?utf-8?q?") {
+		t.Fatalf("headers = %q", headers)
+	}
+	decoded, err := base64.StdEncoding.DecodeString(strings.ReplaceAll(encoded, "\r\n", ""))
+	if err != nil || string(decoded) != body {
+		t.Fatalf("body = %q, %v", decoded, err)
+	}
+}
+
+func TestSMTPChannelNeedsARecipient(t *testing.T) {
+	channel, err := NewSMTPChannel("localhost", 25, "", "", "noreply@example.com", time.Second)
+	if err != nil {
+		t.Fatalf("NewSMTPChannel: %v", err)
+	}
+	if err := channel.Send(context.Background(), Message{}); !errors.Is(err, ErrNoRecipient) {
+		t.Fatalf("err = %v, want ErrNoRecipient", err)
+	}
+}
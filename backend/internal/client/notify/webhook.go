@@ -0,0 +1,94 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// SignatureHeader carries the hex HMAC-SHA256 of the request body under the webhook secret,
+// prefixed with "sha256=", so receivers can check the request came from us.
+const SignatureHeader = "X-Signature-256"
+
+// WebhookChannel posts each message as JSON to a URL, e.g. a tenant's SMS or push gateway.
+type WebhookChannel struct {
+	url        string
+	secret     string
+	httpClient *http.Client
+}
+
+type webhookPayload struct {
+	ID             string                 `json:"id"`
+	Kind           string                 `json:"kind"`
+	OrganizationID string                 `json:"organization_id"`
+	UserID         string                 `json:"user_id"`
+	Email          string                 `json:"email,omitempty"`
+	Lang           string                 `json:"lang"`
+	Subject        string                 `json:"subject"`
+	Body           string                 `json:"body"`
+	Data           map[string]interface{} `json:"data,omitempty"`
+	SentAt         time.Time              `json:"sent_at"`
+}
+
+// NewWebhookChannel posts with httpClient, which should refuse internal addresses when url
+// comes from a tenant; see package egress.
+func NewWebhookChannel(url string, secret string, httpClient *http.Client) *WebhookChannel {
+	return &WebhookChannel{url: url, secret: secret, httpClient: httpClient}
+}
+
+func (w *WebhookChannel) Name() string {
+	return "webhook"
+}
+
+func (w *WebhookChannel) Send(ctx context.Context, message Message) error {
+	body, err := json.Marshal(webhookPayload{
+		ID:             message.ID,
+		Kind:           message.Kind,
+		OrganizationID: message.OrganizationID,
+		UserID:         message.UserID,
+		Email:          message.Email,
+		Lang:           message.Lang,
+		Subject:        message.Subject,
+		Body:           message.Body,
+		Data:           message.Data,
+		SentAt:         message.SentAt,
+	})
+	if err != nil {
+		return fmt.Errorf("webhook: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("webhook: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if w.secret != "" {
+		req.Header.Set(SignatureHeader, Sign(w.secret, body))
+	}
+
+	res, err := w.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("webhook: %w", err)
+	}
+	defer res.Body.Close()
+	io.Copy(io.Discard, res.Body)
+
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return fmt.Errorf("webhook: POST returned %d", res.StatusCode)
+	}
+	return nil
+}
+
+// Sign returns the SignatureHeader value for body.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,46 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestWebhookChannelPostsSignedJSON(t *testing.T) {
+	var received webhookPayload
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if got := r.Header.Get(SignatureHeader); got != Sign("s3cret", body) {
+			t.Errorf("signature = %q", got)
+		}
+		if err := json.Unmarshal(body, &received); err != nil {
+			t.Errorf("body: %v", err)
+		}
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	channel := NewWebhookChannel(server.URL, "s3cret", &http.Client{Timeout: 5 * time.Second})
+	message := Message{ID: "job-1", Kind: "DAILY_CHECK_IN", UserID: "u1", Lang: "ar", Subject: "تسجيل يومي", Body: "هل كنت بلا تدخين أمس؟", Data: map[string]interface{}{"date": "2026-03-01"}}
+	if err := channel.Send(context.Background(), message); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if received.ID != "job-1" || received.Body != message.Body || received.Data["date"] != "2026-03-01" {
+		t.Fatalf("received = %+v", received)
+	}
+}
+
+func TestWebhookChannelReportsErrorStatuses(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	if err := NewWebhookChannel(server.URL, "", &http.Client{Timeout: 5 * time.Second}).Send(context.Background(), Message{}); err == nil {
+		t.Fatal("expected an error for a 502 response")
+	}
+}
@@ -0,0 +1,264 @@
+// Package resilience wraps calls to remote providers with per-attempt timeouts, retries
+// with exponential backoff and a circuit breaker.
+package resilience
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+var (
+	// ErrUnavailable marks a call that failed because the provider is down or overloaded,
+	// as opposed to a call that was rejected. Callers can use it to degrade gracefully.
+	ErrUnavailable = errors.New("provider unavailable")
+	ErrCircuitOpen = fmt.Errorf("circuit breaker open: %w", ErrUnavailable)
+
+	errStalled = errors.New("stream stalled")
+)
+
+type Policy struct {
+	// Timeout bounds each attempt. For streams it bounds the silence between pieces instead.
+	Timeout     time.Duration
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+	// FailureThreshold is the number of consecutive failed calls that opens the circuit;
+	// Cooldown is how long it stays open before a single trial call is let through.
+	FailureThreshold int
+	Cooldown         time.Duration
+}
+
+// Executor runs calls to one provider under a Policy. It is safe for concurrent use; all
+// calls share the same circuit breaker.
+type Executor struct {
+	name    string
+	policy  Policy
+	breaker *breaker
+	sleep   func(ctx context.Context, d time.Duration) error
+}
+
+func NewExecutor(name string, policy Policy) *Executor {
+	if policy.MaxAttempts < 1 {
+		policy.MaxAttempts = 1
+	}
+	return &Executor{
+		name:    name,
+		policy:  policy,
+		breaker: newBreaker(policy.FailureThreshold, policy.Cooldown),
+		sleep:   sleepContext,
+	}
+}
+
+// Do runs fn until it succeeds, fails with a permanent error or runs out of attempts.
+func (e *Executor) Do(ctx context.Context, fn func(ctx context.Context) error) error {
+	return e.run(ctx, func(ctx context.Context) (bool, error) {
+		if e.policy.Timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, e.policy.Timeout)
+			defer cancel()
+		}
+		return false, fn(ctx)
+	})
+}
+
+// Stream is Do for calls that deliver results incrementally. fn must call progress whenever
+// it receives a piece of the result. Once anything has been delivered the call is not
+// retried, since the caller has already consumed part of it.
+func (e *Executor) Stream(ctx context.Context, fn func(ctx context.Context, progress func()) error) error {
+	return e.run(ctx, func(ctx context.Context) (bool, error) {
+		ctx, cancel := context.WithCancelCause(ctx)
+		defer cancel(nil)
+
+		delivered := false
+		var timer *time.Timer
+		if e.policy.Timeout > 0 {
+			timer = time.AfterFunc(e.policy.Timeout, func() { cancel(errStalled) })
+			defer timer.Stop()
+		}
+		progress := func() {
+			delivered = true
+			if timer != nil {
+				timer.Reset(e.policy.Timeout)
+			}
+		}
+
+		err := fn(ctx, progress)
+		if err != nil && errors.Is(context.Cause(ctx), errStalled) {
+			// Report a timeout rather than the cancellation it caused, so the attempt counts as transient.
+			return delivered, fmt.Errorf("no data for %s: %w", e.policy.Timeout, context.DeadlineExceeded)
+		}
+		return delivered, err
+	})
+}
+
+func (e *Executor) run(ctx context.Context, attempt func(ctx context.Context) (bool, error)) error {
+	if !e.breaker.allow() {
+		return fmt.Errorf("%s: %w", e.name, ErrCircuitOpen)
+	}
+
+	for n := 1; ; n++ {
+		delivered, err := attempt(ctx)
+		if err == nil {
+			e.breaker.success()
+			return nil
+		}
+		if ctx.Err() != nil {
+			// The caller gave up; that says nothing about the provider's health.
+			e.breaker.release()
+			return err
+		}
+		if !Retryable(err) {
+			e.breaker.success()
+			return err
+		}
+		if delivered || n >= e.policy.MaxAttempts {
+			e.breaker.failure()
+			return fmt.Errorf("%s: %w: %w", e.name, ErrUnavailable, err)
+		}
+
+		delay := e.backoff(n, err)
+		log.Warn().Msgf("%s: attempt %d failed, retrying in %s: %s", e.name, n, delay, err.Error())
+		if err := e.sleep(ctx, delay); err != nil {
+			e.breaker.release()
+			return err
+		}
+	}
+}
+
+// backoff returns the delay before the next attempt: the provider's Retry-After if it sent
+// one, otherwise exponential backoff with full jitter. Both are capped at MaxDelay.
+func (e *Executor) backoff(attempt int, err error) time.Duration {
+	var ra interface{ RetryAfter() time.Duration }
+	if errors.As(err, &ra) && ra.RetryAfter() > 0 {
+		return min(ra.RetryAfter(), e.policy.MaxDelay)
+	}
+
+	ceiling := e.policy.BaseDelay << (attempt - 1)
+	if ceiling <= 0 || ceiling > e.policy.MaxDelay {
+		ceiling = e.policy.MaxDelay
+	}
+	if ceiling <= 0 {
+		return 0
+	}
+	return rand.N(ceiling) + 1
+}
+
+// Retryable reports whether err is worth retrying. Errors that implement Transient decide
+// for themselves; cancellation never is; anything else (network errors, timeouts) is
+// assumed to be transient.
+func Retryable(err error) bool {
+	if errors.Is(err, context.Canceled) {
+		return false
+	}
+	var t interface{ Transient() bool }
+	if errors.As(err, &t) {
+		return t.Transient()
+	}
+	return true
+}
+
+// ParseRetryAfter parses a Retry-After header given either in seconds or as an HTTP date.
+func ParseRetryAfter(header string, now time.Time) time.Duration {
+	if header == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(header); err == nil {
+		return max(time.Duration(seconds)*time.Second, 0)
+	}
+	if at, err := time.Parse(time.RFC1123, header); err == nil {
+		return max(at.Sub(now), 0)
+	}
+	return 0
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+type breakerState int
+
+const (
+	stateClosed breakerState = iota
+	stateOpen
+	stateHalfOpen
+)
+
+// breaker opens after threshold consecutive failures and fails calls fast until cooldown
+// has passed. It then lets one trial call through: success closes it, failure reopens it.
+type breaker struct {
+	mu        sync.Mutex
+	threshold int
+	cooldown  time.Duration
+	now       func() time.Time
+	state     breakerState
+	failures  int
+	openedAt  time.Time
+}
+
+func newBreaker(threshold int, cooldown time.Duration) *breaker {
+	return &breaker{threshold: threshold, cooldown: cooldown, now: time.Now}
+}
+
+func (b *breaker) allow() bool {
+	if b.threshold <= 0 {
+		return true
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case stateOpen:
+		if b.now().Sub(b.openedAt) < b.cooldown {
+			return false
+		}
+		b.state = stateHalfOpen
+		return true
+	case stateHalfOpen:
+		// A trial call is already in flight.
+		return false
+	default:
+		return true
+	}
+}
+
+func (b *breaker) success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.state = stateClosed
+	b.failures = 0
+}
+
+func (b *breaker) failure() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures++
+	if b.state == stateHalfOpen || (b.threshold > 0 && b.failures >= b.threshold) {
+		b.state = stateOpen
+		b.openedAt = b.now()
+	}
+}
+
+// release ends a call without a verdict, e.g. because the caller cancelled it.
+func (b *breaker) release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == stateHalfOpen {
+		b.state = stateOpen
+	}
+}
@@ -0,0 +1,169 @@
+package resilience
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+type transientError struct {
+	transient  bool
+	retryAfter time.Duration
+}
+
+func (e transientError) Error() string             { return "provider error" }
+func (e transientError) Transient() bool           { return e.transient }
+func (e transientError) RetryAfter() time.Duration { return e.retryAfter }
+
+func newTestExecutor(policy Policy) (*Executor, *[]time.Duration) {
+	var slept []time.Duration
+	e := NewExecutor("test", policy)
+	e.sleep = func(ctx context.Context, d time.Duration) error {
+		slept = append(slept, d)
+		return nil
+	}
+	return e, &slept
+}
+
+func TestDoRetriesTransientErrorsHonoringRetryAfter(t *testing.T) {
+	e, slept := newTestExecutor(Policy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: time.Minute})
+
+	calls := 0
+	err := e.Do(context.Background(), func(ctx context.Context) error {
+		calls++
+		if calls < 3 {
+			return transientError{transient: true, retryAfter: 2 * time.Second}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Do: %v", err)
+	}
+	if calls != 3 {
+		t.Fatalf("calls = %d, want 3", calls)
+	}
+	if len(*slept) != 2 || (*slept)[0] != 2*time.Second {
+		t.Fatalf("slept %v, want the Retry-After delay twice", *slept)
+	}
+}
+
+func TestDoCapsBackoffAtMaxDelay(t *testing.T) {
+	e, slept := newTestExecutor(Policy{MaxAttempts: 4, BaseDelay: time.Second, MaxDelay: 1500 * time.Millisecond})
+
+	err := e.Do(context.Background(), func(ctx context.Context) error {
+		return transientError{transient: true, retryAfter: time.Hour}
+	})
+	if !errors.Is(err, ErrUnavailable) {
+		t.Fatalf("err = %v, want ErrUnavailable", err)
+	}
+	for _, d := range *slept {
+		if d > 1500*time.Millisecond {
+			t.Fatalf("slept %s, above MaxDelay", d)
+		}
+	}
+}
+
+func TestDoDoesNotRetryPermanentErrors(t *testing.T) {
+	e, _ := newTestExecutor(Policy{MaxAttempts: 3})
+
+	calls := 0
+	err := e.Do(context.Background(), func(ctx context.Context) error {
+		calls++
+		return transientError{transient: false}
+	})
+	if calls != 1 {
+		t.Fatalf("calls = %d, want 1", calls)
+	}
+	if errors.Is(err, ErrUnavailable) {
+		t.Fatalf("permanent error reported as unavailable: %v", err)
+	}
+}
+
+func TestDoAppliesPerAttemptTimeout(t *testing.T) {
+	e, _ := newTestExecutor(Policy{MaxAttempts: 2, Timeout: 10 * time.Millisecond})
+
+	calls := 0
+	err := e.Do(context.Background(), func(ctx context.Context) error {
+		calls++
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	if calls != 2 {
+		t.Fatalf("calls = %d, want a retry after the timeout", calls)
+	}
+	if !errors.Is(err, ErrUnavailable) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("err = %v", err)
+	}
+}
+
+func TestCircuitOpensAfterThresholdAndRecovers(t *testing.T) {
+	e, _ := newTestExecutor(Policy{MaxAttempts: 1, FailureThreshold: 2, Cooldown: time.Minute})
+	now := time.Now()
+	e.breaker.now = func() time.Time { return now }
+
+	failing := func(ctx context.Context) error { return errors.New("connection refused") }
+	_ = e.Do(context.Background(), failing)
+	_ = e.Do(context.Background(), failing)
+
+	calls := 0
+	err := e.Do(context.Background(), func(ctx context.Context) error {
+		calls++
+		return nil
+	})
+	if !errors.Is(err, ErrCircuitOpen) || !errors.Is(err, ErrUnavailable) || calls != 0 {
+		t.Fatalf("expected fast failure while open, err = %v, calls = %d", err, calls)
+	}
+
+	now = now.Add(time.Minute)
+	if err := e.Do(context.Background(), func(ctx context.Context) error { return nil }); err != nil {
+		t.Fatalf("trial call after cooldown: %v", err)
+	}
+	if err := e.Do(context.Background(), func(ctx context.Context) error { return nil }); err != nil {
+		t.Fatalf("expected circuit to close after a successful trial, got %v", err)
+	}
+}
+
+func TestStreamIsNotRetriedAfterDelivery(t *testing.T) {
+	e, _ := newTestExecutor(Policy{MaxAttempts: 3})
+
+	calls := 0
+	err := e.Stream(context.Background(), func(ctx context.Context, progress func()) error {
+		calls++
+		progress()
+		return errors.New("connection reset")
+	})
+	if calls != 1 {
+		t.Fatalf("calls = %d, want 1", calls)
+	}
+	if !errors.Is(err, ErrUnavailable) {
+		t.Fatalf("err = %v, want ErrUnavailable", err)
+	}
+}
+
+func TestStreamTimesOutWhenStalled(t *testing.T) {
+	e, _ := newTestExecutor(Policy{MaxAttempts: 1, Timeout: 10 * time.Millisecond})
+
+	err := e.Stream(context.Background(), func(ctx context.Context, progress func()) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	if !errors.Is(err, context.DeadlineExceeded) || !errors.Is(err, ErrUnavailable) {
+		t.Fatalf("err = %v, want a stalled-stream timeout", err)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	cases := map[string]time.Duration{
+		"":                              0,
+		"3":                             3 * time.Second,
+		"Wed, 01 Jan 2025 12:00:30 GMT": 30 * time.Second,
+		"garbage":                       0,
+	}
+	for header, want := range cases {
+		if got := ParseRetryAfter(header, now); got != want {
+			t.Errorf("ParseRetryAfter(%q) = %s, want %s", header, got, want)
+		}
+	}
+}
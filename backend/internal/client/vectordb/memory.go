@@ -0,0 +1,203 @@
+package vectordb
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+const localEmbeddingDimensions = 256
+
+// LocalEmbedder is a deterministic hashing bag-of-words embedder. It needs no network or
+// model files, which makes retrieval reproducible on a laptop and in tests; it only
+// captures lexical overlap, so it is not meant for production.
+type LocalEmbedder struct{}
+
+func NewLocalEmbedder() *LocalEmbedder {
+	return &LocalEmbedder{}
+}
+
+func (e *LocalEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	embeddings := make([][]float32, len(texts))
+	for i, text := range texts {
+		embeddings[i] = e.embed(text)
+	}
+	return embeddings, nil
+}
+
+func (e *LocalEmbedder) Dimensions() int {
+	return localEmbeddingDimensions
+}
+
+func (e *LocalEmbedder) embed(text string) []float32 {
+	vector := make([]float32, localEmbeddingDimensions)
+	tokens := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for _, token := range tokens {
+		h := fnv.New32a()
+		h.Write([]byte(token))
+		vector[h.Sum32()%localEmbeddingDimensions]++
+	}
+
+	var norm float64
+	for _, v := range vector {
+		norm += float64(v) * float64(v)
+	}
+	if norm == 0 {
+		return vector
+	}
+	norm = math.Sqrt(norm)
+	for i := range vector {
+		vector[i] = float32(float64(vector[i]) / norm)
+	}
+	return vector
+}
+
+// MemoryStore is an in-process VectorStore that ranks records by brute-force cosine
+// similarity. Its contents are lost on restart.
+type MemoryStore struct {
+	mu         sync.RWMutex
+	embedder   Embedder
+	namespaces map[string]map[string]memoryRecord
+}
+
+type memoryRecord struct {
+	Record
+	embedding []float32
+}
+
+func NewMemoryStore(embedder Embedder) *MemoryStore {
+	return &MemoryStore{
+		embedder:   embedder,
+		namespaces: map[string]map[string]memoryRecord{},
+	}
+}
+
+func (m *MemoryStore) Upsert(ctx context.Context, namespace string, records []Record) error {
+	if len(records) == 0 {
+		return nil
+	}
+
+	texts := make([]string, len(records))
+	for i, record := range records {
+		texts[i] = record.Text
+	}
+	embeddings, err := m.embedder.Embed(ctx, texts)
+	if err != nil {
+		return fmt.Errorf("Embed: %w", err)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stored, ok := m.namespaces[namespace]
+	if !ok {
+		stored = map[string]memoryRecord{}
+		m.namespaces[namespace] = stored
+	}
+	for i, record := range records {
+		stored[record.ID] = memoryRecord{Record: copyRecord(record), embedding: embeddings[i]}
+	}
+	return nil
+}
+
+// Search ranks by cosine similarity. There is no reranker, so TopN only trims the result.
+func (m *MemoryStore) Search(ctx context.Context, namespace string, query string, opts SearchOptions) ([]Hit, error) {
+	limit := opts.TopK
+	if limit == 0 {
+		limit = 5
+	}
+	if opts.TopN > 0 && opts.TopN < limit {
+		limit = opts.TopN
+	}
+
+	embeddings, err := m.embedder.Embed(ctx, []string{query})
+	if err != nil {
+		return nil, fmt.Errorf("Embed: %w", err)
+	}
+	queryEmbedding := embeddings[0]
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var hits []Hit
+	for _, record := range m.namespaces[namespace] {
+		if !matchesFilter(record.Fields, opts.Filter) {
+			continue
+		}
+		hits = append(hits, Hit{Record: copyRecord(record.Record), Score: cosine(queryEmbedding, record.embedding)})
+	}
+
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		return hits[i].ID < hits[j].ID
+	})
+	if len(hits) > limit {
+		hits = hits[:limit]
+	}
+	return hits, nil
+}
+
+func (m *MemoryStore) Delete(ctx context.Context, namespace string, ids []string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stored := m.namespaces[namespace]
+	for _, id := range ids {
+		delete(stored, id)
+	}
+	return nil
+}
+
+func (m *MemoryStore) Fetch(ctx context.Context, namespace string, ids []string) ([]Record, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	stored := m.namespaces[namespace]
+	var records []Record
+	for _, id := range ids {
+		if record, ok := stored[id]; ok {
+			records = append(records, copyRecord(record.Record))
+		}
+	}
+	return records, nil
+}
+
+func matchesFilter(fields map[string]string, filter map[string]string) bool {
+	for key, value := range filter {
+		if fields[key] != value {
+			return false
+		}
+	}
+	return true
+}
+
+func cosine(a []float32, b []float32) float32 {
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return float32(dot / (math.Sqrt(normA) * math.Sqrt(normB)))
+}
+
+func copyRecord(record Record) Record {
+	fields := make(map[string]string, len(record.Fields))
+	for key, value := range record.Fields {
+		fields[key] = value
+	}
+	record.Fields = fields
+	return record
+}
@@ -0,0 +1,106 @@
+package vectordb
+
+import (
+	"context"
+	"testing"
+)
+
+func TestLocalEmbedderIsDeterministicAndNormalized(t *testing.T) {
+	embedder := NewLocalEmbedder()
+	first, err := embedder.Embed(context.Background(), []string{"Cravings pass within minutes"})
+	if err != nil {
+		t.Fatalf("Embed: %v", err)
+	}
+	second, _ := embedder.Embed(context.Background(), []string{"cravings PASS within minutes!"})
+
+	if len(first[0]) != embedder.Dimensions() {
+		t.Fatalf("got %d dimensions, want %d", len(first[0]), embedder.Dimensions())
+	}
+	if score := cosine(first[0], second[0]); score < 0.999 {
+		t.Fatalf("expected case and punctuation to be ignored, cosine = %f", score)
+	}
+
+	var norm float32
+	for _, v := range first[0] {
+		norm += v * v
+	}
+	if norm < 0.999 || norm > 1.001 {
+		t.Fatalf("expected unit vector, squared norm = %f", norm)
+	}
+}
+
+func TestMemoryStoreSearchRanksByCosine(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore(NewLocalEmbedder())
+
+	err := store.Upsert(ctx, "org-a", []Record{
+		{ID: "1", Text: "Nicotine cravings usually pass within five minutes", Fields: map[string]string{"category": "cravings"}},
+		{ID: "2", Text: "Exercise improves lung capacity after quitting", Fields: map[string]string{"category": "health"}},
+		{ID: "3", Text: "Drink water when a craving hits", Fields: map[string]string{"category": "cravings"}},
+	})
+	if err != nil {
+		t.Fatalf("Upsert: %v", err)
+	}
+
+	hits, err := store.Search(ctx, "org-a", "do nicotine cravings pass quickly", SearchOptions{TopK: 2})
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	if len(hits) != 2 {
+		t.Fatalf("got %d hits, want 2", len(hits))
+	}
+	if hits[0].ID != "1" {
+		t.Fatalf("top hit = %s, want 1", hits[0].ID)
+	}
+	if hits[0].Score < hits[1].Score {
+		t.Fatalf("hits not sorted by score: %v", hits)
+	}
+
+	hits, _ = store.Search(ctx, "org-a", "lung capacity", SearchOptions{TopK: 5, Filter: map[string]string{"category": "cravings"}})
+	for _, hit := range hits {
+		if hit.Fields["category"] != "cravings" {
+			t.Fatalf("filter not applied, got %v", hit)
+		}
+	}
+}
+
+func TestMemoryStoreIsolatesNamespaces(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore(NewLocalEmbedder())
+
+	_ = store.Upsert(ctx, "org-a", []Record{{ID: "1", Text: "smoke free"}})
+
+	hits, err := store.Search(ctx, "org-b", "smoke free", SearchOptions{TopK: 5})
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	if len(hits) != 0 {
+		t.Fatalf("expected no hits in another namespace, got %v", hits)
+	}
+}
+
+func TestMemoryStoreFetchAndDelete(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore(NewLocalEmbedder())
+
+	_ = store.Upsert(ctx, "org-a", []Record{
+		{ID: "1", Text: "one", Fields: map[string]string{"document_id": "d"}},
+		{ID: "2", Text: "two"},
+	})
+
+	records, err := store.Fetch(ctx, "org-a", []string{"1", "missing"})
+	if err != nil {
+		t.Fatalf("Fetch: %v", err)
+	}
+	if len(records) != 1 || records[0].Text != "one" || records[0].Fields["document_id"] != "d" {
+		t.Fatalf("unexpected fetch result: %v", records)
+	}
+
+	if err := store.Delete(ctx, "org-a", []string{"1"}); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	records, _ = store.Fetch(ctx, "org-a", []string{"1", "2"})
+	if len(records) != 1 || records[0].ID != "2" {
+		t.Fatalf("expected only record 2 to remain, got %v", records)
+	}
+}
@@ -0,0 +1,236 @@
+package vectordb
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"patient-chatbot/internal/config"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// These settings make the HNSW index keep scanning until enough rows pass a search's
+// namespace and field filter. The index covers every namespace, so with pgvector's defaults
+// a small namespace would often get none of the few dozen candidates it returns.
+// pgvector 0.8 added iterative scans; older versions can only be told to look further.
+var (
+	iterativeScanSettings = []string{`SET LOCAL hnsw.iterative_scan = strict_order`, `SET LOCAL hnsw.max_scan_tuples = 100000`}
+	wideScanSettings      = []string{`SET LOCAL hnsw.ef_search = 1000`}
+)
+
+// PgvectorStore keeps embeddings in Postgres using the pgvector extension, so deployments
+// can run retrieval entirely on their own infrastructure.
+type PgvectorStore struct {
+	db           *gorm.DB
+	embedder     Embedder
+	scanSettings []string
+}
+
+type vectorRow struct {
+	ID      string
+	Content string
+	Fields  string
+	Score   float32
+}
+
+func NewPgvectorStore(cfg *config.Config, embedder Embedder) (*PgvectorStore, error) {
+	db, err := gorm.Open(postgres.Open(cfg.DBURL), &gorm.Config{})
+	if err != nil {
+		return nil, fmt.Errorf("pgvector connect: %w", err)
+	}
+
+	statements := []string{
+		`CREATE EXTENSION IF NOT EXISTS vector`,
+		fmt.Sprintf(`CREATE TABLE IF NOT EXISTS vector_records (
+			namespace varchar(255) NOT NULL,
+			id varchar(255) NOT NULL,
+			content text NOT NULL,
+			fields jsonb NOT NULL DEFAULT '{}',
+			embedding vector(%d) NOT NULL,
+			PRIMARY KEY (namespace, id)
+		)`, embedder.Dimensions()),
+		`CREATE INDEX IF NOT EXISTS idx_vector_records_embedding ON vector_records USING hnsw (embedding vector_cosine_ops)`,
+	}
+	for _, stmt := range statements {
+		if err := db.Exec(stmt).Error; err != nil {
+			return nil, fmt.Errorf("pgvector migrate: %w", err)
+		}
+	}
+
+	var version string
+	if err := db.Raw(`SELECT extversion FROM pg_extension WHERE extname = 'vector'`).Scan(&version).Error; err != nil {
+		return nil, fmt.Errorf("pgvector version: %w", err)
+	}
+	scanSettings := wideScanSettings
+	if supportsIterativeScan(version) {
+		scanSettings = iterativeScanSettings
+	}
+
+	return &PgvectorStore{db: db, embedder: embedder, scanSettings: scanSettings}, nil
+}
+
+// supportsIterativeScan reports whether a pgvector version, e.g. "0.8.0", is 0.8 or later.
+func supportsIterativeScan(version string) bool {
+	parts := strings.SplitN(version, ".", 3)
+	if len(parts) < 2 {
+		return false
+	}
+	major, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return false
+	}
+	minor, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return false
+	}
+	return major > 0 || minor >= 8
+}
+
+func (p *PgvectorStore) Upsert(ctx context.Context, namespace string, records []Record) error {
+	if len(records) == 0 {
+		return nil
+	}
+
+	texts := make([]string, len(records))
+	for i, record := range records {
+		texts[i] = record.Text
+	}
+	embeddings, err := p.embedder.Embed(ctx, texts)
+	if err != nil {
+		return fmt.Errorf("Embed: %w", err)
+	}
+
+	return p.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for i, record := range records {
+			fields, err := json.Marshal(nonNilFields(record.Fields))
+			if err != nil {
+				return fmt.Errorf("marshal fields: %w", err)
+			}
+			err = tx.Exec(
+				`INSERT INTO vector_records (namespace, id, content, fields, embedding)
+				VALUES (?, ?, ?, ?::jsonb, ?::vector)
+				ON CONFLICT (namespace, id) DO UPDATE
+				SET content = EXCLUDED.content, fields = EXCLUDED.fields, embedding = EXCLUDED.embedding`,
+				namespace, record.ID, record.Text, string(fields), vectorLiteral(embeddings[i]),
+			).Error
+			if err != nil {
+				return fmt.Errorf("upsert vector record: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+// Search ranks by cosine similarity. There is no reranker, so TopN only trims the result.
+// The scan settings only last for the search's transaction.
+func (p *PgvectorStore) Search(ctx context.Context, namespace string, query string, opts SearchOptions) ([]Hit, error) {
+	limit := opts.TopK
+	if limit == 0 {
+		limit = 5
+	}
+	if opts.TopN > 0 && opts.TopN < limit {
+		limit = opts.TopN
+	}
+
+	embeddings, err := p.embedder.Embed(ctx, []string{query})
+	if err != nil {
+		return nil, fmt.Errorf("Embed: %w", err)
+	}
+	vector := vectorLiteral(embeddings[0])
+
+	filter, err := json.Marshal(nonNilFields(opts.Filter))
+	if err != nil {
+		return nil, fmt.Errorf("marshal filter: %w", err)
+	}
+
+	var rows []vectorRow
+	err = p.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for _, stmt := range p.scanSettings {
+			if err := tx.Exec(stmt).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Raw(
+			`SELECT id, content, fields::text AS fields, 1 - (embedding <=> ?::vector) AS score
+			FROM vector_records
+			WHERE namespace = ? AND fields @> ?::jsonb
+			ORDER BY embedding <=> ?::vector
+			LIMIT ?`,
+			vector, namespace, string(filter), vector, limit,
+		).Scan(&rows).Error
+	})
+	if err != nil {
+		return nil, fmt.Errorf("search vector records: %w", err)
+	}
+
+	hits := make([]Hit, len(rows))
+	for i, row := range rows {
+		record, err := row.toRecord()
+		if err != nil {
+			return nil, err
+		}
+		hits[i] = Hit{Record: record, Score: row.Score}
+	}
+	return hits, nil
+}
+
+func (p *PgvectorStore) Delete(ctx context.Context, namespace string, ids []string) error {
+	err := p.db.WithContext(ctx).Exec(`DELETE FROM vector_records WHERE namespace = ? AND id IN ?`, namespace, ids).Error
+	if err != nil {
+		return fmt.Errorf("delete vector records: %w", err)
+	}
+	return nil
+}
+
+func (p *PgvectorStore) Fetch(ctx context.Context, namespace string, ids []string) ([]Record, error) {
+	var rows []vectorRow
+	err := p.db.WithContext(ctx).Raw(
+		`SELECT id, content, fields::text AS fields FROM vector_records WHERE namespace = ? AND id IN ?`,
+		namespace, ids,
+	).Scan(&rows).Error
+	if err != nil {
+		return nil, fmt.Errorf("fetch vector records: %w", err)
+	}
+
+	records := make([]Record, len(rows))
+	for i, row := range rows {
+		records[i], err = row.toRecord()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return records, nil
+}
+
+func (r vectorRow) toRecord() (Record, error) {
+	fields := map[string]string{}
+	if err := json.Unmarshal([]byte(r.Fields), &fields); err != nil {
+		return Record{}, fmt.Errorf("unmarshal fields: %w", err)
+	}
+	return Record{ID: r.ID, Text: r.Content, Fields: fields}, nil
+}
+
+func nonNilFields(fields map[string]string) map[string]string {
+	if fields == nil {
+		return map[string]string{}
+	}
+	return fields
+}
+
+// vectorLiteral formats an embedding in pgvector's text representation, e.g. "[0.1,0.2]".
+func vectorLiteral(v []float32) string {
+	var b strings.Builder
+	b.WriteByte('[')
+	for i, f := range v {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(strconv.FormatFloat(float64(f), 'f', -1, 32))
+	}
+	b.WriteByte(']')
+	return b.String()
+}
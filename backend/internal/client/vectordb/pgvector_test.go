@@ -0,0 +1,78 @@
+package vectordb
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+
+	"patient-chatbot/internal/config"
+)
+
+// newTestPgvectorStore connects to TEST_DB_URL, skipping the test when it is not set
+// outside CI, like the service tests do.
+func newTestPgvectorStore(t *testing.T) *PgvectorStore {
+	t.Helper()
+	dbURL := os.Getenv("TEST_DB_URL")
+	if dbURL == "" {
+		if os.Getenv("CI") != "" {
+			t.Fatal("TEST_DB_URL must be set in CI")
+		}
+		t.Skip("TEST_DB_URL not set")
+	}
+
+	store, err := NewPgvectorStore(&config.Config{DBURL: dbURL}, NewLocalEmbedder())
+	if err != nil {
+		t.Fatalf("NewPgvectorStore: %v", err)
+	}
+	return store
+}
+
+func TestSupportsIterativeScan(t *testing.T) {
+	for version, want := range map[string]bool{"0.7.4": false, "0.8.0": true, "0.10.1": true, "1.0": true, "": false} {
+		if got := supportsIterativeScan(version); got != want {
+			t.Errorf("supportsIterativeScan(%q) = %v, want %v", version, got, want)
+		}
+	}
+}
+
+func TestPgvectorSearchFindsRecordsInASmallNamespace(t *testing.T) {
+	store := newTestPgvectorStore(t)
+	ctx := context.Background()
+	large, small := t.Name()+"/large", t.Name()+"/small"
+	t.Cleanup(func() {
+		store.db.Exec(`DELETE FROM vector_records WHERE namespace IN ?`, []string{large, small})
+	})
+
+	var records []Record
+	for i := 0; i < 2000; i++ {
+		records = append(records, Record{ID: fmt.Sprint(i), Text: fmt.Sprintf("Cravings usually pass within minutes, tip %d", i)})
+	}
+	if err := store.Upsert(ctx, large, records); err != nil {
+		t.Fatalf("Upsert: %v", err)
+	}
+	err := store.Upsert(ctx, small, []Record{
+		{ID: "a", Text: "Drink a glass of water", Fields: map[string]string{"category": "tips"}},
+		{ID: "b", Text: "Go for a short walk", Fields: map[string]string{"category": "tips"}},
+		{ID: "c", Text: "Call a friend", Fields: map[string]string{"category": "support"}},
+	})
+	if err != nil {
+		t.Fatalf("Upsert: %v", err)
+	}
+
+	hits, err := store.Search(ctx, small, "Cravings usually pass within minutes", SearchOptions{TopK: 3})
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	if len(hits) != 3 {
+		t.Fatalf("got %d hits from the small namespace, want all 3", len(hits))
+	}
+
+	hits, err = store.Search(ctx, small, "Cravings usually pass within minutes", SearchOptions{TopK: 3, Filter: map[string]string{"category": "tips"}})
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	if len(hits) != 2 {
+		t.Fatalf("got %d hits with the filter, want 2", len(hits))
+	}
+}
@@ -0,0 +1,146 @@
+package vectordb
+
+import (
+	"context"
+	"fmt"
+
+	"patient-chatbot/internal/config"
+
+	"github.com/pinecone-io/go-pinecone/v4/pinecone"
+)
+
+type PineconeStore struct {
+	idxConnection *pinecone.IndexConnection
+}
+
+func NewPineconeStore(cfg *config.Config) (*PineconeStore, error) {
+	pc, err := pinecone.NewClient(pinecone.NewClientParams{
+		ApiKey: cfg.PineconeAPIKey,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("pinecone NewClient: %w", err)
+	}
+
+	conn, err := pc.Index(pinecone.NewIndexConnParams{
+		Host: cfg.PineconeHost,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("IndexConnection: %w", err)
+	}
+
+	return &PineconeStore{
+		idxConnection: conn,
+	}, nil
+}
+
+func (p *PineconeStore) Search(ctx context.Context, namespace string, query string, opts SearchOptions) ([]Hit, error) {
+	k := opts.TopK
+	if k == 0 {
+		k = 5
+	}
+
+	req := &pinecone.SearchRecordsRequest{
+		Query: pinecone.SearchRecordsQuery{
+			TopK: int32(k),
+			Inputs: &map[string]interface{}{
+				"text": query,
+			},
+		},
+		Fields: &[]string{"chunk_text", "category", "document_id"},
+	}
+	if opts.TopN > 0 {
+		topN := int32(opts.TopN)
+		req.Rerank = &pinecone.SearchRecordsRerank{
+			Model:      "bge-reranker-v2-m3",
+			TopN:       &topN,
+			RankFields: []string{"chunk_text"},
+		}
+	}
+	if len(opts.Filter) > 0 {
+		filter := make(map[string]interface{}, len(opts.Filter))
+		for field, value := range opts.Filter {
+			filter[field] = map[string]interface{}{"$eq": value}
+		}
+		req.Query.Filter = &filter
+	}
+
+	res, err := p.idxConnection.WithNamespace(namespace).SearchRecords(ctx, req)
+	if err != nil {
+		return nil, fmt.Errorf("SearchRecords: %w", err)
+	}
+
+	hits := make([]Hit, len(res.Result.Hits))
+	for i, hit := range res.Result.Hits {
+		hits[i] = Hit{
+			Record: toRecord(hit.Id, hit.Fields),
+			Score:  hit.Score,
+		}
+	}
+	return hits, nil
+}
+
+func (p *PineconeStore) Upsert(ctx context.Context, namespace string, records []Record) error {
+	integrated := make([]*pinecone.IntegratedRecord, len(records))
+	for i, record := range records {
+		r := pinecone.IntegratedRecord{
+			"id":         record.ID,
+			"chunk_text": record.Text,
+		}
+		for field, value := range record.Fields {
+			r[field] = value
+		}
+		integrated[i] = &r
+	}
+
+	err := p.idxConnection.WithNamespace(namespace).UpsertRecords(ctx, integrated)
+	if err != nil {
+		return fmt.Errorf("UpsertRecords: %w", err)
+	}
+	return nil
+}
+
+func (p *PineconeStore) Delete(ctx context.Context, namespace string, ids []string) error {
+	err := p.idxConnection.WithNamespace(namespace).DeleteVectorsById(ctx, ids)
+	if err != nil {
+		return fmt.Errorf("DeleteVectorsById: %w", err)
+	}
+	return nil
+}
+
+func (p *PineconeStore) Fetch(ctx context.Context, namespace string, ids []string) ([]Record, error) {
+	res, err := p.idxConnection.WithNamespace(namespace).FetchVectors(ctx, ids)
+	if err != nil {
+		return nil, fmt.Errorf("FetchVectors: %w", err)
+	}
+
+	records := make([]Record, 0, len(res.Vectors))
+	for _, id := range ids {
+		vector, ok := res.Vectors[id]
+		if !ok {
+			continue
+		}
+		var metadata map[string]interface{}
+		if vector.Metadata != nil {
+			metadata = vector.Metadata.AsMap()
+		}
+		records = append(records, toRecord(vector.Id, metadata))
+	}
+	return records, nil
+}
+
+// toRecord splits Pinecone's flat field map into the record text and string metadata.
+func toRecord(id string, fields map[string]interface{}) Record {
+	record := Record{ID: id, Fields: map[string]string{}}
+	for field, value := range fields {
+		s, ok := value.(string)
+		if !ok {
+			continue
+		}
+		if field == "chunk_text" {
+			record.Text = s
+			continue
+		}
+		record.Fields[field] = s
+	}
+	return record
+}
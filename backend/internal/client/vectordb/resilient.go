@@ -0,0 +1,50 @@
+package vectordb
+
+import (
+	"context"
+
+	"patient-chatbot/internal/client/resilience"
+)
+
+// resilientStore retries transient vector store failures and stops calling a store that
+// keeps failing.
+type resilientStore struct {
+	store    VectorStore
+	executor *resilience.Executor
+}
+
+func NewResilientStore(store VectorStore, executor *resilience.Executor) VectorStore {
+	return &resilientStore{store: store, executor: executor}
+}
+
+func (r *resilientStore) Upsert(ctx context.Context, namespace string, records []Record) error {
+	return r.executor.Do(ctx, func(ctx context.Context) error {
+		return r.store.Upsert(ctx, namespace, records)
+	})
+}
+
+func (r *resilientStore) Search(ctx context.Context, namespace string, query string, opts SearchOptions) ([]Hit, error) {
+	var hits []Hit
+	err := r.executor.Do(ctx, func(ctx context.Context) error {
+		var err error
+		hits, err = r.store.Search(ctx, namespace, query, opts)
+		return err
+	})
+	return hits, err
+}
+
+func (r *resilientStore) Delete(ctx context.Context, namespace string, ids []string) error {
+	return r.executor.Do(ctx, func(ctx context.Context) error {
+		return r.store.Delete(ctx, namespace, ids)
+	})
+}
+
+func (r *resilientStore) Fetch(ctx context.Context, namespace string, ids []string) ([]Record, error) {
+	var records []Record
+	err := r.executor.Do(ctx, func(ctx context.Context) error {
+		var err error
+		records, err = r.store.Fetch(ctx, namespace, ids)
+		return err
+	})
+	return records, err
+}
@@ -0,0 +1,84 @@
+package vectordb
+
+import (
+	"context"
+	"fmt"
+
+	"patient-chatbot/internal/client/resilience"
+	"patient-chatbot/internal/config"
+
+	"github.com/google/uuid"
+)
+
+const (
+	StorePinecone = "pinecone"
+	StorePgvector = "pgvector"
+	StoreMemory   = "memory"
+)
+
+// Record is a chunk of text as stored in a vector store. Fields carries flat metadata
+// (e.g. document_id, category) that can be returned with hits and used in filters.
+type Record struct {
+	ID     string
+	Text   string
+	Fields map[string]string
+}
+
+type Hit struct {
+	Record
+	Score float32
+}
+
+type SearchOptions struct {
+	// TopK is the number of nearest neighbours to retrieve.
+	TopK int
+	// TopN, when set, is the number of hits kept after reranking.
+	TopN int
+	// Filter restricts results to records whose fields equal every given value.
+	Filter map[string]string
+}
+
+// VectorStore is implemented by every retrieval backend. Namespaces isolate tenants.
+type VectorStore interface {
+	Upsert(ctx context.Context, namespace string, records []Record) error
+	Search(ctx context.Context, namespace string, query string, opts SearchOptions) ([]Hit, error)
+	Delete(ctx context.Context, namespace string, ids []string) error
+	Fetch(ctx context.Context, namespace string, ids []string) ([]Record, error)
+}
+
+// New builds the vector store selected by VECTOR_STORE. Remote stores are wrapped with the
+// configured timeouts, retries and circuit breaker.
+func New(cfg *config.Config) (VectorStore, error) {
+	var store VectorStore
+	var err error
+	switch cfg.VectorStore {
+	case StorePinecone:
+		store, err = NewPineconeStore(cfg)
+	case StorePgvector:
+		store, err = NewPgvectorStore(cfg, NewOpenAIEmbedder(cfg))
+	case StoreMemory:
+		return NewMemoryStore(NewLocalEmbedder()), nil
+	default:
+		return nil, fmt.Errorf("unknown vector store %q", cfg.VectorStore)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return NewResilientStore(store, resilience.NewExecutor("vector store", resilience.Policy{
+		Timeout:          cfg.VectorStoreTimeout,
+		MaxAttempts:      cfg.VectorStoreMaxAttempts,
+		BaseDelay:        cfg.RetryBaseDelay,
+		MaxDelay:         cfg.RetryMaxDelay,
+		FailureThreshold: cfg.BreakerFailureThreshold,
+		Cooldown:         cfg.BreakerCooldown,
+	})), nil
+}
+
+// Namespace returns the namespace holding an organization's knowledge base.
+func Namespace(prefix string, orgID uuid.UUID) string {
+	if prefix == "" {
+		return orgID.String()
+	}
+	return prefix + "-" + orgID.String()
+}
@@ -1,27 +1,74 @@
 package config
 
 import (
+	"errors"
 	"fmt"
+	"io/fs"
+	"net/netip"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
 
 type Config struct {
-	PineconeNamespace    string
-	PineconeAPIKey       string
-	PineconeHost         string
-	PineconeIndex        string
-	GroqAPIKey           string
-	LLMModel             string
-	ArabicLLMModel       string
-	MULTIMODAL_LLM_MODEL string
-	DBURL                string
+	LLMProvider              string
+	LLMBaseURL               string
+	LLMAPIKey                string
+	LLMAPIVersion            string
+	LLMTimeout               time.Duration
+	LLMMaxAttempts           int
+	VectorStoreTimeout       time.Duration
+	VectorStoreMaxAttempts   int
+	RetryBaseDelay           time.Duration
+	RetryMaxDelay            time.Duration
+	BreakerFailureThreshold  int
+	BreakerCooldown          time.Duration
+	IngestionWorkers         int
+	IngestionMaxAttempts     int
+	IngestionPollInterval    time.Duration
+	PdftoppmPath             string
+	ChunkSize                int
+	ChunkOverlap             int
+	VectorStore              string
+	PineconeNamespace        string
+	PineconeAPIKey           string
+	PineconeHost             string
+	PineconeIndex            string
+	GroqAPIKey               string
+	LLMModel                 string
+	ArabicLLMModel           string
+	MULTIMODAL_LLM_MODEL     string
+	DBURL                    string
+	JWTSecret                string
+	BootstrapToken           string
+	AccessTokenTTL           time.Duration
+	RefreshTokenTTL          time.Duration
+	EmbeddingBaseURL         string
+	EmbeddingAPIKey          string
+	EmbeddingModel           string
+	EmbeddingDimensions      int
+	PublicBaseURL            string
+	CalendarTimeout          time.Duration
+	EgressAllowlist          []netip.Prefix
+	CredentialsKey           string
+	SMTPHost                 string
+	SMTPPort                 int
+	SMTPUsername             string
+	SMTPPassword             string
+	SMTPFrom                 string
+	NotificationTimeout      time.Duration
+	NotificationMaxAttempts  int
+	NotificationPollInterval time.Duration
+	NotificationPlanInterval time.Duration
 }
 
 func Load() (*Config, error) {
+	// The .env file is optional so the server and tests can be configured from the environment alone.
 	err := godotenv.Load()
-	if err != nil {
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
 		return nil, fmt.Errorf("error loading .env file: %w", err)
 	}
 
@@ -44,20 +91,260 @@ func Load() (*Config, error) {
 		sslMode,
 	)
 
+	accessTokenTTL, err := durationEnv("ACCESS_TOKEN_TTL", 15*time.Minute)
+	if err != nil {
+		return nil, err
+	}
+	refreshTokenTTL, err := durationEnv("REFRESH_TOKEN_TTL", 30*24*time.Hour)
+	if err != nil {
+		return nil, err
+	}
+
+	embeddingDimensions, err := intEnv("EMBEDDING_DIMENSIONS", 0)
+	if err != nil {
+		return nil, err
+	}
+
+	llmTimeout, err := durationEnv("LLM_TIMEOUT", 60*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	llmMaxAttempts, err := intEnv("LLM_MAX_ATTEMPTS", 3)
+	if err != nil {
+		return nil, err
+	}
+	vectorStoreTimeout, err := durationEnv("VECTOR_STORE_TIMEOUT", 10*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	vectorStoreMaxAttempts, err := intEnv("VECTOR_STORE_MAX_ATTEMPTS", 3)
+	if err != nil {
+		return nil, err
+	}
+	retryBaseDelay, err := durationEnv("RETRY_BASE_DELAY", 500*time.Millisecond)
+	if err != nil {
+		return nil, err
+	}
+	retryMaxDelay, err := durationEnv("RETRY_MAX_DELAY", 10*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	breakerFailureThreshold, err := intEnv("BREAKER_FAILURE_THRESHOLD", 5)
+	if err != nil {
+		return nil, err
+	}
+	breakerCooldown, err := durationEnv("BREAKER_COOLDOWN", 30*time.Second)
+	if err != nil {
+		return nil, err
+	}
+
+	ingestionWorkers, err := intEnv("INGESTION_WORKERS", 2)
+	if err != nil {
+		return nil, err
+	}
+	ingestionMaxAttempts, err := intEnv("INGESTION_MAX_ATTEMPTS", 3)
+	if err != nil {
+		return nil, err
+	}
+	ingestionPollInterval, err := durationEnv("INGESTION_POLL_INTERVAL", 5*time.Second)
+	if err != nil {
+		return nil, err
+	}
+
+	calendarTimeout, err := durationEnv("CALENDAR_TIMEOUT", 10*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	egressAllowlist, err := prefixListEnv("EGRESS_ALLOWLIST")
+	if err != nil {
+		return nil, err
+	}
+
+	smtpPort, err := intEnv("SMTP_PORT", 587)
+	if err != nil {
+		return nil, err
+	}
+	notificationTimeout, err := durationEnv("NOTIFICATION_TIMEOUT", 10*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	notificationMaxAttempts, err := intEnv("NOTIFICATION_MAX_ATTEMPTS", 5)
+	if err != nil {
+		return nil, err
+	}
+	notificationPollInterval, err := durationEnv("NOTIFICATION_POLL_INTERVAL", 30*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	notificationPlanInterval, err := durationEnv("NOTIFICATION_PLAN_INTERVAL", 15*time.Minute)
+	if err != nil {
+		return nil, err
+	}
+
+	chunkSize, err := intEnv("CHUNK_SIZE", 800)
+	if err != nil {
+		return nil, err
+	}
+	chunkOverlap, err := intEnv("CHUNK_OVERLAP", 120)
+	if err != nil {
+		return nil, err
+	}
+	if chunkSize <= 0 || chunkOverlap < 0 || chunkOverlap >= chunkSize {
+		return nil, fmt.Errorf("CHUNK_SIZE must be positive and greater than CHUNK_OVERLAP")
+	}
+
+	pdftoppmPath := os.Getenv("PDFTOPPM_PATH")
+	if pdftoppmPath == "" {
+		pdftoppmPath = "pdftoppm"
+	}
+
+	vectorStore := os.Getenv("VECTOR_STORE")
+	if vectorStore == "" {
+		vectorStore = "pinecone"
+	}
+
+	llmProvider := os.Getenv("LLM_PROVIDER")
+	if llmProvider == "" {
+		llmProvider = "groq"
+	}
+
 	cfg := &Config{
-		PineconeNamespace:    os.Getenv("PINECONE_NAMESPACE"),
-		PineconeAPIKey:       os.Getenv("PINECONE_API_KEY"),
-		PineconeIndex:        os.Getenv("PINECONE_INDEX"),
-		PineconeHost:         os.Getenv("PINECONE_HOST"),
-		GroqAPIKey:           os.Getenv("GROQ_API_KEY"),
-		LLMModel:             os.Getenv("LLM_MODEL"),
-		ArabicLLMModel:       os.Getenv("ARABIC_LLM_MODEL"),
-		MULTIMODAL_LLM_MODEL: os.Getenv("MULTIMODAL_LLM_MODEL"),
-		DBURL:                dbURL,
-	}
-
-	if cfg.PineconeAPIKey == "" || cfg.PineconeIndex == "" || cfg.PineconeHost == "" || cfg.GroqAPIKey == "" || cfg.LLMModel == "" || cfg.ArabicLLMModel == "" || cfg.MULTIMODAL_LLM_MODEL == "" {
+		LLMProvider:              llmProvider,
+		LLMBaseURL:               os.Getenv("LLM_BASE_URL"),
+		LLMAPIKey:                os.Getenv("LLM_API_KEY"),
+		LLMAPIVersion:            os.Getenv("LLM_API_VERSION"),
+		LLMTimeout:               llmTimeout,
+		LLMMaxAttempts:           llmMaxAttempts,
+		VectorStoreTimeout:       vectorStoreTimeout,
+		VectorStoreMaxAttempts:   vectorStoreMaxAttempts,
+		RetryBaseDelay:           retryBaseDelay,
+		RetryMaxDelay:            retryMaxDelay,
+		BreakerFailureThreshold:  breakerFailureThreshold,
+		BreakerCooldown:          breakerCooldown,
+		IngestionWorkers:         ingestionWorkers,
+		IngestionMaxAttempts:     ingestionMaxAttempts,
+		IngestionPollInterval:    ingestionPollInterval,
+		PdftoppmPath:             pdftoppmPath,
+		ChunkSize:                chunkSize,
+		ChunkOverlap:             chunkOverlap,
+		VectorStore:              vectorStore,
+		PineconeNamespace:        os.Getenv("PINECONE_NAMESPACE"),
+		PineconeAPIKey:           os.Getenv("PINECONE_API_KEY"),
+		PineconeIndex:            os.Getenv("PINECONE_INDEX"),
+		PineconeHost:             os.Getenv("PINECONE_HOST"),
+		GroqAPIKey:               os.Getenv("GROQ_API_KEY"),
+		LLMModel:                 os.Getenv("LLM_MODEL"),
+		ArabicLLMModel:           os.Getenv("ARABIC_LLM_MODEL"),
+		MULTIMODAL_LLM_MODEL:     os.Getenv("MULTIMODAL_LLM_MODEL"),
+		DBURL:                    dbURL,
+		JWTSecret:                os.Getenv("JWT_SECRET"),
+		BootstrapToken:           os.Getenv("BOOTSTRAP_TOKEN"),
+		AccessTokenTTL:           accessTokenTTL,
+		RefreshTokenTTL:          refreshTokenTTL,
+		EmbeddingBaseURL:         os.Getenv("EMBEDDING_BASE_URL"),
+		EmbeddingAPIKey:          os.Getenv("EMBEDDING_API_KEY"),
+		EmbeddingModel:           os.Getenv("EMBEDDING_MODEL"),
+		EmbeddingDimensions:      embeddingDimensions,
+		PublicBaseURL:            strings.TrimSuffix(os.Getenv("PUBLIC_BASE_URL"), "/"),
+		CalendarTimeout:          calendarTimeout,
+		EgressAllowlist:          egressAllowlist,
+		CredentialsKey:           os.Getenv("CREDENTIALS_KEY"),
+		SMTPHost:                 os.Getenv("SMTP_HOST"),
+		SMTPPort:                 smtpPort,
+		SMTPUsername:             os.Getenv("SMTP_USERNAME"),
+		SMTPPassword:             os.Getenv("SMTP_PASSWORD"),
+		SMTPFrom:                 os.Getenv("SMTP_FROM"),
+		NotificationTimeout:      notificationTimeout,
+		NotificationMaxAttempts:  notificationMaxAttempts,
+		NotificationPollInterval: notificationPollInterval,
+		NotificationPlanInterval: notificationPlanInterval,
+	}
+
+	switch cfg.VectorStore {
+	case "pinecone":
+		if cfg.PineconeAPIKey == "" || cfg.PineconeIndex == "" || cfg.PineconeHost == "" {
+			return nil, fmt.Errorf("missing required pinecone environment variables")
+		}
+	case "pgvector":
+		if cfg.EmbeddingBaseURL == "" || cfg.EmbeddingModel == "" || cfg.EmbeddingDimensions <= 0 {
+			return nil, fmt.Errorf("missing required embedding environment variables")
+		}
+	case "memory":
+	default:
+		return nil, fmt.Errorf("invalid VECTOR_STORE %q", cfg.VectorStore)
+	}
+
+	switch cfg.LLMProvider {
+	case "groq":
+		if cfg.GroqAPIKey == "" {
+			return nil, fmt.Errorf("missing required groq environment variables")
+		}
+	case "openai":
+		if cfg.LLMBaseURL == "" {
+			return nil, fmt.Errorf("missing required LLM_BASE_URL")
+		}
+	case "ollama", "fake":
+	default:
+		return nil, fmt.Errorf("invalid LLM_PROVIDER %q", cfg.LLMProvider)
+	}
+
+	if cfg.LLMProvider != "fake" && (cfg.LLMModel == "" || cfg.ArabicLLMModel == "" || cfg.MULTIMODAL_LLM_MODEL == "") {
+		return nil, fmt.Errorf("missing required LLM model environment variables")
+	}
+
+	if cfg.JWTSecret == "" {
 		return nil, fmt.Errorf("missing required environment variables")
 	}
+	if cfg.SMTPHost != "" && cfg.SMTPFrom == "" {
+		return nil, fmt.Errorf("missing required SMTP_FROM")
+	}
 	return cfg, nil
 }
+
+// intEnv reads an integer from the environment, falling back to def when unset.
+func intEnv(key string, def int) (int, error) {
+	v := os.Getenv(key)
+	if v == "" {
+		return def, nil
+	}
+	i, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %w", key, err)
+	}
+	return i, nil
+}
+
+// durationEnv reads a Go duration (e.g. "15m", "720h") from the environment, falling back to def when unset.
+func durationEnv(key string, def time.Duration) (time.Duration, error) {
+	v := os.Getenv(key)
+	if v == "" {
+		return def, nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %w", key, err)
+	}
+	return d, nil
+}
+
+// prefixListEnv reads a comma-separated list of CIDR prefixes or single IPs from the
+// environment, e.g. "10.20.0.0/16,192.168.1.5".
+func prefixListEnv(key string) ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+	for _, entry := range strings.Split(os.Getenv(key), ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		prefix, err := netip.ParsePrefix(entry)
+		if err != nil {
+			addr, addrErr := netip.ParseAddr(entry)
+			if addrErr != nil {
+				return nil, fmt.Errorf("invalid %s: %w", key, err)
+			}
+			prefix = netip.PrefixFrom(addr, addr.BitLen())
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes, nil
+}
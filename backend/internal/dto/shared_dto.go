@@ -1,6 +1,9 @@
 package dto
 
-import "patient-chatbot/internal/repository"
+import (
+	"patient-chatbot/internal/repository"
+	"time"
+)
 
 type Role string
 
@@ -10,22 +13,94 @@ const (
 	SystemRole    Role = "system"
 )
 
+// Message is a chat turn. Clients may only send user and assistant turns.
 type Message struct {
-	Role    Role   `json:"role"`
+	Role    Role   `json:"role" binding:"oneof=user assistant"`
 	Content string `json:"content"`
 }
 
+type ChatAnswer struct {
+	Answer  string   `json:"answer"`
+	Sources []Source `json:"sources"`
+	// Degraded is set when the LLM was unavailable and the answer quotes the sources directly.
+	Degraded bool `json:"degraded"`
+}
+
+// Source identifies a knowledge-base chunk that was supplied to the model for an answer.
+type Source struct {
+	ChunkID       string  `json:"chunk_id"`
+	DocumentID    string  `json:"document_id"`
+	DocumentTitle string  `json:"document_title"`
+	Category      string  `json:"category"`
+	Page          *int    `json:"page,omitempty"`
+	Score         float32 `json:"score"`
+	Snippet       string  `json:"snippet"`
+}
+
 type DashboardData struct {
 	TotalMoneySaved     int `json:"total_money_saved"`
 	TotalDaysSmokeFree  int `json:"total_days_smoke_free"`
 	StreakDaysSmokeFree int `json:"streak_days_smoke_free"`
 }
 
+type AuthTokens struct {
+	AccessToken      string    `json:"access_token"`
+	AccessExpiresAt  time.Time `json:"access_expires_at"`
+	RefreshToken     string    `json:"refresh_token"`
+	RefreshExpiresAt time.Time `json:"refresh_expires_at"`
+}
+
 type FullMonthProgressEvents struct {
 	Date   string                         `json:"date"`
 	Status repository.ProgressEventStatus `json:"status"`
 }
 
+// CopingStrategy is a coping strategy in the user's language. Effectiveness is the
+// percentage of the user's cravings it helped them resist, or nil if they have not tried it.
+type CopingStrategy struct {
+	Key           string   `json:"key"`
+	Title         string   `json:"title"`
+	Description   string   `json:"description"`
+	Steps         []string `json:"steps"`
+	EstimatedTime string   `json:"estimated_time"`
+	Source        string   `json:"source"`
+	Effectiveness *int     `json:"effectiveness"`
+	TimesTried    int      `json:"times_tried"`
+}
+
+// Milestone is a health milestone in the user's language and how close they are to it.
+// AchievedAt is nil until it is reached.
+type Milestone struct {
+	Key              string     `json:"key"`
+	Title            string     `json:"title"`
+	Description      string     `json:"description"`
+	Category         string     `json:"category"`
+	AfterMinutes     int        `json:"after_minutes"`
+	Achieved         bool       `json:"achieved"`
+	AchievedAt       *time.Time `json:"achieved_at"`
+	RemainingSeconds int64      `json:"remaining_seconds"`
+	Percentage       int        `json:"percentage"`
+}
+
+type MilestoneTimeline struct {
+	QuitDate   *time.Time  `json:"quit_date"`
+	Milestones []Milestone `json:"milestones"`
+}
+
+// Achievement is an achievement in the user's language. Progress is the user's value for
+// its metric, capped at Target.
+type Achievement struct {
+	Key         string     `json:"key"`
+	Title       string     `json:"title"`
+	Description string     `json:"description"`
+	Metric      string     `json:"metric"`
+	Target      int        `json:"target"`
+	Progress    int        `json:"progress"`
+	Percentage  int        `json:"percentage"`
+	Unlocked    bool       `json:"unlocked"`
+	UnlockedAt  *time.Time `json:"unlocked_at"`
+}
+
 /*
 GET /api/v1/dashboard
 response example:
@@ -0,0 +1,165 @@
+package extract
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// DOCX extracts the body text of a Word document as a single page. Headings are marked with
+// Markdown hashes and list items with a dash; each table row becomes a line of cells
+// separated by " | ". Headers, footers and comments are left out.
+func DOCX(data []byte) (*Document, error) {
+	pkg, err := openOOXML(data)
+	if err != nil {
+		return nil, err
+	}
+	content, err := pkg.read(pkg.mainPart("word/document.xml"))
+	if err != nil {
+		return nil, fmt.Errorf("read docx: %w", err)
+	}
+
+	text, err := docxText(content)
+	if err != nil {
+		return nil, fmt.Errorf("parse docx: %w", err)
+	}
+	return &Document{Pages: []Page{{Text: text}}}, nil
+}
+
+// mainPart returns the part the package's root relationships point to as the main document.
+func (p *ooxmlPackage) mainPart(fallback string) string {
+	rels, err := p.relationships("")
+	if err != nil {
+		return fallback
+	}
+	for _, rel := range rels {
+		if strings.HasSuffix(rel.Type, "/officeDocument") {
+			return rel.Target
+		}
+	}
+	return fallback
+}
+
+func docxText(content []byte) (string, error) {
+	var (
+		blocks     []string
+		para       strings.Builder
+		prefix     string
+		paraDepth  int
+		inRun      bool
+		inText     bool
+		tableDepth int
+		cell       []string
+		row        []string
+		rows       []string
+	)
+
+	decoder := xml.NewDecoder(bytes.NewReader(content))
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "Fallback":
+				// Alternate content repeats the text of its preferred choice.
+				if err := decoder.Skip(); err != nil {
+					return "", err
+				}
+			case "p":
+				// Text boxes nest paragraphs inside a paragraph; their text joins the outer one.
+				if paraDepth == 0 {
+					para.Reset()
+					prefix = ""
+				}
+				paraDepth++
+			case "pStyle":
+				prefix = headingPrefix(attr(t.Attr, "val"))
+			case "numPr":
+				if prefix == "" {
+					prefix = "- "
+				}
+			case "r":
+				inRun = true
+			case "t":
+				inText = true
+			case "tab":
+				if inRun {
+					para.WriteByte('\t')
+				}
+			case "br", "cr":
+				if inRun {
+					para.WriteByte('\n')
+				}
+			case "tbl":
+				tableDepth++
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "p":
+				paraDepth--
+				if paraDepth > 0 {
+					continue
+				}
+				text := strings.TrimSpace(para.String())
+				if text == "" {
+					continue
+				}
+				if tableDepth > 0 {
+					cell = append(cell, text)
+				} else {
+					blocks = append(blocks, prefix+text)
+				}
+			case "r":
+				inRun = false
+			case "t":
+				inText = false
+			case "tc":
+				row = append(row, strings.Join(cell, " "))
+				cell = nil
+			case "tr":
+				if strings.TrimSpace(strings.Join(row, "")) != "" {
+					rows = append(rows, strings.Join(row, " | "))
+				}
+				row = nil
+			case "tbl":
+				tableDepth--
+				if tableDepth == 0 {
+					blocks = append(blocks, strings.Join(rows, "\n"))
+					rows = nil
+				}
+			}
+		case xml.CharData:
+			if inText {
+				para.Write(t)
+			}
+		}
+	}
+	return textBlocks(blocks), nil
+}
+
+// headingPrefix maps a paragraph style such as "Heading2" or "Title" to a Markdown heading marker.
+func headingPrefix(style string) string {
+	style = strings.ToLower(strings.ReplaceAll(style, " ", ""))
+	if style == "title" {
+		return "# "
+	}
+	level, ok := strings.CutPrefix(style, "heading")
+	if !ok {
+		return ""
+	}
+	n, err := strconv.Atoi(level)
+	if err != nil || n < 1 {
+		n = 1
+	}
+	return strings.Repeat("#", min(n, 6)) + " "
+}
@@ -0,0 +1,120 @@
+// Package extract turns uploaded files into plain text, page by page where the format has pages.
+package extract
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	MimePDF  = "application/pdf"
+	MimeDOCX = "application/vnd.openxmlformats-officedocument.wordprocessingml.document"
+	MimeXLSX = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	MimePPTX = "application/vnd.openxmlformats-officedocument.presentationml.presentation"
+	MimeDOC  = "application/msword"
+	MimeXLS  = "application/vnd.ms-excel"
+	MimePPT  = "application/vnd.ms-powerpoint"
+	MimeCSV  = "text/csv"
+	MimeHTML = "text/html"
+	MimeText = "text/plain"
+)
+
+var ErrUnsupportedFormat = errors.New("unsupported file format")
+
+// Page is the text of one page. Number is 1-based, or 0 for formats without pages.
+type Page struct {
+	Number int
+	Text   string
+	// Scanned is set when the page carries too little text to be digital, e.g. a scanned
+	// image. Its text has to be recovered by rasterizing the page and running OCR.
+	Scanned bool
+}
+
+type Document struct {
+	Pages []Page
+}
+
+// DetectMimeType returns the MIME type of an uploaded file, without parameters. Office
+// formats are zip archives and CSV sniffs as plain text, so the extension takes precedence
+// over the content for the formats it identifies.
+func DetectMimeType(ext string, data []byte) string {
+	switch strings.ToLower(ext) {
+	case ".pdf":
+		return MimePDF
+	case ".docx":
+		return MimeDOCX
+	case ".xlsx":
+		return MimeXLSX
+	case ".pptx":
+		return MimePPTX
+	case ".doc":
+		return MimeDOC
+	case ".xls":
+		return MimeXLS
+	case ".ppt":
+		return MimePPT
+	case ".csv":
+		return MimeCSV
+	case ".html", ".htm":
+		return MimeHTML
+	case ".txt", ".md":
+		return MimeText
+	}
+
+	mimeType, _, err := mime.ParseMediaType(http.DetectContentType(data))
+	if err != nil {
+		return "application/octet-stream"
+	}
+	return mimeType
+}
+
+// Supported reports whether Extract can read files of mimeType.
+func Supported(mimeType string) bool {
+	switch mimeType {
+	case MimePDF, MimeDOCX, MimeXLSX, MimePPTX:
+		return true
+	}
+	return strings.HasPrefix(mimeType, "text/")
+}
+
+// Extract reads a file of any supported format. Legacy binary Office formats are not
+// supported and return ErrUnsupportedFormat.
+func Extract(mimeType string, data []byte) (*Document, error) {
+	switch {
+	case mimeType == MimePDF:
+		return PDF(data)
+	case mimeType == MimeDOCX:
+		return DOCX(data)
+	case mimeType == MimeXLSX:
+		return XLSX(data)
+	case mimeType == MimePPTX:
+		return PPTX(data)
+	case mimeType == MimeCSV:
+		return CSV(data)
+	case mimeType == MimeHTML:
+		return HTML(data)
+	case strings.HasPrefix(mimeType, "text/"):
+		return Text(data), nil
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedFormat, mimeType)
+	}
+}
+
+// Text returns a plain text file as a single page.
+func Text(data []byte) *Document {
+	return &Document{Pages: []Page{{Text: decodeText(data)}}}
+}
+
+// decodeText drops a UTF-8 byte order mark and replaces invalid sequences.
+func decodeText(data []byte) string {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	if utf8.Valid(data) {
+		return string(data)
+	}
+	return strings.ToValidUTF8(string(data), "�")
+}
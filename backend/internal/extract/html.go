@@ -0,0 +1,254 @@
+package extract
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// htmlBoilerplate lists elements that never carry document content.
+var htmlBoilerplate = map[atom.Atom]bool{
+	atom.Script:   true,
+	atom.Style:    true,
+	atom.Noscript: true,
+	atom.Template: true,
+	atom.Nav:      true,
+	atom.Footer:   true,
+	atom.Aside:    true,
+	atom.Form:     true,
+	atom.Button:   true,
+	atom.Select:   true,
+	atom.Iframe:   true,
+	atom.Svg:      true,
+	atom.Canvas:   true,
+	atom.Dialog:   true,
+}
+
+// htmlBoilerplateRoles lists ARIA landmark roles that mark site chrome rather than content.
+var htmlBoilerplateRoles = map[string]bool{
+	"navigation":    true,
+	"banner":        true,
+	"contentinfo":   true,
+	"complementary": true,
+	"search":        true,
+	"dialog":        true,
+}
+
+var htmlBlocks = map[atom.Atom]bool{
+	atom.P: true, atom.Div: true, atom.Section: true, atom.Article: true, atom.Main: true,
+	atom.Blockquote: true, atom.Pre: true, atom.Figure: true, atom.Figcaption: true,
+	atom.Ul: true, atom.Ol: true, atom.Li: true, atom.Dl: true, atom.Dt: true, atom.Dd: true,
+	atom.Table: true, atom.Tr: true, atom.Caption: true, atom.Hr: true, atom.Address: true,
+	atom.Details: true, atom.Summary: true,
+}
+
+// HTML extracts the main content of a web page as a single page. Scripts, navigation,
+// headers, footers, sidebars and forms are dropped, and the <main> or sole <article>
+// element is preferred over the whole body when the page has one. Headings are marked with
+// Markdown hashes, list items with a dash, and table rows become lines of cells separated by " | ".
+func HTML(data []byte) (*Document, error) {
+	root, err := html.Parse(strings.NewReader(decodeText(data)))
+	if err != nil {
+		return nil, fmt.Errorf("parse html: %w", err)
+	}
+
+	content := htmlContentRoot(root)
+	// Inside <main> or <article>, a <header> holds the title rather than the site banner.
+	w := &htmlWriter{keepHeaders: content.DataAtom == atom.Main || content.DataAtom == atom.Article}
+	w.walk(content)
+	w.endBlock()
+	return &Document{Pages: []Page{{Text: textBlocks(w.blocks)}}}, nil
+}
+
+// htmlContentRoot returns the element holding the page's main content.
+func htmlContentRoot(root *html.Node) *html.Node {
+	var main, body *html.Node
+	var articles []*html.Node
+	var find func(n *html.Node)
+	find = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			switch {
+			case n.DataAtom == atom.Main || htmlAttr(n, "role") == "main":
+				if main == nil {
+					main = n
+				}
+			case n.DataAtom == atom.Article:
+				articles = append(articles, n)
+			case n.DataAtom == atom.Body:
+				body = n
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			find(c)
+		}
+	}
+	find(root)
+
+	switch {
+	case main != nil:
+		return main
+	case len(articles) == 1:
+		return articles[0]
+	case body != nil:
+		return body
+	default:
+		return root
+	}
+}
+
+type htmlWriter struct {
+	blocks []string
+	line   strings.Builder
+	inPre  bool
+	inCell bool
+	row    []string
+	rows   []string
+
+	keepHeaders bool
+}
+
+func (w *htmlWriter) walk(n *html.Node) {
+	switch n.Type {
+	case html.TextNode:
+		w.text(n.Data)
+		return
+	case html.ElementNode, html.DocumentNode:
+	default:
+		return
+	}
+
+	if n.Type == html.ElementNode && (htmlHidden(n) || n.DataAtom == atom.Header && !w.keepHeaders) {
+		return
+	}
+
+	switch n.DataAtom {
+	case atom.Br:
+		w.line.WriteByte('\n')
+		return
+	case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6:
+		w.endBlock()
+		w.line.WriteString(strings.Repeat("#", int(n.Data[1]-'0')) + " ")
+	case atom.Li:
+		w.endBlock()
+		w.line.WriteString("- ")
+	case atom.Pre:
+		w.endBlock()
+		w.inPre = true
+	case atom.Td, atom.Th:
+		w.line.Reset()
+		w.inCell = true
+	default:
+		if htmlBlocks[n.DataAtom] {
+			w.endBlock()
+		}
+	}
+
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		w.walk(c)
+	}
+
+	switch n.DataAtom {
+	case atom.Td, atom.Th:
+		w.row = append(w.row, w.line.String())
+		w.line.Reset()
+		w.inCell = false
+	case atom.Tr:
+		if cells := trimCells(w.row); cells != nil {
+			w.rows = append(w.rows, joinCells(cells))
+		}
+		w.row = nil
+	case atom.Table:
+		w.endBlock()
+		w.blocks = append(w.blocks, strings.Join(w.rows, "\n"))
+		w.rows = nil
+	case atom.Pre:
+		w.endBlock()
+		w.inPre = false
+	case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6, atom.Li:
+		w.endBlock()
+	default:
+		if htmlBlocks[n.DataAtom] {
+			w.endBlock()
+		}
+	}
+}
+
+// text appends inline text, collapsing runs of whitespace outside <pre>.
+func (w *htmlWriter) text(s string) {
+	if w.inPre {
+		w.line.WriteString(s)
+		return
+	}
+	fields := strings.Fields(s)
+	if len(fields) == 0 {
+		if s != "" && w.line.Len() > 0 {
+			w.line.WriteByte(' ')
+		}
+		return
+	}
+	if startsWithSpace(s) && w.line.Len() > 0 {
+		w.line.WriteByte(' ')
+	}
+	w.line.WriteString(strings.Join(fields, " "))
+	if endsWithSpace(s) {
+		w.line.WriteByte(' ')
+	}
+}
+
+// endBlock finishes the current paragraph. Inside a table cell, block elements only
+// separate words, so that the cell stays on its row's line.
+func (w *htmlWriter) endBlock() {
+	if w.inCell {
+		w.line.WriteByte(' ')
+		return
+	}
+	text := w.line.String()
+	w.line.Reset()
+	if !w.inPre {
+		text = collapseLines(text)
+	}
+	if strings.TrimSpace(strings.TrimLeft(text, "#- ")) != "" {
+		w.blocks = append(w.blocks, text)
+	}
+}
+
+// collapseLines trims each line and drops doubled spaces left where inline elements met.
+func collapseLines(text string) string {
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		lines[i] = strings.Join(strings.Fields(line), " ")
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
+
+// htmlHidden reports whether an element is boilerplate or hidden from readers.
+func htmlHidden(n *html.Node) bool {
+	if htmlBoilerplate[n.DataAtom] || htmlBoilerplateRoles[htmlAttr(n, "role")] || htmlAttr(n, "aria-hidden") == "true" {
+		return true
+	}
+	for _, a := range n.Attr {
+		if a.Key == "hidden" {
+			return true
+		}
+	}
+	return false
+}
+
+func htmlAttr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+func startsWithSpace(s string) bool {
+	return s != "" && strings.TrimLeft(s, " \t\r\n\f") != s
+}
+
+func endsWithSpace(s string) bool {
+	return s != "" && strings.TrimRight(s, " \t\r\n\f") != s
+}
@@ -0,0 +1,42 @@
+package extract
+
+import "testing"
+
+func TestHTMLDropsBoilerplate(t *testing.T) {
+	page := `<!DOCTYPE html><html><head><title>Quit guide</title><style>p{color:red}</style></head>
+	<body>
+		<header><a href="/">Home</a> <a href="/about">About</a></header>
+		<nav><ul><li>Menu item</li></ul></nav>
+		<main>
+			<h1>Coping   with cravings</h1>
+			<p>Cravings <strong>usually</strong> pass
+			within five minutes.</p>
+			<ul><li>Drink water</li><li>Go for a walk</li></ul>
+			<table><tr><th>Week</th><th>Goal</th></tr><tr><td><p>1</p></td><td>Set a quit date</td></tr></table>
+			<div hidden>Hidden text</div>
+			<script>track()</script>
+		</main>
+		<aside>Related articles</aside>
+		<footer>© Clinic</footer>
+	</body></html>`
+
+	doc, err := HTML([]byte(page))
+	if err != nil {
+		t.Fatalf("HTML: %v", err)
+	}
+
+	want := "# Coping with cravings\n\nCravings usually pass within five minutes.\n\n- Drink water\n\n- Go for a walk\n\nWeek | Goal\n1 | Set a quit date"
+	if len(doc.Pages) != 1 || doc.Pages[0].Text != want {
+		t.Fatalf("got %q, want %q", doc.Pages[0].Text, want)
+	}
+}
+
+func TestHTMLWithoutMainKeepsBody(t *testing.T) {
+	doc, err := HTML([]byte(`<body><nav>Menu</nav><div>First<br>Second</div></body>`))
+	if err != nil {
+		t.Fatalf("HTML: %v", err)
+	}
+	if doc.Pages[0].Text != "First\nSecond" {
+		t.Fatalf("got %q", doc.Pages[0].Text)
+	}
+}
@@ -0,0 +1,133 @@
+package extract
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+)
+
+// maxPartSize caps the uncompressed size of a single part of an Office file, so a zip bomb
+// cannot exhaust memory.
+const maxPartSize = 64 << 20
+
+var errPartNotFound = errors.New("part not found")
+
+// ooxmlPackage is an Office Open XML file (.docx, .xlsx, .pptx): a zip archive of XML parts
+// linked by relationship parts.
+type ooxmlPackage struct {
+	files map[string]*zip.File
+}
+
+func openOOXML(data []byte) (*ooxmlPackage, error) {
+	reader, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("open office file: %w", err)
+	}
+	pkg := &ooxmlPackage{files: make(map[string]*zip.File, len(reader.File))}
+	for _, f := range reader.File {
+		pkg.files[f.Name] = f
+	}
+	return pkg, nil
+}
+
+// read returns the content of a part. name is relative to the package root, without a leading slash.
+func (p *ooxmlPackage) read(name string) ([]byte, error) {
+	f, ok := p.files[name]
+	if !ok {
+		return nil, fmt.Errorf("%s: %w", name, errPartNotFound)
+	}
+	if f.UncompressedSize64 > maxPartSize {
+		return nil, fmt.Errorf("%s: part too large", name)
+	}
+
+	rc, err := f.Open()
+	if err != nil {
+		return nil, fmt.Errorf("open %s: %w", name, err)
+	}
+	defer rc.Close()
+
+	data, err := io.ReadAll(io.LimitReader(rc, maxPartSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("read %s: %w", name, err)
+	}
+	if len(data) > maxPartSize {
+		return nil, fmt.Errorf("%s: part too large", name)
+	}
+	return data, nil
+}
+
+type relationship struct {
+	ID     string `xml:"Id,attr"`
+	Type   string `xml:"Type,attr"`
+	Target string `xml:"Target,attr"`
+	Mode   string `xml:"TargetMode,attr"`
+}
+
+// relationships returns the relationships of part keyed by ID, with targets resolved to
+// part names. External targets such as hyperlinks are skipped.
+func (p *ooxmlPackage) relationships(part string) (map[string]relationship, error) {
+	dir, file := path.Split(part)
+	data, err := p.read(dir + "_rels/" + file + ".rels")
+	if errors.Is(err, errPartNotFound) {
+		return map[string]relationship{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var rels struct {
+		Relationships []relationship `xml:"Relationship"`
+	}
+	if err := xml.Unmarshal(data, &rels); err != nil {
+		return nil, fmt.Errorf("parse relationships of %s: %w", part, err)
+	}
+
+	byID := make(map[string]relationship, len(rels.Relationships))
+	for _, rel := range rels.Relationships {
+		if rel.Mode == "External" {
+			continue
+		}
+		if strings.HasPrefix(rel.Target, "/") {
+			rel.Target = strings.TrimPrefix(rel.Target, "/")
+		} else {
+			rel.Target = path.Join(dir, rel.Target)
+		}
+		byID[rel.ID] = rel
+	}
+	return byID, nil
+}
+
+// relationshipID returns the r:id attribute of an element.
+func relationshipID(attrs []xml.Attr) string {
+	for _, attr := range attrs {
+		if attr.Name.Local == "id" && strings.HasSuffix(attr.Name.Space, "/relationships") {
+			return attr.Value
+		}
+	}
+	return ""
+}
+
+func attr(attrs []xml.Attr, local string) string {
+	for _, attr := range attrs {
+		if attr.Name.Local == local {
+			return attr.Value
+		}
+	}
+	return ""
+}
+
+// textBlocks joins non-empty blocks with blank lines, the paragraph separator used by all extractors.
+func textBlocks(blocks []string) string {
+	kept := blocks[:0:0]
+	for _, block := range blocks {
+		if block = strings.TrimSpace(block); block != "" {
+			kept = append(kept, block)
+		}
+	}
+	return strings.Join(kept, "\n\n")
+}
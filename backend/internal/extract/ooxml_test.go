@@ -0,0 +1,154 @@
+package extract
+
+import (
+	"archive/zip"
+	"bytes"
+	"strings"
+	"testing"
+)
+
+const (
+	nsW   = `xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main"`
+	nsA   = `xmlns:a="http://schemas.openxmlformats.org/drawingml/2006/main"`
+	nsP   = `xmlns:p="http://schemas.openxmlformats.org/presentationml/2006/main"`
+	nsR   = `xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"`
+	nsRel = `xmlns="http://schemas.openxmlformats.org/package/2006/relationships"`
+	relNS = "http://schemas.openxmlformats.org/officeDocument/2006/relationships/"
+)
+
+// buildZip writes an archive holding the given parts.
+func buildZip(t *testing.T, parts map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for name, content := range parts {
+		f, err := w.Create(name)
+		if err != nil {
+			t.Fatalf("create %s: %v", name, err)
+		}
+		f.Write([]byte(content))
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("close zip: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func rels(entries ...string) string {
+	return `<?xml version="1.0" encoding="UTF-8"?><Relationships ` + nsRel + `>` + strings.Join(entries, "") + `</Relationships>`
+}
+
+func rel(id, kind, target string) string {
+	return `<Relationship Id="` + id + `" Type="` + relNS + kind + `" Target="` + target + `"/>`
+}
+
+func TestDOCXExtractsParagraphsHeadingsListsAndTables(t *testing.T) {
+	body := `<w:document ` + nsW + `><w:body>
+		<w:p><w:pPr><w:pStyle w:val="Heading1"/></w:pPr><w:r><w:t>Coping with cravings</w:t></w:r></w:p>
+		<w:p><w:r><w:t xml:space="preserve">Cravings usually pass </w:t></w:r><w:r><w:t>within five minutes.</w:t></w:r></w:p>
+		<w:p><w:pPr><w:numPr><w:ilvl w:val="0"/></w:numPr></w:pPr><w:r><w:t>Drink water</w:t></w:r></w:p>
+		<w:p/>
+		<w:tbl>
+			<w:tr><w:tc><w:p><w:r><w:t>Week</w:t></w:r></w:p></w:tc><w:tc><w:p><w:r><w:t>Goal</w:t></w:r></w:p></w:tc></w:tr>
+			<w:tr><w:tc><w:p><w:r><w:t>1</w:t></w:r></w:p></w:tc><w:tc><w:p><w:r><w:t>Set a quit date</w:t></w:r></w:p></w:tc></w:tr>
+		</w:tbl>
+	</w:body></w:document>`
+	data := buildZip(t, map[string]string{
+		"_rels/.rels":       rels(rel("rId1", "officeDocument", "word/document.xml")),
+		"word/document.xml": body,
+	})
+
+	doc, err := DOCX(data)
+	if err != nil {
+		t.Fatalf("DOCX: %v", err)
+	}
+
+	want := "# Coping with cravings\n\nCravings usually pass within five minutes.\n\n- Drink water\n\nWeek | Goal\n1 | Set a quit date"
+	if len(doc.Pages) != 1 || doc.Pages[0].Text != want {
+		t.Fatalf("got %q, want %q", doc.Pages[0].Text, want)
+	}
+}
+
+func TestXLSXExtractsRowsAsTableText(t *testing.T) {
+	data := buildZip(t, map[string]string{
+		"_rels/.rels": rels(rel("rId1", "officeDocument", "xl/workbook.xml")),
+		"xl/workbook.xml": `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" ` + nsR + `><sheets>
+			<sheet name="Doses" sheetId="1" r:id="rId1"/>
+			<sheet name="Lookup" sheetId="2" state="hidden" r:id="rId2"/>
+		</sheets></workbook>`,
+		"xl/_rels/workbook.xml.rels": rels(
+			rel("rId1", "worksheet", "worksheets/sheet1.xml"),
+			rel("rId2", "worksheet", "worksheets/sheet2.xml"),
+			rel("rId3", "sharedStrings", "sharedStrings.xml"),
+		),
+		"xl/sharedStrings.xml": `<sst xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">
+			<si><t>Product</t></si><si><t>Dose</t></si><si><r><t>Nicotine </t></r><r><t>patch</t></r></si>
+		</sst>`,
+		"xl/worksheets/sheet1.xml": `<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>
+			<row r="1"><c r="A1" t="s"><v>0</v></c><c r="C1" t="s"><v>1</v></c></row>
+			<row r="2"><c r="A2" t="s"><v>2</v></c><c r="C2"><v>21</v></c></row>
+			<row r="3"><c r="A3" t="inlineStr"><is><t>Gum</t></is></c><c r="C3" t="str"><f>A1</f><v>4</v></c></row>
+		</sheetData></worksheet>`,
+		"xl/worksheets/sheet2.xml": `<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>
+			<row r="1"><c r="A1" t="inlineStr"><is><t>hidden</t></is></c></row>
+		</sheetData></worksheet>`,
+	})
+
+	doc, err := XLSX(data)
+	if err != nil {
+		t.Fatalf("XLSX: %v", err)
+	}
+
+	want := "## Doses\nProduct: Nicotine patch | Dose: 21\nProduct: Gum | Dose: 4"
+	if len(doc.Pages) != 1 || doc.Pages[0].Text != want {
+		t.Fatalf("got %q, want %q", doc.Pages[0].Text, want)
+	}
+}
+
+func TestPPTXExtractsSlidesWithNotes(t *testing.T) {
+	slide := func(title, body string) string {
+		return `<p:sld ` + nsA + ` ` + nsP + `><p:cSld><p:spTree>
+			<p:sp><p:nvSpPr><p:nvPr><p:ph type="title"/></p:nvPr></p:nvSpPr><p:txBody><a:p><a:r><a:t>` + title + `</a:t></a:r></a:p></p:txBody></p:sp>
+			<p:sp><p:nvSpPr><p:nvPr><p:ph idx="1"/></p:nvPr></p:nvSpPr><p:txBody><a:p><a:r><a:t>` + body + `</a:t></a:r></a:p></p:txBody></p:sp>
+		</p:spTree></p:cSld></p:sld>`
+	}
+	data := buildZip(t, map[string]string{
+		"_rels/.rels": rels(rel("rId1", "officeDocument", "ppt/presentation.xml")),
+		"ppt/presentation.xml": `<p:presentation ` + nsP + ` ` + nsR + `><p:sldIdLst>
+			<p:sldId id="256" r:id="rId3"/><p:sldId id="257" r:id="rId2"/>
+		</p:sldIdLst></p:presentation>`,
+		"ppt/_rels/presentation.xml.rels": rels(
+			rel("rId2", "slide", "slides/slide2.xml"),
+			rel("rId3", "slide", "slides/slide1.xml"),
+		),
+		"ppt/slides/slide1.xml":            slide("Why quit", "Lungs recover within weeks."),
+		"ppt/slides/slide2.xml":            slide("Support", "Call the quitline."),
+		"ppt/slides/_rels/slide1.xml.rels": rels(rel("rId1", "notesSlide", "../notesSlides/notesSlide1.xml")),
+		"ppt/notesSlides/notesSlide1.xml": `<p:notes ` + nsA + ` ` + nsP + `><p:cSld><p:spTree>
+			<p:sp><p:nvSpPr><p:nvPr><p:ph type="body" idx="1"/></p:nvPr></p:nvSpPr><p:txBody><a:p><a:r><a:t>Mention the two-week mark.</a:t></a:r></a:p></p:txBody></p:sp>
+			<p:sp><p:nvSpPr><p:nvPr><p:ph type="sldNum" idx="5"/></p:nvPr></p:nvSpPr><p:txBody><a:p><a:r><a:t>1</a:t></a:r></a:p></p:txBody></p:sp>
+		</p:spTree></p:cSld></p:notes>`,
+	})
+
+	doc, err := PPTX(data)
+	if err != nil {
+		t.Fatalf("PPTX: %v", err)
+	}
+
+	if len(doc.Pages) != 2 {
+		t.Fatalf("got %d slides, want 2", len(doc.Pages))
+	}
+	want := "# Why quit\n\nLungs recover within weeks.\n\nNotes:\nMention the two-week mark."
+	if doc.Pages[0].Number != 1 || doc.Pages[0].Text != want {
+		t.Fatalf("first slide = %+v, want text %q", doc.Pages[0], want)
+	}
+	if doc.Pages[1].Number != 2 || doc.Pages[1].Text != "# Support\n\nCall the quitline." {
+		t.Fatalf("unexpected second slide %+v", doc.Pages[1])
+	}
+}
+
+func TestOOXMLRejectsNonZip(t *testing.T) {
+	if _, err := DOCX([]byte("not a zip")); err == nil {
+		t.Fatal("expected an error")
+	}
+}
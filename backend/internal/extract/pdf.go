@@ -0,0 +1,98 @@
+package extract
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/ledongthuc/pdf"
+)
+
+// minPageTextRunes is the number of letters and digits below which a PDF page is treated as scanned.
+const minPageTextRunes = 20
+
+// PDF extracts the embedded text of each page.
+func PDF(data []byte) (doc *Document, err error) {
+	// The parser panics on some malformed files.
+	defer func() {
+		if r := recover(); r != nil {
+			doc, err = nil, fmt.Errorf("parse pdf: %v", r)
+		}
+	}()
+
+	reader, err := pdf.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("open pdf: %w", err)
+	}
+
+	doc = &Document{}
+	for n := 1; n <= reader.NumPage(); n++ {
+		text, err := reader.Page(n).GetPlainText(nil)
+		if err != nil {
+			return nil, fmt.Errorf("read pdf page %d: %w", n, err)
+		}
+		text = normalizePDFText(text)
+		doc.Pages = append(doc.Pages, Page{
+			Number:  n,
+			Text:    text,
+			Scanned: countTextRunes(text) < minPageTextRunes,
+		})
+	}
+	return doc, nil
+}
+
+// RasterizePDFPage renders one page to PNG with pdftoppm (poppler-utils), for OCR of scanned pages.
+func RasterizePDFPage(ctx context.Context, pdftoppm string, data []byte, page int) ([]byte, error) {
+	f, err := os.CreateTemp("", "page-*.pdf")
+	if err != nil {
+		return nil, fmt.Errorf("create temp file: %w", err)
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("write temp file: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return nil, fmt.Errorf("close temp file: %w", err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, pdftoppm,
+		"-f", strconv.Itoa(page), "-l", strconv.Itoa(page),
+		"-r", "150", "-png", "-singlefile",
+		f.Name(),
+	)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("pdftoppm page %d: %w: %s", page, err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.Bytes(), nil
+}
+
+// normalizePDFText trims each line and drops the blank lines the parser emits between text objects.
+func normalizePDFText(text string) string {
+	lines := strings.Split(text, "\n")
+	kept := lines[:0]
+	for _, line := range lines {
+		if line = strings.TrimSpace(line); line != "" {
+			kept = append(kept, line)
+		}
+	}
+	return strings.Join(kept, "\n")
+}
+
+func countTextRunes(text string) int {
+	n := 0
+	for _, r := range text {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			n++
+		}
+	}
+	return n
+}
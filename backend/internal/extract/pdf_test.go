@@ -0,0 +1,81 @@
+package extract
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// buildPDF writes a minimal PDF with one page per entry of pages, each showing its text in Helvetica.
+func buildPDF(pages []string) []byte {
+	var objects []string
+	objects = append(objects, "<< /Type /Catalog /Pages 2 0 R >>")
+
+	kids := make([]string, len(pages))
+	for i := range pages {
+		kids[i] = fmt.Sprintf("%d 0 R", 4+2*i)
+	}
+	objects = append(objects, fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(pages)))
+	objects = append(objects, "<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica >>")
+
+	for i, text := range pages {
+		objects = append(objects, fmt.Sprintf(
+			"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 612 792] /Resources << /Font << /F1 3 0 R >> >> /Contents %d 0 R >>", 5+2*i))
+		content := ""
+		if text != "" {
+			content = fmt.Sprintf("BT /F1 12 Tf 72 720 Td (%s) Tj ET", text)
+		}
+		objects = append(objects, fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", len(content), content))
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for i, object := range objects {
+		offsets[i] = buf.Len()
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", i+1, object)
+	}
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+	return buf.Bytes()
+}
+
+func TestPDFExtractsTextPerPage(t *testing.T) {
+	doc, err := PDF(buildPDF([]string{
+		"Nicotine cravings usually pass within five minutes.",
+		"",
+		"Exercise improves lung capacity after quitting.",
+	}))
+	if err != nil {
+		t.Fatalf("PDF: %v", err)
+	}
+
+	if len(doc.Pages) != 3 {
+		t.Fatalf("got %d pages, want 3", len(doc.Pages))
+	}
+	for i, page := range doc.Pages {
+		if page.Number != i+1 {
+			t.Fatalf("page %d numbered %d", i, page.Number)
+		}
+	}
+	if doc.Pages[0].Text != "Nicotine cravings usually pass within five minutes." || doc.Pages[0].Scanned {
+		t.Fatalf("unexpected first page %+v", doc.Pages[0])
+	}
+	if !doc.Pages[1].Scanned {
+		t.Fatalf("expected the empty page to be treated as scanned, got %+v", doc.Pages[1])
+	}
+	if !strings.Contains(doc.Pages[2].Text, "lung capacity") {
+		t.Fatalf("unexpected third page %+v", doc.Pages[2])
+	}
+}
+
+func TestPDFRejectsGarbage(t *testing.T) {
+	if _, err := PDF([]byte("not a pdf")); err == nil {
+		t.Fatal("expected an error")
+	}
+}
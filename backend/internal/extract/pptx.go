@@ -0,0 +1,178 @@
+package extract
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// PPTX extracts each slide as a page numbered by its position in the presentation. Slide
+// titles are marked as Markdown headings and speaker notes follow the slide text.
+func PPTX(data []byte) (*Document, error) {
+	pkg, err := openOOXML(data)
+	if err != nil {
+		return nil, err
+	}
+
+	presentationPart := pkg.mainPart("ppt/presentation.xml")
+	content, err := pkg.read(presentationPart)
+	if err != nil {
+		return nil, fmt.Errorf("read pptx: %w", err)
+	}
+	var presentation struct {
+		Slides []struct {
+			Attrs []xml.Attr `xml:",any,attr"`
+		} `xml:"sldIdLst>sldId"`
+	}
+	if err := xml.Unmarshal(content, &presentation); err != nil {
+		return nil, fmt.Errorf("parse pptx presentation: %w", err)
+	}
+	rels, err := pkg.relationships(presentationPart)
+	if err != nil {
+		return nil, err
+	}
+
+	doc := &Document{}
+	for i, slide := range presentation.Slides {
+		rel, ok := rels[relationshipID(slide.Attrs)]
+		if !ok {
+			continue
+		}
+		text, err := pptxSlideText(pkg, rel.Target)
+		if err != nil {
+			return nil, fmt.Errorf("slide %d: %w", i+1, err)
+		}
+		doc.Pages = append(doc.Pages, Page{Number: i + 1, Text: text})
+	}
+	return doc, nil
+}
+
+func pptxSlideText(pkg *ooxmlPackage, part string) (string, error) {
+	content, err := pkg.read(part)
+	if err != nil {
+		return "", err
+	}
+	blocks, err := pptxShapesText(content, false)
+	if err != nil {
+		return "", fmt.Errorf("parse %s: %w", part, err)
+	}
+
+	rels, err := pkg.relationships(part)
+	if err != nil {
+		return "", err
+	}
+	for _, rel := range rels {
+		if !strings.HasSuffix(rel.Type, "/notesSlide") {
+			continue
+		}
+		content, err := pkg.read(rel.Target)
+		if err != nil {
+			return "", err
+		}
+		notes, err := pptxShapesText(content, true)
+		if err != nil {
+			return "", fmt.Errorf("parse %s: %w", rel.Target, err)
+		}
+		if text := textBlocks(notes); text != "" {
+			blocks = append(blocks, "Notes:\n"+text)
+		}
+	}
+	return textBlocks(blocks), nil
+}
+
+// pptxShapesText returns the text of each shape on a slide, one block per shape. On notes
+// pages only the body placeholder holds the notes; the others repeat the slide image,
+// number, header and footer.
+func pptxShapesText(content []byte, notes bool) ([]string, error) {
+	var (
+		blocks    []string
+		shape     []string
+		para      strings.Builder
+		shapeKind string
+		inText    bool
+		inTable   bool
+		cell      []string
+		row       []string
+		rows      []string
+	)
+
+	decoder := xml.NewDecoder(bytes.NewReader(content))
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "Fallback":
+				if err := decoder.Skip(); err != nil {
+					return nil, err
+				}
+			case "sp", "graphicFrame":
+				shape = nil
+				shapeKind = ""
+			case "ph":
+				shapeKind = attr(t.Attr, "type")
+				if shapeKind == "" {
+					// Placeholders without a type are body placeholders.
+					shapeKind = "body"
+				}
+			case "p":
+				para.Reset()
+			case "t":
+				inText = true
+			case "br":
+				para.WriteByte('\n')
+			case "tbl":
+				inTable = true
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "t":
+				inText = false
+			case "p":
+				text := strings.TrimSpace(para.String())
+				switch {
+				case text == "":
+				case inTable:
+					cell = append(cell, text)
+				default:
+					shape = append(shape, text)
+				}
+			case "tc":
+				row = append(row, strings.Join(cell, " "))
+				cell = nil
+			case "tr":
+				if strings.TrimSpace(strings.Join(row, "")) != "" {
+					rows = append(rows, strings.Join(row, " | "))
+				}
+				row = nil
+			case "tbl":
+				shape = append(shape, strings.Join(rows, "\n"))
+				rows = nil
+				inTable = false
+			case "sp", "graphicFrame":
+				if notes && shapeKind != "body" {
+					continue
+				}
+				text := strings.Join(shape, "\n")
+				if shapeKind == "title" || shapeKind == "ctrTitle" {
+					text = "# " + strings.ReplaceAll(text, "\n", " ")
+				}
+				blocks = append(blocks, text)
+			}
+		case xml.CharData:
+			if inText {
+				para.Write(t)
+			}
+		}
+	}
+	return blocks, nil
+}
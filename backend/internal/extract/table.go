@@ -0,0 +1,109 @@
+package extract
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// CSV extracts a comma, semicolon or tab separated file as a single page of table text.
+func CSV(data []byte) (*Document, error) {
+	text := decodeText(data)
+
+	reader := csv.NewReader(strings.NewReader(text))
+	reader.Comma = sniffDelimiter(text)
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+
+	var rows [][]string
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("parse csv: %w", err)
+		}
+		rows = append(rows, record)
+	}
+	return &Document{Pages: []Page{{Text: tableText(rows)}}}, nil
+}
+
+// sniffDelimiter picks the separator that occurs most often in the first line.
+func sniffDelimiter(text string) rune {
+	line, _, _ := strings.Cut(text, "\n")
+	best, count := ',', strings.Count(line, ",")
+	for _, candidate := range []rune{';', '\t'} {
+		if n := strings.Count(line, string(candidate)); n > count {
+			best, count = candidate, n
+		}
+	}
+	return best
+}
+
+// tableText renders rows so that each line stands on its own when retrieved: the first
+// non-empty row is taken as the header, and every later row lists its cells as
+// "Header: value" pairs separated by " | ". Empty rows and cells are dropped.
+func tableText(rows [][]string) string {
+	var header []string
+	var lines []string
+	for _, row := range rows {
+		cells := trimCells(row)
+		if len(cells) == 0 {
+			continue
+		}
+		if header == nil {
+			header = cells
+			continue
+		}
+
+		var line strings.Builder
+		for i, value := range cells {
+			if value == "" {
+				continue
+			}
+			if line.Len() > 0 {
+				line.WriteString(" | ")
+			}
+			if i < len(header) && header[i] != "" {
+				line.WriteString(header[i])
+				line.WriteString(": ")
+			}
+			line.WriteString(value)
+		}
+		lines = append(lines, line.String())
+	}
+
+	if len(lines) == 0 && header != nil {
+		// A single row has nothing to label; keep it as is.
+		return joinCells(header)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// trimCells trims every cell and drops trailing empty ones, returning nil for an empty row.
+func trimCells(row []string) []string {
+	cells := make([]string, len(row))
+	last := -1
+	for i, cell := range row {
+		cells[i] = strings.Join(strings.Fields(cell), " ")
+		if cells[i] != "" {
+			last = i
+		}
+	}
+	if last < 0 {
+		return nil
+	}
+	return cells[:last+1]
+}
+
+func joinCells(cells []string) string {
+	var kept []string
+	for _, cell := range cells {
+		if cell != "" {
+			kept = append(kept, cell)
+		}
+	}
+	return strings.Join(kept, " | ")
+}
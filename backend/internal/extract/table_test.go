@@ -0,0 +1,43 @@
+package extract
+
+import "testing"
+
+func TestCSVLabelsCellsWithHeaders(t *testing.T) {
+	data := "\xef\xbb\xbfProduct;Dose;Notes\nNicotine patch;21 mg;\"Apply daily; rotate sites\"\n;;\nGum;4 mg\n"
+
+	doc, err := CSV([]byte(data))
+	if err != nil {
+		t.Fatalf("CSV: %v", err)
+	}
+
+	want := "Product: Nicotine patch | Dose: 21 mg | Notes: Apply daily; rotate sites\nProduct: Gum | Dose: 4 mg"
+	if len(doc.Pages) != 1 || doc.Pages[0].Text != want {
+		t.Fatalf("got %q, want %q", doc.Pages[0].Text, want)
+	}
+}
+
+func TestDetectMimeTypePrefersExtension(t *testing.T) {
+	zipHeader := []byte("PK\x03\x04")
+	cases := []struct {
+		ext  string
+		data []byte
+		want string
+	}{
+		{".docx", zipHeader, MimeDOCX},
+		{".XLSX", zipHeader, MimeXLSX},
+		{".csv", []byte("a,b\n1,2"), MimeCSV},
+		{".htm", []byte("<p>hi</p>"), MimeHTML},
+		{"", []byte("%PDF-1.4"), MimePDF},
+		{".bin", []byte("\x89PNG\r\n\x1a\n"), "image/png"},
+		{"", []byte("plain words"), MimeText},
+	}
+	for _, c := range cases {
+		if got := DetectMimeType(c.ext, c.data); got != c.want {
+			t.Errorf("DetectMimeType(%q) = %q, want %q", c.ext, got, c.want)
+		}
+	}
+
+	if Supported(MimeDOC) {
+		t.Error("legacy .doc files should not be supported")
+	}
+}
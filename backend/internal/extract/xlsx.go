@@ -0,0 +1,181 @@
+package extract
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type xlsxWorkbook struct {
+	Sheets []struct {
+		Name  string     `xml:"name,attr"`
+		State string     `xml:"state,attr"`
+		Attrs []xml.Attr `xml:",any,attr"`
+	} `xml:"sheets>sheet"`
+}
+
+type xlsxRichText struct {
+	Text string `xml:"t"`
+	Runs []struct {
+		Text string `xml:"t"`
+	} `xml:"r"`
+}
+
+func (r xlsxRichText) String() string {
+	if len(r.Runs) == 0 {
+		return r.Text
+	}
+	var b strings.Builder
+	for _, run := range r.Runs {
+		b.WriteString(run.Text)
+	}
+	return b.String()
+}
+
+type xlsxWorksheet struct {
+	Rows []struct {
+		Cells []struct {
+			Ref    string       `xml:"r,attr"`
+			Type   string       `xml:"t,attr"`
+			Value  string       `xml:"v"`
+			Inline xlsxRichText `xml:"is"`
+		} `xml:"c"`
+	} `xml:"sheetData>row"`
+}
+
+// XLSX extracts each visible worksheet as table text under a "## <sheet name>" heading.
+// Cells hold their stored values: formulas contribute their cached result, and numbers,
+// including dates, are not formatted.
+func XLSX(data []byte) (*Document, error) {
+	pkg, err := openOOXML(data)
+	if err != nil {
+		return nil, err
+	}
+
+	workbookPart := pkg.mainPart("xl/workbook.xml")
+	content, err := pkg.read(workbookPart)
+	if err != nil {
+		return nil, fmt.Errorf("read xlsx: %w", err)
+	}
+	var workbook xlsxWorkbook
+	if err := xml.Unmarshal(content, &workbook); err != nil {
+		return nil, fmt.Errorf("parse xlsx workbook: %w", err)
+	}
+
+	rels, err := pkg.relationships(workbookPart)
+	if err != nil {
+		return nil, err
+	}
+	sharedStrings, err := xlsxSharedStrings(pkg, rels)
+	if err != nil {
+		return nil, err
+	}
+
+	var sheets []string
+	for _, sheet := range workbook.Sheets {
+		if sheet.State != "" && sheet.State != "visible" {
+			continue
+		}
+		rel, ok := rels[relationshipID(sheet.Attrs)]
+		if !ok {
+			continue
+		}
+		content, err := pkg.read(rel.Target)
+		if err != nil {
+			return nil, fmt.Errorf("read sheet %q: %w", sheet.Name, err)
+		}
+		rows, err := xlsxRows(content, sharedStrings)
+		if err != nil {
+			return nil, fmt.Errorf("parse sheet %q: %w", sheet.Name, err)
+		}
+		if table := tableText(rows); table != "" {
+			sheets = append(sheets, "## "+sheet.Name+"\n"+table)
+		}
+	}
+	return &Document{Pages: []Page{{Text: textBlocks(sheets)}}}, nil
+}
+
+func xlsxSharedStrings(pkg *ooxmlPackage, rels map[string]relationship) ([]string, error) {
+	for _, rel := range rels {
+		if !strings.HasSuffix(rel.Type, "/sharedStrings") {
+			continue
+		}
+		content, err := pkg.read(rel.Target)
+		if errors.Is(err, errPartNotFound) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		var sst struct {
+			Items []xlsxRichText `xml:"si"`
+		}
+		if err := xml.Unmarshal(content, &sst); err != nil {
+			return nil, fmt.Errorf("parse shared strings: %w", err)
+		}
+		strs := make([]string, len(sst.Items))
+		for i, item := range sst.Items {
+			strs[i] = item.String()
+		}
+		return strs, nil
+	}
+	return nil, nil
+}
+
+// xlsxRows returns the cell values of a worksheet, placing each cell in the column its
+// reference names, since empty cells are not stored.
+func xlsxRows(content []byte, sharedStrings []string) ([][]string, error) {
+	var sheet xlsxWorksheet
+	if err := xml.Unmarshal(content, &sheet); err != nil {
+		return nil, err
+	}
+
+	rows := make([][]string, 0, len(sheet.Rows))
+	for _, r := range sheet.Rows {
+		var row []string
+		for _, c := range r.Cells {
+			col := columnIndex(c.Ref)
+			if col < 0 {
+				col = len(row)
+			}
+			for len(row) <= col {
+				row = append(row, "")
+			}
+
+			switch c.Type {
+			case "s":
+				if i, err := strconv.Atoi(c.Value); err == nil && i >= 0 && i < len(sharedStrings) {
+					row[col] = sharedStrings[i]
+				}
+			case "inlineStr":
+				row[col] = c.Inline.String()
+			case "b":
+				row[col] = strings.ToUpper(strconv.FormatBool(c.Value == "1"))
+			default:
+				row[col] = c.Value
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// columnIndex converts the column letters of a cell reference such as "BC12" to a 0-based index.
+func columnIndex(ref string) int {
+	col := 0
+	n := 0
+	for _, r := range ref {
+		if r < 'A' || r > 'Z' {
+			break
+		}
+		col = col*26 + int(r-'A'+1)
+		n++
+	}
+	if n == 0 || n > 3 {
+		return -1
+	}
+	return col - 1
+}
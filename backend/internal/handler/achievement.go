@@ -0,0 +1,20 @@
+package handler
+
+import (
+	"patient-chatbot/internal/middleware"
+	"patient-chatbot/internal/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+func (h *Handler) HandleGetAchievements(c *gin.Context) {
+	achievements, err := h.service.GetAchievements(c.Request.Context(), middleware.GetUserID(c), middleware.GetLang(c))
+	if err != nil {
+		log.Error().Msg("error: " + err.Error())
+		c.JSON(500, NewResponse(nil, utils.Localize(c, "an_error_occurred_while_processing_your_request")))
+		return
+	}
+
+	c.JSON(200, NewResponse(achievements, utils.Localize(c, "achievements_fetched_successfully")))
+}
@@ -0,0 +1,315 @@
+package handler
+
+import (
+	"errors"
+	"time"
+
+	"patient-chatbot/internal/middleware"
+	"patient-chatbot/internal/repository"
+	"patient-chatbot/internal/service"
+	"patient-chatbot/internal/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+func (h *Handler) HandleGetClinicians(c *gin.Context) {
+	h.getClinicians(c, true)
+}
+
+func (h *Handler) HandleGetClinicianCatalogue(c *gin.Context) {
+	h.getClinicians(c, false)
+}
+
+func (h *Handler) getClinicians(c *gin.Context, activeOnly bool) {
+	clinicians, err := h.service.GetClinicians(c.Request.Context(), middleware.GetOrgID(c), activeOnly)
+	if err != nil {
+		log.Error().Msg("error: " + err.Error())
+		c.JSON(500, NewResponse(nil, utils.Localize(c, "an_error_occurred_while_processing_your_request")))
+		return
+	}
+
+	cliniciansDTO := make([]ClinicianDTO, len(clinicians))
+	for i := range clinicians {
+		cliniciansDTO[i] = toClinicianDTO(&clinicians[i])
+	}
+	c.JSON(200, NewResponse(cliniciansDTO, utils.Localize(c, "clinicians_fetched_successfully")))
+}
+
+func (h *Handler) HandleCreateClinician(c *gin.Context) {
+	var request ClinicianRequestDTO
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(400, NewResponse(nil, utils.Localize(c, "request_is_invalid")))
+		return
+	}
+
+	clinician, err := h.service.CreateClinician(c.Request.Context(), middleware.GetOrgID(c), toClinicianInput(request))
+	if err != nil {
+		log.Error().Msg("error: " + err.Error())
+		c.JSON(500, NewResponse(nil, utils.Localize(c, "an_error_occurred_while_processing_your_request")))
+		return
+	}
+
+	c.JSON(201, NewResponse(toClinicianDTO(clinician), utils.Localize(c, "clinician_created_successfully")))
+}
+
+func (h *Handler) HandleUpdateClinician(c *gin.Context) {
+	var request ClinicianRequestDTO
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(400, NewResponse(nil, utils.Localize(c, "request_is_invalid")))
+		return
+	}
+
+	clinician, err := h.service.UpdateClinician(c.Request.Context(), middleware.GetOrgID(c), c.Param("id"), toClinicianInput(request))
+	if errors.Is(err, service.ErrClinicianNotFound) {
+		c.JSON(404, NewResponse(nil, utils.Localize(c, "clinician_not_found")))
+		return
+	}
+	if err != nil {
+		log.Error().Msg("error: " + err.Error())
+		c.JSON(500, NewResponse(nil, utils.Localize(c, "an_error_occurred_while_processing_your_request")))
+		return
+	}
+
+	c.JSON(200, NewResponse(toClinicianDTO(clinician), utils.Localize(c, "clinician_updated_successfully")))
+}
+
+func (h *Handler) HandleDeleteClinician(c *gin.Context) {
+	err := h.service.DeleteClinician(c.Request.Context(), middleware.GetOrgID(c), c.Param("id"))
+	if errors.Is(err, service.ErrClinicianNotFound) {
+		c.JSON(404, NewResponse(nil, utils.Localize(c, "clinician_not_found")))
+		return
+	}
+	if err != nil {
+		log.Error().Msg("error: " + err.Error())
+		c.JSON(500, NewResponse(nil, utils.Localize(c, "an_error_occurred_while_processing_your_request")))
+		return
+	}
+
+	c.JSON(200, NewResponse(nil, utils.Localize(c, "clinician_deleted_successfully")))
+}
+
+func (h *Handler) HandleCreateAvailability(c *gin.Context) {
+	var request AvailabilityRequestDTO
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(400, NewResponse(nil, utils.Localize(c, "request_is_invalid")))
+		return
+	}
+
+	slots, err := h.service.CreateAvailability(c.Request.Context(), middleware.GetOrgID(c), c.Param("id"), request.From, request.To, request.SlotMinutes)
+	if errors.Is(err, service.ErrClinicianNotFound) {
+		c.JSON(404, NewResponse(nil, utils.Localize(c, "clinician_not_found")))
+		return
+	}
+	if errors.Is(err, service.ErrInvalidAvailability) {
+		c.JSON(400, NewResponse(nil, utils.Localize(c, "request_is_invalid")))
+		return
+	}
+	if errors.Is(err, service.ErrSlotsOverlap) {
+		c.JSON(409, NewResponse(nil, utils.Localize(c, "slots_overlap")))
+		return
+	}
+	if err != nil {
+		log.Error().Msg("error: " + err.Error())
+		c.JSON(500, NewResponse(nil, utils.Localize(c, "an_error_occurred_while_processing_your_request")))
+		return
+	}
+
+	slotsDTO := make([]SlotDTO, len(slots))
+	for i, slot := range slots {
+		slotsDTO[i] = toSlotDTO(slot)
+	}
+	c.JSON(201, NewResponse(slotsDTO, utils.Localize(c, "slots_created_successfully")))
+}
+
+func (h *Handler) HandleDeleteSlot(c *gin.Context) {
+	err := h.service.DeleteSlot(c.Request.Context(), middleware.GetOrgID(c), c.Param("id"))
+	if errors.Is(err, service.ErrSlotNotFound) {
+		c.JSON(404, NewResponse(nil, utils.Localize(c, "slot_not_found")))
+		return
+	}
+	if errors.Is(err, service.ErrSlotBooked) {
+		c.JSON(409, NewResponse(nil, utils.Localize(c, "slot_is_booked")))
+		return
+	}
+	if err != nil {
+		log.Error().Msg("error: " + err.Error())
+		c.JSON(500, NewResponse(nil, utils.Localize(c, "an_error_occurred_while_processing_your_request")))
+		return
+	}
+
+	c.JSON(200, NewResponse(nil, utils.Localize(c, "slot_deleted_successfully")))
+}
+
+func (h *Handler) HandleGetOpenSlots(c *gin.Context) {
+	var request GetSlotsRequestDTO
+	if err := c.ShouldBindQuery(&request); err != nil {
+		c.JSON(400, NewResponse(nil, utils.Localize(c, "request_is_invalid")))
+		return
+	}
+
+	slots, err := h.service.GetOpenSlots(c.Request.Context(), middleware.GetOrgID(c), request.ClinicianID, request.From, request.To)
+	if errors.Is(err, service.ErrClinicianNotFound) {
+		c.JSON(404, NewResponse(nil, utils.Localize(c, "clinician_not_found")))
+		return
+	}
+	if err != nil {
+		log.Error().Msg("error: " + err.Error())
+		c.JSON(500, NewResponse(nil, utils.Localize(c, "an_error_occurred_while_processing_your_request")))
+		return
+	}
+
+	slotsDTO := make([]SlotDTO, len(slots))
+	for i := range slots {
+		slotsDTO[i] = toSlotDTO(&slots[i])
+	}
+	c.JSON(200, NewResponse(slotsDTO, utils.Localize(c, "slots_fetched_successfully")))
+}
+
+func (h *Handler) HandleBookAppointment(c *gin.Context) {
+	var request BookAppointmentRequestDTO
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(400, NewResponse(nil, utils.Localize(c, "request_is_invalid")))
+		return
+	}
+
+	appointment, err := h.service.BookAppointment(c.Request.Context(), middleware.GetUserID(c), middleware.GetOrgID(c), service.AppointmentInput{
+		SlotID: request.SlotID,
+		Title:  request.Title,
+		Type:   repository.AppointmentType(request.Type),
+		Notes:  request.Notes,
+	})
+	if errors.Is(err, service.ErrInvalidAppointment) {
+		c.JSON(400, NewResponse(nil, utils.Localize(c, "request_is_invalid")))
+		return
+	}
+	if errors.Is(err, service.ErrSlotUnavailable) {
+		c.JSON(409, NewResponse(nil, utils.Localize(c, "slot_unavailable")))
+		return
+	}
+	if err != nil {
+		log.Error().Msg("error: " + err.Error())
+		c.JSON(500, NewResponse(nil, utils.Localize(c, "an_error_occurred_while_processing_your_request")))
+		return
+	}
+
+	c.JSON(201, NewResponse(toAppointmentDTO(appointment), utils.Localize(c, "appointment_booked_successfully")))
+}
+
+func (h *Handler) HandleGetAppointments(c *gin.Context) {
+	var request PaginationRequest
+	if err := c.ShouldBindQuery(&request); err != nil {
+		c.JSON(400, NewResponse(nil, utils.Localize(c, "request_is_invalid")))
+		return
+	}
+
+	appointments, total, err := h.service.GetAppointments(c.Request.Context(), middleware.GetUserID(c), request.Page, request.PageSize)
+	if err != nil {
+		log.Error().Msg("error: " + err.Error())
+		c.JSON(500, NewResponse(nil, utils.Localize(c, "an_error_occurred_while_processing_your_request")))
+		return
+	}
+
+	appointmentsDTO := make([]AppointmentDTO, len(appointments))
+	for i := range appointments {
+		appointmentsDTO[i] = toAppointmentDTO(&appointments[i])
+	}
+	c.JSON(200, NewResponse(GetAppointmentsResponseDTO{
+		Appointments: appointmentsDTO,
+		PageSize:     request.PageSize,
+		Page:         request.Page,
+		Total:        total,
+	}, utils.Localize(c, "appointments_fetched_successfully")))
+}
+
+func (h *Handler) HandleRescheduleAppointment(c *gin.Context) {
+	var request RescheduleAppointmentRequestDTO
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(400, NewResponse(nil, utils.Localize(c, "request_is_invalid")))
+		return
+	}
+
+	appointment, err := h.service.RescheduleAppointment(c.Request.Context(), middleware.GetUserID(c), c.Param("id"), request.SlotID)
+	if errors.Is(err, service.ErrAppointmentNotFound) {
+		c.JSON(404, NewResponse(nil, utils.Localize(c, "appointment_not_found")))
+		return
+	}
+	if errors.Is(err, service.ErrSlotUnavailable) {
+		c.JSON(409, NewResponse(nil, utils.Localize(c, "slot_unavailable")))
+		return
+	}
+	if err != nil {
+		log.Error().Msg("error: " + err.Error())
+		c.JSON(500, NewResponse(nil, utils.Localize(c, "an_error_occurred_while_processing_your_request")))
+		return
+	}
+
+	c.JSON(200, NewResponse(toAppointmentDTO(appointment), utils.Localize(c, "appointment_rescheduled_successfully")))
+}
+
+func (h *Handler) HandleCancelAppointment(c *gin.Context) {
+	appointment, err := h.service.CancelAppointment(c.Request.Context(), middleware.GetUserID(c), c.Param("id"))
+	if errors.Is(err, service.ErrAppointmentNotFound) {
+		c.JSON(404, NewResponse(nil, utils.Localize(c, "appointment_not_found")))
+		return
+	}
+	if err != nil {
+		log.Error().Msg("error: " + err.Error())
+		c.JSON(500, NewResponse(nil, utils.Localize(c, "an_error_occurred_while_processing_your_request")))
+		return
+	}
+
+	c.JSON(200, NewResponse(toAppointmentDTO(appointment), utils.Localize(c, "appointment_cancelled_successfully")))
+}
+
+// toClinicianInput maps a request to the service input. Clinicians are active unless the
+// request says otherwise.
+func toClinicianInput(request ClinicianRequestDTO) service.ClinicianInput {
+	active := true
+	if request.Active != nil {
+		active = *request.Active
+	}
+	return service.ClinicianInput{
+		Name:      request.Name,
+		Specialty: request.Specialty,
+		Location:  request.Location,
+		Active:    active,
+	}
+}
+
+func toClinicianDTO(clinician *repository.Clinician) ClinicianDTO {
+	return ClinicianDTO{
+		ClinicianID: clinician.ID.String(),
+		Name:        clinician.Name,
+		Specialty:   clinician.Specialty,
+		Location:    clinician.Location,
+		Active:      clinician.Active,
+	}
+}
+
+func toSlotDTO(slot *repository.AvailabilitySlot) SlotDTO {
+	return SlotDTO{
+		SlotID:      slot.ID.String(),
+		ClinicianID: slot.ClinicianID.String(),
+		Clinician:   slot.Clinician.Name,
+		Location:    slot.Clinician.Location,
+		StartsAt:    slot.StartsAt,
+		EndsAt:      slot.EndsAt,
+	}
+}
+
+func toAppointmentDTO(appointment *repository.Appointment) AppointmentDTO {
+	return AppointmentDTO{
+		AppointmentID: appointment.ID.String(),
+		Title:         appointment.Title,
+		Type:          string(appointment.Type),
+		Status:        string(appointment.CurrentStatus(time.Now())),
+		ClinicianID:   appointment.ClinicianID.String(),
+		Clinician:     appointment.Clinician.Name,
+		Location:      appointment.Location,
+		Notes:         appointment.Notes,
+		StartsAt:      appointment.StartsAt,
+		EndsAt:        appointment.EndsAt,
+		CreatedAt:     appointment.CreatedAt,
+	}
+}
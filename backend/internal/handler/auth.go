@@ -0,0 +1,129 @@
+package handler
+
+import (
+	"errors"
+
+	"patient-chatbot/internal/service"
+	"patient-chatbot/internal/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+)
+
+func (h *Handler) HandleCreateOrganization(c *gin.Context) {
+	var request CreateOrganizationRequestDTO
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(400, NewResponse(nil, utils.Localize(c, "request_is_invalid")))
+		return
+	}
+
+	tokens, err := h.service.CreateOrganization(c.Request.Context(), request.Name, request.AdminEmail, request.AdminPassword)
+	if errors.Is(err, service.ErrPasswordTooLong) {
+		c.JSON(400, NewResponse(nil, utils.Localize(c, "password_too_long")))
+		return
+	}
+	if errors.Is(err, service.ErrEmailTaken) {
+		c.JSON(409, NewResponse(nil, utils.Localize(c, "email_already_registered")))
+		return
+	}
+	if err != nil {
+		log.Error().Msg("error: " + err.Error())
+		c.JSON(500, NewResponse(nil, utils.Localize(c, "an_error_occurred_while_processing_your_request")))
+		return
+	}
+
+	c.JSON(201, NewResponse(tokens, utils.Localize(c, "organization_created_successfully")))
+}
+
+func (h *Handler) HandleSignup(c *gin.Context) {
+	var request SignupRequestDTO
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(400, NewResponse(nil, utils.Localize(c, "request_is_invalid")))
+		return
+	}
+
+	tokens, err := h.service.Signup(c.Request.Context(), uuid.MustParse(request.OrganizationID), request.Email, request.Password)
+	if errors.Is(err, service.ErrPasswordTooLong) {
+		c.JSON(400, NewResponse(nil, utils.Localize(c, "password_too_long")))
+		return
+	}
+	if errors.Is(err, service.ErrOrganizationNotFound) {
+		c.JSON(404, NewResponse(nil, utils.Localize(c, "organization_not_found")))
+		return
+	}
+	if errors.Is(err, service.ErrEmailTaken) {
+		c.JSON(409, NewResponse(nil, utils.Localize(c, "email_already_registered")))
+		return
+	}
+	if err != nil {
+		log.Error().Msg("error: " + err.Error())
+		c.JSON(500, NewResponse(nil, utils.Localize(c, "an_error_occurred_while_processing_your_request")))
+		return
+	}
+
+	c.JSON(201, NewResponse(tokens, utils.Localize(c, "signed_up_successfully")))
+}
+
+func (h *Handler) HandleLogin(c *gin.Context) {
+	var request LoginRequestDTO
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(400, NewResponse(nil, utils.Localize(c, "request_is_invalid")))
+		return
+	}
+
+	tokens, err := h.service.Login(c.Request.Context(), request.Email, request.Password)
+	if errors.Is(err, service.ErrInvalidCredentials) {
+		c.JSON(401, NewResponse(nil, utils.Localize(c, "invalid_credentials")))
+		return
+	}
+	if err != nil {
+		log.Error().Msg("error: " + err.Error())
+		c.JSON(500, NewResponse(nil, utils.Localize(c, "an_error_occurred_while_processing_your_request")))
+		return
+	}
+
+	c.JSON(200, NewResponse(tokens, utils.Localize(c, "logged_in_successfully")))
+}
+
+func (h *Handler) HandleRefresh(c *gin.Context) {
+	var request RefreshRequestDTO
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(400, NewResponse(nil, utils.Localize(c, "request_is_invalid")))
+		return
+	}
+
+	tokens, err := h.service.Refresh(c.Request.Context(), request.RefreshToken)
+	if errors.Is(err, service.ErrInvalidToken) {
+		c.JSON(401, NewResponse(nil, utils.Localize(c, "unauthorized")))
+		return
+	}
+	if err != nil {
+		log.Error().Msg("error: " + err.Error())
+		c.JSON(500, NewResponse(nil, utils.Localize(c, "an_error_occurred_while_processing_your_request")))
+		return
+	}
+
+	c.JSON(200, NewResponse(tokens, utils.Localize(c, "token_refreshed_successfully")))
+}
+
+func (h *Handler) HandleLogout(c *gin.Context) {
+	var request RefreshRequestDTO
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(400, NewResponse(nil, utils.Localize(c, "request_is_invalid")))
+		return
+	}
+
+	err := h.service.Logout(c.Request.Context(), request.RefreshToken)
+	if errors.Is(err, service.ErrInvalidToken) {
+		c.JSON(401, NewResponse(nil, utils.Localize(c, "unauthorized")))
+		return
+	}
+	if err != nil {
+		log.Error().Msg("error: " + err.Error())
+		c.JSON(500, NewResponse(nil, utils.Localize(c, "an_error_occurred_while_processing_your_request")))
+		return
+	}
+
+	c.JSON(200, NewResponse(nil, utils.Localize(c, "logged_out_successfully")))
+}
@@ -0,0 +1,156 @@
+package handler
+
+import (
+	"errors"
+	"strings"
+
+	"patient-chatbot/internal/middleware"
+	"patient-chatbot/internal/repository"
+	"patient-chatbot/internal/service"
+	"patient-chatbot/internal/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+func (h *Handler) HandleGetCalendarIntegration(c *gin.Context) {
+	integration, err := h.service.GetCalendarIntegration(c.Request.Context(), middleware.GetOrgID(c))
+	if errors.Is(err, service.ErrCalendarIntegrationNotFound) {
+		c.JSON(404, NewResponse(nil, utils.Localize(c, "calendar_integration_not_found")))
+		return
+	}
+	if err != nil {
+		log.Error().Msg("error: " + err.Error())
+		c.JSON(500, NewResponse(nil, utils.Localize(c, "an_error_occurred_while_processing_your_request")))
+		return
+	}
+
+	c.JSON(200, NewResponse(toCalendarIntegrationDTO(integration), utils.Localize(c, "calendar_integration_fetched_successfully")))
+}
+
+func (h *Handler) HandleSetCalendarIntegration(c *gin.Context) {
+	var request CalendarIntegrationRequestDTO
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(400, NewResponse(nil, utils.Localize(c, "request_is_invalid")))
+		return
+	}
+
+	enabled := true
+	if request.Enabled != nil {
+		enabled = *request.Enabled
+	}
+	integration, err := h.service.SetCalendarIntegration(c.Request.Context(), middleware.GetOrgID(c), service.CalendarIntegrationInput{
+		URL:      request.URL,
+		Username: request.Username,
+		Password: request.Password,
+		Enabled:  enabled,
+	})
+	if errors.Is(err, service.ErrInvalidCalendarIntegration) {
+		c.JSON(400, NewResponse(nil, utils.Localize(c, "request_is_invalid")))
+		return
+	}
+	if errors.Is(err, service.ErrCredentialsKeyMissing) {
+		log.Error().Msg("error: " + err.Error())
+		c.JSON(503, NewResponse(nil, utils.Localize(c, "credentials_key_not_configured")))
+		return
+	}
+	if errors.Is(err, service.ErrCalendarUnreachable) {
+		log.Warn().Msg("warning: " + err.Error())
+		c.JSON(422, NewResponse(nil, utils.Localize(c, "calendar_server_unreachable")))
+		return
+	}
+	if err != nil {
+		log.Error().Msg("error: " + err.Error())
+		c.JSON(500, NewResponse(nil, utils.Localize(c, "an_error_occurred_while_processing_your_request")))
+		return
+	}
+
+	c.JSON(200, NewResponse(toCalendarIntegrationDTO(integration), utils.Localize(c, "calendar_integration_saved_successfully")))
+}
+
+func (h *Handler) HandleDeleteCalendarIntegration(c *gin.Context) {
+	err := h.service.DeleteCalendarIntegration(c.Request.Context(), middleware.GetOrgID(c))
+	if errors.Is(err, service.ErrCalendarIntegrationNotFound) {
+		c.JSON(404, NewResponse(nil, utils.Localize(c, "calendar_integration_not_found")))
+		return
+	}
+	if err != nil {
+		log.Error().Msg("error: " + err.Error())
+		c.JSON(500, NewResponse(nil, utils.Localize(c, "an_error_occurred_while_processing_your_request")))
+		return
+	}
+
+	c.JSON(200, NewResponse(nil, utils.Localize(c, "calendar_integration_deleted_successfully")))
+}
+
+func (h *Handler) HandleCreateCalendarFeed(c *gin.Context) {
+	feedURL, err := h.service.CreateCalendarFeed(c.Request.Context(), middleware.GetUserID(c), middleware.GetLang(c))
+	if err != nil {
+		log.Error().Msg("error: " + err.Error())
+		c.JSON(500, NewResponse(nil, utils.Localize(c, "an_error_occurred_while_processing_your_request")))
+		return
+	}
+
+	// Without PUBLIC_BASE_URL the URL is relative to this server.
+	if strings.HasPrefix(feedURL, "/") {
+		feedURL = requestBaseURL(c) + feedURL
+	}
+	c.JSON(201, NewResponse(CalendarFeedDTO{URL: feedURL}, utils.Localize(c, "calendar_feed_created_successfully")))
+}
+
+func (h *Handler) HandleRevokeCalendarFeed(c *gin.Context) {
+	if err := h.service.RevokeCalendarFeed(c.Request.Context(), middleware.GetUserID(c)); err != nil {
+		log.Error().Msg("error: " + err.Error())
+		c.JSON(500, NewResponse(nil, utils.Localize(c, "an_error_occurred_while_processing_your_request")))
+		return
+	}
+
+	c.JSON(200, NewResponse(nil, utils.Localize(c, "calendar_feed_revoked_successfully")))
+}
+
+// HandleGetCalendarFeed serves a patient's iCalendar feed. It is public so calendar apps can
+// subscribe to it; the secret token in the URL identifies the patient. Calendar apps send no
+// useful Accept-Language, so the language comes from the URL.
+func (h *Handler) HandleGetCalendarFeed(c *gin.Context) {
+	token, ok := strings.CutSuffix(c.Param("feed"), ".ics")
+	lang := middleware.GetLang(c)
+	if queryLang := c.Query("lang"); queryLang != "" {
+		lang = queryLang
+	}
+
+	var feed []byte
+	err := service.ErrCalendarFeedNotFound
+	if ok {
+		feed, err = h.service.GetCalendarFeed(c.Request.Context(), token, lang)
+	}
+	if errors.Is(err, service.ErrCalendarFeedNotFound) {
+		c.JSON(404, NewResponse(nil, utils.Localize(c, "calendar_feed_not_found")))
+		return
+	}
+	if err != nil {
+		log.Error().Msg("error: " + err.Error())
+		c.JSON(500, NewResponse(nil, utils.Localize(c, "an_error_occurred_while_processing_your_request")))
+		return
+	}
+
+	c.Header("Cache-Control", "private, max-age=900")
+	c.Data(200, "text/calendar; charset=utf-8", feed)
+}
+
+func toCalendarIntegrationDTO(integration *repository.CalendarIntegration) CalendarIntegrationDTO {
+	return CalendarIntegrationDTO{
+		URL:           integration.URL,
+		Username:      integration.Username,
+		Enabled:       integration.Enabled,
+		LastSyncedAt:  integration.LastSyncedAt,
+		LastSyncError: integration.LastSyncError,
+	}
+}
+
+func requestBaseURL(c *gin.Context) string {
+	scheme := "http"
+	if c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	return scheme + "://" + c.Request.Host
+}
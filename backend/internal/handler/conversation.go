@@ -0,0 +1,115 @@
+package handler
+
+import (
+	"errors"
+
+	"patient-chatbot/internal/dto"
+	"patient-chatbot/internal/middleware"
+	"patient-chatbot/internal/repository"
+	"patient-chatbot/internal/service"
+	"patient-chatbot/internal/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+func (h *Handler) HandleCreateConversation(c *gin.Context) {
+	var request CreateConversationRequestDTO
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(400, NewResponse(nil, utils.Localize(c, "request_is_invalid")))
+		return
+	}
+
+	conversation, err := h.service.CreateConversation(c.Request.Context(), middleware.GetUserID(c), middleware.GetOrgID(c), request.Title)
+	if err != nil {
+		log.Error().Msg("error: " + err.Error())
+		c.JSON(500, NewResponse(nil, utils.Localize(c, "an_error_occurred_while_processing_your_request")))
+		return
+	}
+
+	c.JSON(201, NewResponse(toConversationDTO(conversation), utils.Localize(c, "conversation_created_successfully")))
+}
+
+func (h *Handler) HandleGetConversations(c *gin.Context) {
+	var request PaginationRequest
+	if err := c.ShouldBindQuery(&request); err != nil {
+		c.JSON(400, NewResponse(nil, utils.Localize(c, "request_is_invalid")))
+		return
+	}
+
+	conversations, total, err := h.service.GetConversations(c.Request.Context(), middleware.GetUserID(c), request.Page, request.PageSize)
+	if err != nil {
+		log.Error().Msg("error: " + err.Error())
+		c.JSON(500, NewResponse(nil, utils.Localize(c, "an_error_occurred_while_processing_your_request")))
+		return
+	}
+
+	conversationsDTO := make([]ConversationDTO, len(conversations))
+	for i := range conversations {
+		conversationsDTO[i] = toConversationDTO(&conversations[i])
+	}
+
+	c.JSON(200, NewResponse(GetConversationsResponseDTO{
+		Conversations: conversationsDTO,
+		PageSize:      request.PageSize,
+		Page:          request.Page,
+		Total:         total,
+	}, utils.Localize(c, "conversations_fetched_successfully")))
+}
+
+func (h *Handler) HandleGetConversation(c *gin.Context) {
+	conversation, err := h.service.GetConversation(c.Request.Context(), middleware.GetUserID(c), c.Param("id"))
+	if errors.Is(err, service.ErrConversationNotFound) {
+		c.JSON(404, NewResponse(nil, utils.Localize(c, "conversation_not_found")))
+		return
+	}
+	if err != nil {
+		log.Error().Msg("error: " + err.Error())
+		c.JSON(500, NewResponse(nil, utils.Localize(c, "an_error_occurred_while_processing_your_request")))
+		return
+	}
+
+	messages := make([]ConversationMessageDTO, len(conversation.Messages))
+	for i, message := range conversation.Messages {
+		chunkIDs := make([]string, len(message.ChunkIDs))
+		for j, chunkID := range message.ChunkIDs {
+			chunkIDs[j] = chunkID.String()
+		}
+		messages[i] = ConversationMessageDTO{
+			MessageID: message.ID.String(),
+			Role:      dto.Role(message.Role),
+			Content:   message.Content,
+			ChunkIDs:  chunkIDs,
+			CreatedAt: message.CreatedAt,
+		}
+	}
+
+	c.JSON(200, NewResponse(ConversationDetailDTO{
+		ConversationDTO: toConversationDTO(conversation),
+		Messages:        messages,
+	}, utils.Localize(c, "conversation_fetched_successfully")))
+}
+
+func (h *Handler) HandleDeleteConversation(c *gin.Context) {
+	err := h.service.DeleteConversation(c.Request.Context(), middleware.GetUserID(c), c.Param("id"))
+	if errors.Is(err, service.ErrConversationNotFound) {
+		c.JSON(404, NewResponse(nil, utils.Localize(c, "conversation_not_found")))
+		return
+	}
+	if err != nil {
+		log.Error().Msg("error: " + err.Error())
+		c.JSON(500, NewResponse(nil, utils.Localize(c, "an_error_occurred_while_processing_your_request")))
+		return
+	}
+
+	c.JSON(200, NewResponse(nil, utils.Localize(c, "conversation_deleted_successfully")))
+}
+
+func toConversationDTO(conversation *repository.Conversation) ConversationDTO {
+	return ConversationDTO{
+		ConversationID: conversation.ID.String(),
+		Title:          conversation.Title,
+		CreatedAt:      conversation.CreatedAt,
+		UpdatedAt:      conversation.UpdatedAt,
+	}
+}
@@ -0,0 +1,149 @@
+package handler
+
+import (
+	"errors"
+
+	"patient-chatbot/internal/middleware"
+	"patient-chatbot/internal/repository"
+	"patient-chatbot/internal/service"
+	"patient-chatbot/internal/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+func (h *Handler) HandleGetCopingStrategies(c *gin.Context) {
+	strategies, err := h.service.GetCopingStrategies(c.Request.Context(), middleware.GetUserID(c), middleware.GetOrgID(c), middleware.GetLang(c))
+	if err != nil {
+		log.Error().Msg("error: " + err.Error())
+		c.JSON(500, NewResponse(nil, utils.Localize(c, "an_error_occurred_while_processing_your_request")))
+		return
+	}
+
+	c.JSON(200, NewResponse(strategies, utils.Localize(c, "coping_strategies_fetched_successfully")))
+}
+
+func (h *Handler) HandleGetCopingStrategyCatalogue(c *gin.Context) {
+	strategies, err := h.service.GetOrganizationCopingStrategies(c.Request.Context(), middleware.GetOrgID(c))
+	if err != nil {
+		log.Error().Msg("error: " + err.Error())
+		c.JSON(500, NewResponse(nil, utils.Localize(c, "an_error_occurred_while_processing_your_request")))
+		return
+	}
+
+	strategiesDTO := make([]CopingStrategyDTO, len(strategies))
+	for i := range strategies {
+		strategiesDTO[i] = toCopingStrategyDTO(&strategies[i])
+	}
+	c.JSON(200, NewResponse(strategiesDTO, utils.Localize(c, "coping_strategies_fetched_successfully")))
+}
+
+func (h *Handler) HandleCreateCopingStrategy(c *gin.Context) {
+	var request CopingStrategyRequestDTO
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(400, NewResponse(nil, utils.Localize(c, "request_is_invalid")))
+		return
+	}
+
+	strategy, err := h.service.CreateCopingStrategy(c.Request.Context(), middleware.GetOrgID(c), toCopingStrategyInput(request))
+	if errors.Is(err, service.ErrInvalidCopingStrategy) {
+		c.JSON(400, NewResponse(nil, utils.Localize(c, "request_is_invalid")))
+		return
+	}
+	if errors.Is(err, service.ErrCopingStrategyKeyTaken) {
+		c.JSON(409, NewResponse(nil, utils.Localize(c, "coping_strategy_key_taken")))
+		return
+	}
+	if err != nil {
+		log.Error().Msg("error: " + err.Error())
+		c.JSON(500, NewResponse(nil, utils.Localize(c, "an_error_occurred_while_processing_your_request")))
+		return
+	}
+
+	c.JSON(201, NewResponse(toCopingStrategyDTO(strategy), utils.Localize(c, "coping_strategy_created_successfully")))
+}
+
+func (h *Handler) HandleUpdateCopingStrategy(c *gin.Context) {
+	var request CopingStrategyRequestDTO
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(400, NewResponse(nil, utils.Localize(c, "request_is_invalid")))
+		return
+	}
+
+	strategy, err := h.service.UpdateCopingStrategy(c.Request.Context(), middleware.GetOrgID(c), c.Param("id"), toCopingStrategyInput(request))
+	if errors.Is(err, service.ErrCopingStrategyNotFound) {
+		c.JSON(404, NewResponse(nil, utils.Localize(c, "coping_strategy_not_found")))
+		return
+	}
+	if err != nil {
+		log.Error().Msg("error: " + err.Error())
+		c.JSON(500, NewResponse(nil, utils.Localize(c, "an_error_occurred_while_processing_your_request")))
+		return
+	}
+
+	c.JSON(200, NewResponse(toCopingStrategyDTO(strategy), utils.Localize(c, "coping_strategy_updated_successfully")))
+}
+
+func (h *Handler) HandleDeleteCopingStrategy(c *gin.Context) {
+	err := h.service.DeleteCopingStrategy(c.Request.Context(), middleware.GetOrgID(c), c.Param("id"))
+	if errors.Is(err, service.ErrCopingStrategyNotFound) {
+		c.JSON(404, NewResponse(nil, utils.Localize(c, "coping_strategy_not_found")))
+		return
+	}
+	if err != nil {
+		log.Error().Msg("error: " + err.Error())
+		c.JSON(500, NewResponse(nil, utils.Localize(c, "an_error_occurred_while_processing_your_request")))
+		return
+	}
+
+	c.JSON(200, NewResponse(nil, utils.Localize(c, "coping_strategy_deleted_successfully")))
+}
+
+// toCopingStrategyInput maps a request to the service input. Strategies are active unless
+// the request says otherwise.
+func toCopingStrategyInput(request CopingStrategyRequestDTO) service.CopingStrategyInput {
+	active := true
+	if request.Active != nil {
+		active = *request.Active
+	}
+	return service.CopingStrategyInput{
+		Key:             request.Key,
+		TitleEN:         request.TitleEN,
+		TitleAR:         request.TitleAR,
+		DescriptionEN:   request.DescriptionEN,
+		DescriptionAR:   request.DescriptionAR,
+		StepsEN:         request.StepsEN,
+		StepsAR:         request.StepsAR,
+		EstimatedTimeEN: request.EstimatedTimeEN,
+		EstimatedTimeAR: request.EstimatedTimeAR,
+		Source:          request.Source,
+		Active:          active,
+		Position:        request.Position,
+	}
+}
+
+func toCopingStrategyDTO(strategy *repository.CopingStrategy) CopingStrategyDTO {
+	stepsEN, stepsAR := []string(strategy.StepsEN), []string(strategy.StepsAR)
+	if stepsEN == nil {
+		stepsEN = []string{}
+	}
+	if stepsAR == nil {
+		stepsAR = []string{}
+	}
+	return CopingStrategyDTO{
+		CopingStrategyID: strategy.ID.String(),
+		Key:              strategy.Key,
+		TitleEN:          strategy.TitleEN,
+		TitleAR:          strategy.TitleAR,
+		DescriptionEN:    strategy.DescriptionEN,
+		DescriptionAR:    strategy.DescriptionAR,
+		StepsEN:          stepsEN,
+		StepsAR:          stepsAR,
+		EstimatedTimeEN:  strategy.EstimatedTimeEN,
+		EstimatedTimeAR:  strategy.EstimatedTimeAR,
+		Source:           strategy.Source,
+		Active:           strategy.Active,
+		Position:         strategy.Position,
+		UpdatedAt:        strategy.UpdatedAt,
+	}
+}
@@ -0,0 +1,123 @@
+package handler
+
+import (
+	"errors"
+
+	"patient-chatbot/internal/middleware"
+	"patient-chatbot/internal/repository"
+	"patient-chatbot/internal/service"
+	"patient-chatbot/internal/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+func (h *Handler) HandleLogCraving(c *gin.Context) {
+	var request LogCravingRequestDTO
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(400, NewResponse(nil, utils.Localize(c, "request_is_invalid")))
+		return
+	}
+
+	input := service.CravingInput{
+		Intensity: request.Intensity,
+		Triggers:  request.Triggers,
+		Location:  request.Location,
+		Notes:     request.Notes,
+	}
+	if request.OccurredAt != nil {
+		input.OccurredAt = *request.OccurredAt
+	}
+	if request.Outcome != "" {
+		outcome := repository.CravingOutcome(request.Outcome)
+		input.Outcome = &outcome
+	}
+
+	logged, err := h.service.LogCraving(c.Request.Context(), middleware.GetUserID(c), middleware.GetOrgID(c), input, middleware.GetLang(c))
+	if errors.Is(err, service.ErrInvalidCraving) {
+		c.JSON(400, NewResponse(nil, utils.Localize(c, "request_is_invalid")))
+		return
+	}
+	if err != nil {
+		log.Error().Msg("error: " + err.Error())
+		c.JSON(500, NewResponse(nil, utils.Localize(c, "an_error_occurred_while_processing_your_request")))
+		return
+	}
+
+	c.JSON(201, NewResponse(LogCravingResponseDTO{
+		Craving:  toCravingDTO(logged.Craving),
+		Strategy: logged.Strategy,
+		Sources:  logged.Sources,
+	}, utils.Localize(c, "craving_logged_successfully")))
+}
+
+func (h *Handler) HandleGetCravings(c *gin.Context) {
+	var request PaginationRequest
+	if err := c.ShouldBindQuery(&request); err != nil {
+		c.JSON(400, NewResponse(nil, utils.Localize(c, "request_is_invalid")))
+		return
+	}
+
+	cravings, total, err := h.service.GetCravings(c.Request.Context(), middleware.GetUserID(c), request.Page, request.PageSize)
+	if err != nil {
+		log.Error().Msg("error: " + err.Error())
+		c.JSON(500, NewResponse(nil, utils.Localize(c, "an_error_occurred_while_processing_your_request")))
+		return
+	}
+
+	cravingsDTO := make([]CravingDTO, len(cravings))
+	for i := range cravings {
+		cravingsDTO[i] = toCravingDTO(&cravings[i])
+	}
+
+	c.JSON(200, NewResponse(GetCravingsResponseDTO{
+		Cravings: cravingsDTO,
+		PageSize: request.PageSize,
+		Page:     request.Page,
+		Total:    total,
+	}, utils.Localize(c, "cravings_fetched_successfully")))
+}
+
+func (h *Handler) HandleSetCravingOutcome(c *gin.Context) {
+	var request CravingOutcomeRequestDTO
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(400, NewResponse(nil, utils.Localize(c, "request_is_invalid")))
+		return
+	}
+
+	craving, err := h.service.SetCravingOutcome(c.Request.Context(), middleware.GetUserID(c), c.Param("id"), repository.CravingOutcome(request.Outcome))
+	if errors.Is(err, service.ErrCravingNotFound) {
+		c.JSON(404, NewResponse(nil, utils.Localize(c, "craving_not_found")))
+		return
+	}
+	if err != nil {
+		log.Error().Msg("error: " + err.Error())
+		c.JSON(500, NewResponse(nil, utils.Localize(c, "an_error_occurred_while_processing_your_request")))
+		return
+	}
+
+	c.JSON(200, NewResponse(toCravingDTO(craving), utils.Localize(c, "craving_updated_successfully")))
+}
+
+func toCravingDTO(craving *repository.Craving) CravingDTO {
+	var outcome *string
+	if craving.Outcome != nil {
+		value := string(*craving.Outcome)
+		outcome = &value
+	}
+	triggers := []string(craving.Triggers)
+	if triggers == nil {
+		triggers = []string{}
+	}
+	return CravingDTO{
+		CravingID:   craving.ID.String(),
+		Intensity:   craving.Intensity,
+		Triggers:    triggers,
+		Location:    craving.Location,
+		Notes:       craving.Notes,
+		Outcome:     outcome,
+		StrategyKey: craving.StrategyKey,
+		OccurredAt:  craving.OccurredAt,
+		CreatedAt:   craving.CreatedAt,
+	}
+}
@@ -1,13 +1,14 @@
 package handler
 
 import (
+	"errors"
 	"patient-chatbot/internal/middleware"
+	"patient-chatbot/internal/repository"
 	"patient-chatbot/internal/service"
 	"patient-chatbot/internal/utils"
 	"time"
 
 	"github.com/gin-gonic/gin"
-	"github.com/google/uuid"
 	"github.com/rs/zerolog/log"
 )
 
@@ -30,15 +31,24 @@ func (h *Handler) HandleChat(c *gin.Context) {
 		return
 	}
 
+	if wantsStream(c) {
+		h.streamChat(c, request)
+		return
+	}
+
 	lang := middleware.GetLang(c)
-	data, err := h.service.Chat(c.Request.Context(), request.Messages, lang)
+	data, err := h.service.Chat(c.Request.Context(), middleware.GetUserID(c), middleware.GetOrgID(c), request.conversationID(), request.Messages, lang)
+	if errors.Is(err, service.ErrConversationNotFound) {
+		c.JSON(404, NewResponse(nil, utils.Localize(c, "conversation_not_found")))
+		return
+	}
 	if err != nil {
 		log.Error().Msg("error: " + err.Error())
 		c.JSON(500, NewResponse(nil, utils.Localize(c, "an_error_occurred_while_processing_your_request")))
 		return
 	}
 
-	c.JSON(200, NewResponse(ChatResponseDTO{Answer: data}, utils.Localize(c, "chat_message_sent")))
+	c.JSON(200, NewResponse(ChatResponseDTO{Answer: data.Answer, Sources: data.Sources, Degraded: data.Degraded}, utils.Localize(c, "chat_message_sent")))
 }
 
 func (h *Handler) HandleUpload(c *gin.Context) {
@@ -48,14 +58,71 @@ func (h *Handler) HandleUpload(c *gin.Context) {
 		return
 	}
 
-	err := h.service.Upload(c.Request.Context(), request.File)
+	orgID := middleware.GetOrgID(c)
+	if request.OrgID != "" && request.OrgID != orgID.String() {
+		c.JSON(403, NewResponse(nil, utils.Localize(c, "forbidden")))
+		return
+	}
+
+	job, err := h.service.Upload(c.Request.Context(), orgID, request.File)
+	if errors.Is(err, service.ErrUnsupportedFileType) {
+		c.JSON(415, NewResponse(nil, utils.Localize(c, "file_type_not_supported")))
+		return
+	}
 	if err != nil {
 		log.Error().Msg("error: " + err.Error())
 		c.JSON(500, NewResponse(nil, err.Error()))
 		return
 	}
 
-	c.JSON(200, NewResponse(nil, utils.Localize(c, "file_uploaded_successfully")))
+	c.JSON(202, NewResponse(UploadResponseDTO{
+		DocID:           job.DocumentID.String(),
+		IngestionStatus: string(job.Status),
+	}, utils.Localize(c, "file_uploaded_successfully")))
+}
+
+func (h *Handler) HandleGetDocumentStatus(c *gin.Context) {
+	job, err := h.service.GetIngestionStatus(c.Request.Context(), middleware.GetOrgID(c), c.Param("id"))
+	if errors.Is(err, service.ErrDocumentNotFound) {
+		c.JSON(404, NewResponse(nil, utils.Localize(c, "document_not_found")))
+		return
+	}
+	if err != nil {
+		log.Error().Msg("error: " + err.Error())
+		c.JSON(500, NewResponse(nil, utils.Localize(c, "an_error_occurred_while_processing_your_request")))
+		return
+	}
+
+	c.JSON(200, NewResponse(toIngestionStatusDTO(job), utils.Localize(c, "document_status_fetched_successfully")))
+}
+
+func (h *Handler) HandleRetryDocumentIngestion(c *gin.Context) {
+	job, err := h.service.RetryIngestion(c.Request.Context(), middleware.GetOrgID(c), c.Param("id"))
+	if errors.Is(err, service.ErrDocumentNotFound) {
+		c.JSON(404, NewResponse(nil, utils.Localize(c, "document_not_found")))
+		return
+	}
+	if errors.Is(err, service.ErrIngestionNotFailed) {
+		c.JSON(409, NewResponse(nil, utils.Localize(c, "document_ingestion_not_failed")))
+		return
+	}
+	if err != nil {
+		log.Error().Msg("error: " + err.Error())
+		c.JSON(500, NewResponse(nil, utils.Localize(c, "an_error_occurred_while_processing_your_request")))
+		return
+	}
+
+	c.JSON(202, NewResponse(toIngestionStatusDTO(job), utils.Localize(c, "document_ingestion_retried_successfully")))
+}
+
+func toIngestionStatusDTO(job *repository.IngestionJob) IngestionStatusDTO {
+	return IngestionStatusDTO{
+		DocID:     job.DocumentID.String(),
+		Status:    string(job.Status),
+		Attempts:  job.Attempts,
+		Error:     job.Error,
+		UpdatedAt: job.UpdatedAt,
+	}
 }
 
 func (h *Handler) HandleGetDocuments(c *gin.Context) {
@@ -65,7 +132,7 @@ func (h *Handler) HandleGetDocuments(c *gin.Context) {
 		return
 	}
 
-	documents, total, err := h.service.GetDocuments(c.Request.Context(), request.Page, request.PageSize)
+	documents, total, err := h.service.GetDocuments(c.Request.Context(), middleware.GetOrgID(c), request.Page, request.PageSize)
 	if err != nil {
 		log.Error().Msg("error: " + err.Error())
 		c.JSON(500, NewResponse(nil, utils.Localize(c, "an_error_occurred_while_processing_your_request")))
@@ -85,6 +152,7 @@ func (h *Handler) HandleGetDocuments(c *gin.Context) {
 			DocumentID:        document.ID.String(),
 			DocumentName:      document.Title + document.Extension,
 			DocumentExtension: Extension(document.Extension),
+			Summary:           document.Summary,
 			ExtractedContent:  chunkContents,
 			UploadedAt:        document.CreatedAt.Format("2006-01-02"),
 		}
@@ -100,7 +168,7 @@ func (h *Handler) HandleGetDocuments(c *gin.Context) {
 
 func (h *Handler) HandleDeleteDocument(c *gin.Context) {
 	id := c.Param("id")
-	err := h.service.DeleteDocument(c.Request.Context(), id)
+	err := h.service.DeleteDocument(c.Request.Context(), middleware.GetOrgID(c), id)
 	if err != nil {
 		log.Error().Msg("error: " + err.Error())
 		c.JSON(500, NewResponse(nil, utils.Localize(c, "an_error_occurred_while_processing_your_request")))
@@ -111,7 +179,7 @@ func (h *Handler) HandleDeleteDocument(c *gin.Context) {
 
 func (h *Handler) HandleDeleteContent(c *gin.Context) {
 	id := c.Param("id")
-	err := h.service.DeleteChunk(c.Request.Context(), id)
+	err := h.service.DeleteChunk(c.Request.Context(), middleware.GetOrgID(c), id)
 	if err != nil {
 		log.Error().Msg("error: " + err.Error())
 		c.JSON(500, NewResponse(nil, utils.Localize(c, "an_error_occurred_while_processing_your_request")))
@@ -126,7 +194,7 @@ func (h *Handler) HandleGetDashboardCalendar(c *gin.Context) {
 		date = time.Now().Format("2006-01-02")
 	}
 
-	dashboardData, err := h.service.GetDashboardCalendar(c.Request.Context(), uuid.MustParse("d1fc8771-bac7-4080-913b-2b25e4ab4957"), date)
+	dashboardData, err := h.service.GetDashboardCalendar(c.Request.Context(), middleware.GetUserID(c), date)
 	if err != nil {
 		log.Error().Msg("error: " + err.Error())
 		c.JSON(500, NewResponse(nil, utils.Localize(c, "an_error_occurred_while_processing_your_request")))
@@ -136,7 +204,7 @@ func (h *Handler) HandleGetDashboardCalendar(c *gin.Context) {
 }
 
 func (h *Handler) HandleGetDashboardData(c *gin.Context) {
-	dashboardData, err := h.service.GetDashboardData(c.Request.Context(), uuid.MustParse("d1fc8771-bac7-4080-913b-2b25e4ab4957"))
+	dashboardData, err := h.service.GetDashboardData(c.Request.Context(), middleware.GetUserID(c))
 	if err != nil {
 		log.Error().Msg("error: " + err.Error())
 		c.JSON(500, NewResponse(nil, utils.Localize(c, "an_error_occurred_while_processing_your_request")))
@@ -146,7 +214,7 @@ func (h *Handler) HandleGetDashboardData(c *gin.Context) {
 }
 
 func (h *Handler) HandleReportSlip(c *gin.Context) {
-	err := h.service.ReportSlip(c.Request.Context(), uuid.MustParse("d1fc8771-bac7-4080-913b-2b25e4ab4957"))
+	err := h.service.ReportSlip(c.Request.Context(), middleware.GetUserID(c))
 	if err != nil {
 		log.Error().Msg("error: " + err.Error())
 		c.JSON(500, NewResponse(nil, utils.Localize(c, "an_error_occurred_while_processing_your_request")))
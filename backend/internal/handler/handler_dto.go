@@ -3,6 +3,9 @@ package handler
 import (
 	"mime/multipart"
 	"patient-chatbot/internal/dto"
+	"time"
+
+	"github.com/google/uuid"
 )
 
 type HandlerResponse struct {
@@ -17,20 +20,63 @@ func NewResponse(data interface{}, message string) HandlerResponse {
 	}
 }
 
+type CreateOrganizationRequestDTO struct {
+	Name          string `json:"name" binding:"required,max=255"`
+	AdminEmail    string `json:"admin_email" binding:"required,email,max=255"`
+	AdminPassword string `json:"admin_password" binding:"required,min=8,max=72"`
+}
+
+type SignupRequestDTO struct {
+	OrganizationID string `json:"organization_id" binding:"required,uuid"`
+	Email          string `json:"email" binding:"required,email,max=255"`
+	Password       string `json:"password" binding:"required,min=8,max=72"`
+}
+
+type LoginRequestDTO struct {
+	Email    string `json:"email" binding:"required,email"`
+	Password string `json:"password" binding:"required"`
+}
+
+type RefreshRequestDTO struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
 type ChatRequestDTO struct {
-	Messages []dto.Message `json:"messages" binding:"required"`
+	ConversationID string        `json:"conversation_id" binding:"omitempty,uuid"`
+	Messages       []dto.Message `json:"messages" binding:"required,min=1,dive"`
+}
+
+// conversationID returns the parsed conversation ID, or nil for a stateless chat.
+func (r ChatRequestDTO) conversationID() *uuid.UUID {
+	if r.ConversationID == "" {
+		return nil
+	}
+	id := uuid.MustParse(r.ConversationID)
+	return &id
 }
 
 type ChatResponseDTO struct {
-	Answer string `json:"answer"`
+	Answer   string       `json:"answer"`
+	Sources  []dto.Source `json:"sources"`
+	Degraded bool         `json:"degraded"`
 }
 
 type UploadRequestDTO struct {
-	File *multipart.FileHeader `form:"file"  binding:"required"`
+	OrgID string                `form:"org_id"`
+	File  *multipart.FileHeader `form:"file"  binding:"required"`
 }
 
 type UploadResponseDTO struct {
-	Answer string `json:"answer"`
+	DocID           string `json:"doc_id"`
+	IngestionStatus string `json:"ingestion_status"`
+}
+
+type IngestionStatusDTO struct {
+	DocID     string    `json:"doc_id"`
+	Status    string    `json:"status"`
+	Attempts  int       `json:"attempts"`
+	Error     *string   `json:"error"`
+	UpdatedAt time.Time `json:"updated_at"`
 }
 
 type Extension string
@@ -66,6 +112,7 @@ type Documents struct {
 	DocumentID        string             `json:"document_id"`
 	DocumentName      string             `json:"document_name"`
 	DocumentExtension Extension          `json:"document_extension"`
+	Summary           string             `json:"summary"`
 	ExtractedContent  []ExtractedContent `json:"extracted_content"`
 	UploadedAt        string             `json:"uploaded_at"`
 }
@@ -74,3 +121,319 @@ type ExtractedContent struct {
 	ContentID string `json:"content_id"`
 	Content   string `json:"content"`
 }
+
+type CreateConversationRequestDTO struct {
+	Title string `json:"title" binding:"max=255"`
+}
+
+type ConversationDTO struct {
+	ConversationID string    `json:"conversation_id"`
+	Title          string    `json:"title"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+type ConversationMessageDTO struct {
+	MessageID string    `json:"message_id"`
+	Role      dto.Role  `json:"role"`
+	Content   string    `json:"content"`
+	ChunkIDs  []string  `json:"chunk_ids"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type ConversationDetailDTO struct {
+	ConversationDTO
+	Messages []ConversationMessageDTO `json:"messages"`
+}
+
+type GetConversationsResponseDTO struct {
+	Conversations []ConversationDTO `json:"conversations"`
+	PageSize      int               `json:"page_size"`
+	Page          int               `json:"page"`
+	Total         int               `json:"total"`
+}
+
+type LogCravingRequestDTO struct {
+	Intensity  int        `json:"intensity" binding:"required,min=1,max=10"`
+	Triggers   []string   `json:"triggers" binding:"max=10,dive,max=50"`
+	Location   string     `json:"location" binding:"max=255"`
+	Notes      string     `json:"notes" binding:"max=2000"`
+	OccurredAt *time.Time `json:"occurred_at"`
+	Outcome    string     `json:"outcome" binding:"omitempty,oneof=RESISTED SLIPPED"`
+}
+
+type CravingOutcomeRequestDTO struct {
+	Outcome string `json:"outcome" binding:"required,oneof=RESISTED SLIPPED"`
+}
+
+type CravingDTO struct {
+	CravingID   string    `json:"craving_id"`
+	Intensity   int       `json:"intensity"`
+	Triggers    []string  `json:"triggers"`
+	Location    *string   `json:"location"`
+	Notes       *string   `json:"notes"`
+	Outcome     *string   `json:"outcome"`
+	StrategyKey *string   `json:"strategy_key"`
+	OccurredAt  time.Time `json:"occurred_at"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+type LogCravingResponseDTO struct {
+	Craving  CravingDTO          `json:"craving"`
+	Strategy *dto.CopingStrategy `json:"strategy"`
+	Sources  []dto.Source        `json:"sources"`
+}
+
+type GetCravingsResponseDTO struct {
+	Cravings []CravingDTO `json:"cravings"`
+	PageSize int          `json:"page_size"`
+	Page     int          `json:"page"`
+	Total    int          `json:"total"`
+}
+
+type CopingStrategyRequestDTO struct {
+	Key             string   `json:"key" binding:"max=64"`
+	TitleEN         string   `json:"title_en" binding:"required,max=255"`
+	TitleAR         string   `json:"title_ar" binding:"max=255"`
+	DescriptionEN   string   `json:"description_en" binding:"max=2000"`
+	DescriptionAR   string   `json:"description_ar" binding:"max=2000"`
+	StepsEN         []string `json:"steps_en" binding:"max=20,dive,max=500"`
+	StepsAR         []string `json:"steps_ar" binding:"max=20,dive,max=500"`
+	EstimatedTimeEN string   `json:"estimated_time_en" binding:"max=50"`
+	EstimatedTimeAR string   `json:"estimated_time_ar" binding:"max=50"`
+	Source          string   `json:"source" binding:"max=500"`
+	Active          *bool    `json:"active"`
+	Position        int      `json:"position" binding:"min=0"`
+}
+
+type CopingStrategyDTO struct {
+	CopingStrategyID string    `json:"coping_strategy_id"`
+	Key              string    `json:"key"`
+	TitleEN          string    `json:"title_en"`
+	TitleAR          string    `json:"title_ar"`
+	DescriptionEN    string    `json:"description_en"`
+	DescriptionAR    string    `json:"description_ar"`
+	StepsEN          []string  `json:"steps_en"`
+	StepsAR          []string  `json:"steps_ar"`
+	EstimatedTimeEN  string    `json:"estimated_time_en"`
+	EstimatedTimeAR  string    `json:"estimated_time_ar"`
+	Source           string    `json:"source"`
+	Active           bool      `json:"active"`
+	Position         int       `json:"position"`
+	UpdatedAt        time.Time `json:"updated_at"`
+}
+
+type QuitDateRequestDTO struct {
+	QuitDate time.Time `json:"quit_date" binding:"required"`
+}
+
+type MilestoneRequestDTO struct {
+	Key           string `json:"key" binding:"max=64"`
+	TitleEN       string `json:"title_en" binding:"required,max=255"`
+	TitleAR       string `json:"title_ar" binding:"max=255"`
+	DescriptionEN string `json:"description_en" binding:"max=2000"`
+	DescriptionAR string `json:"description_ar" binding:"max=2000"`
+	Category      string `json:"category" binding:"required,oneof=immediate short medium long"`
+	AfterMinutes  int    `json:"after_minutes" binding:"required,min=1"`
+	Active        *bool  `json:"active"`
+}
+
+type MilestoneDefinitionDTO struct {
+	MilestoneID   string    `json:"milestone_id"`
+	Key           string    `json:"key"`
+	TitleEN       string    `json:"title_en"`
+	TitleAR       string    `json:"title_ar"`
+	DescriptionEN string    `json:"description_en"`
+	DescriptionAR string    `json:"description_ar"`
+	Category      string    `json:"category"`
+	AfterMinutes  int       `json:"after_minutes"`
+	Active        bool      `json:"active"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+type ClinicianRequestDTO struct {
+	Name      string `json:"name" binding:"required,max=255"`
+	Specialty string `json:"specialty" binding:"max=255"`
+	Location  string `json:"location" binding:"max=255"`
+	Active    *bool  `json:"active"`
+}
+
+type ClinicianDTO struct {
+	ClinicianID string `json:"clinician_id"`
+	Name        string `json:"name"`
+	Specialty   string `json:"specialty"`
+	Location    string `json:"location"`
+	Active      bool   `json:"active"`
+}
+
+type AvailabilityRequestDTO struct {
+	From        time.Time `json:"from" binding:"required"`
+	To          time.Time `json:"to" binding:"required"`
+	SlotMinutes int       `json:"slot_minutes" binding:"required"`
+}
+
+type GetSlotsRequestDTO struct {
+	ClinicianID string    `form:"clinician_id"`
+	From        time.Time `form:"from"`
+	To          time.Time `form:"to"`
+}
+
+type SlotDTO struct {
+	SlotID      string    `json:"slot_id"`
+	ClinicianID string    `json:"clinician_id"`
+	Clinician   string    `json:"clinician"`
+	Location    string    `json:"location"`
+	StartsAt    time.Time `json:"starts_at"`
+	EndsAt      time.Time `json:"ends_at"`
+}
+
+type BookAppointmentRequestDTO struct {
+	SlotID string `json:"slot_id" binding:"required"`
+	Title  string `json:"title" binding:"required,max=255"`
+	Type   string `json:"type" binding:"required,oneof=CONSULTATION FOLLOW_UP TEST PROCEDURE"`
+	Notes  string `json:"notes" binding:"max=2000"`
+}
+
+type RescheduleAppointmentRequestDTO struct {
+	SlotID string `json:"slot_id" binding:"required"`
+}
+
+type AppointmentDTO struct {
+	AppointmentID string    `json:"appointment_id"`
+	Title         string    `json:"title"`
+	Type          string    `json:"type"`
+	Status        string    `json:"status"`
+	ClinicianID   string    `json:"clinician_id"`
+	Clinician     string    `json:"clinician"`
+	Location      string    `json:"location"`
+	Notes         *string   `json:"notes"`
+	StartsAt      time.Time `json:"starts_at"`
+	EndsAt        time.Time `json:"ends_at"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+type GetAppointmentsResponseDTO struct {
+	Appointments []AppointmentDTO `json:"appointments"`
+	PageSize     int              `json:"page_size"`
+	Page         int              `json:"page"`
+	Total        int              `json:"total"`
+}
+
+type CalendarIntegrationRequestDTO struct {
+	URL      string `json:"url" binding:"required,max=1024"`
+	Username string `json:"username" binding:"max=255"`
+	Password string `json:"password"`
+	Enabled  *bool  `json:"enabled"`
+}
+
+// CalendarIntegrationDTO never includes the password.
+type CalendarIntegrationDTO struct {
+	URL           string     `json:"url"`
+	Username      string     `json:"username"`
+	Enabled       bool       `json:"enabled"`
+	LastSyncedAt  *time.Time `json:"last_synced_at"`
+	LastSyncError *string    `json:"last_sync_error"`
+}
+
+type CalendarFeedDTO struct {
+	URL string `json:"url"`
+}
+
+// NotificationPreferencesRequestDTO replaces the patient's preferences. Omitted switches are
+// turned on. Quiet hours are "HH:MM" in the patient's timezone; leave both out for none.
+type NotificationPreferencesRequestDTO struct {
+	Timezone             string `json:"timezone" binding:"required,max=64"`
+	Language             string `json:"language" binding:"required,oneof=en ar"`
+	QuietHoursStart      string `json:"quiet_hours_start" binding:"omitempty,len=5"`
+	QuietHoursEnd        string `json:"quiet_hours_end" binding:"omitempty,len=5"`
+	EmailEnabled         *bool  `json:"email_enabled"`
+	AppointmentReminders *bool  `json:"appointment_reminders"`
+	DailyCheckIns        *bool  `json:"daily_check_ins"`
+	MilestoneAlerts      *bool  `json:"milestone_alerts"`
+	StreakNudges         *bool  `json:"streak_nudges"`
+}
+
+type NotificationPreferencesDTO struct {
+	Timezone             string  `json:"timezone"`
+	Language             string  `json:"language"`
+	QuietHoursStart      *string `json:"quiet_hours_start"`
+	QuietHoursEnd        *string `json:"quiet_hours_end"`
+	EmailEnabled         bool    `json:"email_enabled"`
+	AppointmentReminders bool    `json:"appointment_reminders"`
+	DailyCheckIns        bool    `json:"daily_check_ins"`
+	MilestoneAlerts      bool    `json:"milestone_alerts"`
+	StreakNudges         bool    `json:"streak_nudges"`
+}
+
+type NotificationWebhookRequestDTO struct {
+	URL     string `json:"url" binding:"required,max=1024"`
+	Secret  string `json:"secret"`
+	Enabled *bool  `json:"enabled"`
+}
+
+// NotificationWebhookDTO never includes the secret.
+type NotificationWebhookDTO struct {
+	URL       string    `json:"url"`
+	Enabled   bool      `json:"enabled"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// AssistantSettingsRequestDTO is a full set of assistant settings; omitted fields use the
+// built-in defaults. base_version is the version being edited, to detect concurrent saves.
+type AssistantSettingsRequestDTO struct {
+	PersonaName         string  `json:"persona_name" binding:"max=64"`
+	Tone                string  `json:"tone" binding:"omitempty,oneof=empathetic motivational professional friendly"`
+	MaxCompletionTokens int     `json:"max_completion_tokens" binding:"omitempty,min=64,max=4096"`
+	Temperature         float32 `json:"temperature" binding:"min=0,max=2"`
+	OutOfScopeRefusalEN string  `json:"out_of_scope_refusal_en" binding:"max=1000"`
+	OutOfScopeRefusalAR string  `json:"out_of_scope_refusal_ar" binding:"max=1000"`
+	MedicalRefusalEN    string  `json:"medical_refusal_en" binding:"max=1000"`
+	MedicalRefusalAR    string  `json:"medical_refusal_ar" binding:"max=1000"`
+	PromptTemplateEN    string  `json:"prompt_template_en" binding:"max=20000"`
+	PromptTemplateAR    string  `json:"prompt_template_ar" binding:"max=20000"`
+	BaseVersion         *int    `json:"base_version" binding:"omitempty,min=0"`
+}
+
+// AssistantSettingsPreviewRequestDTO previews unsaved settings in lang. When message is set,
+// the context is retrieved for it from the organization's documents.
+type AssistantSettingsPreviewRequestDTO struct {
+	AssistantSettingsRequestDTO
+	Lang    string `json:"lang" binding:"omitempty,oneof=en ar"`
+	Message string `json:"message" binding:"max=2000"`
+}
+
+type AssistantSettingsDTO struct {
+	Version             int       `json:"version"`
+	PersonaName         string    `json:"persona_name"`
+	Tone                string    `json:"tone"`
+	MaxCompletionTokens int       `json:"max_completion_tokens"`
+	Temperature         float32   `json:"temperature"`
+	OutOfScopeRefusalEN string    `json:"out_of_scope_refusal_en"`
+	OutOfScopeRefusalAR string    `json:"out_of_scope_refusal_ar"`
+	MedicalRefusalEN    string    `json:"medical_refusal_en"`
+	MedicalRefusalAR    string    `json:"medical_refusal_ar"`
+	PromptTemplateEN    string    `json:"prompt_template_en"`
+	PromptTemplateAR    string    `json:"prompt_template_ar"`
+	CreatedBy           *string   `json:"created_by"`
+	CreatedAt           time.Time `json:"created_at"`
+}
+
+type GetAssistantSettingsVersionsResponseDTO struct {
+	Versions []AssistantSettingsDTO `json:"versions"`
+	PageSize int                    `json:"page_size"`
+	Page     int                    `json:"page"`
+	Total    int                    `json:"total"`
+}
+
+type AssistantPreviewDTO struct {
+	SystemPrompt string   `json:"system_prompt"`
+	Temperature  float32  `json:"temperature"`
+	MaxTokens    int      `json:"max_tokens"`
+	Context      []string `json:"context"`
+}
+
+// AssistantSettingsErrorDTO says what is wrong with rejected settings, e.g. where a prompt
+// template fails to parse.
+type AssistantSettingsErrorDTO struct {
+	Error string `json:"error"`
+}
@@ -0,0 +1,159 @@
+package handler
+
+import (
+	"errors"
+
+	"patient-chatbot/internal/middleware"
+	"patient-chatbot/internal/repository"
+	"patient-chatbot/internal/service"
+	"patient-chatbot/internal/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+func (h *Handler) HandleSetQuitDate(c *gin.Context) {
+	var request QuitDateRequestDTO
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(400, NewResponse(nil, utils.Localize(c, "request_is_invalid")))
+		return
+	}
+
+	err := h.service.SetQuitDate(c.Request.Context(), middleware.GetUserID(c), request.QuitDate)
+	if errors.Is(err, service.ErrInvalidQuitDate) {
+		c.JSON(400, NewResponse(nil, utils.Localize(c, "request_is_invalid")))
+		return
+	}
+	if err != nil {
+		log.Error().Msg("error: " + err.Error())
+		c.JSON(500, NewResponse(nil, utils.Localize(c, "an_error_occurred_while_processing_your_request")))
+		return
+	}
+
+	c.JSON(200, NewResponse(nil, utils.Localize(c, "quit_date_updated_successfully")))
+}
+
+func (h *Handler) HandleGetMilestones(c *gin.Context) {
+	timeline, err := h.service.GetMilestones(c.Request.Context(), middleware.GetUserID(c), middleware.GetOrgID(c), middleware.GetLang(c))
+	if err != nil {
+		log.Error().Msg("error: " + err.Error())
+		c.JSON(500, NewResponse(nil, utils.Localize(c, "an_error_occurred_while_processing_your_request")))
+		return
+	}
+
+	c.JSON(200, NewResponse(timeline, utils.Localize(c, "milestones_fetched_successfully")))
+}
+
+func (h *Handler) HandleGetMilestoneCatalogue(c *gin.Context) {
+	milestones, err := h.service.GetOrganizationMilestones(c.Request.Context(), middleware.GetOrgID(c))
+	if err != nil {
+		log.Error().Msg("error: " + err.Error())
+		c.JSON(500, NewResponse(nil, utils.Localize(c, "an_error_occurred_while_processing_your_request")))
+		return
+	}
+
+	milestonesDTO := make([]MilestoneDefinitionDTO, len(milestones))
+	for i := range milestones {
+		milestonesDTO[i] = toMilestoneDefinitionDTO(&milestones[i])
+	}
+	c.JSON(200, NewResponse(milestonesDTO, utils.Localize(c, "milestones_fetched_successfully")))
+}
+
+func (h *Handler) HandleCreateMilestone(c *gin.Context) {
+	var request MilestoneRequestDTO
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(400, NewResponse(nil, utils.Localize(c, "request_is_invalid")))
+		return
+	}
+
+	milestone, err := h.service.CreateMilestone(c.Request.Context(), middleware.GetOrgID(c), toMilestoneInput(request))
+	if errors.Is(err, service.ErrInvalidMilestone) {
+		c.JSON(400, NewResponse(nil, utils.Localize(c, "request_is_invalid")))
+		return
+	}
+	if errors.Is(err, service.ErrMilestoneKeyTaken) {
+		c.JSON(409, NewResponse(nil, utils.Localize(c, "milestone_key_taken")))
+		return
+	}
+	if err != nil {
+		log.Error().Msg("error: " + err.Error())
+		c.JSON(500, NewResponse(nil, utils.Localize(c, "an_error_occurred_while_processing_your_request")))
+		return
+	}
+
+	c.JSON(201, NewResponse(toMilestoneDefinitionDTO(milestone), utils.Localize(c, "milestone_created_successfully")))
+}
+
+func (h *Handler) HandleUpdateMilestone(c *gin.Context) {
+	var request MilestoneRequestDTO
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(400, NewResponse(nil, utils.Localize(c, "request_is_invalid")))
+		return
+	}
+
+	milestone, err := h.service.UpdateMilestone(c.Request.Context(), middleware.GetOrgID(c), c.Param("id"), toMilestoneInput(request))
+	if errors.Is(err, service.ErrInvalidMilestone) {
+		c.JSON(400, NewResponse(nil, utils.Localize(c, "request_is_invalid")))
+		return
+	}
+	if errors.Is(err, service.ErrMilestoneNotFound) {
+		c.JSON(404, NewResponse(nil, utils.Localize(c, "milestone_not_found")))
+		return
+	}
+	if err != nil {
+		log.Error().Msg("error: " + err.Error())
+		c.JSON(500, NewResponse(nil, utils.Localize(c, "an_error_occurred_while_processing_your_request")))
+		return
+	}
+
+	c.JSON(200, NewResponse(toMilestoneDefinitionDTO(milestone), utils.Localize(c, "milestone_updated_successfully")))
+}
+
+func (h *Handler) HandleDeleteMilestone(c *gin.Context) {
+	err := h.service.DeleteMilestone(c.Request.Context(), middleware.GetOrgID(c), c.Param("id"))
+	if errors.Is(err, service.ErrMilestoneNotFound) {
+		c.JSON(404, NewResponse(nil, utils.Localize(c, "milestone_not_found")))
+		return
+	}
+	if err != nil {
+		log.Error().Msg("error: " + err.Error())
+		c.JSON(500, NewResponse(nil, utils.Localize(c, "an_error_occurred_while_processing_your_request")))
+		return
+	}
+
+	c.JSON(200, NewResponse(nil, utils.Localize(c, "milestone_deleted_successfully")))
+}
+
+// toMilestoneInput maps a request to the service input. Milestones are active unless the
+// request says otherwise.
+func toMilestoneInput(request MilestoneRequestDTO) service.MilestoneInput {
+	active := true
+	if request.Active != nil {
+		active = *request.Active
+	}
+	return service.MilestoneInput{
+		Key:           request.Key,
+		TitleEN:       request.TitleEN,
+		TitleAR:       request.TitleAR,
+		DescriptionEN: request.DescriptionEN,
+		DescriptionAR: request.DescriptionAR,
+		Category:      repository.MilestoneCategory(request.Category),
+		AfterMinutes:  request.AfterMinutes,
+		Active:        active,
+	}
+}
+
+func toMilestoneDefinitionDTO(milestone *repository.MilestoneDefinition) MilestoneDefinitionDTO {
+	return MilestoneDefinitionDTO{
+		MilestoneID:   milestone.ID.String(),
+		Key:           milestone.Key,
+		TitleEN:       milestone.TitleEN,
+		TitleAR:       milestone.TitleAR,
+		DescriptionEN: milestone.DescriptionEN,
+		DescriptionAR: milestone.DescriptionAR,
+		Category:      string(milestone.Category),
+		AfterMinutes:  milestone.AfterMinutes,
+		Active:        milestone.Active,
+		UpdatedAt:     milestone.UpdatedAt,
+	}
+}
@@ -0,0 +1,152 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+
+	"patient-chatbot/internal/middleware"
+	"patient-chatbot/internal/repository"
+	"patient-chatbot/internal/service"
+	"patient-chatbot/internal/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+func (h *Handler) HandleGetNotificationPreferences(c *gin.Context) {
+	preferences, err := h.service.GetNotificationPreferences(c.Request.Context(), middleware.GetUserID(c), middleware.GetLang(c))
+	if err != nil {
+		log.Error().Msg("error: " + err.Error())
+		c.JSON(500, NewResponse(nil, utils.Localize(c, "an_error_occurred_while_processing_your_request")))
+		return
+	}
+
+	c.JSON(200, NewResponse(toNotificationPreferencesDTO(preferences), utils.Localize(c, "notification_preferences_fetched_successfully")))
+}
+
+func (h *Handler) HandleSetNotificationPreferences(c *gin.Context) {
+	var request NotificationPreferencesRequestDTO
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(400, NewResponse(nil, utils.Localize(c, "request_is_invalid")))
+		return
+	}
+
+	preferences, err := h.service.SetNotificationPreferences(c.Request.Context(), middleware.GetUserID(c), service.NotificationPreferencesInput{
+		Timezone:             request.Timezone,
+		Language:             request.Language,
+		QuietHoursStart:      request.QuietHoursStart,
+		QuietHoursEnd:        request.QuietHoursEnd,
+		EmailEnabled:         switchOn(request.EmailEnabled),
+		AppointmentReminders: switchOn(request.AppointmentReminders),
+		DailyCheckIns:        switchOn(request.DailyCheckIns),
+		MilestoneAlerts:      switchOn(request.MilestoneAlerts),
+		StreakNudges:         switchOn(request.StreakNudges),
+	})
+	if errors.Is(err, service.ErrInvalidNotificationPreferences) {
+		c.JSON(400, NewResponse(nil, utils.Localize(c, "request_is_invalid")))
+		return
+	}
+	if err != nil {
+		log.Error().Msg("error: " + err.Error())
+		c.JSON(500, NewResponse(nil, utils.Localize(c, "an_error_occurred_while_processing_your_request")))
+		return
+	}
+
+	c.JSON(200, NewResponse(toNotificationPreferencesDTO(preferences), utils.Localize(c, "notification_preferences_saved_successfully")))
+}
+
+func (h *Handler) HandleGetNotificationWebhook(c *gin.Context) {
+	webhook, err := h.service.GetNotificationWebhook(c.Request.Context(), middleware.GetOrgID(c))
+	if errors.Is(err, service.ErrNotificationWebhookNotFound) {
+		c.JSON(404, NewResponse(nil, utils.Localize(c, "notification_webhook_not_found")))
+		return
+	}
+	if err != nil {
+		log.Error().Msg("error: " + err.Error())
+		c.JSON(500, NewResponse(nil, utils.Localize(c, "an_error_occurred_while_processing_your_request")))
+		return
+	}
+
+	c.JSON(200, NewResponse(toNotificationWebhookDTO(webhook), utils.Localize(c, "notification_webhook_fetched_successfully")))
+}
+
+func (h *Handler) HandleSetNotificationWebhook(c *gin.Context) {
+	var request NotificationWebhookRequestDTO
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(400, NewResponse(nil, utils.Localize(c, "request_is_invalid")))
+		return
+	}
+
+	webhook, err := h.service.SetNotificationWebhook(c.Request.Context(), middleware.GetOrgID(c), service.NotificationWebhookInput{
+		URL:     request.URL,
+		Secret:  request.Secret,
+		Enabled: switchOn(request.Enabled),
+	})
+	if errors.Is(err, service.ErrCredentialsKeyMissing) {
+		log.Error().Msg("error: " + err.Error())
+		c.JSON(503, NewResponse(nil, utils.Localize(c, "credentials_key_not_configured")))
+		return
+	}
+	if errors.Is(err, service.ErrInvalidNotificationWebhook) {
+		c.JSON(400, NewResponse(nil, utils.Localize(c, "request_is_invalid")))
+		return
+	}
+	if err != nil {
+		log.Error().Msg("error: " + err.Error())
+		c.JSON(500, NewResponse(nil, utils.Localize(c, "an_error_occurred_while_processing_your_request")))
+		return
+	}
+
+	c.JSON(200, NewResponse(toNotificationWebhookDTO(webhook), utils.Localize(c, "notification_webhook_saved_successfully")))
+}
+
+func (h *Handler) HandleDeleteNotificationWebhook(c *gin.Context) {
+	err := h.service.DeleteNotificationWebhook(c.Request.Context(), middleware.GetOrgID(c))
+	if errors.Is(err, service.ErrNotificationWebhookNotFound) {
+		c.JSON(404, NewResponse(nil, utils.Localize(c, "notification_webhook_not_found")))
+		return
+	}
+	if err != nil {
+		log.Error().Msg("error: " + err.Error())
+		c.JSON(500, NewResponse(nil, utils.Localize(c, "an_error_occurred_while_processing_your_request")))
+		return
+	}
+
+	c.JSON(200, NewResponse(nil, utils.Localize(c, "notification_webhook_deleted_successfully")))
+}
+
+func toNotificationPreferencesDTO(preferences *repository.NotificationPreferences) NotificationPreferencesDTO {
+	return NotificationPreferencesDTO{
+		Timezone:             preferences.Timezone,
+		Language:             preferences.Language,
+		QuietHoursStart:      formatClock(preferences.QuietHoursStart),
+		QuietHoursEnd:        formatClock(preferences.QuietHoursEnd),
+		EmailEnabled:         preferences.EmailEnabled,
+		AppointmentReminders: preferences.AppointmentReminders,
+		DailyCheckIns:        preferences.DailyCheckIns,
+		MilestoneAlerts:      preferences.MilestoneAlerts,
+		StreakNudges:         preferences.StreakNudges,
+	}
+}
+
+func toNotificationWebhookDTO(webhook *repository.NotificationWebhook) NotificationWebhookDTO {
+	return NotificationWebhookDTO{
+		URL:       webhook.URL,
+		Enabled:   webhook.Enabled,
+		UpdatedAt: webhook.UpdatedAt,
+	}
+}
+
+// formatClock writes minutes after midnight as "HH:MM".
+func formatClock(minutes *int) *string {
+	if minutes == nil {
+		return nil
+	}
+	clock := fmt.Sprintf("%02d:%02d", *minutes/60, *minutes%60)
+	return &clock
+}
+
+// switchOn reads an optional on/off field that defaults to on.
+func switchOn(value *bool) bool {
+	return value == nil || *value
+}
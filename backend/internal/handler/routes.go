@@ -4,17 +4,79 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-func RegisterRoutes(r *gin.Engine, h *Handler) {
+func RegisterRoutes(r *gin.Engine, h *Handler, authMiddleware gin.HandlerFunc, adminMiddleware gin.HandlerFunc, bootstrapMiddleware gin.HandlerFunc) {
 	api := r.Group("/api/v1")
 	{
 		api.GET("/health", h.HandleGetHealth)
-		api.POST("/chat", h.HandleChat)
-		api.POST("/upload", h.HandleUpload)
-		api.GET("/documents", h.HandleGetDocuments)
-		api.DELETE("/document/:id", h.HandleDeleteDocument)
-		api.DELETE("/content/:id", h.HandleDeleteContent)
-		api.GET("/dashboard", h.HandleGetDashboardData)
-		api.GET("/dashboard/calendar", h.HandleGetDashboardCalendar)
-		api.POST("/dashboard/slip", h.HandleReportSlip)
+		api.POST("/organizations", bootstrapMiddleware, h.HandleCreateOrganization)
+		api.POST("/auth/signup", h.HandleSignup)
+		api.POST("/auth/login", h.HandleLogin)
+		api.POST("/auth/refresh", h.HandleRefresh)
+		api.POST("/auth/logout", h.HandleLogout)
+		api.GET("/calendar/feeds/:feed", h.HandleGetCalendarFeed)
+	}
+
+	protected := api.Group("", authMiddleware)
+	{
+		protected.POST("/chat", h.HandleChat)
+		protected.POST("/conversations", h.HandleCreateConversation)
+		protected.GET("/conversations", h.HandleGetConversations)
+		protected.GET("/conversations/:id", h.HandleGetConversation)
+		protected.DELETE("/conversations/:id", h.HandleDeleteConversation)
+		protected.GET("/documents", h.HandleGetDocuments)
+		protected.GET("/documents/:id/status", h.HandleGetDocumentStatus)
+		protected.GET("/dashboard", h.HandleGetDashboardData)
+		protected.GET("/dashboard/calendar", h.HandleGetDashboardCalendar)
+		protected.POST("/dashboard/slip", h.HandleReportSlip)
+		protected.POST("/cravings", h.HandleLogCraving)
+		protected.GET("/cravings", h.HandleGetCravings)
+		protected.PATCH("/cravings/:id", h.HandleSetCravingOutcome)
+		protected.GET("/coping-strategies", h.HandleGetCopingStrategies)
+		protected.PUT("/quit-date", h.HandleSetQuitDate)
+		protected.GET("/milestones", h.HandleGetMilestones)
+		protected.GET("/achievements", h.HandleGetAchievements)
+		protected.GET("/clinicians", h.HandleGetClinicians)
+		protected.GET("/slots", h.HandleGetOpenSlots)
+		protected.POST("/appointments", h.HandleBookAppointment)
+		protected.GET("/appointments", h.HandleGetAppointments)
+		protected.POST("/appointments/:id/reschedule", h.HandleRescheduleAppointment)
+		protected.POST("/appointments/:id/cancel", h.HandleCancelAppointment)
+		protected.POST("/calendar/feed", h.HandleCreateCalendarFeed)
+		protected.DELETE("/calendar/feed", h.HandleRevokeCalendarFeed)
+		protected.GET("/notifications/preferences", h.HandleGetNotificationPreferences)
+		protected.PUT("/notifications/preferences", h.HandleSetNotificationPreferences)
+	}
+
+	admin := protected.Group("", adminMiddleware)
+	{
+		admin.POST("/upload", h.HandleUpload)
+		admin.POST("/documents/:id/retry", h.HandleRetryDocumentIngestion)
+		admin.DELETE("/document/:id", h.HandleDeleteDocument)
+		admin.DELETE("/content/:id", h.HandleDeleteContent)
+		admin.GET("/coping-strategies/catalogue", h.HandleGetCopingStrategyCatalogue)
+		admin.POST("/coping-strategies", h.HandleCreateCopingStrategy)
+		admin.PUT("/coping-strategies/:id", h.HandleUpdateCopingStrategy)
+		admin.DELETE("/coping-strategies/:id", h.HandleDeleteCopingStrategy)
+		admin.GET("/milestones/catalogue", h.HandleGetMilestoneCatalogue)
+		admin.POST("/milestones", h.HandleCreateMilestone)
+		admin.PUT("/milestones/:id", h.HandleUpdateMilestone)
+		admin.DELETE("/milestones/:id", h.HandleDeleteMilestone)
+		admin.GET("/clinicians/catalogue", h.HandleGetClinicianCatalogue)
+		admin.POST("/clinicians", h.HandleCreateClinician)
+		admin.PUT("/clinicians/:id", h.HandleUpdateClinician)
+		admin.DELETE("/clinicians/:id", h.HandleDeleteClinician)
+		admin.POST("/clinicians/:id/slots", h.HandleCreateAvailability)
+		admin.DELETE("/slots/:id", h.HandleDeleteSlot)
+		admin.GET("/calendar/integration", h.HandleGetCalendarIntegration)
+		admin.PUT("/calendar/integration", h.HandleSetCalendarIntegration)
+		admin.DELETE("/calendar/integration", h.HandleDeleteCalendarIntegration)
+		admin.GET("/notifications/webhook", h.HandleGetNotificationWebhook)
+		admin.PUT("/notifications/webhook", h.HandleSetNotificationWebhook)
+		admin.DELETE("/notifications/webhook", h.HandleDeleteNotificationWebhook)
+		admin.GET("/settings/assistant", h.HandleGetAssistantSettings)
+		admin.PUT("/settings/assistant", h.HandleSaveAssistantSettings)
+		admin.GET("/settings/assistant/versions", h.HandleGetAssistantSettingsVersions)
+		admin.POST("/settings/assistant/versions/:version/restore", h.HandleRestoreAssistantSettings)
+		admin.POST("/settings/assistant/preview", h.HandlePreviewAssistantSettings)
 	}
 }
@@ -0,0 +1,163 @@
+package handler
+
+import (
+	"errors"
+
+	"patient-chatbot/internal/middleware"
+	"patient-chatbot/internal/repository"
+	"patient-chatbot/internal/service"
+	"patient-chatbot/internal/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+func (h *Handler) HandleGetAssistantSettings(c *gin.Context) {
+	settings, err := h.service.GetAssistantSettings(c.Request.Context(), middleware.GetOrgID(c))
+	if err != nil {
+		log.Error().Msg("error: " + err.Error())
+		c.JSON(500, NewResponse(nil, utils.Localize(c, "an_error_occurred_while_processing_your_request")))
+		return
+	}
+
+	c.JSON(200, NewResponse(toAssistantSettingsDTO(settings), utils.Localize(c, "assistant_settings_fetched_successfully")))
+}
+
+func (h *Handler) HandleSaveAssistantSettings(c *gin.Context) {
+	var request AssistantSettingsRequestDTO
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(400, NewResponse(nil, utils.Localize(c, "request_is_invalid")))
+		return
+	}
+
+	settings, err := h.service.SaveAssistantSettings(c.Request.Context(), middleware.GetOrgID(c), middleware.GetUserID(c), toAssistantSettingsInput(request))
+	if errors.Is(err, service.ErrInvalidAssistantSettings) {
+		c.JSON(400, NewResponse(AssistantSettingsErrorDTO{Error: err.Error()}, utils.Localize(c, "assistant_settings_invalid")))
+		return
+	}
+	if errors.Is(err, service.ErrSettingsChanged) {
+		c.JSON(409, NewResponse(nil, utils.Localize(c, "assistant_settings_changed")))
+		return
+	}
+	if err != nil {
+		log.Error().Msg("error: " + err.Error())
+		c.JSON(500, NewResponse(nil, utils.Localize(c, "an_error_occurred_while_processing_your_request")))
+		return
+	}
+
+	c.JSON(201, NewResponse(toAssistantSettingsDTO(settings), utils.Localize(c, "assistant_settings_saved_successfully")))
+}
+
+func (h *Handler) HandleGetAssistantSettingsVersions(c *gin.Context) {
+	var request PaginationRequest
+	if err := c.ShouldBindQuery(&request); err != nil {
+		c.JSON(400, NewResponse(nil, utils.Localize(c, "request_is_invalid")))
+		return
+	}
+
+	versions, total, err := h.service.GetAssistantSettingsVersions(c.Request.Context(), middleware.GetOrgID(c), request.Page, request.PageSize)
+	if err != nil {
+		log.Error().Msg("error: " + err.Error())
+		c.JSON(500, NewResponse(nil, utils.Localize(c, "an_error_occurred_while_processing_your_request")))
+		return
+	}
+
+	versionsDTO := make([]AssistantSettingsDTO, len(versions))
+	for i := range versions {
+		versionsDTO[i] = toAssistantSettingsDTO(&versions[i])
+	}
+	c.JSON(200, NewResponse(GetAssistantSettingsVersionsResponseDTO{
+		Versions: versionsDTO,
+		PageSize: request.PageSize,
+		Page:     request.Page,
+		Total:    total,
+	}, utils.Localize(c, "assistant_settings_versions_fetched_successfully")))
+}
+
+func (h *Handler) HandleRestoreAssistantSettings(c *gin.Context) {
+	settings, err := h.service.RestoreAssistantSettings(c.Request.Context(), middleware.GetOrgID(c), middleware.GetUserID(c), c.Param("version"))
+	if errors.Is(err, service.ErrSettingsVersionNotFound) {
+		c.JSON(404, NewResponse(nil, utils.Localize(c, "assistant_settings_version_not_found")))
+		return
+	}
+	if errors.Is(err, service.ErrSettingsChanged) {
+		c.JSON(409, NewResponse(nil, utils.Localize(c, "assistant_settings_changed")))
+		return
+	}
+	if err != nil {
+		log.Error().Msg("error: " + err.Error())
+		c.JSON(500, NewResponse(nil, utils.Localize(c, "an_error_occurred_while_processing_your_request")))
+		return
+	}
+
+	c.JSON(201, NewResponse(toAssistantSettingsDTO(settings), utils.Localize(c, "assistant_settings_restored_successfully")))
+}
+
+func (h *Handler) HandlePreviewAssistantSettings(c *gin.Context) {
+	var request AssistantSettingsPreviewRequestDTO
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(400, NewResponse(nil, utils.Localize(c, "request_is_invalid")))
+		return
+	}
+	lang := request.Lang
+	if lang == "" {
+		lang = middleware.GetLang(c)
+	}
+
+	preview, err := h.service.PreviewAssistantSettings(c.Request.Context(), middleware.GetOrgID(c), toAssistantSettingsInput(request.AssistantSettingsRequestDTO), lang, request.Message)
+	if errors.Is(err, service.ErrInvalidAssistantSettings) {
+		c.JSON(400, NewResponse(AssistantSettingsErrorDTO{Error: err.Error()}, utils.Localize(c, "assistant_settings_invalid")))
+		return
+	}
+	if err != nil {
+		log.Error().Msg("error: " + err.Error())
+		c.JSON(500, NewResponse(nil, utils.Localize(c, "an_error_occurred_while_processing_your_request")))
+		return
+	}
+
+	c.JSON(200, NewResponse(AssistantPreviewDTO{
+		SystemPrompt: preview.SystemPrompt,
+		Temperature:  preview.Temperature,
+		MaxTokens:    preview.MaxTokens,
+		Context:      preview.Context,
+	}, utils.Localize(c, "assistant_settings_preview_generated_successfully")))
+}
+
+func toAssistantSettingsInput(request AssistantSettingsRequestDTO) service.AssistantSettingsInput {
+	return service.AssistantSettingsInput{
+		PersonaName:         request.PersonaName,
+		Tone:                request.Tone,
+		MaxCompletionTokens: request.MaxCompletionTokens,
+		Temperature:         request.Temperature,
+		OutOfScopeRefusalEN: request.OutOfScopeRefusalEN,
+		OutOfScopeRefusalAR: request.OutOfScopeRefusalAR,
+		MedicalRefusalEN:    request.MedicalRefusalEN,
+		MedicalRefusalAR:    request.MedicalRefusalAR,
+		PromptTemplateEN:    request.PromptTemplateEN,
+		PromptTemplateAR:    request.PromptTemplateAR,
+		BaseVersion:         request.BaseVersion,
+	}
+}
+
+func toAssistantSettingsDTO(settings *repository.OrganizationSettings) AssistantSettingsDTO {
+	var createdBy *string
+	if settings.CreatedByID != nil {
+		id := settings.CreatedByID.String()
+		createdBy = &id
+	}
+	return AssistantSettingsDTO{
+		Version:             settings.Version,
+		PersonaName:         settings.PersonaName,
+		Tone:                settings.Tone,
+		MaxCompletionTokens: settings.MaxCompletionTokens,
+		Temperature:         settings.Temperature,
+		OutOfScopeRefusalEN: settings.OutOfScopeRefusalEN,
+		OutOfScopeRefusalAR: settings.OutOfScopeRefusalAR,
+		MedicalRefusalEN:    settings.MedicalRefusalEN,
+		MedicalRefusalAR:    settings.MedicalRefusalAR,
+		PromptTemplateEN:    settings.PromptTemplateEN,
+		PromptTemplateAR:    settings.PromptTemplateAR,
+		CreatedBy:           createdBy,
+		CreatedAt:           settings.CreatedAt,
+	}
+}
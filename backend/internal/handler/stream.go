@@ -0,0 +1,72 @@
+package handler
+
+import (
+	"errors"
+	"strings"
+
+	"patient-chatbot/internal/middleware"
+	"patient-chatbot/internal/service"
+	"patient-chatbot/internal/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+// wantsStream reports whether the client asked for a server-sent-event response,
+// either with ?stream=true or an Accept: text/event-stream header.
+func wantsStream(c *gin.Context) bool {
+	return c.Query("stream") == "true" || strings.Contains(c.GetHeader("Accept"), "text/event-stream")
+}
+
+// streamChat relays the answer as "delta" events, followed by a single "done" event
+// carrying the full answer, or an "error" event if generation fails midway. Failures
+// before the first delta are reported as regular JSON responses.
+func (h *Handler) streamChat(c *gin.Context, request ChatRequestDTO) {
+	started := false
+	startStream := func() {
+		if started {
+			return
+		}
+		started = true
+		c.Header("Content-Type", "text/event-stream")
+		c.Header("Cache-Control", "no-cache")
+		c.Header("Connection", "keep-alive")
+		c.Header("X-Accel-Buffering", "no")
+		c.Status(200)
+	}
+
+	lang := middleware.GetLang(c)
+	answer, err := h.service.ChatStream(
+		c.Request.Context(),
+		middleware.GetUserID(c),
+		middleware.GetOrgID(c),
+		request.conversationID(),
+		request.Messages,
+		lang,
+		func(delta string) error {
+			startStream()
+			c.SSEvent("delta", gin.H{"content": delta})
+			c.Writer.Flush()
+			return c.Request.Context().Err()
+		},
+	)
+	if err != nil && !started {
+		if errors.Is(err, service.ErrConversationNotFound) {
+			c.JSON(404, NewResponse(nil, utils.Localize(c, "conversation_not_found")))
+			return
+		}
+		log.Error().Msg("error: " + err.Error())
+		c.JSON(500, NewResponse(nil, utils.Localize(c, "an_error_occurred_while_processing_your_request")))
+		return
+	}
+	if err != nil {
+		log.Error().Msg("error: " + err.Error())
+		c.SSEvent("error", NewResponse(nil, utils.Localize(c, "an_error_occurred_while_processing_your_request")))
+		c.Writer.Flush()
+		return
+	}
+
+	startStream()
+	c.SSEvent("done", NewResponse(ChatResponseDTO{Answer: answer.Answer, Sources: answer.Sources, Degraded: answer.Degraded}, utils.Localize(c, "chat_message_sent")))
+	c.Writer.Flush()
+}
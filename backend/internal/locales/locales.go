@@ -0,0 +1,11 @@
+// Package locales holds the translated strings and the localized chat prompt catalogue.
+// The catalogue is embedded so the prompts ship with the binary.
+package locales
+
+import "embed"
+
+// Prompts has one directory per language, each with a chat.tmpl prompt template and a
+// defaults.json holding the persona, refusals, fallback answer and tone descriptions.
+//
+//go:embed prompts
+var Prompts embed.FS
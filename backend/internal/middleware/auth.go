@@ -0,0 +1,106 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"slices"
+	"strings"
+
+	"patient-chatbot/internal/auth"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/nicksnyder/go-i18n/v2/i18n"
+)
+
+const (
+	userIDKey = "userID"
+	orgIDKey  = "orgID"
+	roleKey   = "role"
+)
+
+func Auth(b *i18n.Bundle, tokens *auth.TokenManager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		header := c.GetHeader("Authorization")
+		token, ok := strings.CutPrefix(header, "Bearer ")
+		if !ok || token == "" {
+			abortWithMessage(c, b, 401, "unauthorized")
+			return
+		}
+
+		claims, err := tokens.Parse(token, auth.TokenTypeAccess)
+		if err != nil {
+			abortWithMessage(c, b, 401, "unauthorized")
+			return
+		}
+
+		userID, err := claims.UserID()
+		if err != nil {
+			abortWithMessage(c, b, 401, "unauthorized")
+			return
+		}
+		orgID, err := claims.OrganizationID()
+		if err != nil {
+			abortWithMessage(c, b, 401, "unauthorized")
+			return
+		}
+
+		c.Set(userIDKey, userID)
+		c.Set(orgIDKey, orgID)
+		c.Set(roleKey, claims.Role)
+		c.Next()
+	}
+}
+
+// RequireRole rejects callers whose role is not one of roles. It must run after Auth.
+func RequireRole(b *i18n.Bundle, roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !slices.Contains(roles, GetRole(c)) {
+			abortWithMessage(c, b, 403, "forbidden")
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequireBootstrapToken guards operator-only endpoints such as creating an organization. The
+// caller must send token in the X-Bootstrap-Token header; with no token configured the
+// endpoints are disabled.
+func RequireBootstrapToken(b *i18n.Bundle, token string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if token == "" {
+			abortWithMessage(c, b, 403, "forbidden")
+			return
+		}
+		if subtle.ConstantTimeCompare([]byte(c.GetHeader("X-Bootstrap-Token")), []byte(token)) != 1 {
+			abortWithMessage(c, b, 401, "unauthorized")
+			return
+		}
+		c.Next()
+	}
+}
+
+// GetUserID returns the authenticated caller set by Auth.
+func GetUserID(c *gin.Context) uuid.UUID {
+	if v, ok := c.Get(userIDKey); ok {
+		return v.(uuid.UUID)
+	}
+	return uuid.Nil
+}
+
+// GetOrgID returns the organization of the authenticated caller set by Auth.
+func GetOrgID(c *gin.Context) uuid.UUID {
+	if v, ok := c.Get(orgIDKey); ok {
+		return v.(uuid.UUID)
+	}
+	return uuid.Nil
+}
+
+func GetRole(c *gin.Context) string {
+	return c.GetString(roleKey)
+}
+
+func abortWithMessage(c *gin.Context, b *i18n.Bundle, status int, key string) {
+	localizer := i18n.NewLocalizer(b, GetLang(c))
+	msg, _ := localizer.Localize(&i18n.LocalizeConfig{MessageID: key})
+	c.AbortWithStatusJSON(status, gin.H{"data": nil, "message": msg})
+}
@@ -0,0 +1,307 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+func (r *Repository) CreateClinician(ctx context.Context, clinician *Clinician) error {
+	return r.db.WithContext(ctx).Create(clinician).Error
+}
+
+func (r *Repository) GetClinicians(ctx context.Context, orgID uuid.UUID, activeOnly bool) ([]Clinician, error) {
+	var clinicians []Clinician
+	query := r.db.WithContext(ctx).Where("organization_id = ?", orgID)
+	if activeOnly {
+		query = query.Where("active")
+	}
+	err := query.Order("name ASC").Find(&clinicians).Error
+	if err != nil {
+		return nil, err
+	}
+	return clinicians, nil
+}
+
+func (r *Repository) GetClinicianByID(ctx context.Context, orgID uuid.UUID, id uuid.UUID) (*Clinician, error) {
+	var clinician Clinician
+	err := r.db.WithContext(ctx).First(&clinician, "id = ? AND organization_id = ?", id, orgID).Error
+	if err != nil {
+		return nil, err
+	}
+	return &clinician, nil
+}
+
+func (r *Repository) UpdateClinician(ctx context.Context, clinician *Clinician) error {
+	return r.db.WithContext(ctx).Save(clinician).Error
+}
+
+// SoftDeleteClinician deletes a clinician and their open slots. Booked slots are kept, so
+// that the appointments holding them can still be cancelled.
+func (r *Repository) SoftDeleteClinician(ctx context.Context, orgID uuid.UUID, id uuid.UUID) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		res := tx.Delete(&Clinician{}, "id = ? AND organization_id = ?", id, orgID)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return ErrNotFound
+		}
+		return tx.Delete(&AvailabilitySlot{}, "clinician_id = ? AND appointment_id IS NULL", id).Error
+	})
+}
+
+// CreateAvailabilitySlots adds slots to a clinician's calendar. It returns ErrConflict if any
+// of them overlaps a slot the clinician already has.
+func (r *Repository) CreateAvailabilitySlots(ctx context.Context, clinicianID uuid.UUID, slots []*AvailabilitySlot) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// Lock the clinician so that concurrent requests cannot add overlapping slots.
+		var clinician Clinician
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&clinician, "id = ?", clinicianID).Error
+		if err != nil {
+			return err
+		}
+		for _, slot := range slots {
+			var overlapping int64
+			err := tx.Model(&AvailabilitySlot{}).
+				Where("clinician_id = ? AND starts_at < ? AND ends_at > ?", clinicianID, slot.EndsAt, slot.StartsAt).
+				Count(&overlapping).Error
+			if err != nil {
+				return err
+			}
+			if overlapping > 0 {
+				return ErrConflict
+			}
+		}
+		return tx.Create(slots).Error
+	})
+}
+
+// GetOpenSlots returns the organization's unbooked slots that start between from and to,
+// optionally for one clinician only.
+func (r *Repository) GetOpenSlots(ctx context.Context, orgID uuid.UUID, clinicianID *uuid.UUID, from time.Time, to time.Time) ([]AvailabilitySlot, error) {
+	var slots []AvailabilitySlot
+	query := r.db.WithContext(ctx).
+		Joins("Clinician").
+		Where("availability_slots.organization_id = ? AND appointment_id IS NULL AND starts_at >= ? AND starts_at < ?", orgID, from, to).
+		Where(`"Clinician".active`)
+	if clinicianID != nil {
+		query = query.Where("clinician_id = ?", *clinicianID)
+	}
+	err := query.Order("starts_at ASC").Find(&slots).Error
+	if err != nil {
+		return nil, err
+	}
+	return slots, nil
+}
+
+// GetOpenSlot returns one of the organization's unbooked, future slots of an active
+// clinician, with the clinician.
+func (r *Repository) GetOpenSlot(ctx context.Context, orgID uuid.UUID, id uuid.UUID) (*AvailabilitySlot, error) {
+	var slot AvailabilitySlot
+	err := r.db.WithContext(ctx).
+		Joins("Clinician").
+		Where("availability_slots.organization_id = ? AND appointment_id IS NULL AND starts_at > ?", orgID, time.Now()).
+		Where(`"Clinician".active`).
+		First(&slot, "availability_slots.id = ?", id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &slot, nil
+}
+
+// ReplacePendingAction stores the user's pending action, discarding any earlier one.
+func (r *Repository) ReplacePendingAction(ctx context.Context, action *PendingAction) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Delete(&PendingAction{}, "user_id = ?", action.UserID).Error; err != nil {
+			return err
+		}
+		return tx.Create(action).Error
+	})
+}
+
+// GetPendingAction returns the user's pending action unless it has expired.
+func (r *Repository) GetPendingAction(ctx context.Context, userID uuid.UUID) (*PendingAction, error) {
+	var action PendingAction
+	err := r.db.WithContext(ctx).First(&action, "user_id = ? AND expires_at > ?", userID, time.Now()).Error
+	if err != nil {
+		return nil, err
+	}
+	return &action, nil
+}
+
+// DeletePendingAction removes one of the user's pending actions. It returns ErrNotFound if it
+// is already gone, so that of two concurrent confirmations only one goes ahead.
+func (r *Repository) DeletePendingAction(ctx context.Context, userID uuid.UUID, id uuid.UUID) error {
+	res := r.db.WithContext(ctx).Unscoped().Delete(&PendingAction{}, "id = ? AND user_id = ?", id, userID)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// SoftDeleteOpenSlot deletes a slot nobody has booked. It returns ErrConflict if the slot is
+// booked.
+func (r *Repository) SoftDeleteOpenSlot(ctx context.Context, orgID uuid.UUID, id uuid.UUID) error {
+	res := r.db.WithContext(ctx).Delete(&AvailabilitySlot{}, "id = ? AND organization_id = ? AND appointment_id IS NULL", id, orgID)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected > 0 {
+		return nil
+	}
+
+	var slot AvailabilitySlot
+	if err := r.db.WithContext(ctx).First(&slot, "id = ? AND organization_id = ?", id, orgID).Error; err != nil {
+		return err
+	}
+	return ErrConflict
+}
+
+// BookAppointment reserves the appointment's slot and creates the appointment in one
+// transaction, filling in the clinician and times from the slot. The slot is claimed with a
+// conditional update, so of two patients booking it at once only one succeeds; the other
+// gets ErrConflict, as does booking a slot that has already started.
+func (r *Repository) BookAppointment(ctx context.Context, appointment *Appointment) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		slot, err := reserveSlot(tx, appointment.OrganizationID, appointment.SlotID, appointment.ID)
+		if err != nil {
+			return err
+		}
+		appointment.ClinicianID = slot.ClinicianID
+		appointment.StartsAt = slot.StartsAt
+		appointment.EndsAt = slot.EndsAt
+		if appointment.Location == "" {
+			appointment.Location = slot.Clinician.Location
+		}
+		appointment.Status = AppointmentStatusScheduled
+		return tx.Create(appointment).Error
+	})
+}
+
+// RescheduleAppointment moves one of the user's upcoming appointments to another open slot
+// and releases the old one. It returns ErrNotFound if there is no such appointment and
+// ErrConflict if the slot cannot be booked.
+func (r *Repository) RescheduleAppointment(ctx context.Context, userID uuid.UUID, id uuid.UUID, slotID uuid.UUID) (*Appointment, error) {
+	var appointment Appointment
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := lockScheduledAppointment(tx, userID, id, &appointment); err != nil {
+			return err
+		}
+		if slotID == appointment.SlotID {
+			return nil
+		}
+
+		slot, err := reserveSlot(tx, appointment.OrganizationID, slotID, appointment.ID)
+		if err != nil {
+			return err
+		}
+		if err := releaseSlot(tx, appointment.SlotID, appointment.ID); err != nil {
+			return err
+		}
+		appointment.SlotID = slot.ID
+		appointment.ClinicianID = slot.ClinicianID
+		appointment.StartsAt = slot.StartsAt
+		appointment.EndsAt = slot.EndsAt
+		appointment.Location = slot.Clinician.Location
+		return tx.Model(&appointment).Updates(map[string]interface{}{
+			"slot_id":      appointment.SlotID,
+			"clinician_id": appointment.ClinicianID,
+			"starts_at":    appointment.StartsAt,
+			"ends_at":      appointment.EndsAt,
+			"location":     appointment.Location,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &appointment, nil
+}
+
+// CancelAppointment cancels one of the user's upcoming appointments and releases its slot.
+func (r *Repository) CancelAppointment(ctx context.Context, userID uuid.UUID, id uuid.UUID) (*Appointment, error) {
+	var appointment Appointment
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := lockScheduledAppointment(tx, userID, id, &appointment); err != nil {
+			return err
+		}
+		if err := releaseSlot(tx, appointment.SlotID, appointment.ID); err != nil {
+			return err
+		}
+		appointment.Status = AppointmentStatusCancelled
+		return tx.Model(&appointment).Update("status", appointment.Status).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &appointment, nil
+}
+
+func (r *Repository) GetAppointmentsByUserID(ctx context.Context, userID uuid.UUID, offset int, pageSize int) ([]Appointment, int, error) {
+	var appointments []Appointment
+	var total int64
+	query := r.db.WithContext(ctx).Model(&Appointment{}).Where("user_id = ?", userID)
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err := query.Preload("Clinician", func(db *gorm.DB) *gorm.DB { return db.Unscoped() }).
+		Order("starts_at DESC").
+		Offset(offset).
+		Limit(pageSize).
+		Find(&appointments).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	return appointments, int(total), nil
+}
+
+func (r *Repository) GetAppointmentByID(ctx context.Context, userID uuid.UUID, id uuid.UUID) (*Appointment, error) {
+	var appointment Appointment
+	err := r.db.WithContext(ctx).
+		Preload("Clinician", func(db *gorm.DB) *gorm.DB { return db.Unscoped() }).
+		First(&appointment, "id = ? AND user_id = ?", id, userID).Error
+	if err != nil {
+		return nil, err
+	}
+	return &appointment, nil
+}
+
+// reserveSlot claims an open, future slot of an active clinician in the organization for an
+// appointment, returning ErrConflict if there is no such slot.
+func reserveSlot(tx *gorm.DB, orgID uuid.UUID, slotID uuid.UUID, appointmentID uuid.UUID) (*AvailabilitySlot, error) {
+	res := tx.Model(&AvailabilitySlot{}).
+		Where("id = ? AND organization_id = ? AND appointment_id IS NULL AND starts_at > ?", slotID, orgID, time.Now()).
+		Where("clinician_id IN (?)", tx.Model(&Clinician{}).Select("id").Where("active")).
+		Update("appointment_id", appointmentID)
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	if res.RowsAffected == 0 {
+		return nil, ErrConflict
+	}
+
+	var slot AvailabilitySlot
+	if err := tx.Joins("Clinician").First(&slot, "availability_slots.id = ?", slotID).Error; err != nil {
+		return nil, err
+	}
+	return &slot, nil
+}
+
+func releaseSlot(tx *gorm.DB, slotID uuid.UUID, appointmentID uuid.UUID) error {
+	return tx.Model(&AvailabilitySlot{}).
+		Where("id = ? AND appointment_id = ?", slotID, appointmentID).
+		Update("appointment_id", nil).Error
+}
+
+// lockScheduledAppointment loads and locks one of the user's upcoming scheduled
+// appointments, returning ErrNotFound if there is none.
+func lockScheduledAppointment(tx *gorm.DB, userID uuid.UUID, id uuid.UUID, appointment *Appointment) error {
+	return tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		First(appointment, "id = ? AND user_id = ? AND status = ? AND starts_at > ?", id, userID, AppointmentStatusScheduled, time.Now()).Error
+}
@@ -0,0 +1,87 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+func (r *Repository) GetCalendarIntegration(ctx context.Context, orgID uuid.UUID) (*CalendarIntegration, error) {
+	var integration CalendarIntegration
+	err := r.db.WithContext(ctx).First(&integration, "organization_id = ?", orgID).Error
+	if err != nil {
+		return nil, err
+	}
+	return &integration, nil
+}
+
+func (r *Repository) SaveCalendarIntegration(ctx context.Context, integration *CalendarIntegration) error {
+	return r.db.WithContext(ctx).Save(integration).Error
+}
+
+func (r *Repository) SoftDeleteCalendarIntegration(ctx context.Context, orgID uuid.UUID) error {
+	res := r.db.WithContext(ctx).Delete(&CalendarIntegration{}, "organization_id = ?", orgID)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// RecordCalendarSync stores the outcome of the latest sync; syncError is nil when it
+// succeeded.
+func (r *Repository) RecordCalendarSync(ctx context.Context, id uuid.UUID, syncedAt time.Time, syncError *string) error {
+	return r.db.WithContext(ctx).Model(&CalendarIntegration{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"last_synced_at":  syncedAt,
+		"last_sync_error": syncError,
+	}).Error
+}
+
+// UpdateUserCalendarFeedTokenHash replaces the user's calendar feed token, or revokes it when
+// hash is nil.
+func (r *Repository) UpdateUserCalendarFeedTokenHash(ctx context.Context, userID uuid.UUID, hash *string) error {
+	return r.db.WithContext(ctx).Model(&User{}).Where("id = ?", userID).Update("calendar_feed_token_hash", hash).Error
+}
+
+func (r *Repository) GetUserByCalendarFeedTokenHash(ctx context.Context, hash string) (*User, error) {
+	var user User
+	err := r.db.WithContext(ctx).Preload("Organization").First(&user, "calendar_feed_token_hash = ?", hash).Error
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// GetUpcomingAppointments returns the user's scheduled appointments that end after since,
+// earliest first.
+func (r *Repository) GetUpcomingAppointments(ctx context.Context, userID uuid.UUID, since time.Time) ([]Appointment, error) {
+	var appointments []Appointment
+	err := r.db.WithContext(ctx).
+		Preload("Clinician", func(db *gorm.DB) *gorm.DB { return db.Unscoped() }).
+		Where("user_id = ? AND status = ? AND ends_at > ?", userID, AppointmentStatusScheduled, since).
+		Order("starts_at ASC").
+		Find(&appointments).Error
+	if err != nil {
+		return nil, err
+	}
+	return appointments, nil
+}
+
+// GetOrganizationUpcomingAppointments returns the organization's scheduled appointments that
+// end after since, with their clinicians.
+func (r *Repository) GetOrganizationUpcomingAppointments(ctx context.Context, orgID uuid.UUID, since time.Time) ([]Appointment, error) {
+	var appointments []Appointment
+	err := r.db.WithContext(ctx).
+		Preload("Clinician", func(db *gorm.DB) *gorm.DB { return db.Unscoped() }).
+		Where("organization_id = ? AND status = ? AND ends_at > ?", orgID, AppointmentStatusScheduled, since).
+		Order("starts_at ASC").
+		Find(&appointments).Error
+	if err != nil {
+		return nil, err
+	}
+	return appointments, nil
+}
@@ -0,0 +1,186 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// CreateDocumentWithIngestionJob stores an uploaded document together with the job that will ingest it.
+func (r *Repository) CreateDocumentWithIngestionJob(ctx context.Context, document *Document, job *IngestionJob) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(document).Error; err != nil {
+			return err
+		}
+		return tx.Create(job).Error
+	})
+}
+
+// ClaimIngestionJob locks the next runnable job for lease and marks it as extracting. A job is
+// runnable when it is pending and due, or when a previous worker's lease expired mid-run and
+// it has attempts left out of maxAttempts. Abandoned jobs without attempts left are marked
+// failed, so a document that crashes or hangs the worker is not retried forever.
+// Returns ErrNotFound when there is nothing to do.
+func (r *Repository) ClaimIngestionJob(ctx context.Context, lease time.Duration, maxAttempts int) (*IngestionJob, error) {
+	running := []IngestionStatus{IngestionStatusExtracting, IngestionStatusEmbedding}
+	var job IngestionJob
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		err := tx.Model(&IngestionJob{}).
+			Where("status IN ? AND locked_until < ? AND attempts >= ?", running, now, maxAttempts).
+			Updates(map[string]interface{}{
+				"status":       IngestionStatusFailed,
+				"error":        "ingestion did not finish within its time limit",
+				"locked_until": nil,
+			}).Error
+		if err != nil {
+			return err
+		}
+
+		err = tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("(status = ? AND run_at <= ?) OR (status IN ? AND locked_until < ? AND attempts < ?)",
+				IngestionStatusPending, now,
+				running, now, maxAttempts,
+			).
+			Order("run_at").
+			First(&job).Error
+		if err != nil {
+			return err
+		}
+
+		// Postgres keeps microseconds; the lease is compared with the stored value later.
+		lockedUntil := now.Add(lease).Truncate(time.Microsecond)
+		job.Status = IngestionStatusExtracting
+		job.Attempts++
+		job.LockedUntil = &lockedUntil
+		return tx.Model(&job).Updates(map[string]interface{}{
+			"status":       job.Status,
+			"attempts":     job.Attempts,
+			"locked_until": job.LockedUntil,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+func (r *Repository) GetIngestionJobByDocumentID(ctx context.Context, orgID uuid.UUID, documentID uuid.UUID) (*IngestionJob, error) {
+	var job IngestionJob
+	err := r.db.WithContext(ctx).First(&job, "document_id = ? AND organization_id = ?", documentID, orgID).Error
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// SetIngestionJobStatus moves a claimed job to status. Like CompleteIngestionJob and
+// FailIngestionJob, it returns ErrNotFound if job's lease was lost to another worker.
+func (r *Repository) SetIngestionJobStatus(ctx context.Context, job *IngestionJob, status IngestionStatus) error {
+	return r.updateClaimedIngestionJob(ctx, job, map[string]interface{}{"status": status})
+}
+
+// CompleteIngestionJob marks a claimed job ready and drops the uploaded file, which is no
+// longer needed.
+func (r *Repository) CompleteIngestionJob(ctx context.Context, job *IngestionJob) error {
+	return r.updateClaimedIngestionJob(ctx, job, map[string]interface{}{
+		"status":       IngestionStatusReady,
+		"error":        nil,
+		"payload":      nil,
+		"locked_until": nil,
+	})
+}
+
+// FailIngestionJob records a failed attempt of a claimed job. With retryAt set the job goes
+// back to pending until then; otherwise it is marked failed for good.
+func (r *Repository) FailIngestionJob(ctx context.Context, job *IngestionJob, message string, retryAt *time.Time) error {
+	updates := map[string]interface{}{
+		"status":       IngestionStatusFailed,
+		"error":        message,
+		"locked_until": nil,
+	}
+	if retryAt != nil {
+		updates["status"] = IngestionStatusPending
+		updates["run_at"] = *retryAt
+	}
+	return r.updateClaimedIngestionJob(ctx, job, updates)
+}
+
+// updateClaimedIngestionJob applies updates only while job still holds the lease it was
+// claimed with, so a worker that overran its lease cannot overwrite the attempt of the worker
+// that reclaimed the job. Returns ErrNotFound otherwise.
+func (r *Repository) updateClaimedIngestionJob(ctx context.Context, job *IngestionJob, updates map[string]interface{}) error {
+	running := []IngestionStatus{IngestionStatusExtracting, IngestionStatusEmbedding}
+	res := r.db.WithContext(ctx).Model(&IngestionJob{}).
+		Where("id = ? AND status IN ? AND locked_until = ?", job.ID, running, job.LockedUntil).
+		Updates(updates)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// RetryIngestionJob puts a failed job back in the queue with a fresh attempt budget.
+// Returns ErrNotFound unless the job exists and has failed.
+func (r *Repository) RetryIngestionJob(ctx context.Context, orgID uuid.UUID, documentID uuid.UUID) error {
+	res := r.db.WithContext(ctx).Model(&IngestionJob{}).
+		Where("document_id = ? AND organization_id = ? AND status = ?", documentID, orgID, IngestionStatusFailed).
+		Updates(map[string]interface{}{
+			"status":   IngestionStatusPending,
+			"attempts": 0,
+			"run_at":   time.Now(),
+		})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *Repository) UpdateDocumentMetadata(ctx context.Context, id uuid.UUID, title string, category string, summary string) error {
+	return r.db.WithContext(ctx).Model(&Document{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"title":    title,
+		"category": category,
+		"summary":  summary,
+	}).Error
+}
+
+// ReplaceDocumentChunks swaps a document's chunks for new ones, e.g. when a failed ingestion
+// is retried after it had already stored some chunks. It returns the IDs of the chunks it
+// removed so their vectors can be deleted too, or ErrNotFound if the document was deleted in
+// the meantime. The document row is locked so a concurrent delete either waits for the new
+// chunks and deletes them too, or wins and no chunks are stored.
+func (r *Repository) ReplaceDocumentChunks(ctx context.Context, documentID uuid.UUID, chunks []*Chunk) ([]uuid.UUID, error) {
+	var removed []uuid.UUID
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var document Document
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&document, "id = ?", documentID).Error
+		if err != nil {
+			return err
+		}
+		if err := tx.Model(&Chunk{}).Where("document_id = ?", documentID).Pluck("id", &removed).Error; err != nil {
+			return err
+		}
+		if len(removed) > 0 {
+			if err := tx.Delete(&Chunk{}, "id IN ?", removed).Error; err != nil {
+				return err
+			}
+		}
+		if len(chunks) == 0 {
+			return nil
+		}
+		return tx.Create(chunks).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return removed, nil
+}
@@ -14,42 +14,119 @@ type BaseModel struct {
 	DeletedAt gorm.DeletedAt `gorm:"default:null"`
 }
 
+type Organization struct {
+	BaseModel
+	Name string `gorm:"not null;type:varchar(255)"`
+
+	Users     []User     `gorm:"foreignKey:OrganizationID"`
+	Documents []Document `gorm:"foreignKey:OrganizationID"`
+}
+
 type Document struct {
 	BaseModel
-	Title     string `gorm:"not null;type:varchar(255)"`
-	Category  string `gorm:"not null;type:varchar(255)"`
-	Path      string `gorm:"not null;type:varchar(255)"`
-	Extension string `gorm:"not null;type:varchar(255)"`
+	OrganizationID uuid.UUID `gorm:"not null;type:uuid;index"`
+	Title          string    `gorm:"not null;type:varchar(255)"`
+	Category       string    `gorm:"not null;type:varchar(255)"`
+	Path           string    `gorm:"not null;type:varchar(255)"`
+	Extension      string    `gorm:"not null;type:varchar(255)"`
+	Summary        string    `gorm:"not null;type:text;default:''"`
 
-	Chunks   []Chunk   `gorm:"foreignKey:DocumentID"`
-	Messages []Message `gorm:"foreignKey:DocumentID"`
+	Organization Organization `gorm:"foreignKey:OrganizationID"`
+	Chunks       []Chunk      `gorm:"foreignKey:DocumentID"`
 }
 
 type Chunk struct {
 	BaseModel
-	Content    string    `gorm:"not null;type:text"`
-	DocumentID uuid.UUID `gorm:"not null;type:uuid"`
+	OrganizationID uuid.UUID `gorm:"not null;type:uuid;index"`
+	Content        string    `gorm:"not null;type:text"`
+	DocumentID     uuid.UUID `gorm:"not null;type:uuid"`
+	// Page is the 1-based page the chunk was taken from, for formats that have pages.
+	Page *int `gorm:"type:int;default:null"`
 
 	Document Document `gorm:"foreignKey:DocumentID"`
 }
 
-type Message struct {
+type IngestionStatus string
+
+const (
+	IngestionStatusPending    IngestionStatus = "pending"
+	IngestionStatusExtracting IngestionStatus = "extracting"
+	IngestionStatusEmbedding  IngestionStatus = "embedding"
+	IngestionStatusReady      IngestionStatus = "ready"
+	IngestionStatusFailed     IngestionStatus = "failed"
+)
+
+// IngestionJob tracks the background processing of an uploaded document. The uploaded file
+// is kept on the job until it has been ingested, so failed jobs can be retried. A job with no
+// MimeType has no file: it re-indexes the chunks already stored for its document.
+type IngestionJob struct {
 	BaseModel
-	Role       string    `gorm:"not null;type:varchar(255)"`
-	Content    string    `gorm:"not null;type:text"`
-	DocumentID uuid.UUID `gorm:"not null;type:uuid"`
+	OrganizationID uuid.UUID       `gorm:"not null;type:uuid;index"`
+	DocumentID     uuid.UUID       `gorm:"not null;type:uuid;uniqueIndex"`
+	Status         IngestionStatus `gorm:"not null;type:varchar(255);index"`
+	Attempts       int             `gorm:"not null;type:int"`
+	Error          *string         `gorm:"type:text;default:null"`
+	MimeType       string          `gorm:"not null;type:varchar(255)"`
+	Payload        []byte          `gorm:"type:bytea"`
+	RunAt          time.Time       `gorm:"not null;index"`
+	LockedUntil    *time.Time      `gorm:"default:null"`
 
 	Document Document `gorm:"foreignKey:DocumentID"`
 }
 
+type Conversation struct {
+	BaseModel
+	OrganizationID uuid.UUID `gorm:"not null;type:uuid;index"`
+	UserID         uuid.UUID `gorm:"not null;type:uuid;index"`
+	Title          string    `gorm:"not null;type:varchar(255)"`
+
+	User     User      `gorm:"foreignKey:UserID"`
+	Messages []Message `gorm:"foreignKey:ConversationID"`
+}
+
+type Message struct {
+	BaseModel
+	ConversationID uuid.UUID `gorm:"not null;type:uuid;index"`
+	Role           string    `gorm:"not null;type:varchar(255)"`
+	Content        string    `gorm:"not null;type:text"`
+	ChunkIDs       UUIDList  `gorm:"not null;default:'[]'"`
+
+	Conversation Conversation `gorm:"foreignKey:ConversationID"`
+}
+
+type UserRole string
+
+const (
+	UserRolePatient UserRole = "PATIENT"
+	UserRoleAdmin   UserRole = "ADMIN"
+)
+
 type User struct {
 	BaseModel
-	Email         string `gorm:"not null;type:varchar(255)"`
-	Password      string `gorm:"not null;type:varchar(255)"`
-	MoneySaved    int    `gorm:"not null;type:int"`
-	DaysSmokeFree int    `gorm:"not null;type:int"`
+	OrganizationID uuid.UUID `gorm:"not null;type:uuid;index"`
+	Role           UserRole  `gorm:"not null;type:varchar(255);default:PATIENT"`
+	Email          string    `gorm:"not null;type:varchar(255);uniqueIndex"`
+	PasswordHash   string    `gorm:"column:password;not null;type:varchar(255)"`
+	MoneySaved     int       `gorm:"not null;type:int"`
+	// QuitDate is when the patient stopped smoking, or plans to. Health milestones are
+	// counted from it.
+	QuitDate *time.Time `gorm:"default:null"`
+	// CalendarFeedTokenHash is the SHA-256 of the secret in the patient's calendar feed URL.
+	CalendarFeedTokenHash *string `gorm:"type:varchar(64);uniqueIndex;default:null"`
 
+	Organization   Organization    `gorm:"foreignKey:OrganizationID"`
 	ProgressEvents []ProgressEvent `gorm:"foreignKey:UserID"`
+	RefreshTokens  []RefreshToken  `gorm:"foreignKey:UserID"`
+}
+
+// RefreshToken tracks an issued refresh token by its jti so it can be rotated and revoked.
+type RefreshToken struct {
+	BaseModel
+	UserID    uuid.UUID  `gorm:"not null;type:uuid;index"`
+	ExpiresAt time.Time  `gorm:"not null"`
+	RevokedAt *time.Time `gorm:"default:null"`
+
+	User User `gorm:"foreignKey:UserID"`
 }
 
 type ProgressEventStatus string
@@ -62,11 +139,315 @@ const (
 
 type ProgressEvent struct {
 	BaseModel
-	UserID     uuid.UUID           `gorm:"not null;type:uuid"`
-	Date       time.Time           `gorm:"not null;type:date;uniqueIndex:idx_user_date"`
+	UserID     uuid.UUID           `gorm:"not null;type:uuid;uniqueIndex:idx_progress_user_date"`
+	Date       time.Time           `gorm:"not null;type:date;uniqueIndex:idx_progress_user_date"`
 	Status     ProgressEventStatus `gorm:"not null;type:varchar(255)"`
 	Notes      *string             `gorm:"type:text;default:NULL"`
 	MoneySaved *int                `gorm:"type:int;default:0"`
 
 	User User `gorm:"foreignKey:UserID"`
 }
+
+type CravingOutcome string
+
+const (
+	CravingOutcomeResisted CravingOutcome = "RESISTED"
+	CravingOutcomeSlipped  CravingOutcome = "SLIPPED"
+)
+
+// Craving is an urge to smoke a patient logged, rated from 1 (mild) to 10 (overwhelming).
+// Outcome stays null until the patient says whether they resisted it. StrategyKey is the Key
+// of the coping strategy recommended for it. DayStatusBeforeSlip is the status its day had
+// before the craving turned SLIPPED and marked it a slip, or null if the day had none.
+type Craving struct {
+	BaseModel
+	OrganizationID uuid.UUID       `gorm:"not null;type:uuid;index"`
+	UserID         uuid.UUID       `gorm:"not null;type:uuid;index"`
+	Intensity      int             `gorm:"not null;type:int"`
+	Triggers       StringList      `gorm:"not null;default:'[]'"`
+	Location       *string         `gorm:"type:varchar(255);default:NULL"`
+	Notes          *string         `gorm:"type:text;default:NULL"`
+	Outcome        *CravingOutcome `gorm:"type:varchar(255);default:NULL"`
+	StrategyKey    *string         `gorm:"type:varchar(255);default:NULL"`
+	OccurredAt     time.Time       `gorm:"not null;index"`
+
+	DayStatusBeforeSlip *ProgressEventStatus `gorm:"type:varchar(255);default:NULL"`
+
+	User User `gorm:"foreignKey:UserID"`
+}
+
+// CopingStrategy is an entry in an organization's catalogue of coping strategies, written
+// in English and Arabic. Key identifies it within the organization and never changes, so
+// cravings keep pointing at it when it is edited.
+type CopingStrategy struct {
+	BaseModel
+	OrganizationID  uuid.UUID  `gorm:"not null;type:uuid;uniqueIndex:idx_coping_strategy_org_key,where:deleted_at IS NULL"`
+	Key             string     `gorm:"not null;type:varchar(255);uniqueIndex:idx_coping_strategy_org_key,where:deleted_at IS NULL"`
+	TitleEN         string     `gorm:"not null;type:varchar(255)"`
+	TitleAR         string     `gorm:"not null;type:varchar(255)"`
+	DescriptionEN   string     `gorm:"not null;type:text;default:''"`
+	DescriptionAR   string     `gorm:"not null;type:text;default:''"`
+	StepsEN         StringList `gorm:"not null;default:'[]'"`
+	StepsAR         StringList `gorm:"not null;default:'[]'"`
+	EstimatedTimeEN string     `gorm:"not null;type:varchar(255);default:''"`
+	EstimatedTimeAR string     `gorm:"not null;type:varchar(255);default:''"`
+	Source          string     `gorm:"not null;type:varchar(255);default:''"`
+	Active          bool       `gorm:"not null"`
+	Position        int        `gorm:"not null;type:int;default:0"`
+
+	Organization Organization `gorm:"foreignKey:OrganizationID"`
+}
+
+type MilestoneCategory string
+
+const (
+	MilestoneCategoryImmediate MilestoneCategory = "immediate"
+	MilestoneCategoryShort     MilestoneCategory = "short"
+	MilestoneCategoryMedium    MilestoneCategory = "medium"
+	MilestoneCategoryLong      MilestoneCategory = "long"
+)
+
+// MilestoneDefinition is a health milestone in an organization's catalogue, reached
+// AfterMinutes after a patient's quit date.
+type MilestoneDefinition struct {
+	BaseModel
+	OrganizationID uuid.UUID         `gorm:"not null;type:uuid;uniqueIndex:idx_milestone_org_key,where:deleted_at IS NULL"`
+	Key            string            `gorm:"not null;type:varchar(255);uniqueIndex:idx_milestone_org_key,where:deleted_at IS NULL"`
+	TitleEN        string            `gorm:"not null;type:varchar(255)"`
+	TitleAR        string            `gorm:"not null;type:varchar(255)"`
+	DescriptionEN  string            `gorm:"not null;type:text;default:''"`
+	DescriptionAR  string            `gorm:"not null;type:text;default:''"`
+	Category       MilestoneCategory `gorm:"not null;type:varchar(255)"`
+	AfterMinutes   int               `gorm:"not null;type:int"`
+	Active         bool              `gorm:"not null"`
+
+	Organization Organization `gorm:"foreignKey:OrganizationID"`
+}
+
+// UserAchievement records when a user unlocked an achievement. AchievementKey refers to
+// a rule defined in the service.
+type UserAchievement struct {
+	BaseModel
+	UserID         uuid.UUID `gorm:"not null;type:uuid;uniqueIndex:idx_user_achievement"`
+	AchievementKey string    `gorm:"not null;type:varchar(255);uniqueIndex:idx_user_achievement"`
+	UnlockedAt     time.Time `gorm:"not null"`
+
+	User User `gorm:"foreignKey:UserID"`
+}
+
+// Clinician is someone patients can book appointments with.
+type Clinician struct {
+	BaseModel
+	OrganizationID uuid.UUID `gorm:"not null;type:uuid;index"`
+	Name           string    `gorm:"not null;type:varchar(255)"`
+	Specialty      string    `gorm:"not null;type:varchar(255);default:''"`
+	Location       string    `gorm:"not null;type:varchar(255);default:''"`
+	Active         bool      `gorm:"not null"`
+
+	Organization Organization `gorm:"foreignKey:OrganizationID"`
+}
+
+// AvailabilitySlot is a time a clinician can see a patient. AppointmentID is set while an
+// appointment holds the slot.
+type AvailabilitySlot struct {
+	BaseModel
+	OrganizationID uuid.UUID  `gorm:"not null;type:uuid;index"`
+	ClinicianID    uuid.UUID  `gorm:"not null;type:uuid;uniqueIndex:idx_slot_clinician_start,where:deleted_at IS NULL"`
+	StartsAt       time.Time  `gorm:"not null;index;uniqueIndex:idx_slot_clinician_start,where:deleted_at IS NULL"`
+	EndsAt         time.Time  `gorm:"not null"`
+	AppointmentID  *uuid.UUID `gorm:"type:uuid;default:null"`
+
+	Clinician Clinician `gorm:"foreignKey:ClinicianID"`
+}
+
+type AppointmentType string
+
+const (
+	AppointmentTypeConsultation AppointmentType = "CONSULTATION"
+	AppointmentTypeFollowUp     AppointmentType = "FOLLOW_UP"
+	AppointmentTypeTest         AppointmentType = "TEST"
+	AppointmentTypeProcedure    AppointmentType = "PROCEDURE"
+)
+
+type AppointmentStatus string
+
+const (
+	AppointmentStatusScheduled AppointmentStatus = "SCHEDULED"
+	AppointmentStatusCancelled AppointmentStatus = "CANCELLED"
+	// AppointmentStatusCompleted is never stored; see Appointment.CurrentStatus.
+	AppointmentStatusCompleted AppointmentStatus = "COMPLETED"
+)
+
+// Appointment is a patient's booking of an availability slot. The slot's clinician and times
+// are copied onto it, so cancelled appointments keep them after the slot is released. At
+// most one scheduled appointment can hold a slot.
+type Appointment struct {
+	BaseModel
+	OrganizationID uuid.UUID         `gorm:"not null;type:uuid;index"`
+	UserID         uuid.UUID         `gorm:"not null;type:uuid;index"`
+	ClinicianID    uuid.UUID         `gorm:"not null;type:uuid;index"`
+	SlotID         uuid.UUID         `gorm:"not null;type:uuid;uniqueIndex:idx_appointment_slot,where:status = 'SCHEDULED' AND deleted_at IS NULL"`
+	Title          string            `gorm:"not null;type:varchar(255)"`
+	Type           AppointmentType   `gorm:"not null;type:varchar(255)"`
+	Status         AppointmentStatus `gorm:"not null;type:varchar(255)"`
+	Location       string            `gorm:"not null;type:varchar(255);default:''"`
+	Notes          *string           `gorm:"type:text;default:null"`
+	StartsAt       time.Time         `gorm:"not null"`
+	EndsAt         time.Time         `gorm:"not null"`
+
+	User      User      `gorm:"foreignKey:UserID"`
+	Clinician Clinician `gorm:"foreignKey:ClinicianID"`
+}
+
+// CurrentStatus is the appointment's status as of now: a scheduled appointment that has
+// ended is completed.
+func (a *Appointment) CurrentStatus(now time.Time) AppointmentStatus {
+	if a.Status == AppointmentStatusScheduled && !now.Before(a.EndsAt) {
+		return AppointmentStatusCompleted
+	}
+	return a.Status
+}
+
+type PendingActionKind string
+
+const (
+	PendingActionBookAppointment       PendingActionKind = "BOOK_APPOINTMENT"
+	PendingActionRescheduleAppointment PendingActionKind = "RESCHEDULE_APPOINTMENT"
+	PendingActionCancelAppointment     PendingActionKind = "CANCEL_APPOINTMENT"
+)
+
+// PendingAction is an appointment change the assistant proposed in chat, waiting for the
+// patient to confirm it in a later message. A user has at most one; proposing another
+// replaces it. Pending actions are deleted outright rather than soft-deleted.
+type PendingAction struct {
+	BaseModel
+	UserID          uuid.UUID         `gorm:"not null;type:uuid;uniqueIndex"`
+	OrganizationID  uuid.UUID         `gorm:"not null;type:uuid"`
+	Kind            PendingActionKind `gorm:"not null;type:varchar(255)"`
+	AppointmentID   *uuid.UUID        `gorm:"type:uuid;default:null"`
+	SlotID          *uuid.UUID        `gorm:"type:uuid;default:null"`
+	Title           string            `gorm:"not null;type:varchar(255);default:''"`
+	AppointmentType AppointmentType   `gorm:"not null;type:varchar(255);default:''"`
+	Notes           *string           `gorm:"type:text;default:null"`
+	ExpiresAt       time.Time         `gorm:"not null"`
+}
+
+// CalendarIntegration is an organization's CalDAV calendar collection that booked
+// appointments are copied to. The password is encrypted with auth.Sealer.
+type CalendarIntegration struct {
+	BaseModel
+	OrganizationID     uuid.UUID  `gorm:"not null;type:uuid;uniqueIndex:idx_calendar_integration_org,where:deleted_at IS NULL"`
+	URL                string     `gorm:"not null;type:varchar(1024)"`
+	Username           string     `gorm:"not null;type:varchar(255)"`
+	PasswordCiphertext string     `gorm:"not null;type:text"`
+	Enabled            bool       `gorm:"not null"`
+	LastSyncedAt       *time.Time `gorm:"default:null"`
+	LastSyncError      *string    `gorm:"type:text;default:null"`
+
+	Organization Organization `gorm:"foreignKey:OrganizationID"`
+}
+
+// NotificationPreferences are a patient's notification settings. Patients who never saved
+// any get service.defaultNotificationPreferences.
+type NotificationPreferences struct {
+	BaseModel
+	UserID   uuid.UUID `gorm:"not null;type:uuid;uniqueIndex"`
+	Timezone string    `gorm:"not null;type:varchar(64)"`
+	Language string    `gorm:"not null;type:varchar(8)"`
+	// QuietHoursStart and QuietHoursEnd are minutes after local midnight; the quiet period may
+	// span midnight. Both are nil when the patient has no quiet hours.
+	QuietHoursStart      *int `gorm:"type:int;default:null"`
+	QuietHoursEnd        *int `gorm:"type:int;default:null"`
+	EmailEnabled         bool `gorm:"not null"`
+	AppointmentReminders bool `gorm:"not null"`
+	DailyCheckIns        bool `gorm:"not null"`
+	MilestoneAlerts      bool `gorm:"not null"`
+	StreakNudges         bool `gorm:"not null"`
+
+	User User `gorm:"foreignKey:UserID"`
+}
+
+// NotificationWebhook is an organization's webhook notification channel. The secret signs
+// each request and is encrypted with auth.Sealer.
+type NotificationWebhook struct {
+	BaseModel
+	OrganizationID   uuid.UUID `gorm:"not null;type:uuid;uniqueIndex:idx_notification_webhook_org,where:deleted_at IS NULL"`
+	URL              string    `gorm:"not null;type:varchar(1024)"`
+	SecretCiphertext string    `gorm:"not null;type:text"`
+	Enabled          bool      `gorm:"not null"`
+
+	Organization Organization `gorm:"foreignKey:OrganizationID"`
+}
+
+type NotificationKind string
+
+const (
+	NotificationKindAppointmentReminder NotificationKind = "APPOINTMENT_REMINDER"
+	NotificationKindDailyCheckIn        NotificationKind = "DAILY_CHECK_IN"
+	NotificationKindMilestone           NotificationKind = "MILESTONE"
+	NotificationKindStreakAtRisk        NotificationKind = "STREAK_AT_RISK"
+)
+
+type NotificationStatus string
+
+const (
+	NotificationStatusPending NotificationStatus = "PENDING"
+	NotificationStatusSending NotificationStatus = "SENDING"
+	NotificationStatusSent    NotificationStatus = "SENT"
+	// NotificationStatusSkipped means the notification no longer applied when it was due,
+	// e.g. the appointment was cancelled or the patient turned that kind off.
+	NotificationStatusSkipped NotificationStatus = "SKIPPED"
+	NotificationStatusFailed  NotificationStatus = "FAILED"
+)
+
+// NotificationJob is a notification scheduled for RunAt. DedupeKey identifies what it is
+// about, e.g. one reminder per appointment time, so planning the same notification twice
+// is a no-op. EventAt is the time it refers to: the appointment's start, the moment a
+// milestone is reached or the local day of a check-in.
+type NotificationJob struct {
+	BaseModel
+	OrganizationID uuid.UUID          `gorm:"not null;type:uuid;index"`
+	UserID         uuid.UUID          `gorm:"not null;type:uuid;index"`
+	Kind           NotificationKind   `gorm:"not null;type:varchar(255)"`
+	DedupeKey      string             `gorm:"not null;type:varchar(255);uniqueIndex"`
+	Reference      string             `gorm:"not null;type:varchar(255);default:''"`
+	EventAt        time.Time          `gorm:"not null"`
+	Status         NotificationStatus `gorm:"not null;type:varchar(255);index"`
+	Attempts       int                `gorm:"not null;type:int"`
+	Error          *string            `gorm:"type:text;default:null"`
+	RunAt          time.Time          `gorm:"not null;index"`
+	LockedUntil    *time.Time         `gorm:"default:null"`
+	SentAt         *time.Time         `gorm:"default:null"`
+	// DeliveredChannels names the channels that already took the notification, so retries
+	// only go to the ones that failed.
+	DeliveredChannels StringList `gorm:"not null;default:'[]'"`
+}
+
+// StrategyStats counts how often a user resisted cravings they tried a coping strategy on.
+type StrategyStats struct {
+	StrategyKey string
+	Tried       int
+	Resisted    int
+}
+
+// OrganizationSettings is one version of an organization's assistant settings. Versions are
+// never changed: saving adds a new one, and the highest version is in effect. Empty text
+// fields mean the built-in default.
+type OrganizationSettings struct {
+	BaseModel
+	OrganizationID      uuid.UUID  `gorm:"not null;type:uuid;uniqueIndex:idx_organization_settings_version"`
+	Version             int        `gorm:"not null;type:int;uniqueIndex:idx_organization_settings_version"`
+	CreatedByID         *uuid.UUID `gorm:"type:uuid;default:null"`
+	PersonaName         string     `gorm:"not null;type:varchar(64);default:''"`
+	Tone                string     `gorm:"not null;type:varchar(32)"`
+	MaxCompletionTokens int        `gorm:"not null;type:int"`
+	Temperature         float32    `gorm:"not null;type:real"`
+	OutOfScopeRefusalEN string     `gorm:"not null;type:text;default:''"`
+	OutOfScopeRefusalAR string     `gorm:"not null;type:text;default:''"`
+	MedicalRefusalEN    string     `gorm:"not null;type:text;default:''"`
+	MedicalRefusalAR    string     `gorm:"not null;type:text;default:''"`
+	PromptTemplateEN    string     `gorm:"not null;type:text;default:''"`
+	PromptTemplateAR    string     `gorm:"not null;type:text;default:''"`
+
+	Organization Organization `gorm:"foreignKey:OrganizationID"`
+}
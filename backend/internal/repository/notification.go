@@ -0,0 +1,201 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+func (r *Repository) GetNotificationPreferences(ctx context.Context, userID uuid.UUID) (*NotificationPreferences, error) {
+	var preferences NotificationPreferences
+	err := r.db.WithContext(ctx).First(&preferences, "user_id = ?", userID).Error
+	if err != nil {
+		return nil, err
+	}
+	return &preferences, nil
+}
+
+// GetNotificationPreferencesByUserIDs returns the saved preferences of those users who have
+// any, keyed by user.
+func (r *Repository) GetNotificationPreferencesByUserIDs(ctx context.Context, userIDs []uuid.UUID) (map[uuid.UUID]NotificationPreferences, error) {
+	var preferences []NotificationPreferences
+	if err := r.db.WithContext(ctx).Where("user_id IN ?", userIDs).Find(&preferences).Error; err != nil {
+		return nil, err
+	}
+	byUser := make(map[uuid.UUID]NotificationPreferences, len(preferences))
+	for _, p := range preferences {
+		byUser[p.UserID] = p
+	}
+	return byUser, nil
+}
+
+// SaveNotificationPreferences creates or replaces the user's preferences.
+func (r *Repository) SaveNotificationPreferences(ctx context.Context, preferences *NotificationPreferences) error {
+	return r.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{
+			"timezone", "language", "quiet_hours_start", "quiet_hours_end", "email_enabled",
+			"appointment_reminders", "daily_check_ins", "milestone_alerts", "streak_nudges", "updated_at",
+		}),
+	}).Create(preferences).Error
+}
+
+func (r *Repository) GetNotificationWebhook(ctx context.Context, orgID uuid.UUID) (*NotificationWebhook, error) {
+	var webhook NotificationWebhook
+	err := r.db.WithContext(ctx).First(&webhook, "organization_id = ?", orgID).Error
+	if err != nil {
+		return nil, err
+	}
+	return &webhook, nil
+}
+
+func (r *Repository) SaveNotificationWebhook(ctx context.Context, webhook *NotificationWebhook) error {
+	return r.db.WithContext(ctx).Save(webhook).Error
+}
+
+func (r *Repository) SoftDeleteNotificationWebhook(ctx context.Context, orgID uuid.UUID) error {
+	res := r.db.WithContext(ctx).Delete(&NotificationWebhook{}, "organization_id = ?", orgID)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// GetPatients returns up to limit patients with IDs greater than afterID, in ID order, so
+// callers can page through every patient.
+func (r *Repository) GetPatients(ctx context.Context, afterID uuid.UUID, limit int) ([]User, error) {
+	var users []User
+	err := r.db.WithContext(ctx).
+		Where("role = ? AND id > ?", UserRolePatient, afterID).
+		Order("id ASC").
+		Limit(limit).
+		Find(&users).Error
+	if err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+// GetAppointmentsStartingBetween returns scheduled appointments that start in [from, to).
+func (r *Repository) GetAppointmentsStartingBetween(ctx context.Context, from time.Time, to time.Time) ([]Appointment, error) {
+	var appointments []Appointment
+	err := r.db.WithContext(ctx).
+		Where("status = ? AND starts_at >= ? AND starts_at < ?", AppointmentStatusScheduled, from, to).
+		Find(&appointments).Error
+	if err != nil {
+		return nil, err
+	}
+	return appointments, nil
+}
+
+// HasProgressEvent reports whether the user logged the given day, formatted as 2006-01-02.
+func (r *Repository) HasProgressEvent(ctx context.Context, userID uuid.UUID, date string) (bool, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&ProgressEvent{}).Where("user_id = ? AND date = ?", userID, date).Count(&count).Error
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// CreateNotificationJobs schedules jobs, skipping any whose DedupeKey is already taken.
+func (r *Repository) CreateNotificationJobs(ctx context.Context, jobs []*NotificationJob) error {
+	if len(jobs) == 0 {
+		return nil
+	}
+	return r.db.WithContext(ctx).
+		Clauses(clause.OnConflict{Columns: []clause.Column{{Name: "dedupe_key"}}, DoNothing: true}).
+		Create(jobs).Error
+}
+
+// ClaimNotificationJob locks the next due job for lease and marks it as sending. A job is due
+// when it is pending and its time has come, or when a previous worker's lease expired
+// mid-send. Returns ErrNotFound when there is nothing to do.
+func (r *Repository) ClaimNotificationJob(ctx context.Context, lease time.Duration) (*NotificationJob, error) {
+	var job NotificationJob
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("(status = ? AND run_at <= ?) OR (status = ? AND locked_until < ?)",
+				NotificationStatusPending, now, NotificationStatusSending, now).
+			Order("run_at").
+			First(&job).Error
+		if err != nil {
+			return err
+		}
+
+		lockedUntil := now.Add(lease)
+		job.Status = NotificationStatusSending
+		job.Attempts++
+		job.LockedUntil = &lockedUntil
+		return tx.Model(&job).Updates(map[string]interface{}{
+			"status":       job.Status,
+			"attempts":     job.Attempts,
+			"locked_until": job.LockedUntil,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// FinishNotificationJob marks a job sent or skipped. reason says why it was skipped.
+func (r *Repository) FinishNotificationJob(ctx context.Context, id uuid.UUID, status NotificationStatus, reason *string) error {
+	updates := map[string]interface{}{
+		"status":       status,
+		"error":        reason,
+		"locked_until": nil,
+	}
+	if status == NotificationStatusSent {
+		updates["sent_at"] = time.Now()
+	}
+	return r.db.WithContext(ctx).Model(&NotificationJob{}).Where("id = ?", id).Updates(updates).Error
+}
+
+// DeferNotificationJob puts a claimed job back until runAt without counting the attempt,
+// e.g. to wait out the patient's quiet hours.
+func (r *Repository) DeferNotificationJob(ctx context.Context, id uuid.UUID, runAt time.Time) error {
+	return r.db.WithContext(ctx).Model(&NotificationJob{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"status":       NotificationStatusPending,
+		"run_at":       runAt,
+		"attempts":     gorm.Expr("attempts - 1"),
+		"locked_until": nil,
+	}).Error
+}
+
+// AddNotificationDeliveredChannel records that channel took the job's notification.
+func (r *Repository) AddNotificationDeliveredChannel(ctx context.Context, id uuid.UUID, channel string) error {
+	return r.db.WithContext(ctx).Model(&NotificationJob{}).Where("id = ?", id).
+		Update("delivered_channels", gorm.Expr("delivered_channels || jsonb_build_array(?::text)", channel)).Error
+}
+
+// FailNotificationJob records a failed attempt. With retryAt set the job goes back to pending
+// until then; otherwise it is marked failed for good.
+func (r *Repository) FailNotificationJob(ctx context.Context, id uuid.UUID, message string, retryAt *time.Time) error {
+	updates := map[string]interface{}{
+		"status":       NotificationStatusFailed,
+		"error":        message,
+		"locked_until": nil,
+	}
+	if retryAt != nil {
+		updates["status"] = NotificationStatusPending
+		updates["run_at"] = *retryAt
+	}
+	return r.db.WithContext(ctx).Model(&NotificationJob{}).Where("id = ?", id).Updates(updates).Error
+}
+
+func (r *Repository) GetNotificationJobByID(ctx context.Context, id uuid.UUID) (*NotificationJob, error) {
+	var job NotificationJob
+	err := r.db.WithContext(ctx).First(&job, "id = ?", id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
@@ -2,12 +2,23 @@ package repository
 
 import (
 	"context"
+	"errors"
+	"slices"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/rs/zerolog/log"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrNotFound  = gorm.ErrRecordNotFound
+	ErrDuplicate = gorm.ErrDuplicatedKey
+	// ErrConflict means the write would clash with existing data, e.g. a slot that is
+	// already booked.
+	ErrConflict = errors.New("conflict")
 )
 
 type Repository struct {
@@ -15,35 +26,124 @@ type Repository struct {
 }
 
 func NewRepository(dbURL string) *Repository {
-	db, err := gorm.Open(postgres.Open(dbURL), &gorm.Config{})
+	db, err := gorm.Open(postgres.Open(dbURL), &gorm.Config{TranslateError: true})
 	if err != nil {
 		log.Error().Msg("Failed to connect to database: " + err.Error())
 	}
 
+	// @NOTE: users, documents and chunks predate organizations, and AutoMigrate cannot add a NOT NULL organization_id to tables that already have rows.
+	if err := migrateToOrganizations(db); err != nil {
+		log.Error().Msg("migration failed: " + err.Error())
+	}
+
 	err = db.AutoMigrate(
+		&Organization{},
 		&Document{},
 		&Chunk{},
+		&Conversation{},
 		&Message{},
 		&User{},
 		&ProgressEvent{},
+		&RefreshToken{},
+		&IngestionJob{},
+		&Craving{},
+		&CopingStrategy{},
+		&MilestoneDefinition{},
+		&UserAchievement{},
+		&Clinician{},
+		&AvailabilitySlot{},
+		&Appointment{},
+		&CalendarIntegration{},
+		&PendingAction{},
+		&NotificationPreferences{},
+		&NotificationWebhook{},
+		&NotificationJob{},
+		&OrganizationSettings{},
 	)
 	if err != nil {
 		log.Error().Msg("migration failed: " + err.Error())
 	}
 
-	db.FirstOrCreate(&User{
-		BaseModel: BaseModel{
-			ID: uuid.MustParse("d1fc8771-bac7-4080-913b-2b25e4ab4957"),
-		},
-		Email:         "malaeena@groq.com",
-		Password:      "123456",
-		MoneySaved:    0,
-		DaysSmokeFree: 0,
-	})
+	// @NOTE: messages used to hang off documents; the column is obsolete and NOT NULL, so it would block inserts.
+	if db.Migrator().HasColumn(&Message{}, "document_id") {
+		if err := db.Migrator().DropColumn(&Message{}, "document_id"); err != nil {
+			log.Error().Msg("migration failed: " + err.Error())
+		}
+	}
+
+	// @NOTE: idx_user_date only covered the date, so two patients could not log the same day.
+	if db.Migrator().HasIndex(&ProgressEvent{}, "idx_user_date") {
+		if err := db.Migrator().DropIndex(&ProgressEvent{}, "idx_user_date"); err != nil {
+			log.Error().Msg("migration failed: " + err.Error())
+		}
+	}
+
+	// @NOTE: days_smoke_free was never kept up to date; progress is counted from events and the quit date instead.
+	if db.Migrator().HasColumn(&User{}, "days_smoke_free") {
+		if err := db.Migrator().DropColumn(&User{}, "days_smoke_free"); err != nil {
+			log.Error().Msg("migration failed: " + err.Error())
+		}
+	}
 
 	return &Repository{db: db}
 }
 
+// migrateToOrganizations adds organization_id to the tables created before organizations
+// existed. Their rows are moved into a new default organization before the column is made NOT
+// NULL, all in one transaction. The documents' vectors are still in the namespace shared by
+// every organization, so each document is queued to be re-indexed into its organization's.
+func migrateToOrganizations(db *gorm.DB) error {
+	var tables []string
+	for _, table := range []string{"users", "documents", "chunks"} {
+		if db.Migrator().HasTable(table) && !db.Migrator().HasColumn(table, "organization_id") {
+			tables = append(tables, table)
+		}
+	}
+	if len(tables) == 0 {
+		return nil
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.AutoMigrate(&Organization{}); err != nil {
+			return err
+		}
+		organization := Organization{
+			BaseModel: BaseModel{ID: uuid.New()},
+			Name:      "Default organization",
+		}
+		if err := tx.Create(&organization).Error; err != nil {
+			return err
+		}
+		log.Info().Msgf("moving existing %v into the default organization %s", tables, organization.ID)
+
+		for _, table := range tables {
+			if err := tx.Exec("ALTER TABLE " + table + " ADD COLUMN organization_id uuid").Error; err != nil {
+				return err
+			}
+			if err := tx.Exec("UPDATE "+table+" SET organization_id = ?", organization.ID).Error; err != nil {
+				return err
+			}
+			if err := tx.Exec("ALTER TABLE " + table + " ALTER COLUMN organization_id SET NOT NULL").Error; err != nil {
+				return err
+			}
+		}
+
+		if !slices.Contains(tables, "documents") {
+			return nil
+		}
+		if err := tx.AutoMigrate(&IngestionJob{}); err != nil {
+			return err
+		}
+		return tx.Exec(
+			`INSERT INTO ingestion_jobs (id, created_at, updated_at, organization_id, document_id, status, attempts, mime_type, run_at)
+			SELECT gen_random_uuid(), now(), now(), organization_id, id, ?, 0, '', now()
+			FROM documents WHERE deleted_at IS NULL
+			ON CONFLICT (document_id) DO NOTHING`,
+			IngestionStatusPending,
+		).Error
+	})
+}
+
 func (r *Repository) CreateDocument(ctx context.Context, document *Document) error {
 	return r.db.WithContext(ctx).Create(document).Error
 }
@@ -56,53 +156,144 @@ func (r *Repository) CreateMessage(ctx context.Context, message *Message) error
 	return r.db.WithContext(ctx).Create(message).Error
 }
 
-func (r *Repository) GetAllDocuments(ctx context.Context, offset int, pageSize int) ([]Document, int, error) {
+func (r *Repository) CreateConversation(ctx context.Context, conversation *Conversation) error {
+	return r.db.WithContext(ctx).Create(conversation).Error
+}
+
+func (r *Repository) GetConversationsByUserID(ctx context.Context, userID uuid.UUID, offset int, pageSize int) ([]Conversation, int, error) {
+	var conversations []Conversation
+	var total int64
+	err := r.db.WithContext(ctx).Where("user_id = ?", userID).Order("updated_at DESC").Offset(offset).Limit(pageSize).Find(&conversations).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	err = r.db.WithContext(ctx).Model(&Conversation{}).Where("user_id = ?", userID).Count(&total).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	return conversations, int(total), nil
+}
+
+func (r *Repository) GetConversationByID(ctx context.Context, userID uuid.UUID, id uuid.UUID) (*Conversation, error) {
+	var conversation Conversation
+	err := r.db.WithContext(ctx).
+		Preload("Messages", func(db *gorm.DB) *gorm.DB { return db.Order("created_at ASC") }).
+		First(&conversation, "id = ? AND user_id = ?", id, userID).Error
+	if err != nil {
+		return nil, err
+	}
+	return &conversation, nil
+}
+
+// GetRecentMessages returns the last limit messages of a conversation in chronological order.
+func (r *Repository) GetRecentMessages(ctx context.Context, conversationID uuid.UUID, limit int) ([]Message, error) {
+	var messages []Message
+	err := r.db.WithContext(ctx).
+		Where("conversation_id = ?", conversationID).
+		Order("created_at DESC").
+		Limit(limit).
+		Find(&messages).Error
+	if err != nil {
+		return nil, err
+	}
+	slices.Reverse(messages)
+	return messages, nil
+}
+
+// AppendMessages stores a turn of the conversation and bumps its updated_at so it sorts first.
+func (r *Repository) AppendMessages(ctx context.Context, conversation *Conversation, messages []*Message) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(messages).Error; err != nil {
+			return err
+		}
+		return tx.Model(conversation).Updates(map[string]interface{}{
+			"title":      conversation.Title,
+			"updated_at": time.Now(),
+		}).Error
+	})
+}
+
+func (r *Repository) SoftDeleteConversation(ctx context.Context, userID uuid.UUID, id uuid.UUID) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		res := tx.Delete(&Conversation{}, "id = ? AND user_id = ?", id, userID)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return ErrNotFound
+		}
+		return tx.Delete(&Message{}, "conversation_id = ?", id).Error
+	})
+}
+
+func (r *Repository) GetAllDocuments(ctx context.Context, orgID uuid.UUID, offset int, pageSize int) ([]Document, int, error) {
 	var documents []Document
 	var total int64
-	err := r.db.WithContext(ctx).Preload("Chunks").Offset(offset).Limit(pageSize).Find(&documents).Error
+	err := r.db.WithContext(ctx).Preload("Chunks").Where("organization_id = ?", orgID).Offset(offset).Limit(pageSize).Find(&documents).Error
 	if err != nil {
 		return nil, 0, err
 	}
-	err = r.db.WithContext(ctx).Model(&Document{}).Count(&total).Error
+	err = r.db.WithContext(ctx).Model(&Document{}).Where("organization_id = ?", orgID).Count(&total).Error
 	if err != nil {
 		return nil, 0, err
 	}
 	return documents, int(total), nil
 }
 
-func (r *Repository) GetDocumentByID(ctx context.Context, id uuid.UUID) (*Document, error) {
+func (r *Repository) GetDocumentByID(ctx context.Context, orgID uuid.UUID, id uuid.UUID) (*Document, error) {
 	var document Document
-	err := r.db.WithContext(ctx).Preload("Chunks").First(&document, "id = ?", id).Error
+	err := r.db.WithContext(ctx).Preload("Chunks").First(&document, "id = ? AND organization_id = ?", id, orgID).Error
 	if err != nil {
 		return nil, err
 	}
 	return &document, nil
 }
 
-func (r *Repository) GetChunkByID(ctx context.Context, id uuid.UUID) (*Chunk, error) {
+func (r *Repository) GetChunkByID(ctx context.Context, orgID uuid.UUID, id uuid.UUID) (*Chunk, error) {
 	var chunk Chunk
-	err := r.db.WithContext(ctx).First(&chunk, "id = ?", id).Error
+	err := r.db.WithContext(ctx).First(&chunk, "id = ? AND organization_id = ?", id, orgID).Error
 	if err != nil {
 		return nil, err
 	}
 	return &chunk, nil
 }
 
-func (r *Repository) SoftDeleteDocumentAndChunks(ctx context.Context, id uuid.UUID) error {
+func (r *Repository) GetChunksByIDs(ctx context.Context, orgID uuid.UUID, ids []uuid.UUID) ([]Chunk, error) {
+	var chunks []Chunk
+	if len(ids) == 0 {
+		return chunks, nil
+	}
+	err := r.db.WithContext(ctx).Preload("Document").
+		Joins("JOIN documents ON documents.id = chunks.document_id AND documents.deleted_at IS NULL").
+		Where("chunks.id IN ? AND chunks.organization_id = ?", ids, orgID).
+		Find(&chunks).Error
+	if err != nil {
+		return nil, err
+	}
+	return chunks, nil
+}
+
+// SoftDeleteDocumentAndChunks deletes a document with its chunks and ingestion job. The
+// document is deleted first so its row lock orders this against ReplaceDocumentChunks; a job
+// that is already running notices the deletion there and stores nothing.
+func (r *Repository) SoftDeleteDocumentAndChunks(ctx context.Context, orgID uuid.UUID, id uuid.UUID) error {
 	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
-		if err := tx.Delete(&Chunk{}, "document_id = ?", id).Error; err != nil {
+		if err := tx.Delete(&Document{}, "id = ? AND organization_id = ?", id, orgID).Error; err != nil {
 			return err
 		}
-		return tx.Delete(&Document{}, "id = ?", id).Error
+		if err := tx.Delete(&IngestionJob{}, "document_id = ? AND organization_id = ?", id, orgID).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&Chunk{}, "document_id = ? AND organization_id = ?", id, orgID).Error
 	})
 }
 
-func (r *Repository) SoftDeleteChunk(ctx context.Context, id uuid.UUID) error {
-	return r.db.WithContext(ctx).Delete(&Chunk{}, "id = ?", id).Error
+func (r *Repository) SoftDeleteChunk(ctx context.Context, orgID uuid.UUID, id uuid.UUID) error {
+	return r.db.WithContext(ctx).Delete(&Chunk{}, "id = ? AND organization_id = ?", id, orgID).Error
 }
 
 func (r *Repository) UpsertProgressMoneySaved(ctx context.Context, userID uuid.UUID, money int) error {
-	today := time.Now()
+	today := time.Now().Format("2006-01-02")
 
 	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
 		res := tx.Model(&ProgressEvent{}).
@@ -131,36 +322,40 @@ func (r *Repository) UpsertProgressMoneySaved(ctx context.Context, userID uuid.U
 	})
 }
 
+// UpsertProgressStatus sets the status of the user's progress on the given day.
 func (r *Repository) UpsertProgressStatus(
 	ctx context.Context,
 	userID uuid.UUID,
+	day time.Time,
 	status ProgressEventStatus,
 ) error {
-	today := time.Now().Format("2006-01-02")
-
 	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
-		res := tx.Model(&ProgressEvent{}).
-			Where("user_id = ? AND date = ?", userID, today).
-			UpdateColumn("status", status)
-		if res.Error != nil {
-			return res.Error
-		}
+		return upsertProgressStatus(tx, userID, day, status)
+	})
+}
 
-		if res.RowsAffected == 0 {
-			evt := ProgressEvent{
-				BaseModel: BaseModel{
-					ID: uuid.New(),
-				},
-				UserID: userID,
-				Date:   time.Now(),
-				Status: status,
-			}
-			if err := tx.Create(&evt).Error; err != nil {
-				return err
-			}
+func upsertProgressStatus(tx *gorm.DB, userID uuid.UUID, day time.Time, status ProgressEventStatus) error {
+	res := tx.Model(&ProgressEvent{}).
+		Where("user_id = ? AND date = ?", userID, day.Format("2006-01-02")).
+		UpdateColumn("status", status)
+	if res.Error != nil {
+		return res.Error
+	}
+
+	if res.RowsAffected == 0 {
+		evt := ProgressEvent{
+			BaseModel: BaseModel{
+				ID: uuid.New(),
+			},
+			UserID: userID,
+			Date:   day,
+			Status: status,
 		}
-		return nil
-	})
+		if err := tx.Create(&evt).Error; err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 func (r *Repository) GetProgressEventsForMonth(
@@ -191,15 +386,359 @@ func (r *Repository) GetUserByID(ctx context.Context, id uuid.UUID) (*User, erro
 	return &user, nil
 }
 
+func (r *Repository) UpdateUserQuitDate(ctx context.Context, userID uuid.UUID, quitDate time.Time) error {
+	return r.db.WithContext(ctx).Model(&User{}).Where("id = ?", userID).Update("quit_date", quitDate).Error
+}
+
+func (r *Repository) GetOrganizationByID(ctx context.Context, id uuid.UUID) (*Organization, error) {
+	var organization Organization
+	err := r.db.WithContext(ctx).First(&organization, "id = ?", id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &organization, nil
+}
+
+// CreateOrganizationWithAdmin creates a tenant together with its first administrator.
+func (r *Repository) CreateOrganizationWithAdmin(ctx context.Context, organization *Organization, admin *User) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(organization).Error; err != nil {
+			return err
+		}
+		admin.OrganizationID = organization.ID
+		admin.Role = UserRoleAdmin
+		return tx.Create(admin).Error
+	})
+}
+
+func (r *Repository) GetUserByEmail(ctx context.Context, email string) (*User, error) {
+	var user User
+	err := r.db.WithContext(ctx).First(&user, "email = ?", email).Error
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (r *Repository) CreateUser(ctx context.Context, user *User) error {
+	return r.db.WithContext(ctx).Create(user).Error
+}
+
+func (r *Repository) CreateRefreshToken(ctx context.Context, token *RefreshToken) error {
+	return r.db.WithContext(ctx).Create(token).Error
+}
+
+// RevokeRefreshToken marks an active refresh token as revoked. It returns ErrNotFound when the token
+// is unknown, already revoked or expired, so a refresh token can only ever be exchanged once.
+func (r *Repository) RevokeRefreshToken(ctx context.Context, id uuid.UUID, userID uuid.UUID) error {
+	now := time.Now()
+	res := r.db.WithContext(ctx).Model(&RefreshToken{}).
+		Where("id = ? AND user_id = ? AND revoked_at IS NULL AND expires_at > ?", id, userID, now).
+		UpdateColumn("revoked_at", now)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
 func (r *Repository) CreateProgressEvent(ctx context.Context, progressEvent *ProgressEvent) error {
 	return r.db.WithContext(ctx).Create(progressEvent).Error
 }
 
 func (r *Repository) GetProgressEventsByUserID(ctx context.Context, userID uuid.UUID) ([]ProgressEvent, error) {
 	var progressEvents []ProgressEvent
-	err := r.db.WithContext(ctx).Where("user_id = ?", userID).Find(&progressEvents).Error
+	err := r.db.WithContext(ctx).Where("user_id = ?", userID).Order("date ASC").Find(&progressEvents).Error
 	if err != nil {
 		return nil, err
 	}
 	return progressEvents, nil
 }
+
+// CreateCraving saves a new craving; one that is already SLIPPED marks its day a slip.
+func (r *Repository) CreateCraving(ctx context.Context, craving *Craving) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if craving.Outcome != nil && *craving.Outcome == CravingOutcomeSlipped {
+			if err := markCravingSlip(tx, craving); err != nil {
+				return err
+			}
+		}
+		return tx.Create(craving).Error
+	})
+}
+
+func (r *Repository) GetCravingsByUserID(ctx context.Context, userID uuid.UUID, offset int, pageSize int) ([]Craving, int, error) {
+	var cravings []Craving
+	var total int64
+	err := r.db.WithContext(ctx).Where("user_id = ?", userID).Order("occurred_at DESC").Offset(offset).Limit(pageSize).Find(&cravings).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	err = r.db.WithContext(ctx).Model(&Craving{}).Where("user_id = ?", userID).Count(&total).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	return cravings, int(total), nil
+}
+
+func (r *Repository) GetCravingByID(ctx context.Context, userID uuid.UUID, id uuid.UUID) (*Craving, error) {
+	var craving Craving
+	err := r.db.WithContext(ctx).First(&craving, "id = ? AND user_id = ?", id, userID).Error
+	if err != nil {
+		return nil, err
+	}
+	return &craving, nil
+}
+
+// UpdateCravingOutcome sets a craving's outcome and keeps its day in step. Only a change
+// touches the day: turning SLIPPED marks the day a slip, and turning back restores the
+// status it had before, unless another craving that day is still SLIPPED.
+func (r *Repository) UpdateCravingOutcome(ctx context.Context, userID uuid.UUID, id uuid.UUID, outcome CravingOutcome) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var craving Craving
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&craving, "id = ? AND user_id = ?", id, userID).Error
+		if err != nil {
+			return err
+		}
+
+		slipped := craving.Outcome != nil && *craving.Outcome == CravingOutcomeSlipped
+		switch {
+		case outcome == CravingOutcomeSlipped && !slipped:
+			if err := markCravingSlip(tx, &craving); err != nil {
+				return err
+			}
+		case outcome != CravingOutcomeSlipped && slipped:
+			if err := unmarkCravingSlip(tx, &craving); err != nil {
+				return err
+			}
+			craving.DayStatusBeforeSlip = nil
+		}
+
+		return tx.Model(&craving).UpdateColumns(map[string]interface{}{
+			"outcome":                outcome,
+			"day_status_before_slip": craving.DayStatusBeforeSlip,
+		}).Error
+	})
+}
+
+// markCravingSlip marks the craving's day a slip, noting on the craving what the day was
+// before. The caller saves the craving.
+func markCravingSlip(tx *gorm.DB, craving *Craving) error {
+	var event ProgressEvent
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("user_id = ? AND date = ?", craving.UserID, craving.OccurredAt.Format("2006-01-02")).
+		Take(&event).Error
+	switch {
+	case err == nil:
+		craving.DayStatusBeforeSlip = &event.Status
+	case errors.Is(err, ErrNotFound):
+		craving.DayStatusBeforeSlip = nil
+	default:
+		return err
+	}
+	return upsertProgressStatus(tx, craving.UserID, craving.OccurredAt, ProgressEventStatusSlip)
+}
+
+// unmarkCravingSlip undoes markCravingSlip, unless the day was changed since or another
+// craving that day still stands as a slip. A day the slip created is removed if nothing
+// else was recorded on it.
+func unmarkCravingSlip(tx *gorm.DB, craving *Craving) error {
+	day := craving.OccurredAt.Format("2006-01-02")
+	start, err := time.ParseInLocation("2006-01-02", day, craving.OccurredAt.Location())
+	if err != nil {
+		return err
+	}
+
+	var others int64
+	err = tx.Model(&Craving{}).
+		Where("user_id = ? AND id <> ? AND outcome = ? AND occurred_at >= ? AND occurred_at < ?",
+			craving.UserID, craving.ID, CravingOutcomeSlipped, start, start.AddDate(0, 0, 1)).
+		Count(&others).Error
+	if err != nil || others > 0 {
+		return err
+	}
+
+	previous := ProgressEventStatusUnknown
+	if craving.DayStatusBeforeSlip != nil {
+		previous = *craving.DayStatusBeforeSlip
+	} else {
+		err := tx.Unscoped().
+			Where("user_id = ? AND date = ? AND status = ?", craving.UserID, day, ProgressEventStatusSlip).
+			Where("notes IS NULL AND COALESCE(money_saved, 0) = 0").
+			Delete(&ProgressEvent{}).Error
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Model(&ProgressEvent{}).
+		Where("user_id = ? AND date = ? AND status = ?", craving.UserID, day, ProgressEventStatusSlip).
+		UpdateColumn("status", previous).Error
+}
+
+func (r *Repository) CountResistedCravings(ctx context.Context, userID uuid.UUID) (int, error) {
+	var total int64
+	err := r.db.WithContext(ctx).Model(&Craving{}).Where("user_id = ? AND outcome = ?", userID, CravingOutcomeResisted).Count(&total).Error
+	if err != nil {
+		return 0, err
+	}
+	return int(total), nil
+}
+
+// CountMessagesByUserID counts the messages with the given role across the user's
+// conversations.
+func (r *Repository) CountMessagesByUserID(ctx context.Context, userID uuid.UUID, role string) (int, error) {
+	var total int64
+	err := r.db.WithContext(ctx).Model(&Message{}).
+		Joins("JOIN conversations ON conversations.id = messages.conversation_id").
+		Where("conversations.user_id = ? AND messages.role = ?", userID, role).
+		Count(&total).Error
+	if err != nil {
+		return 0, err
+	}
+	return int(total), nil
+}
+
+// GetStrategyStats counts, per coping strategy, the user's cravings with a known outcome.
+func (r *Repository) GetStrategyStats(ctx context.Context, userID uuid.UUID) ([]StrategyStats, error) {
+	var stats []StrategyStats
+	err := r.db.WithContext(ctx).Model(&Craving{}).
+		Select("strategy_key, COUNT(*) AS tried, COUNT(*) FILTER (WHERE outcome = ?) AS resisted", CravingOutcomeResisted).
+		Where("user_id = ? AND strategy_key IS NOT NULL AND outcome IS NOT NULL", userID).
+		Group("strategy_key").
+		Scan(&stats).Error
+	if err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+// CountCopingStrategies counts an organization's coping strategies, including deleted ones.
+func (r *Repository) CountCopingStrategies(ctx context.Context, orgID uuid.UUID) (int, error) {
+	var total int64
+	err := r.db.WithContext(ctx).Unscoped().Model(&CopingStrategy{}).Where("organization_id = ?", orgID).Count(&total).Error
+	if err != nil {
+		return 0, err
+	}
+	return int(total), nil
+}
+
+// SeedCopingStrategies creates strategies, skipping any whose key the organization already
+// has, so concurrent seeding is harmless.
+func (r *Repository) SeedCopingStrategies(ctx context.Context, strategies []*CopingStrategy) error {
+	return r.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(strategies).Error
+}
+
+func (r *Repository) GetCopingStrategies(ctx context.Context, orgID uuid.UUID, activeOnly bool) ([]CopingStrategy, error) {
+	var strategies []CopingStrategy
+	query := r.db.WithContext(ctx).Where("organization_id = ?", orgID)
+	if activeOnly {
+		query = query.Where("active")
+	}
+	err := query.Order("position ASC, created_at ASC").Find(&strategies).Error
+	if err != nil {
+		return nil, err
+	}
+	return strategies, nil
+}
+
+func (r *Repository) GetCopingStrategyByID(ctx context.Context, orgID uuid.UUID, id uuid.UUID) (*CopingStrategy, error) {
+	var strategy CopingStrategy
+	err := r.db.WithContext(ctx).First(&strategy, "id = ? AND organization_id = ?", id, orgID).Error
+	if err != nil {
+		return nil, err
+	}
+	return &strategy, nil
+}
+
+func (r *Repository) CreateCopingStrategy(ctx context.Context, strategy *CopingStrategy) error {
+	return r.db.WithContext(ctx).Create(strategy).Error
+}
+
+func (r *Repository) UpdateCopingStrategy(ctx context.Context, strategy *CopingStrategy) error {
+	return r.db.WithContext(ctx).Save(strategy).Error
+}
+
+func (r *Repository) SoftDeleteCopingStrategy(ctx context.Context, orgID uuid.UUID, id uuid.UUID) error {
+	res := r.db.WithContext(ctx).Delete(&CopingStrategy{}, "id = ? AND organization_id = ?", id, orgID)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// CountMilestoneDefinitions counts an organization's milestones, including deleted ones.
+func (r *Repository) CountMilestoneDefinitions(ctx context.Context, orgID uuid.UUID) (int, error) {
+	var total int64
+	err := r.db.WithContext(ctx).Unscoped().Model(&MilestoneDefinition{}).Where("organization_id = ?", orgID).Count(&total).Error
+	if err != nil {
+		return 0, err
+	}
+	return int(total), nil
+}
+
+// SeedMilestoneDefinitions creates milestones, skipping any whose key the organization
+// already has, so concurrent seeding is harmless.
+func (r *Repository) SeedMilestoneDefinitions(ctx context.Context, milestones []*MilestoneDefinition) error {
+	return r.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(milestones).Error
+}
+
+func (r *Repository) GetMilestoneDefinitions(ctx context.Context, orgID uuid.UUID, activeOnly bool) ([]MilestoneDefinition, error) {
+	var milestones []MilestoneDefinition
+	query := r.db.WithContext(ctx).Where("organization_id = ?", orgID)
+	if activeOnly {
+		query = query.Where("active")
+	}
+	err := query.Order("after_minutes ASC, created_at ASC").Find(&milestones).Error
+	if err != nil {
+		return nil, err
+	}
+	return milestones, nil
+}
+
+func (r *Repository) GetMilestoneDefinitionByID(ctx context.Context, orgID uuid.UUID, id uuid.UUID) (*MilestoneDefinition, error) {
+	var milestone MilestoneDefinition
+	err := r.db.WithContext(ctx).First(&milestone, "id = ? AND organization_id = ?", id, orgID).Error
+	if err != nil {
+		return nil, err
+	}
+	return &milestone, nil
+}
+
+func (r *Repository) CreateMilestoneDefinition(ctx context.Context, milestone *MilestoneDefinition) error {
+	return r.db.WithContext(ctx).Create(milestone).Error
+}
+
+func (r *Repository) UpdateMilestoneDefinition(ctx context.Context, milestone *MilestoneDefinition) error {
+	return r.db.WithContext(ctx).Save(milestone).Error
+}
+
+func (r *Repository) SoftDeleteMilestoneDefinition(ctx context.Context, orgID uuid.UUID, id uuid.UUID) error {
+	res := r.db.WithContext(ctx).Delete(&MilestoneDefinition{}, "id = ? AND organization_id = ?", id, orgID)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *Repository) GetUserAchievements(ctx context.Context, userID uuid.UUID) ([]UserAchievement, error) {
+	var achievements []UserAchievement
+	err := r.db.WithContext(ctx).Where("user_id = ?", userID).Order("unlocked_at ASC").Find(&achievements).Error
+	if err != nil {
+		return nil, err
+	}
+	return achievements, nil
+}
+
+// CreateUserAchievements records unlocked achievements, skipping any the user already has,
+// so concurrent evaluation is harmless.
+func (r *Repository) CreateUserAchievements(ctx context.Context, achievements []*UserAchievement) error {
+	return r.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(achievements).Error
+}
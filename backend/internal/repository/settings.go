@@ -0,0 +1,51 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+)
+
+// GetLatestOrganizationSettings returns the settings version in effect.
+func (r *Repository) GetLatestOrganizationSettings(ctx context.Context, orgID uuid.UUID) (*OrganizationSettings, error) {
+	var settings OrganizationSettings
+	err := r.db.WithContext(ctx).
+		Where("organization_id = ?", orgID).
+		Order("version DESC").
+		First(&settings).Error
+	if err != nil {
+		return nil, err
+	}
+	return &settings, nil
+}
+
+func (r *Repository) GetOrganizationSettingsVersion(ctx context.Context, orgID uuid.UUID, version int) (*OrganizationSettings, error) {
+	var settings OrganizationSettings
+	err := r.db.WithContext(ctx).First(&settings, "organization_id = ? AND version = ?", orgID, version).Error
+	if err != nil {
+		return nil, err
+	}
+	return &settings, nil
+}
+
+// GetOrganizationSettingsVersions returns a page of the organization's settings versions,
+// newest first.
+func (r *Repository) GetOrganizationSettingsVersions(ctx context.Context, orgID uuid.UUID, offset int, pageSize int) ([]OrganizationSettings, int, error) {
+	var versions []OrganizationSettings
+	var total int64
+	query := r.db.WithContext(ctx).Model(&OrganizationSettings{}).Where("organization_id = ?", orgID)
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err := query.Order("version DESC").Offset(offset).Limit(pageSize).Find(&versions).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	return versions, int(total), nil
+}
+
+// CreateOrganizationSettings stores a new version. It returns ErrDuplicate if settings.Version
+// was taken by a concurrent save.
+func (r *Repository) CreateOrganizationSettings(ctx context.Context, settings *OrganizationSettings) error {
+	return r.db.WithContext(ctx).Create(settings).Error
+}
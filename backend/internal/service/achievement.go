@@ -0,0 +1,204 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"patient-chatbot/internal/dto"
+	"patient-chatbot/internal/repository"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+)
+
+type achievementMetric string
+
+const (
+	metricStreakDays       achievementMetric = "streak_days"
+	metricMoneySaved       achievementMetric = "money_saved"
+	metricCravingsResisted achievementMetric = "cravings_resisted"
+	metricChatMessages     achievementMetric = "chat_messages"
+)
+
+// achievementRule unlocks an achievement once the user's value for Metric reaches Target.
+// Keys are stored with unlocked achievements, so they must not change.
+type achievementRule struct {
+	Key           string
+	Metric        achievementMetric
+	Target        int
+	TitleEN       string
+	TitleAR       string
+	DescriptionEN string
+	DescriptionAR string
+}
+
+var achievementRules = []achievementRule{
+	{
+		Key: "first_day", Metric: metricStreakDays, Target: 1,
+		TitleEN: "First Day Champion", TitleAR: "بطل اليوم الأول",
+		DescriptionEN: "Complete your first smoke-free day", DescriptionAR: "أكمل يومك الأول بدون تدخين",
+	},
+	{
+		Key: "three_day_streak", Metric: metricStreakDays, Target: 3,
+		TitleEN: "Three Day Warrior", TitleAR: "محارب الأيام الثلاثة",
+		DescriptionEN: "Keep a 3-day smoke-free streak", DescriptionAR: "حافظ على 3 أيام متتالية بدون تدخين",
+	},
+	{
+		Key: "week_streak", Metric: metricStreakDays, Target: 7,
+		TitleEN: "Week Conqueror", TitleAR: "قاهر الأسبوع",
+		DescriptionEN: "Keep a 7-day smoke-free streak", DescriptionAR: "حافظ على 7 أيام متتالية بدون تدخين",
+	},
+	{
+		Key: "month_streak", Metric: metricStreakDays, Target: 30,
+		TitleEN: "Month Master", TitleAR: "سيد الشهر",
+		DescriptionEN: "Keep a 30-day smoke-free streak", DescriptionAR: "حافظ على 30 يومًا متتاليًا بدون تدخين",
+	},
+	{
+		Key: "money_saver", Metric: metricMoneySaved, Target: 50,
+		TitleEN: "Money Saver", TitleAR: "الموفّر",
+		DescriptionEN: "Save 50 by not buying cigarettes", DescriptionAR: "وفّر 50 بعدم شراء السجائر",
+	},
+	{
+		Key: "big_saver", Metric: metricMoneySaved, Target: 500,
+		TitleEN: "Big Saver", TitleAR: "الموفّر الكبير",
+		DescriptionEN: "Save 500 by not buying cigarettes", DescriptionAR: "وفّر 500 بعدم شراء السجائر",
+	},
+	{
+		Key: "craving_crusher", Metric: metricCravingsResisted, Target: 1,
+		TitleEN: "Craving Crusher", TitleAR: "قاهر الرغبة",
+		DescriptionEN: "Resist your first craving", DescriptionAR: "قاوم رغبتك الأولى في التدخين",
+	},
+	{
+		Key: "willpower_warrior", Metric: metricCravingsResisted, Target: 10,
+		TitleEN: "Willpower Warrior", TitleAR: "محارب الإرادة",
+		DescriptionEN: "Resist 10 cravings", DescriptionAR: "قاوم 10 رغبات في التدخين",
+	},
+	{
+		Key: "engaged_learner", Metric: metricChatMessages, Target: 10,
+		TitleEN: "Engaged Learner", TitleAR: "المتعلم المثابر",
+		DescriptionEN: "Send 10 messages to your coach", DescriptionAR: "أرسل 10 رسائل إلى مدربك",
+	},
+	{
+		Key: "coach_companion", Metric: metricChatMessages, Target: 50,
+		TitleEN: "Coach's Companion", TitleAR: "رفيق المدرب",
+		DescriptionEN: "Send 50 messages to your coach", DescriptionAR: "أرسل 50 رسالة إلى مدربك",
+	},
+}
+
+// GetAchievements returns every achievement in lang, unlocked or not, with the user's
+// progress towards it.
+func (s *Service) GetAchievements(ctx context.Context, userID uuid.UUID, lang string) ([]dto.Achievement, error) {
+	values, unlocked, err := s.unlockAchievements(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return buildAchievements(achievementRules, values, unlocked, lang), nil
+}
+
+// checkAchievements unlocks any achievements the user has earned. It runs after every
+// progress event and craving is written; the write has succeeded by then, so a failure is
+// only logged and the achievements are unlocked on the next check.
+func (s *Service) checkAchievements(ctx context.Context, userID uuid.UUID) {
+	if _, _, err := s.unlockAchievements(ctx, userID); err != nil {
+		log.Warn().Msg("checkAchievements :: " + err.Error())
+	}
+}
+
+// unlockAchievements records the achievements the user has newly earned and returns their
+// current metric values and when each of their achievements was unlocked.
+func (s *Service) unlockAchievements(ctx context.Context, userID uuid.UUID) (map[achievementMetric]int, map[string]time.Time, error) {
+	values, err := s.achievementValues(ctx, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	achievements, err := s.repository.GetUserAchievements(ctx, userID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("unlockAchievements :: getUserAchievements: %w", err)
+	}
+	unlocked := make(map[string]time.Time, len(achievements))
+	for _, achievement := range achievements {
+		unlocked[achievement.AchievementKey] = achievement.UnlockedAt
+	}
+
+	earned := earnedAchievements(achievementRules, values, unlocked)
+	if len(earned) == 0 {
+		return values, unlocked, nil
+	}
+	now := time.Now()
+	created := make([]*repository.UserAchievement, len(earned))
+	for i, key := range earned {
+		created[i] = &repository.UserAchievement{
+			BaseModel:      repository.BaseModel{ID: uuid.New()},
+			UserID:         userID,
+			AchievementKey: key,
+			UnlockedAt:     now,
+		}
+		unlocked[key] = now
+	}
+	if err := s.repository.CreateUserAchievements(ctx, created); err != nil {
+		return nil, nil, fmt.Errorf("unlockAchievements :: createUserAchievements: %w", err)
+	}
+	return values, unlocked, nil
+}
+
+func (s *Service) achievementValues(ctx context.Context, userID uuid.UUID) (map[achievementMetric]int, error) {
+	dashboard, err := s.GetDashboardData(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	resisted, err := s.repository.CountResistedCravings(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("achievementValues :: countResistedCravings: %w", err)
+	}
+	messages, err := s.repository.CountMessagesByUserID(ctx, userID, string(dto.UserRole))
+	if err != nil {
+		return nil, fmt.Errorf("achievementValues :: countMessagesByUserID: %w", err)
+	}
+	return map[achievementMetric]int{
+		metricStreakDays:       dashboard.StreakDaysSmokeFree,
+		metricMoneySaved:       dashboard.TotalMoneySaved,
+		metricCravingsResisted: resisted,
+		metricChatMessages:     messages,
+	}, nil
+}
+
+// earnedAchievements returns the keys of the rules whose target is met but which are not
+// unlocked yet.
+func earnedAchievements(rules []achievementRule, values map[achievementMetric]int, unlocked map[string]time.Time) []string {
+	var earned []string
+	for _, rule := range rules {
+		if _, ok := unlocked[rule.Key]; !ok && values[rule.Metric] >= rule.Target {
+			earned = append(earned, rule.Key)
+		}
+	}
+	return earned
+}
+
+// buildAchievements localizes rules and adds the user's progress. An unlocked achievement
+// stays complete even if its metric has dropped since, e.g. after a slip ends a streak.
+func buildAchievements(rules []achievementRule, values map[achievementMetric]int, unlocked map[string]time.Time, lang string) []dto.Achievement {
+	achievements := make([]dto.Achievement, len(rules))
+	for i, rule := range rules {
+		achievement := dto.Achievement{
+			Key:         rule.Key,
+			Title:       rule.TitleEN,
+			Description: rule.DescriptionEN,
+			Metric:      string(rule.Metric),
+			Target:      rule.Target,
+			Progress:    min(values[rule.Metric], rule.Target),
+		}
+		if lang != "en" {
+			achievement.Title = rule.TitleAR
+			achievement.Description = rule.DescriptionAR
+		}
+		if unlockedAt, ok := unlocked[rule.Key]; ok {
+			achievement.Unlocked = true
+			achievement.UnlockedAt = &unlockedAt
+			achievement.Progress = rule.Target
+		}
+		achievement.Percentage = 100 * achievement.Progress / rule.Target
+		achievements[i] = achievement
+	}
+	return achievements
+}
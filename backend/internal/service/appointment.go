@@ -0,0 +1,338 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"patient-chatbot/internal/repository"
+
+	"github.com/google/uuid"
+)
+
+const (
+	minSlotMinutes = 5
+	maxSlotMinutes = 8 * 60
+	// maxSlotsPerRequest bounds how many slots one availability request can create.
+	maxSlotsPerRequest = 500
+	// defaultSlotWindow is how far ahead open slots are listed when no end is given, and
+	// maxSlotWindow the longest range that can be listed at once.
+	defaultSlotWindow = 14 * 24 * time.Hour
+	maxSlotWindow     = 62 * 24 * time.Hour
+)
+
+var (
+	ErrClinicianNotFound   = errors.New("clinician not found")
+	ErrInvalidAvailability = errors.New("invalid availability")
+	ErrSlotsOverlap        = errors.New("slots overlap existing availability")
+	ErrSlotNotFound        = errors.New("slot not found")
+	ErrSlotBooked          = errors.New("slot is booked")
+	ErrSlotUnavailable     = errors.New("slot is not available")
+	ErrAppointmentNotFound = errors.New("appointment not found")
+	ErrInvalidAppointment  = errors.New("invalid appointment")
+)
+
+type ClinicianInput struct {
+	Name      string
+	Specialty string
+	Location  string
+	Active    bool
+}
+
+// AppointmentInput is a patient's booking of an open slot.
+type AppointmentInput struct {
+	SlotID string
+	Title  string
+	Type   repository.AppointmentType
+	Notes  string
+}
+
+var appointmentTypes = []repository.AppointmentType{
+	repository.AppointmentTypeConsultation,
+	repository.AppointmentTypeFollowUp,
+	repository.AppointmentTypeTest,
+	repository.AppointmentTypeProcedure,
+}
+
+func (s *Service) GetClinicians(ctx context.Context, orgID uuid.UUID, activeOnly bool) ([]repository.Clinician, error) {
+	clinicians, err := s.repository.GetClinicians(ctx, orgID, activeOnly)
+	if err != nil {
+		return nil, fmt.Errorf("getClinicians :: getClinicians: %w", err)
+	}
+	return clinicians, nil
+}
+
+func (s *Service) CreateClinician(ctx context.Context, orgID uuid.UUID, input ClinicianInput) (*repository.Clinician, error) {
+	clinician := &repository.Clinician{
+		BaseModel:      repository.BaseModel{ID: uuid.New()},
+		OrganizationID: orgID,
+	}
+	applyClinicianInput(clinician, input)
+	if err := s.repository.CreateClinician(ctx, clinician); err != nil {
+		return nil, fmt.Errorf("createClinician :: createClinician: %w", err)
+	}
+	return clinician, nil
+}
+
+func (s *Service) UpdateClinician(ctx context.Context, orgID uuid.UUID, id string, input ClinicianInput) (*repository.Clinician, error) {
+	clinician, err := s.getClinician(ctx, orgID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	applyClinicianInput(clinician, input)
+	if err := s.repository.UpdateClinician(ctx, clinician); err != nil {
+		return nil, fmt.Errorf("updateClinician :: updateClinician: %w", err)
+	}
+	return clinician, nil
+}
+
+// DeleteClinician removes a clinician and their open slots. Appointments already booked
+// with them stay, and can still be cancelled or rescheduled.
+func (s *Service) DeleteClinician(ctx context.Context, orgID uuid.UUID, id string) error {
+	clinicianID, err := uuid.Parse(id)
+	if err != nil {
+		return ErrClinicianNotFound
+	}
+
+	err = s.repository.SoftDeleteClinician(ctx, orgID, clinicianID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return ErrClinicianNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("deleteClinician :: softDeleteClinician: %w", err)
+	}
+	return nil
+}
+
+// CreateAvailability splits the time from from to to into back-to-back slots of
+// slotMinutes for a clinician. Time left over at the end that is too short for a slot is
+// not used.
+func (s *Service) CreateAvailability(ctx context.Context, orgID uuid.UUID, clinicianID string, from time.Time, to time.Time, slotMinutes int) ([]*repository.AvailabilitySlot, error) {
+	clinician, err := s.getClinician(ctx, orgID, clinicianID)
+	if err != nil {
+		return nil, err
+	}
+	if slotMinutes < minSlotMinutes || slotMinutes > maxSlotMinutes {
+		return nil, fmt.Errorf("%w: slots must be between %d and %d minutes", ErrInvalidAvailability, minSlotMinutes, maxSlotMinutes)
+	}
+	if !from.After(time.Now()) {
+		return nil, fmt.Errorf("%w: availability must be in the future", ErrInvalidAvailability)
+	}
+
+	length := time.Duration(slotMinutes) * time.Minute
+	var slots []*repository.AvailabilitySlot
+	for start := from; !start.Add(length).After(to); start = start.Add(length) {
+		if len(slots) == maxSlotsPerRequest {
+			return nil, fmt.Errorf("%w: at most %d slots at a time", ErrInvalidAvailability, maxSlotsPerRequest)
+		}
+		slots = append(slots, &repository.AvailabilitySlot{
+			BaseModel:      repository.BaseModel{ID: uuid.New()},
+			OrganizationID: orgID,
+			ClinicianID:    clinician.ID,
+			StartsAt:       start,
+			EndsAt:         start.Add(length),
+		})
+	}
+	if len(slots) == 0 {
+		return nil, fmt.Errorf("%w: the time range is shorter than one slot", ErrInvalidAvailability)
+	}
+
+	err = s.repository.CreateAvailabilitySlots(ctx, clinician.ID, slots)
+	if errors.Is(err, repository.ErrConflict) {
+		return nil, ErrSlotsOverlap
+	}
+	if err != nil {
+		return nil, fmt.Errorf("createAvailability :: createAvailabilitySlots: %w", err)
+	}
+	for _, slot := range slots {
+		slot.Clinician = *clinician
+	}
+	return slots, nil
+}
+
+func (s *Service) DeleteSlot(ctx context.Context, orgID uuid.UUID, id string) error {
+	slotID, err := uuid.Parse(id)
+	if err != nil {
+		return ErrSlotNotFound
+	}
+
+	err = s.repository.SoftDeleteOpenSlot(ctx, orgID, slotID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return ErrSlotNotFound
+	}
+	if errors.Is(err, repository.ErrConflict) {
+		return ErrSlotBooked
+	}
+	if err != nil {
+		return fmt.Errorf("deleteSlot :: softDeleteOpenSlot: %w", err)
+	}
+	return nil
+}
+
+// GetOpenSlots lists the slots patients can book that start between from and to, optionally
+// for one clinician. A zero from means now and a zero to means defaultSlotWindow after from.
+func (s *Service) GetOpenSlots(ctx context.Context, orgID uuid.UUID, clinicianID string, from time.Time, to time.Time) ([]repository.AvailabilitySlot, error) {
+	if now := time.Now(); from.Before(now) {
+		from = now
+	}
+	if to.IsZero() {
+		to = from.Add(defaultSlotWindow)
+	}
+	if to.Sub(from) > maxSlotWindow {
+		to = from.Add(maxSlotWindow)
+	}
+
+	var clinician *uuid.UUID
+	if clinicianID != "" {
+		id, err := uuid.Parse(clinicianID)
+		if err != nil {
+			return nil, ErrClinicianNotFound
+		}
+		clinician = &id
+	}
+
+	slots, err := s.repository.GetOpenSlots(ctx, orgID, clinician, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("getOpenSlots :: getOpenSlots: %w", err)
+	}
+	return slots, nil
+}
+
+// BookAppointment books an open slot for the user. Two patients cannot book the same slot:
+// whoever reserves it second gets ErrSlotUnavailable.
+func (s *Service) BookAppointment(ctx context.Context, userID uuid.UUID, orgID uuid.UUID, input AppointmentInput) (*repository.Appointment, error) {
+	slotID, err := uuid.Parse(input.SlotID)
+	if err != nil {
+		return nil, ErrSlotUnavailable
+	}
+	title := strings.TrimSpace(input.Title)
+	if title == "" {
+		return nil, fmt.Errorf("%w: title is required", ErrInvalidAppointment)
+	}
+	if !validAppointmentType(input.Type) {
+		return nil, fmt.Errorf("%w: unknown type %q", ErrInvalidAppointment, input.Type)
+	}
+
+	appointment := &repository.Appointment{
+		BaseModel:      repository.BaseModel{ID: uuid.New()},
+		OrganizationID: orgID,
+		UserID:         userID,
+		SlotID:         slotID,
+		Title:          title,
+		Type:           input.Type,
+		Notes:          optionalText(input.Notes),
+	}
+	err = s.repository.BookAppointment(ctx, appointment)
+	if errors.Is(err, repository.ErrConflict) || errors.Is(err, repository.ErrDuplicate) {
+		return nil, ErrSlotUnavailable
+	}
+	if err != nil {
+		return nil, fmt.Errorf("bookAppointment :: bookAppointment: %w", err)
+	}
+	return s.getSyncedAppointment(ctx, userID, appointment.ID)
+}
+
+// RescheduleAppointment moves one of the user's upcoming appointments to another open slot.
+func (s *Service) RescheduleAppointment(ctx context.Context, userID uuid.UUID, id string, slotID string) (*repository.Appointment, error) {
+	appointmentID, err := uuid.Parse(id)
+	if err != nil {
+		return nil, ErrAppointmentNotFound
+	}
+	newSlotID, err := uuid.Parse(slotID)
+	if err != nil {
+		return nil, ErrSlotUnavailable
+	}
+
+	_, err = s.repository.RescheduleAppointment(ctx, userID, appointmentID, newSlotID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrAppointmentNotFound
+	}
+	if errors.Is(err, repository.ErrConflict) || errors.Is(err, repository.ErrDuplicate) {
+		return nil, ErrSlotUnavailable
+	}
+	if err != nil {
+		return nil, fmt.Errorf("rescheduleAppointment :: rescheduleAppointment: %w", err)
+	}
+	return s.getSyncedAppointment(ctx, userID, appointmentID)
+}
+
+// CancelAppointment cancels one of the user's upcoming appointments, freeing its slot for
+// others.
+func (s *Service) CancelAppointment(ctx context.Context, userID uuid.UUID, id string) (*repository.Appointment, error) {
+	appointmentID, err := uuid.Parse(id)
+	if err != nil {
+		return nil, ErrAppointmentNotFound
+	}
+
+	_, err = s.repository.CancelAppointment(ctx, userID, appointmentID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrAppointmentNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cancelAppointment :: cancelAppointment: %w", err)
+	}
+	return s.getSyncedAppointment(ctx, userID, appointmentID)
+}
+
+func (s *Service) GetAppointments(ctx context.Context, userID uuid.UUID, page int, pageSize int) ([]repository.Appointment, int, error) {
+	offset := (page - 1) * pageSize
+	appointments, total, err := s.repository.GetAppointmentsByUserID(ctx, userID, offset, pageSize)
+	if err != nil {
+		return nil, 0, fmt.Errorf("getAppointments :: getAppointmentsByUserID: %w", err)
+	}
+	return appointments, total, nil
+}
+
+func (s *Service) getAppointment(ctx context.Context, userID uuid.UUID, id uuid.UUID) (*repository.Appointment, error) {
+	appointment, err := s.repository.GetAppointmentByID(ctx, userID, id)
+	if err != nil {
+		return nil, fmt.Errorf("getAppointment :: getAppointmentByID: %w", err)
+	}
+	return appointment, nil
+}
+
+// getSyncedAppointment loads an appointment that has just changed and syncs it to the
+// organization's calendar.
+func (s *Service) getSyncedAppointment(ctx context.Context, userID uuid.UUID, id uuid.UUID) (*repository.Appointment, error) {
+	appointment, err := s.getAppointment(ctx, userID, id)
+	if err != nil {
+		return nil, err
+	}
+	s.syncAppointment(ctx, appointment)
+	return appointment, nil
+}
+
+func (s *Service) getClinician(ctx context.Context, orgID uuid.UUID, id string) (*repository.Clinician, error) {
+	clinicianID, err := uuid.Parse(id)
+	if err != nil {
+		return nil, ErrClinicianNotFound
+	}
+
+	clinician, err := s.repository.GetClinicianByID(ctx, orgID, clinicianID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrClinicianNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("getClinician :: getClinicianByID: %w", err)
+	}
+	return clinician, nil
+}
+
+func applyClinicianInput(clinician *repository.Clinician, input ClinicianInput) {
+	clinician.Name = strings.TrimSpace(input.Name)
+	clinician.Specialty = strings.TrimSpace(input.Specialty)
+	clinician.Location = strings.TrimSpace(input.Location)
+	clinician.Active = input.Active
+}
+
+func validAppointmentType(appointmentType repository.AppointmentType) bool {
+	for _, known := range appointmentTypes {
+		if appointmentType == known {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,462 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"patient-chatbot/internal/client/llm"
+	"patient-chatbot/internal/repository"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// pendingActionTTL is how long a proposed appointment change waits for confirmation.
+	pendingActionTTL = 15 * time.Minute
+	// maxToolSlots bounds how many open slots find_open_slots returns to the model.
+	maxToolSlots   = 10
+	maxToolDays    = 14
+	toolDateLayout = "2006-01-02"
+)
+
+var (
+	ErrNothingToConfirm = errors.New("there is no appointment change waiting for confirmation")
+	// ErrNotConfirmedYet means the model tried to confirm a change in the same message it was
+	// proposed in, before the patient could answer.
+	ErrNotConfirmedYet = errors.New("the patient has not confirmed this change yet; ask them to confirm it in their next message")
+)
+
+// toolSlot is an open slot as the model sees it.
+type toolSlot struct {
+	SlotID    string    `json:"slotId"`
+	Clinician string    `json:"clinician"`
+	Specialty string    `json:"specialty,omitempty"`
+	Location  string    `json:"location,omitempty"`
+	StartsAt  time.Time `json:"startsAt"`
+	EndsAt    time.Time `json:"endsAt"`
+}
+
+// toolAppointment is an appointment as the model sees it.
+type toolAppointment struct {
+	AppointmentID string    `json:"appointmentId,omitempty"`
+	Title         string    `json:"title"`
+	Type          string    `json:"type"`
+	Status        string    `json:"status,omitempty"`
+	Clinician     string    `json:"clinician"`
+	Location      string    `json:"location,omitempty"`
+	StartsAt      time.Time `json:"startsAt"`
+	EndsAt        time.Time `json:"endsAt"`
+}
+
+// proposal is what the propose_* tools report back: the change that is now waiting for the
+// patient's confirmation.
+type proposal struct {
+	AwaitingConfirmation bool             `json:"awaitingConfirmation"`
+	Action               string           `json:"action"`
+	Appointment          *toolAppointment `json:"appointment,omitempty"`
+	NewSlot              *toolSlot        `json:"newSlot,omitempty"`
+	Instructions         string           `json:"instructions"`
+}
+
+const proposalInstructions = "Nothing has been changed yet. Describe this change to the patient and ask them to confirm it. Call confirm_appointment_change only after they agree in their next message."
+
+// appointmentTools let the assistant find open slots and book, reschedule or cancel the
+// user's appointments. Changes are never made in the message that asks for them: the
+// propose_* tools store a pending action, and confirm_appointment_change only carries out
+// one that was proposed before the current message, so the patient always sees the change
+// and agrees to it first.
+func (s *Service) appointmentTools(userID uuid.UUID, orgID uuid.UUID, turnStartedAt time.Time) []llm.Tool {
+	return []llm.Tool{
+		{
+			Name:        "list_clinicians",
+			Description: "List the clinicians patients can book, with their specialty and location.",
+			Parameters:  llm.Schema{},
+			Run: func(ctx context.Context, arguments json.RawMessage) (interface{}, error) {
+				clinicians, err := s.GetClinicians(ctx, orgID, true)
+				if err != nil {
+					return nil, err
+				}
+				result := make([]map[string]string, len(clinicians))
+				for i, clinician := range clinicians {
+					result[i] = map[string]string{
+						"clinicianId": clinician.ID.String(),
+						"name":        clinician.Name,
+						"specialty":   clinician.Specialty,
+						"location":    clinician.Location,
+					}
+				}
+				return result, nil
+			},
+		},
+		{
+			Name:        "find_open_slots",
+			Description: fmt.Sprintf("Find open appointment slots, earliest first (at most %d).", maxToolSlots),
+			Parameters: llm.Schema{
+				"clinicianId": {Type: llm.JSONString, Description: "Only slots with this clinician, from list_clinicians."},
+				"from":        {Type: llm.JSONString, Description: "First day to search, as YYYY-MM-DD. Defaults to today."},
+				"days":        {Type: llm.JSONInteger, Description: fmt.Sprintf("How many days to search, 1 to %d. Defaults to 7.", maxToolDays)},
+			},
+			Run: func(ctx context.Context, arguments json.RawMessage) (interface{}, error) {
+				return s.findOpenSlots(ctx, orgID, arguments)
+			},
+		},
+		{
+			Name:        "list_my_appointments",
+			Description: "List the user's upcoming appointments.",
+			Parameters:  llm.Schema{},
+			Run: func(ctx context.Context, arguments json.RawMessage) (interface{}, error) {
+				appointments, err := s.repository.GetUpcomingAppointments(ctx, userID, time.Now())
+				if err != nil {
+					return nil, fmt.Errorf("listMyAppointments :: getUpcomingAppointments: %w", err)
+				}
+				result := make([]toolAppointment, len(appointments))
+				for i := range appointments {
+					result[i] = toToolAppointment(&appointments[i])
+				}
+				return result, nil
+			},
+		},
+		{
+			Name:        "propose_appointment",
+			Description: "Propose booking an open slot for the user. This does not book it: the user must confirm first.",
+			Parameters: llm.Schema{
+				"slotId": {Type: llm.JSONString, Required: true, Description: "The slot, from find_open_slots."},
+				"title":  {Type: llm.JSONString, Required: true, Description: "A short title for the appointment, e.g. \"Follow-up on nicotine patches\"."},
+				"type":   {Type: llm.JSONString, Required: true, Description: "One of CONSULTATION, FOLLOW_UP, TEST or PROCEDURE."},
+				"notes":  {Type: llm.JSONString, Description: "Anything the user wants the clinician to know."},
+			},
+			Run: func(ctx context.Context, arguments json.RawMessage) (interface{}, error) {
+				return s.proposeAppointment(ctx, userID, orgID, arguments)
+			},
+		},
+		{
+			Name:        "propose_reschedule",
+			Description: "Propose moving one of the user's upcoming appointments to an open slot. This does not move it: the user must confirm first.",
+			Parameters: llm.Schema{
+				"appointmentId": {Type: llm.JSONString, Required: true, Description: "The appointment, from list_my_appointments."},
+				"slotId":        {Type: llm.JSONString, Required: true, Description: "The new slot, from find_open_slots."},
+			},
+			Run: func(ctx context.Context, arguments json.RawMessage) (interface{}, error) {
+				return s.proposeReschedule(ctx, userID, orgID, arguments)
+			},
+		},
+		{
+			Name:        "propose_cancellation",
+			Description: "Propose cancelling one of the user's upcoming appointments. This does not cancel it: the user must confirm first.",
+			Parameters: llm.Schema{
+				"appointmentId": {Type: llm.JSONString, Required: true, Description: "The appointment, from list_my_appointments."},
+			},
+			Run: func(ctx context.Context, arguments json.RawMessage) (interface{}, error) {
+				return s.proposeCancellation(ctx, userID, orgID, arguments)
+			},
+		},
+		{
+			Name:        "confirm_appointment_change",
+			Description: "Carry out the appointment change proposed earlier, once the user has clearly agreed to it in their current message.",
+			Parameters:  llm.Schema{},
+			Run: func(ctx context.Context, arguments json.RawMessage) (interface{}, error) {
+				return s.confirmAppointmentChange(ctx, userID, orgID, turnStartedAt)
+			},
+		},
+		{
+			Name:        "discard_appointment_change",
+			Description: "Drop the appointment change proposed earlier, when the user declines it or changes their mind.",
+			Parameters:  llm.Schema{},
+			Run: func(ctx context.Context, arguments json.RawMessage) (interface{}, error) {
+				action, err := s.pendingAction(ctx, userID)
+				if err != nil {
+					return nil, err
+				}
+				if err := s.repository.DeletePendingAction(ctx, userID, action.ID); err != nil && !errors.Is(err, repository.ErrNotFound) {
+					return nil, fmt.Errorf("discardAppointmentChange :: deletePendingAction: %w", err)
+				}
+				return map[string]bool{"discarded": true}, nil
+			},
+		},
+	}
+}
+
+func (s *Service) findOpenSlots(ctx context.Context, orgID uuid.UUID, arguments json.RawMessage) ([]toolSlot, error) {
+	var args struct {
+		ClinicianID string `json:"clinicianId"`
+		From        string `json:"from"`
+		Days        int    `json:"days"`
+	}
+	if err := json.Unmarshal(arguments, &args); err != nil {
+		return nil, err
+	}
+	if args.Days == 0 {
+		args.Days = 7
+	}
+	if args.Days < 1 || args.Days > maxToolDays {
+		return nil, fmt.Errorf("%w: days must be between 1 and %d", llm.ErrInvalidToolArguments, maxToolDays)
+	}
+	from := time.Now()
+	if args.From != "" {
+		day, err := time.ParseInLocation(toolDateLayout, args.From, time.Local)
+		if err != nil {
+			return nil, fmt.Errorf("%w: from must be a date as YYYY-MM-DD", llm.ErrInvalidToolArguments)
+		}
+		from = day
+	}
+
+	slots, err := s.GetOpenSlots(ctx, orgID, strings.TrimSpace(args.ClinicianID), from, from.AddDate(0, 0, args.Days))
+	if errors.Is(err, ErrClinicianNotFound) {
+		return nil, fmt.Errorf("%w: %w", llm.ErrInvalidToolArguments, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(slots) > maxToolSlots {
+		slots = slots[:maxToolSlots]
+	}
+	result := make([]toolSlot, len(slots))
+	for i := range slots {
+		result[i] = toToolSlot(&slots[i])
+	}
+	return result, nil
+}
+
+func (s *Service) proposeAppointment(ctx context.Context, userID uuid.UUID, orgID uuid.UUID, arguments json.RawMessage) (*proposal, error) {
+	var args struct {
+		SlotID string `json:"slotId"`
+		Title  string `json:"title"`
+		Type   string `json:"type"`
+		Notes  string `json:"notes"`
+	}
+	if err := json.Unmarshal(arguments, &args); err != nil {
+		return nil, err
+	}
+	title := strings.TrimSpace(args.Title)
+	appointmentType := repository.AppointmentType(strings.ToUpper(strings.TrimSpace(args.Type)))
+	if title == "" {
+		return nil, fmt.Errorf("%w: title is required", llm.ErrInvalidToolArguments)
+	}
+	if !validAppointmentType(appointmentType) {
+		return nil, fmt.Errorf("%w: type must be CONSULTATION, FOLLOW_UP, TEST or PROCEDURE", llm.ErrInvalidToolArguments)
+	}
+	slot, err := s.openSlotForTool(ctx, orgID, args.SlotID)
+	if err != nil {
+		return nil, err
+	}
+
+	action := &repository.PendingAction{
+		Kind:            repository.PendingActionBookAppointment,
+		SlotID:          &slot.ID,
+		Title:           title,
+		AppointmentType: appointmentType,
+		Notes:           optionalText(args.Notes),
+	}
+	if err := s.proposeAction(ctx, userID, orgID, action); err != nil {
+		return nil, err
+	}
+	newSlot := toToolSlot(slot)
+	return &proposal{
+		AwaitingConfirmation: true,
+		Action:               string(action.Kind),
+		Appointment: &toolAppointment{
+			Title:     title,
+			Type:      string(appointmentType),
+			Clinician: slot.Clinician.Name,
+			Location:  slot.Clinician.Location,
+			StartsAt:  slot.StartsAt,
+			EndsAt:    slot.EndsAt,
+		},
+		NewSlot:      &newSlot,
+		Instructions: proposalInstructions,
+	}, nil
+}
+
+func (s *Service) proposeReschedule(ctx context.Context, userID uuid.UUID, orgID uuid.UUID, arguments json.RawMessage) (*proposal, error) {
+	var args struct {
+		AppointmentID string `json:"appointmentId"`
+		SlotID        string `json:"slotId"`
+	}
+	if err := json.Unmarshal(arguments, &args); err != nil {
+		return nil, err
+	}
+	appointment, err := s.upcomingAppointmentForTool(ctx, userID, args.AppointmentID)
+	if err != nil {
+		return nil, err
+	}
+	slot, err := s.openSlotForTool(ctx, orgID, args.SlotID)
+	if err != nil {
+		return nil, err
+	}
+
+	action := &repository.PendingAction{
+		Kind:          repository.PendingActionRescheduleAppointment,
+		AppointmentID: &appointment.ID,
+		SlotID:        &slot.ID,
+	}
+	if err := s.proposeAction(ctx, userID, orgID, action); err != nil {
+		return nil, err
+	}
+	current, newSlot := toToolAppointment(appointment), toToolSlot(slot)
+	return &proposal{
+		AwaitingConfirmation: true,
+		Action:               string(action.Kind),
+		Appointment:          &current,
+		NewSlot:              &newSlot,
+		Instructions:         proposalInstructions,
+	}, nil
+}
+
+func (s *Service) proposeCancellation(ctx context.Context, userID uuid.UUID, orgID uuid.UUID, arguments json.RawMessage) (*proposal, error) {
+	var args struct {
+		AppointmentID string `json:"appointmentId"`
+	}
+	if err := json.Unmarshal(arguments, &args); err != nil {
+		return nil, err
+	}
+	appointment, err := s.upcomingAppointmentForTool(ctx, userID, args.AppointmentID)
+	if err != nil {
+		return nil, err
+	}
+
+	action := &repository.PendingAction{
+		Kind:          repository.PendingActionCancelAppointment,
+		AppointmentID: &appointment.ID,
+	}
+	if err := s.proposeAction(ctx, userID, orgID, action); err != nil {
+		return nil, err
+	}
+	current := toToolAppointment(appointment)
+	return &proposal{
+		AwaitingConfirmation: true,
+		Action:               string(action.Kind),
+		Appointment:          &current,
+		Instructions:         proposalInstructions,
+	}, nil
+}
+
+// confirmAppointmentChange carries out the user's pending action if it was proposed before
+// the current message. The action is claimed by deleting it, so it runs at most once; if it
+// then fails, e.g. because someone else booked the slot meanwhile, the model is told why.
+func (s *Service) confirmAppointmentChange(ctx context.Context, userID uuid.UUID, orgID uuid.UUID, turnStartedAt time.Time) (interface{}, error) {
+	action, err := s.pendingAction(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !action.CreatedAt.Before(turnStartedAt) {
+		return nil, fmt.Errorf("%w: %w", llm.ErrInvalidToolArguments, ErrNotConfirmedYet)
+	}
+	err = s.repository.DeletePendingAction(ctx, userID, action.ID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, fmt.Errorf("%w: %w", llm.ErrInvalidToolArguments, ErrNothingToConfirm)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("confirmAppointmentChange :: deletePendingAction: %w", err)
+	}
+
+	var appointment *repository.Appointment
+	switch action.Kind {
+	case repository.PendingActionBookAppointment:
+		notes := ""
+		if action.Notes != nil {
+			notes = *action.Notes
+		}
+		appointment, err = s.BookAppointment(ctx, userID, orgID, AppointmentInput{
+			SlotID: action.SlotID.String(),
+			Title:  action.Title,
+			Type:   action.AppointmentType,
+			Notes:  notes,
+		})
+	case repository.PendingActionRescheduleAppointment:
+		appointment, err = s.RescheduleAppointment(ctx, userID, action.AppointmentID.String(), action.SlotID.String())
+	case repository.PendingActionCancelAppointment:
+		appointment, err = s.CancelAppointment(ctx, userID, action.AppointmentID.String())
+	default:
+		return nil, fmt.Errorf("confirmAppointmentChange :: unknown pending action %q", action.Kind)
+	}
+	if errors.Is(err, ErrSlotUnavailable) || errors.Is(err, ErrAppointmentNotFound) || errors.Is(err, ErrInvalidAppointment) {
+		return nil, fmt.Errorf("%w: %w", llm.ErrInvalidToolArguments, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"done": true, "action": action.Kind, "appointment": toToolAppointment(appointment)}, nil
+}
+
+func (s *Service) proposeAction(ctx context.Context, userID uuid.UUID, orgID uuid.UUID, action *repository.PendingAction) error {
+	action.ID = uuid.New()
+	action.UserID = userID
+	action.OrganizationID = orgID
+	action.ExpiresAt = time.Now().Add(pendingActionTTL)
+	if err := s.repository.ReplacePendingAction(ctx, action); err != nil {
+		return fmt.Errorf("proposeAction :: replacePendingAction: %w", err)
+	}
+	return nil
+}
+
+func (s *Service) pendingAction(ctx context.Context, userID uuid.UUID) (*repository.PendingAction, error) {
+	action, err := s.repository.GetPendingAction(ctx, userID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, fmt.Errorf("%w: %w", llm.ErrInvalidToolArguments, ErrNothingToConfirm)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("pendingAction :: getPendingAction: %w", err)
+	}
+	return action, nil
+}
+
+func (s *Service) openSlotForTool(ctx context.Context, orgID uuid.UUID, id string) (*repository.AvailabilitySlot, error) {
+	slotID, err := uuid.Parse(strings.TrimSpace(id))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", llm.ErrInvalidToolArguments, ErrSlotUnavailable)
+	}
+	slot, err := s.repository.GetOpenSlot(ctx, orgID, slotID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, fmt.Errorf("%w: %w", llm.ErrInvalidToolArguments, ErrSlotUnavailable)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("openSlotForTool :: getOpenSlot: %w", err)
+	}
+	return slot, nil
+}
+
+func (s *Service) upcomingAppointmentForTool(ctx context.Context, userID uuid.UUID, id string) (*repository.Appointment, error) {
+	appointmentID, err := uuid.Parse(strings.TrimSpace(id))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", llm.ErrInvalidToolArguments, ErrAppointmentNotFound)
+	}
+	appointment, err := s.repository.GetAppointmentByID(ctx, userID, appointmentID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, fmt.Errorf("%w: %w", llm.ErrInvalidToolArguments, ErrAppointmentNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("upcomingAppointmentForTool :: getAppointmentByID: %w", err)
+	}
+	if appointment.Status != repository.AppointmentStatusScheduled || !appointment.StartsAt.After(time.Now()) {
+		return nil, fmt.Errorf("%w: only upcoming appointments can be changed", llm.ErrInvalidToolArguments)
+	}
+	return appointment, nil
+}
+
+func toToolSlot(slot *repository.AvailabilitySlot) toolSlot {
+	return toolSlot{
+		SlotID:    slot.ID.String(),
+		Clinician: slot.Clinician.Name,
+		Specialty: slot.Clinician.Specialty,
+		Location:  slot.Clinician.Location,
+		StartsAt:  slot.StartsAt,
+		EndsAt:    slot.EndsAt,
+	}
+}
+
+func toToolAppointment(appointment *repository.Appointment) toolAppointment {
+	return toolAppointment{
+		AppointmentID: appointment.ID.String(),
+		Title:         appointment.Title,
+		Type:          string(appointment.Type),
+		Status:        string(appointment.CurrentStatus(time.Now())),
+		Clinician:     appointment.Clinician.Name,
+		Location:      appointment.Location,
+		StartsAt:      appointment.StartsAt,
+		EndsAt:        appointment.EndsAt,
+	}
+}
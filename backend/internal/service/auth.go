@@ -0,0 +1,205 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"patient-chatbot/internal/auth"
+	"patient-chatbot/internal/dto"
+	"patient-chatbot/internal/repository"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	ErrInvalidCredentials   = errors.New("invalid credentials")
+	ErrEmailTaken           = errors.New("email already registered")
+	ErrInvalidToken         = errors.New("invalid or revoked token")
+	ErrOrganizationNotFound = errors.New("organization not found")
+	ErrPasswordTooLong      = errors.New("password too long")
+)
+
+// maxPasswordBytes is bcrypt's limit. It counts bytes, so a password within the request's
+// 72-character limit can still exceed it once encoded as UTF-8.
+const maxPasswordBytes = 72
+
+// CreateOrganization registers a new tenant and signs its first administrator in.
+func (s *Service) CreateOrganization(ctx context.Context, name string, adminEmail string, adminPassword string) (*dto.AuthTokens, error) {
+	if len(adminPassword) > maxPasswordBytes {
+		return nil, ErrPasswordTooLong
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(adminPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, fmt.Errorf("createOrganization :: hashPassword: %w", err)
+	}
+
+	organization := &repository.Organization{
+		BaseModel: repository.BaseModel{
+			ID: uuid.New(),
+		},
+		Name: strings.TrimSpace(name),
+	}
+	admin := &repository.User{
+		BaseModel: repository.BaseModel{
+			ID: uuid.New(),
+		},
+		Email:        normalizeEmail(adminEmail),
+		PasswordHash: string(hash),
+	}
+	err = s.repository.CreateOrganizationWithAdmin(ctx, organization, admin)
+	if errors.Is(err, repository.ErrDuplicate) {
+		return nil, ErrEmailTaken
+	}
+	if err != nil {
+		return nil, fmt.Errorf("createOrganization :: createOrganizationWithAdmin: %w", err)
+	}
+
+	return s.issueTokens(ctx, admin)
+}
+
+// Signup registers a patient in an existing organization.
+func (s *Service) Signup(ctx context.Context, orgID uuid.UUID, email string, password string) (*dto.AuthTokens, error) {
+	if len(password) > maxPasswordBytes {
+		return nil, ErrPasswordTooLong
+	}
+	_, err := s.repository.GetOrganizationByID(ctx, orgID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrOrganizationNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("signup :: getOrganizationByID: %w", err)
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, fmt.Errorf("signup :: hashPassword: %w", err)
+	}
+
+	user := &repository.User{
+		BaseModel: repository.BaseModel{
+			ID: uuid.New(),
+		},
+		OrganizationID: orgID,
+		Role:           repository.UserRolePatient,
+		Email:          normalizeEmail(email),
+		PasswordHash:   string(hash),
+	}
+	err = s.repository.CreateUser(ctx, user)
+	if errors.Is(err, repository.ErrDuplicate) {
+		return nil, ErrEmailTaken
+	}
+	if err != nil {
+		return nil, fmt.Errorf("signup :: createUser: %w", err)
+	}
+
+	return s.issueTokens(ctx, user)
+}
+
+func (s *Service) Login(ctx context.Context, email string, password string) (*dto.AuthTokens, error) {
+	user, err := s.repository.GetUserByEmail(ctx, normalizeEmail(email))
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrInvalidCredentials
+	}
+	if err != nil {
+		return nil, fmt.Errorf("login :: getUserByEmail: %w", err)
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)); err != nil {
+		return nil, ErrInvalidCredentials
+	}
+
+	return s.issueTokens(ctx, user)
+}
+
+// Refresh exchanges a refresh token for a new token pair, revoking the old refresh token.
+func (s *Service) Refresh(ctx context.Context, refreshToken string) (*dto.AuthTokens, error) {
+	userID, tokenID, err := s.parseRefreshToken(refreshToken)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.repository.RevokeRefreshToken(ctx, tokenID, userID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrInvalidToken
+	}
+	if err != nil {
+		return nil, fmt.Errorf("refresh :: revokeRefreshToken: %w", err)
+	}
+
+	user, err := s.repository.GetUserByID(ctx, userID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrInvalidToken
+	}
+	if err != nil {
+		return nil, fmt.Errorf("refresh :: getUserByID: %w", err)
+	}
+
+	return s.issueTokens(ctx, user)
+}
+
+func (s *Service) Logout(ctx context.Context, refreshToken string) error {
+	userID, tokenID, err := s.parseRefreshToken(refreshToken)
+	if err != nil {
+		return err
+	}
+
+	err = s.repository.RevokeRefreshToken(ctx, tokenID, userID)
+	if err != nil && !errors.Is(err, repository.ErrNotFound) {
+		return fmt.Errorf("logout :: revokeRefreshToken: %w", err)
+	}
+	return nil
+}
+
+func (s *Service) parseRefreshToken(refreshToken string) (uuid.UUID, uuid.UUID, error) {
+	claims, err := s.tokens.Parse(refreshToken, auth.TokenTypeRefresh)
+	if err != nil {
+		return uuid.Nil, uuid.Nil, ErrInvalidToken
+	}
+	userID, err := claims.UserID()
+	if err != nil {
+		return uuid.Nil, uuid.Nil, ErrInvalidToken
+	}
+	tokenID, err := claims.TokenID()
+	if err != nil {
+		return uuid.Nil, uuid.Nil, ErrInvalidToken
+	}
+	return userID, tokenID, nil
+}
+
+func (s *Service) issueTokens(ctx context.Context, user *repository.User) (*dto.AuthTokens, error) {
+	accessToken, accessExpiresAt, err := s.tokens.IssueAccessToken(user.ID, user.OrganizationID, string(user.Role))
+	if err != nil {
+		return nil, fmt.Errorf("issueTokens :: issueAccessToken: %w", err)
+	}
+
+	tokenID := uuid.New()
+	refreshToken, refreshExpiresAt, err := s.tokens.IssueRefreshToken(user.ID, tokenID)
+	if err != nil {
+		return nil, fmt.Errorf("issueTokens :: issueRefreshToken: %w", err)
+	}
+
+	err = s.repository.CreateRefreshToken(ctx, &repository.RefreshToken{
+		BaseModel: repository.BaseModel{
+			ID: tokenID,
+		},
+		UserID:    user.ID,
+		ExpiresAt: refreshExpiresAt,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("issueTokens :: createRefreshToken: %w", err)
+	}
+
+	return &dto.AuthTokens{
+		AccessToken:      accessToken,
+		AccessExpiresAt:  accessExpiresAt,
+		RefreshToken:     refreshToken,
+		RefreshExpiresAt: refreshExpiresAt,
+	}, nil
+}
+
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
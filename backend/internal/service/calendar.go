@@ -0,0 +1,387 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"patient-chatbot/internal/auth"
+	"patient-chatbot/internal/client/calendar"
+	"patient-chatbot/internal/repository"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// CalendarFeedPath is where feeds are served, followed by "<token>.ics".
+	CalendarFeedPath = "/api/v1/calendar/feeds/"
+	// calendarFeedHistory is how far back past appointments stay in a feed.
+	calendarFeedHistory = 90 * 24 * time.Hour
+	calendarUIDDomain   = "@patient-chatbot"
+)
+
+var (
+	ErrCalendarIntegrationNotFound = errors.New("calendar integration not found")
+	ErrInvalidCalendarIntegration  = errors.New("invalid calendar integration")
+	ErrCalendarUnreachable         = errors.New("calendar server could not be reached with these settings")
+	ErrCalendarFeedNotFound        = errors.New("calendar feed not found")
+	// ErrCredentialsKeyMissing means CREDENTIALS_KEY is not set, so tenant credentials such
+	// as CalDAV passwords and webhook secrets can be neither stored nor read.
+	ErrCredentialsKeyMissing = errors.New("CREDENTIALS_KEY is not configured")
+)
+
+// CalendarIntegrationInput configures an organization's CalDAV calendar. An empty Password
+// keeps the stored one.
+type CalendarIntegrationInput struct {
+	URL      string
+	Username string
+	Password string
+	Enabled  bool
+}
+
+func (s *Service) GetCalendarIntegration(ctx context.Context, orgID uuid.UUID) (*repository.CalendarIntegration, error) {
+	integration, err := s.repository.GetCalendarIntegration(ctx, orgID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrCalendarIntegrationNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("getCalendarIntegration :: getCalendarIntegration: %w", err)
+	}
+	return integration, nil
+}
+
+// SetCalendarIntegration creates or replaces the organization's calendar integration. When
+// it is enabled the server is checked first, and the organization's upcoming appointments
+// are then synced to it in the background.
+func (s *Service) SetCalendarIntegration(ctx context.Context, orgID uuid.UUID, input CalendarIntegrationInput) (*repository.CalendarIntegration, error) {
+	collectionURL := strings.TrimSpace(input.URL)
+	parsed, err := url.Parse(collectionURL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return nil, fmt.Errorf("%w: url must be an http(s) URL", ErrInvalidCalendarIntegration)
+	}
+	sealer, err := s.credentialSealer()
+	if err != nil {
+		return nil, err
+	}
+
+	integration, err := s.repository.GetCalendarIntegration(ctx, orgID)
+	if errors.Is(err, repository.ErrNotFound) {
+		integration = &repository.CalendarIntegration{
+			BaseModel:      repository.BaseModel{ID: uuid.New()},
+			OrganizationID: orgID,
+		}
+	} else if err != nil {
+		return nil, fmt.Errorf("setCalendarIntegration :: getCalendarIntegration: %w", err)
+	}
+
+	password := input.Password
+	if password == "" {
+		if integration.PasswordCiphertext == "" {
+			return nil, fmt.Errorf("%w: password is required", ErrInvalidCalendarIntegration)
+		}
+		password, err = sealer.Open(integration.PasswordCiphertext)
+		if err != nil {
+			return nil, fmt.Errorf("setCalendarIntegration :: open: %w", err)
+		}
+	}
+
+	username := strings.TrimSpace(input.Username)
+	if input.Enabled {
+		client := calendar.NewCalDAVClient(collectionURL, username, password, s.calendarHTTP)
+		if err := client.Check(ctx); err != nil {
+			// The cause stays in the log: echoing it would tell the caller what is listening
+			// at the address.
+			log.Warn().Msg("setCalendarIntegration :: check: " + err.Error())
+			return nil, ErrCalendarUnreachable
+		}
+	}
+
+	ciphertext, err := sealer.Seal(password)
+	if err != nil {
+		return nil, fmt.Errorf("setCalendarIntegration :: seal: %w", err)
+	}
+	integration.URL = collectionURL
+	integration.Username = username
+	integration.PasswordCiphertext = ciphertext
+	integration.Enabled = input.Enabled
+	integration.LastSyncError = nil
+	if err := s.repository.SaveCalendarIntegration(ctx, integration); err != nil {
+		return nil, fmt.Errorf("setCalendarIntegration :: saveCalendarIntegration: %w", err)
+	}
+
+	if integration.Enabled {
+		s.syncUpcomingAppointments(ctx, orgID)
+	}
+	return integration, nil
+}
+
+// DeleteCalendarIntegration stops syncing to the organization's calendar. Events already
+// written to it are left in place.
+func (s *Service) DeleteCalendarIntegration(ctx context.Context, orgID uuid.UUID) error {
+	err := s.repository.SoftDeleteCalendarIntegration(ctx, orgID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return ErrCalendarIntegrationNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("deleteCalendarIntegration :: softDeleteCalendarIntegration: %w", err)
+	}
+	return nil
+}
+
+// CreateCalendarFeed issues a new secret feed URL for the user, replacing any earlier one.
+// The feed is served in lang.
+func (s *Service) CreateCalendarFeed(ctx context.Context, userID uuid.UUID, lang string) (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", fmt.Errorf("createCalendarFeed :: read token: %w", err)
+	}
+	token := base64.RawURLEncoding.EncodeToString(secret)
+	hash := calendarFeedTokenHash(token)
+	if err := s.repository.UpdateUserCalendarFeedTokenHash(ctx, userID, &hash); err != nil {
+		return "", fmt.Errorf("createCalendarFeed :: updateUserCalendarFeedTokenHash: %w", err)
+	}
+	return s.cfg.PublicBaseURL + CalendarFeedPath + token + ".ics?lang=" + url.QueryEscape(lang), nil
+}
+
+// RevokeCalendarFeed stops serving the user's feed.
+func (s *Service) RevokeCalendarFeed(ctx context.Context, userID uuid.UUID) error {
+	if err := s.repository.UpdateUserCalendarFeedTokenHash(ctx, userID, nil); err != nil {
+		return fmt.Errorf("revokeCalendarFeed :: updateUserCalendarFeedTokenHash: %w", err)
+	}
+	return nil
+}
+
+// GetCalendarFeed renders the iCalendar feed identified by token: the user's scheduled
+// appointments from the last 90 days onwards and, once a quit date is set, their health
+// milestones as all-day events on the day they are reached.
+func (s *Service) GetCalendarFeed(ctx context.Context, token string, lang string) ([]byte, error) {
+	if token == "" {
+		return nil, ErrCalendarFeedNotFound
+	}
+	user, err := s.repository.GetUserByCalendarFeedTokenHash(ctx, calendarFeedTokenHash(token))
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrCalendarFeedNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("getCalendarFeed :: getUserByCalendarFeedTokenHash: %w", err)
+	}
+
+	now := time.Now()
+	appointments, err := s.repository.GetUpcomingAppointments(ctx, user.ID, now.Add(-calendarFeedHistory))
+	if err != nil {
+		return nil, fmt.Errorf("getCalendarFeed :: getUpcomingAppointments: %w", err)
+	}
+	events := make([]calendar.Event, 0, len(appointments))
+	for i := range appointments {
+		events = append(events, patientAppointmentEvent(&appointments[i]))
+	}
+
+	if user.QuitDate != nil {
+		definitions, err := s.organizationMilestones(ctx, user.OrganizationID, true)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, milestoneEvents(user, definitions, now, lang)...)
+	}
+
+	return calendar.Encode(user.Organization.Name, events), nil
+}
+
+// syncAppointment copies an appointment to the organization's calendar in the background:
+// scheduled appointments are written and cancelled ones removed. Failures do not affect the
+// booking; they are logged and recorded on the integration. Syncs may run in any order, so
+// the appointment is written as it is when the sync runs; see writeCurrentAppointment.
+func (s *Service) syncAppointment(ctx context.Context, appointment *repository.Appointment) {
+	ctx = context.WithoutCancel(ctx)
+	s.calendarSyncs.Add(1)
+	go func() {
+		defer s.calendarSyncs.Done()
+		integration, err := s.repository.GetCalendarIntegration(ctx, appointment.OrganizationID)
+		if errors.Is(err, repository.ErrNotFound) {
+			return
+		}
+		if err != nil {
+			log.Warn().Msg("syncAppointment :: getCalendarIntegration: " + err.Error())
+			return
+		}
+		if !integration.Enabled {
+			return
+		}
+		s.writeCurrentAppointment(ctx, integration, appointment)
+	}()
+}
+
+// syncUpcomingAppointments writes the organization's upcoming appointments to its calendar
+// in the background, so a newly connected calendar also shows existing bookings.
+func (s *Service) syncUpcomingAppointments(ctx context.Context, orgID uuid.UUID) {
+	ctx = context.WithoutCancel(ctx)
+	s.calendarSyncs.Add(1)
+	go func() {
+		defer s.calendarSyncs.Done()
+		integration, err := s.repository.GetCalendarIntegration(ctx, orgID)
+		if err != nil {
+			log.Warn().Msg("syncUpcomingAppointments :: getCalendarIntegration: " + err.Error())
+			return
+		}
+		appointments, err := s.repository.GetOrganizationUpcomingAppointments(ctx, orgID, time.Now())
+		if err != nil {
+			log.Warn().Msg("syncUpcomingAppointments :: getOrganizationUpcomingAppointments: " + err.Error())
+			return
+		}
+		for i := range appointments {
+			if !s.writeCurrentAppointment(ctx, integration, &appointments[i]) {
+				return
+			}
+		}
+	}()
+}
+
+// writeCurrentAppointment reloads the appointment and writes it while holding its lock, so
+// of two syncs racing after a quick book and cancel, whichever runs last still writes the
+// cancellation instead of putting back the event the other one deleted.
+func (s *Service) writeCurrentAppointment(ctx context.Context, integration *repository.CalendarIntegration, appointment *repository.Appointment) bool {
+	lock := &s.calendarSyncLocks[int(appointment.ID[0])%len(s.calendarSyncLocks)]
+	lock.Lock()
+	defer lock.Unlock()
+
+	current, err := s.repository.GetAppointmentByID(ctx, appointment.UserID, appointment.ID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return true
+	}
+	if err != nil {
+		log.Warn().Msg("syncAppointment :: getAppointmentByID: " + err.Error())
+		return false
+	}
+	return s.writeAppointment(ctx, integration, current)
+}
+
+// writeAppointment puts or deletes the appointment's event and records the outcome on the
+// integration. It reports whether the write succeeded.
+func (s *Service) writeAppointment(ctx context.Context, integration *repository.CalendarIntegration, appointment *repository.Appointment) bool {
+	err := s.putAppointment(ctx, integration, appointment)
+	var syncError *string
+	if err != nil {
+		log.Warn().Msg("syncAppointment :: " + err.Error())
+		message := err.Error()
+		syncError = &message
+	}
+	if err := s.repository.RecordCalendarSync(ctx, integration.ID, time.Now(), syncError); err != nil {
+		log.Warn().Msg("syncAppointment :: recordCalendarSync: " + err.Error())
+	}
+	return syncError == nil
+}
+
+func (s *Service) putAppointment(ctx context.Context, integration *repository.CalendarIntegration, appointment *repository.Appointment) error {
+	sealer, err := s.credentialSealer()
+	if err != nil {
+		return err
+	}
+	password, err := sealer.Open(integration.PasswordCiphertext)
+	if err != nil {
+		return fmt.Errorf("open: %w", err)
+	}
+	client := calendar.NewCalDAVClient(integration.URL, integration.Username, password, s.calendarHTTP)
+
+	if appointment.Status == repository.AppointmentStatusCancelled {
+		if err := client.DeleteEvent(ctx, appointmentUID(appointment.ID)); err != nil {
+			return fmt.Errorf("deleteEvent: %w", err)
+		}
+		return nil
+	}
+
+	patient, err := s.repository.GetUserByID(ctx, appointment.UserID)
+	if err != nil {
+		return fmt.Errorf("getUserByID: %w", err)
+	}
+	if err := client.PutEvent(ctx, clinicAppointmentEvent(appointment, patient)); err != nil {
+		return fmt.Errorf("putEvent: %w", err)
+	}
+	return nil
+}
+
+// credentialSealer returns the sealer for tenant credentials, or ErrCredentialsKeyMissing.
+// There is deliberately no fallback key: credentials sealed under e.g. the JWT secret would
+// become unreadable the next time that secret is rotated.
+func (s *Service) credentialSealer() (*auth.Sealer, error) {
+	if s.sealer == nil {
+		return nil, ErrCredentialsKeyMissing
+	}
+	return s.sealer, nil
+}
+
+// clinicAppointmentEvent is an appointment as it appears on the clinic's calendar.
+func clinicAppointmentEvent(appointment *repository.Appointment, patient *repository.User) calendar.Event {
+	event := appointmentEvent(appointment)
+	description := []string{
+		"Patient: " + patient.Email,
+		"Clinician: " + appointment.Clinician.Name,
+		"Type: " + string(appointment.Type),
+	}
+	if appointment.Notes != nil {
+		description = append(description, *appointment.Notes)
+	}
+	event.Description = strings.Join(description, "\n")
+	return event
+}
+
+// patientAppointmentEvent is an appointment as it appears in the patient's feed.
+func patientAppointmentEvent(appointment *repository.Appointment) calendar.Event {
+	event := appointmentEvent(appointment)
+	description := appointment.Clinician.Name
+	if appointment.Clinician.Specialty != "" {
+		description += " (" + appointment.Clinician.Specialty + ")"
+	}
+	if appointment.Notes != nil {
+		description += "\n" + *appointment.Notes
+	}
+	event.Description = description
+	return event
+}
+
+func appointmentEvent(appointment *repository.Appointment) calendar.Event {
+	return calendar.Event{
+		UID:      appointmentUID(appointment.ID),
+		Summary:  appointment.Title,
+		Location: appointment.Location,
+		Start:    appointment.StartsAt,
+		End:      appointment.EndsAt,
+		Status:   calendar.EventStatusConfirmed,
+		Stamp:    appointment.UpdatedAt,
+	}
+}
+
+func milestoneEvents(user *repository.User, definitions []repository.MilestoneDefinition, now time.Time, lang string) []calendar.Event {
+	milestones := buildMilestones(definitions, user.QuitDate, now, lang)
+	events := make([]calendar.Event, len(milestones))
+	for i, milestone := range milestones {
+		reachedAt := user.QuitDate.Add(time.Duration(milestone.AfterMinutes) * time.Minute).UTC()
+		day := time.Date(reachedAt.Year(), reachedAt.Month(), reachedAt.Day(), 0, 0, 0, 0, time.UTC)
+		events[i] = calendar.Event{
+			UID:         "milestone-" + milestone.Key + "-" + user.ID.String() + calendarUIDDomain,
+			Summary:     milestone.Title,
+			Description: milestone.Description,
+			Start:       day,
+			End:         day.AddDate(0, 0, 1),
+			AllDay:      true,
+			Stamp:       now,
+		}
+	}
+	return events
+}
+
+func appointmentUID(id uuid.UUID) string {
+	return id.String() + calendarUIDDomain
+}
+
+func calendarFeedTokenHash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,51 @@
+package service
+
+import (
+	"regexp"
+	"strings"
+
+	"patient-chatbot/internal/repository"
+)
+
+// Coping strategies and milestones are identified within their organization's catalogue by
+// a key such as "deep_breathing", which never changes once created.
+const maxCatalogueKeyLength = 64
+
+var (
+	catalogueKeyPattern = regexp.MustCompile(`^[a-z0-9]+(_[a-z0-9]+)*$`)
+	nonKeyCharacters    = regexp.MustCompile(`[^a-z0-9]+`)
+)
+
+// catalogueKey derives a key from an English title, e.g. "5-4-3-2-1 Grounding" becomes
+// "5_4_3_2_1_grounding".
+func catalogueKey(title string) string {
+	key := strings.Trim(nonKeyCharacters.ReplaceAllString(strings.ToLower(title), "_"), "_")
+	if len(key) > maxCatalogueKeyLength {
+		key = strings.TrimRight(key[:maxCatalogueKeyLength], "_")
+	}
+	return key
+}
+
+func validCatalogueKey(key string) bool {
+	return len(key) <= maxCatalogueKeyLength && catalogueKeyPattern.MatchString(key)
+}
+
+// trimList trims every item and drops blank ones.
+func trimList(items []string) repository.StringList {
+	trimmed := repository.StringList{}
+	for _, item := range items {
+		if item = strings.TrimSpace(item); item != "" {
+			trimmed = append(trimmed, item)
+		}
+	}
+	return trimmed
+}
+
+// orText returns text, or fallback if text is empty. Arabic catalogue text falls back to
+// English this way.
+func orText(text string, fallback string) string {
+	if text == "" {
+		return fallback
+	}
+	return text
+}
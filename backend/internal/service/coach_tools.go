@@ -0,0 +1,160 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"patient-chatbot/internal/client/llm"
+	"patient-chatbot/internal/dto"
+	"patient-chatbot/internal/repository"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// maxBackfillDays is how far back the coach may log a smoke-free day or a slip.
+	maxBackfillDays = 7
+	maxCravingLevel = 10
+)
+
+var daysAgoParameter = llm.Property{
+	Type:        llm.JSONInteger,
+	Description: fmt.Sprintf("How many days ago it happened: 0 for today (the default), 1 for yesterday, up to %d.", maxBackfillDays),
+}
+
+// progressUpdate is what the progress tools report back to the model: the day that was
+// changed and the user's totals afterwards.
+type progressUpdate struct {
+	Date   string                         `json:"date"`
+	Status repository.ProgressEventStatus `json:"status"`
+	*dto.DashboardData
+}
+
+// coachTools are the tools the coach may call during a chat. They act for userID only,
+// whatever the model passes in. coachTools is called once per user message, which is how
+// the appointment tools tell a confirmation apart from the proposal it confirms.
+func (s *Service) coachTools(userID uuid.UUID, orgID uuid.UUID, lang string) []llm.Tool {
+	turnStartedAt := time.Now()
+	tools := []llm.Tool{
+		{
+			Name:        "log_smoke_free_day",
+			Description: "Record that the user did not smoke on a day.",
+			Parameters:  llm.Schema{"daysAgo": daysAgoParameter},
+			Run: func(ctx context.Context, arguments json.RawMessage) (interface{}, error) {
+				return s.logDayStatus(ctx, userID, arguments, repository.ProgressEventStatusSmokeFree)
+			},
+		},
+		{
+			Name:        "report_slip",
+			Description: "Record that the user smoked on a day.",
+			Parameters:  llm.Schema{"daysAgo": daysAgoParameter},
+			Run: func(ctx context.Context, arguments json.RawMessage) (interface{}, error) {
+				return s.logDayStatus(ctx, userID, arguments, repository.ProgressEventStatusSlip)
+			},
+		},
+		{
+			Name:        "log_money_saved",
+			Description: "Add money the user saved today by not buying cigarettes to their total.",
+			Parameters: llm.Schema{
+				"amount": {Type: llm.JSONInteger, Required: true, Description: "The amount saved, in whole units of the user's currency."},
+			},
+			Run: func(ctx context.Context, arguments json.RawMessage) (interface{}, error) {
+				var args struct {
+					Amount int `json:"amount"`
+				}
+				if err := json.Unmarshal(arguments, &args); err != nil {
+					return nil, err
+				}
+				if args.Amount <= 0 {
+					return nil, fmt.Errorf("%w: amount must be positive", llm.ErrInvalidToolArguments)
+				}
+				if err := s.repository.UpsertProgressMoneySaved(ctx, userID, args.Amount); err != nil {
+					return nil, fmt.Errorf("logMoneySaved :: upsertProgressMoneySaved: %w", err)
+				}
+				s.checkAchievements(ctx, userID)
+				return s.GetDashboardData(ctx, userID)
+			},
+		},
+		{
+			Name:        "log_craving",
+			Description: "Record a craving to smoke the user describes. Returns a coping strategy to suggest to them.",
+			Parameters: llm.Schema{
+				"intensity": {Type: llm.JSONInteger, Required: true, Description: fmt.Sprintf("How strong the craving is, from 1 (mild) to %d (overwhelming).", maxCravingLevel)},
+				"trigger":   {Type: llm.JSONString, Description: "What set it off in a few words, e.g. \"coffee\" or \"stress at work\"."},
+				"notes":     {Type: llm.JSONString, Description: "Anything else the user said about it."},
+			},
+			Run: func(ctx context.Context, arguments json.RawMessage) (interface{}, error) {
+				return s.logCraving(ctx, userID, orgID, arguments, lang)
+			},
+		},
+		{
+			Name:        "get_my_progress",
+			Description: "Get the user's total smoke-free days, current streak and total money saved.",
+			Parameters:  llm.Schema{},
+			Run: func(ctx context.Context, arguments json.RawMessage) (interface{}, error) {
+				return s.GetDashboardData(ctx, userID)
+			},
+		},
+	}
+	return append(tools, s.appointmentTools(userID, orgID, turnStartedAt)...)
+}
+
+func (s *Service) logDayStatus(ctx context.Context, userID uuid.UUID, arguments json.RawMessage, status repository.ProgressEventStatus) (*progressUpdate, error) {
+	var args struct {
+		DaysAgo int `json:"daysAgo"`
+	}
+	if err := json.Unmarshal(arguments, &args); err != nil {
+		return nil, err
+	}
+	if args.DaysAgo < 0 || args.DaysAgo > maxBackfillDays {
+		return nil, fmt.Errorf("%w: daysAgo must be between 0 and %d", llm.ErrInvalidToolArguments, maxBackfillDays)
+	}
+
+	day := time.Now().AddDate(0, 0, -args.DaysAgo)
+	if err := s.repository.UpsertProgressStatus(ctx, userID, day, status); err != nil {
+		return nil, fmt.Errorf("logDayStatus :: upsertProgressStatus: %w", err)
+	}
+	s.checkAchievements(ctx, userID)
+	data, err := s.GetDashboardData(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return &progressUpdate{Date: day.Format("2006-01-02"), Status: status, DashboardData: data}, nil
+}
+
+func (s *Service) logCraving(ctx context.Context, userID uuid.UUID, orgID uuid.UUID, arguments json.RawMessage, lang string) (interface{}, error) {
+	var args struct {
+		Intensity int    `json:"intensity"`
+		Trigger   string `json:"trigger"`
+		Notes     string `json:"notes"`
+	}
+	if err := json.Unmarshal(arguments, &args); err != nil {
+		return nil, err
+	}
+
+	logged, err := s.LogCraving(ctx, userID, orgID, CravingInput{
+		Intensity: args.Intensity,
+		Triggers:  []string{args.Trigger},
+		Notes:     args.Notes,
+	}, lang)
+	if errors.Is(err, ErrInvalidCraving) {
+		return nil, fmt.Errorf("%w: %w", llm.ErrInvalidToolArguments, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"logged": true, "recommendedStrategy": logged.Strategy}, nil
+}
+
+// optionalText returns nil for blank text, for nullable columns.
+func optionalText(text string) *string {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return nil
+	}
+	return &text
+}
@@ -0,0 +1,162 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"patient-chatbot/internal/dto"
+	"patient-chatbot/internal/repository"
+
+	"github.com/google/uuid"
+)
+
+const (
+	maxHistoryMessages      = 50
+	maxConversationTitleLen = 60
+)
+
+var ErrConversationNotFound = errors.New("conversation not found")
+
+func (s *Service) CreateConversation(ctx context.Context, userID uuid.UUID, orgID uuid.UUID, title string) (*repository.Conversation, error) {
+	conversation := &repository.Conversation{
+		BaseModel: repository.BaseModel{
+			ID: uuid.New(),
+		},
+		OrganizationID: orgID,
+		UserID:         userID,
+		Title:          strings.TrimSpace(title),
+	}
+	if err := s.repository.CreateConversation(ctx, conversation); err != nil {
+		return nil, fmt.Errorf("createConversation :: createConversation: %w", err)
+	}
+	return conversation, nil
+}
+
+func (s *Service) GetConversations(ctx context.Context, userID uuid.UUID, page int, pageSize int) ([]repository.Conversation, int, error) {
+	offset := (page - 1) * pageSize
+	conversations, total, err := s.repository.GetConversationsByUserID(ctx, userID, offset, pageSize)
+	if err != nil {
+		return nil, 0, fmt.Errorf("getConversations :: getConversationsByUserID: %w", err)
+	}
+	return conversations, total, nil
+}
+
+func (s *Service) GetConversation(ctx context.Context, userID uuid.UUID, id string) (*repository.Conversation, error) {
+	conversationID, err := uuid.Parse(id)
+	if err != nil {
+		return nil, ErrConversationNotFound
+	}
+
+	conversation, err := s.repository.GetConversationByID(ctx, userID, conversationID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrConversationNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("getConversation :: getConversationByID: %w", err)
+	}
+	return conversation, nil
+}
+
+func (s *Service) DeleteConversation(ctx context.Context, userID uuid.UUID, id string) error {
+	conversationID, err := uuid.Parse(id)
+	if err != nil {
+		return ErrConversationNotFound
+	}
+
+	err = s.repository.SoftDeleteConversation(ctx, userID, conversationID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return ErrConversationNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("deleteConversation :: softDeleteConversation: %w", err)
+	}
+	return nil
+}
+
+// loadHistory builds the transcript sent to the model. Without a conversation the client's
+// messages are used as-is; with one, only the client's last message is taken as the new turn
+// and prior turns come from the database. Either way the new turn is the user's, whatever
+// role the client gave it.
+func (s *Service) loadHistory(ctx context.Context, userID uuid.UUID, conversationID *uuid.UUID, messages []dto.Message) (*repository.Conversation, []dto.Message, error) {
+	turn := dto.Message{Role: dto.UserRole, Content: messages[len(messages)-1].Content}
+
+	if conversationID == nil {
+		if len(messages) > maxHistoryMessages {
+			messages = messages[len(messages)-maxHistoryMessages:]
+		}
+		history := append(append([]dto.Message(nil), messages[:len(messages)-1]...), turn)
+		return nil, history, nil
+	}
+
+	conversation, err := s.repository.GetConversationByID(ctx, userID, *conversationID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, nil, ErrConversationNotFound
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("loadHistory :: getConversationByID: %w", err)
+	}
+
+	stored, err := s.repository.GetRecentMessages(ctx, conversation.ID, maxHistoryMessages-1)
+	if err != nil {
+		return nil, nil, fmt.Errorf("loadHistory :: getRecentMessages: %w", err)
+	}
+
+	history := make([]dto.Message, 0, len(stored)+1)
+	for _, message := range stored {
+		history = append(history, dto.Message{
+			Role:    dto.Role(message.Role),
+			Content: message.Content,
+		})
+	}
+	history = append(history, turn)
+
+	return conversation, history, nil
+}
+
+// saveTurn persists the user's message and the assistant's reply along with the chunks used to answer,
+// then checks whether the user has earned a chat achievement.
+func (s *Service) saveTurn(ctx context.Context, conversation *repository.Conversation, userMessage dto.Message, answer string, chunks []retrievedChunk) error {
+	chunkIDs := make(repository.UUIDList, len(chunks))
+	for i, chunk := range chunks {
+		chunkIDs[i] = chunk.ID
+	}
+
+	if conversation.Title == "" {
+		conversation.Title = conversationTitle(userMessage.Content)
+	}
+
+	err := s.repository.AppendMessages(ctx, conversation, []*repository.Message{
+		{
+			BaseModel: repository.BaseModel{
+				ID: uuid.New(),
+			},
+			ConversationID: conversation.ID,
+			Role:           string(dto.UserRole),
+			Content:        userMessage.Content,
+		},
+		{
+			BaseModel: repository.BaseModel{
+				ID: uuid.New(),
+			},
+			ConversationID: conversation.ID,
+			Role:           string(dto.AssistantRole),
+			Content:        answer,
+			ChunkIDs:       chunkIDs,
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("saveTurn :: appendMessages: %w", err)
+	}
+	s.checkAchievements(ctx, conversation.UserID)
+	return nil
+}
+
+func conversationTitle(content string) string {
+	title := []rune(strings.Join(strings.Fields(content), " "))
+	if len(title) > maxConversationTitleLen {
+		return string(title[:maxConversationTitleLen]) + "…"
+	}
+	return string(title)
+}
@@ -0,0 +1,346 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+
+	"patient-chatbot/internal/dto"
+	"patient-chatbot/internal/repository"
+
+	"github.com/google/uuid"
+)
+
+// defaultCopingStrategies seed every organization's catalogue; admins can edit or remove
+// them afterwards.
+var defaultCopingStrategies = []repository.CopingStrategy{
+	{
+		Key:           "deep_breathing",
+		TitleEN:       "Deep Breathing Exercise",
+		TitleAR:       "تمرين التنفس العميق",
+		DescriptionEN: "Slow, deep breathing calms the body until the urge passes.",
+		DescriptionAR: "التنفس البطيء والعميق يهدّئ الجسم حتى تزول الرغبة.",
+		StepsEN: repository.StringList{
+			"Sit comfortably and relax your shoulders",
+			"Breathe in slowly through your nose for 4 counts",
+			"Hold your breath for 4 counts",
+			"Breathe out slowly through your mouth for 6 counts",
+			"Repeat 5 to 10 times",
+		},
+		StepsAR: repository.StringList{
+			"اجلس بوضعية مريحة وأرخِ كتفيك",
+			"استنشق ببطء من أنفك وأنت تعدّ إلى 4",
+			"احبس نفسك وأنت تعدّ إلى 4",
+			"أخرج الزفير ببطء من فمك وأنت تعدّ إلى 6",
+			"كرّر ذلك من 5 إلى 10 مرات",
+		},
+		EstimatedTimeEN: "3-5 minutes",
+		EstimatedTimeAR: "3-5 دقائق",
+	},
+	{
+		Key:           "physical_activity",
+		TitleEN:       "Physical Activity",
+		TitleAR:       "النشاط البدني",
+		DescriptionEN: "A short burst of movement redirects your energy away from the craving.",
+		DescriptionAR: "الحركة القصيرة والسريعة تصرف طاقتك بعيدًا عن الرغبة.",
+		StepsEN: repository.StringList{
+			"Stand up and leave the place where the craving started",
+			"Take a brisk 2-minute walk or do 10 jumping jacks",
+			"Stretch your arms and legs while breathing steadily",
+		},
+		StepsAR: repository.StringList{
+			"قف واترك المكان الذي بدأت فيه الرغبة",
+			"امشِ بسرعة لمدة دقيقتين أو قم بـ 10 قفزات",
+			"مدّد ذراعيك وساقيك مع التنفس بانتظام",
+		},
+		EstimatedTimeEN: "2-5 minutes",
+		EstimatedTimeAR: "2-5 دقائق",
+	},
+	{
+		Key:           "grounding",
+		TitleEN:       "5-4-3-2-1 Grounding",
+		TitleAR:       "تمرين التأريض 5-4-3-2-1",
+		DescriptionEN: "Focusing on your senses pulls your attention away from the urge.",
+		DescriptionAR: "التركيز على حواسك يصرف انتباهك عن الرغبة.",
+		StepsEN: repository.StringList{
+			"Name 5 things you can see",
+			"Name 4 things you can touch",
+			"Name 3 things you can hear",
+			"Name 2 things you can smell",
+			"Name 1 thing you can taste",
+		},
+		StepsAR: repository.StringList{
+			"سمِّ 5 أشياء تراها",
+			"سمِّ 4 أشياء يمكنك لمسها",
+			"سمِّ 3 أشياء تسمعها",
+			"سمِّ شيئين يمكنك شمّهما",
+			"سمِّ شيئًا واحدًا يمكنك تذوّقه",
+		},
+		EstimatedTimeEN: "2-3 minutes",
+		EstimatedTimeAR: "2-3 دقائق",
+	},
+	{
+		Key:           "drink_water",
+		TitleEN:       "Drink a Glass of Water",
+		TitleAR:       "اشرب كوبًا من الماء",
+		DescriptionEN: "Sipping cold water keeps your hands and mouth busy while the craving fades.",
+		DescriptionAR: "شرب الماء البارد يُشغل يديك وفمك بينما تتلاشى الرغبة.",
+		StepsEN: repository.StringList{
+			"Pour a glass of cold water",
+			"Sip it slowly, one mouthful at a time",
+			"Notice how the craving changes as you drink",
+		},
+		StepsAR: repository.StringList{
+			"اسكب كوبًا من الماء البارد",
+			"اشربه ببطء رشفةً رشفة",
+			"لاحظ كيف تتغيّر الرغبة أثناء الشرب",
+		},
+		EstimatedTimeEN: "2-3 minutes",
+		EstimatedTimeAR: "2-3 دقائق",
+	},
+	{
+		Key:           "delay",
+		TitleEN:       "Delay and Distract",
+		TitleAR:       "التأجيل والإلهاء",
+		DescriptionEN: "Most cravings pass within 10 minutes; waiting them out with something else to do works.",
+		DescriptionAR: "معظم الرغبات تزول خلال 10 دقائق، وانتظارها مع الانشغال بشيء آخر يجدي نفعًا.",
+		StepsEN: repository.StringList{
+			"Tell yourself you will wait 10 minutes before deciding anything",
+			"Start a small task: text a friend, tidy a drawer or play a quick game",
+			"When the 10 minutes are up, check how strong the craving is now",
+		},
+		StepsAR: repository.StringList{
+			"قل لنفسك إنك ستنتظر 10 دقائق قبل أن تقرر أي شيء",
+			"ابدأ مهمة صغيرة: راسل صديقًا أو رتّب درجًا أو العب لعبة قصيرة",
+			"بعد انتهاء الدقائق العشر، لاحظ مدى قوة الرغبة الآن",
+		},
+		EstimatedTimeEN: "10 minutes",
+		EstimatedTimeAR: "10 دقائق",
+	},
+}
+
+var (
+	ErrCopingStrategyNotFound = errors.New("coping strategy not found")
+	ErrCopingStrategyKeyTaken = errors.New("coping strategy key already in use")
+	ErrInvalidCopingStrategy  = errors.New("invalid coping strategy")
+)
+
+// CopingStrategyInput is an admin's version of a strategy. Key is only used when creating
+// one; if it is empty, it is derived from the English title.
+type CopingStrategyInput struct {
+	Key             string
+	TitleEN         string
+	TitleAR         string
+	DescriptionEN   string
+	DescriptionAR   string
+	StepsEN         []string
+	StepsAR         []string
+	EstimatedTimeEN string
+	EstimatedTimeAR string
+	Source          string
+	Active          bool
+	Position        int
+}
+
+// GetCopingStrategies returns the organization's active strategies in lang, with how
+// effective each has been for the user.
+func (s *Service) GetCopingStrategies(ctx context.Context, userID uuid.UUID, orgID uuid.UUID, lang string) ([]dto.CopingStrategy, error) {
+	strategies, err := s.organizationStrategies(ctx, orgID, true)
+	if err != nil {
+		return nil, err
+	}
+	stats, err := s.repository.GetStrategyStats(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("getCopingStrategies :: getStrategyStats: %w", err)
+	}
+
+	localized := make([]dto.CopingStrategy, len(strategies))
+	for i := range strategies {
+		localized[i] = localizeStrategy(&strategies[i], lang, stats)
+	}
+	return localized, nil
+}
+
+// GetOrganizationCopingStrategies returns the whole catalogue, inactive strategies included,
+// for admins to edit.
+func (s *Service) GetOrganizationCopingStrategies(ctx context.Context, orgID uuid.UUID) ([]repository.CopingStrategy, error) {
+	return s.organizationStrategies(ctx, orgID, false)
+}
+
+func (s *Service) CreateCopingStrategy(ctx context.Context, orgID uuid.UUID, input CopingStrategyInput) (*repository.CopingStrategy, error) {
+	key := input.Key
+	if key == "" {
+		key = catalogueKey(input.TitleEN)
+	}
+	if !validCatalogueKey(key) {
+		return nil, fmt.Errorf("%w: key must be lower-case letters, digits and underscores", ErrInvalidCopingStrategy)
+	}
+	// Seed first, so that the defaults are not added on top of the organization's own
+	// catalogue later.
+	if _, err := s.organizationStrategies(ctx, orgID, false); err != nil {
+		return nil, err
+	}
+
+	strategy := &repository.CopingStrategy{
+		BaseModel:      repository.BaseModel{ID: uuid.New()},
+		OrganizationID: orgID,
+		Key:            key,
+	}
+	applyStrategyInput(strategy, input)
+	err := s.repository.CreateCopingStrategy(ctx, strategy)
+	if errors.Is(err, repository.ErrDuplicate) {
+		return nil, ErrCopingStrategyKeyTaken
+	}
+	if err != nil {
+		return nil, fmt.Errorf("createCopingStrategy :: createCopingStrategy: %w", err)
+	}
+	return strategy, nil
+}
+
+func (s *Service) UpdateCopingStrategy(ctx context.Context, orgID uuid.UUID, id string, input CopingStrategyInput) (*repository.CopingStrategy, error) {
+	strategy, err := s.getCopingStrategy(ctx, orgID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	applyStrategyInput(strategy, input)
+	if err := s.repository.UpdateCopingStrategy(ctx, strategy); err != nil {
+		return nil, fmt.Errorf("updateCopingStrategy :: updateCopingStrategy: %w", err)
+	}
+	return strategy, nil
+}
+
+// DeleteCopingStrategy removes a strategy from the catalogue. Cravings it was recommended
+// for keep its key.
+func (s *Service) DeleteCopingStrategy(ctx context.Context, orgID uuid.UUID, id string) error {
+	strategyID, err := uuid.Parse(id)
+	if err != nil {
+		return ErrCopingStrategyNotFound
+	}
+
+	err = s.repository.SoftDeleteCopingStrategy(ctx, orgID, strategyID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return ErrCopingStrategyNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("deleteCopingStrategy :: softDeleteCopingStrategy: %w", err)
+	}
+	return nil
+}
+
+func (s *Service) getCopingStrategy(ctx context.Context, orgID uuid.UUID, id string) (*repository.CopingStrategy, error) {
+	strategyID, err := uuid.Parse(id)
+	if err != nil {
+		return nil, ErrCopingStrategyNotFound
+	}
+
+	strategy, err := s.repository.GetCopingStrategyByID(ctx, orgID, strategyID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrCopingStrategyNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("getCopingStrategy :: getCopingStrategyByID: %w", err)
+	}
+	return strategy, nil
+}
+
+// organizationStrategies returns the organization's catalogue, seeding it with
+// defaultCopingStrategies the first time. An organization whose admins deleted every
+// strategy is not seeded again.
+func (s *Service) organizationStrategies(ctx context.Context, orgID uuid.UUID, activeOnly bool) ([]repository.CopingStrategy, error) {
+	total, err := s.repository.CountCopingStrategies(ctx, orgID)
+	if err != nil {
+		return nil, fmt.Errorf("organizationStrategies :: countCopingStrategies: %w", err)
+	}
+	if total == 0 {
+		seed := make([]*repository.CopingStrategy, len(defaultCopingStrategies))
+		for i, strategy := range defaultCopingStrategies {
+			strategy.ID = uuid.New()
+			strategy.OrganizationID = orgID
+			strategy.Active = true
+			strategy.Position = i
+			seed[i] = &strategy
+		}
+		if err := s.repository.SeedCopingStrategies(ctx, seed); err != nil {
+			return nil, fmt.Errorf("organizationStrategies :: seedCopingStrategies: %w", err)
+		}
+	}
+
+	strategies, err := s.repository.GetCopingStrategies(ctx, orgID, activeOnly)
+	if err != nil {
+		return nil, fmt.Errorf("organizationStrategies :: getCopingStrategies: %w", err)
+	}
+	return strategies, nil
+}
+
+func applyStrategyInput(strategy *repository.CopingStrategy, input CopingStrategyInput) {
+	strategy.TitleEN = strings.TrimSpace(input.TitleEN)
+	strategy.TitleAR = strings.TrimSpace(input.TitleAR)
+	strategy.DescriptionEN = strings.TrimSpace(input.DescriptionEN)
+	strategy.DescriptionAR = strings.TrimSpace(input.DescriptionAR)
+	strategy.StepsEN = trimList(input.StepsEN)
+	strategy.StepsAR = trimList(input.StepsAR)
+	strategy.EstimatedTimeEN = strings.TrimSpace(input.EstimatedTimeEN)
+	strategy.EstimatedTimeAR = strings.TrimSpace(input.EstimatedTimeAR)
+	strategy.Source = strings.TrimSpace(input.Source)
+	strategy.Active = input.Active
+	strategy.Position = input.Position
+}
+
+// localizeStrategy returns strategy in lang, falling back to English for text that has no
+// Arabic version, together with how effective it has been for the user.
+func localizeStrategy(strategy *repository.CopingStrategy, lang string, stats []repository.StrategyStats) dto.CopingStrategy {
+	localized := dto.CopingStrategy{
+		Key:           strategy.Key,
+		Title:         strategy.TitleEN,
+		Description:   strategy.DescriptionEN,
+		Steps:         strategy.StepsEN,
+		EstimatedTime: strategy.EstimatedTimeEN,
+		Source:        strategy.Source,
+	}
+	if lang != "en" {
+		localized.Title = orText(strategy.TitleAR, localized.Title)
+		localized.Description = orText(strategy.DescriptionAR, localized.Description)
+		localized.EstimatedTime = orText(strategy.EstimatedTimeAR, localized.EstimatedTime)
+		if len(strategy.StepsAR) > 0 {
+			localized.Steps = strategy.StepsAR
+		}
+	}
+	if localized.Steps == nil {
+		localized.Steps = []string{}
+	}
+
+	for _, s := range stats {
+		if s.StrategyKey == strategy.Key && s.Tried > 0 {
+			effectiveness := int(math.Round(100 * float64(s.Resisted) / float64(s.Tried)))
+			localized.Effectiveness = &effectiveness
+			localized.TimesTried = s.Tried
+		}
+	}
+	return localized
+}
+
+// recommendStrategy picks the strategy the user is most likely to resist a craving with,
+// estimated as (resisted+1)/(tried+2) so that untried strategies start at even odds. Ties go
+// to the strategy tried least, then to the first in keys, so a strategy that failed gives
+// way to one not tried yet.
+func recommendStrategy(keys []string, stats []repository.StrategyStats) string {
+	byKey := make(map[string]repository.StrategyStats, len(stats))
+	for _, s := range stats {
+		byKey[s.StrategyKey] = s
+	}
+
+	best := ""
+	var bestScore float64
+	bestTried := 0
+	for _, key := range keys {
+		s := byKey[key]
+		score := float64(s.Resisted+1) / float64(s.Tried+2)
+		if best == "" || score > bestScore || (score == bestScore && s.Tried < bestTried) {
+			best, bestScore, bestTried = key, score, s.Tried
+		}
+	}
+	return best
+}
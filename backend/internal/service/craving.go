@@ -0,0 +1,179 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"patient-chatbot/internal/dto"
+	"patient-chatbot/internal/repository"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	maxCravingTriggers = 10
+	// maxClockSkew is how far in the future a craving's time may be, to allow for client
+	// clocks that run slightly fast.
+	maxClockSkew = 5 * time.Minute
+)
+
+var (
+	ErrCravingNotFound = errors.New("craving not found")
+	ErrInvalidCraving  = errors.New("invalid craving")
+)
+
+// CravingInput is a craving as the patient reports it. A zero OccurredAt means now.
+type CravingInput struct {
+	Intensity  int
+	Triggers   []string
+	Location   string
+	Notes      string
+	OccurredAt time.Time
+	Outcome    *repository.CravingOutcome
+}
+
+// CravingLog is a logged craving, the coping strategy recommended for it and the
+// knowledge-base content that backs the recommendation up. Strategy is nil if the
+// organization has no active strategies.
+type CravingLog struct {
+	Craving  *repository.Craving
+	Strategy *dto.CopingStrategy
+	Sources  []dto.Source
+}
+
+// LogCraving records a craving and recommends the coping strategy the user has had the most
+// success with. A craving that ended in a slip also marks that day as a slip.
+func (s *Service) LogCraving(ctx context.Context, userID uuid.UUID, orgID uuid.UUID, input CravingInput, lang string) (*CravingLog, error) {
+	if input.Intensity < 1 || input.Intensity > maxCravingLevel {
+		return nil, fmt.Errorf("%w: intensity must be between 1 and %d", ErrInvalidCraving, maxCravingLevel)
+	}
+	if input.OccurredAt.IsZero() {
+		input.OccurredAt = time.Now()
+	}
+	if input.OccurredAt.After(time.Now().Add(maxClockSkew)) {
+		return nil, fmt.Errorf("%w: it cannot have happened in the future", ErrInvalidCraving)
+	}
+	triggers := normalizeTriggers(input.Triggers)
+	if len(triggers) > maxCravingTriggers {
+		return nil, fmt.Errorf("%w: at most %d triggers", ErrInvalidCraving, maxCravingTriggers)
+	}
+
+	strategies, err := s.organizationStrategies(ctx, orgID, true)
+	if err != nil {
+		return nil, err
+	}
+	stats, err := s.repository.GetStrategyStats(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("logCraving :: getStrategyStats: %w", err)
+	}
+	var strategy *dto.CopingStrategy
+	if len(strategies) > 0 {
+		keys := make([]string, len(strategies))
+		for i := range strategies {
+			keys[i] = strategies[i].Key
+		}
+		recommended := recommendStrategy(keys, stats)
+		for i := range strategies {
+			if strategies[i].Key == recommended {
+				localized := localizeStrategy(&strategies[i], lang, stats)
+				strategy = &localized
+			}
+		}
+	}
+
+	craving := &repository.Craving{
+		BaseModel:      repository.BaseModel{ID: uuid.New()},
+		OrganizationID: orgID,
+		UserID:         userID,
+		Intensity:      input.Intensity,
+		Triggers:       triggers,
+		Location:       optionalText(input.Location),
+		Notes:          optionalText(input.Notes),
+		Outcome:        input.Outcome,
+		OccurredAt:     input.OccurredAt,
+	}
+	if strategy != nil {
+		craving.StrategyKey = &strategy.Key
+	}
+	if err := s.repository.CreateCraving(ctx, craving); err != nil {
+		return nil, fmt.Errorf("logCraving :: createCraving: %w", err)
+	}
+	s.checkAchievements(ctx, userID)
+
+	return &CravingLog{
+		Craving:  craving,
+		Strategy: strategy,
+		Sources:  s.copingSources(ctx, orgID, strategy, triggers),
+	}, nil
+}
+
+func (s *Service) GetCravings(ctx context.Context, userID uuid.UUID, page int, pageSize int) ([]repository.Craving, int, error) {
+	offset := (page - 1) * pageSize
+	cravings, total, err := s.repository.GetCravingsByUserID(ctx, userID, offset, pageSize)
+	if err != nil {
+		return nil, 0, fmt.Errorf("getCravings :: getCravingsByUserID: %w", err)
+	}
+	return cravings, total, nil
+}
+
+// SetCravingOutcome records whether the user resisted a craving, which feeds into the
+// strategies recommended to them next. A slip is also recorded on the craving's day, and
+// taken back if the outcome changes again.
+func (s *Service) SetCravingOutcome(ctx context.Context, userID uuid.UUID, id string, outcome repository.CravingOutcome) (*repository.Craving, error) {
+	cravingID, err := uuid.Parse(id)
+	if err != nil {
+		return nil, ErrCravingNotFound
+	}
+
+	err = s.repository.UpdateCravingOutcome(ctx, userID, cravingID, outcome)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrCravingNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("setCravingOutcome :: updateCravingOutcome: %w", err)
+	}
+
+	craving, err := s.repository.GetCravingByID(ctx, userID, cravingID)
+	if err != nil {
+		return nil, fmt.Errorf("setCravingOutcome :: getCravingByID: %w", err)
+	}
+	s.checkAchievements(ctx, userID)
+	return craving, nil
+}
+
+// copingSources searches the organization's knowledge base for guidance on the strategy and
+// triggers. The recommendation stands on its own, so a failed search only loses the sources.
+func (s *Service) copingSources(ctx context.Context, orgID uuid.UUID, strategy *dto.CopingStrategy, triggers []string) []dto.Source {
+	query := "coping with a craving to smoke"
+	if strategy != nil {
+		query = strategy.Title
+	}
+	if len(triggers) > 0 {
+		query += ": " + strings.Join(triggers, ", ")
+	}
+	chunks, err := s.retrieveContext(ctx, orgID, []dto.Message{{Role: dto.UserRole, Content: query}})
+	if err != nil {
+		log.Warn().Msg("logCraving :: retrieveContext: " + err.Error())
+		return []dto.Source{}
+	}
+	return toSources(chunks)
+}
+
+// normalizeTriggers trims and lower-cases trigger tags and drops blanks and duplicates.
+func normalizeTriggers(triggers []string) repository.StringList {
+	normalized := repository.StringList{}
+	seen := make(map[string]bool, len(triggers))
+	for _, trigger := range triggers {
+		trigger = strings.ToLower(strings.Join(strings.Fields(trigger), " "))
+		if trigger == "" || seen[trigger] {
+			continue
+		}
+		seen[trigger] = true
+		normalized = append(normalized, trigger)
+	}
+	return normalized
+}
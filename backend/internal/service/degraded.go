@@ -0,0 +1,83 @@
+package service
+
+import (
+	"errors"
+	"strings"
+
+	"patient-chatbot/internal/client/llm"
+	"patient-chatbot/internal/client/resilience"
+)
+
+const (
+	degradedIntroEN     = "I can't reach the coaching assistant right now, but here is what our guidelines say:"
+	degradedIntroAR     = "لا أستطيع الوصول إلى المساعد حاليًا، ولكن إليك ما تقوله إرشاداتنا:"
+	degradedNoContextEN = "I can't reach the coaching assistant right now. Please try again in a few minutes."
+	degradedNoContextAR = "لا أستطيع الوصول إلى المساعد حاليًا. يُرجى المحاولة مرة أخرى بعد بضع دقائق."
+	interruptedIntroEN  = "I couldn't finish my reply, but this has been saved:"
+	interruptedIntroAR  = "لم أتمكن من إكمال ردي، ولكن تم حفظ ما يلي:"
+)
+
+// savedChanges describes, in English and Arabic, what a successful call to each tool that
+// changes the user's data has saved.
+var savedChanges = map[string]struct{ en, ar string }{
+	"log_smoke_free_day":         {"Your smoke-free day was logged.", "تم تسجيل يومك الخالي من التدخين."},
+	"report_slip":                {"Your slip was recorded.", "تم تسجيل الانتكاسة."},
+	"log_money_saved":            {"The money you saved was recorded.", "تم تسجيل المبلغ الذي وفّرته."},
+	"log_craving":                {"Your craving was logged.", "تم تسجيل الرغبة في التدخين."},
+	"confirm_appointment_change": {"Your appointment change is confirmed.", "تم تأكيد التغيير على موعدك."},
+}
+
+// llmUnavailable reports whether err means the LLM could not be reached, as opposed to
+// a request it rejected. Chat then falls back to degradedAnswer.
+func llmUnavailable(err error) bool {
+	return errors.Is(err, resilience.ErrUnavailable)
+}
+
+// degradedAnswer answers from the retrieved chunks alone, quoting them verbatim.
+func degradedAnswer(chunks []retrievedChunk, lang string) *llm.ChatResult {
+	intro, noContext := degradedIntroEN, degradedNoContextEN
+	if lang != "en" {
+		intro, noContext = degradedIntroAR, degradedNoContextAR
+	}
+	if len(chunks) == 0 {
+		return &llm.ChatResult{Answer: noContext}
+	}
+
+	var b strings.Builder
+	b.WriteString(intro)
+	for _, chunk := range chunks {
+		b.WriteString("\n\n- " + chunk.Text)
+	}
+	return &llm.ChatResult{Answer: b.String()}
+}
+
+// savedChangesReport lists the changes made by the tool calls in result that succeeded, or
+// returns "" if there were none. A chat that fails after such a call answers with this
+// report instead of failing, so the user is not told nothing happened when it did.
+func savedChangesReport(result *llm.ChatResult, lang string) string {
+	if result == nil {
+		return ""
+	}
+
+	var changes []string
+	for _, call := range result.ToolCalls {
+		change, ok := savedChanges[call.Name]
+		if !ok || call.Failed {
+			continue
+		}
+		if lang == "en" {
+			changes = append(changes, change.en)
+		} else {
+			changes = append(changes, change.ar)
+		}
+	}
+	if len(changes) == 0 {
+		return ""
+	}
+
+	intro := interruptedIntroEN
+	if lang != "en" {
+		intro = interruptedIntroAR
+	}
+	return intro + "\n\n- " + strings.Join(changes, "\n- ")
+}
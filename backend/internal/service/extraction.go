@@ -0,0 +1,110 @@
+package service
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"patient-chatbot/internal/extract"
+	"patient-chatbot/internal/repository"
+
+	"github.com/rs/zerolog/log"
+)
+
+type extractedDocument struct {
+	Title    string
+	Category string
+	Summary  string
+	Chunks   []extractedChunk
+}
+
+type extractedChunk struct {
+	Text string
+	Page *int
+}
+
+// extractDocument turns an uploaded file into titled, categorised chunks. Documents are read
+// locally by the format's extractor and images are transcribed by the vision model; either
+// way the text is chunked locally, so the whole document is indexed however long it is.
+func (s *Service) extractDocument(ctx context.Context, job *repository.IngestionJob) (*extractedDocument, error) {
+	var pages []extract.Page
+	if strings.HasPrefix(job.MimeType, "image/") {
+		b64 := base64.StdEncoding.EncodeToString(job.Payload)
+		text, err := s.llmClient.TranscribePage(ctx, fmt.Sprintf("data:%s;base64,%s", job.MimeType, b64))
+		if err != nil {
+			return nil, fmt.Errorf("extractDocument :: transcribePage: %w", err)
+		}
+		pages = []extract.Page{{Text: text}}
+	} else {
+		doc, err := extract.Extract(job.MimeType, job.Payload)
+		if err != nil {
+			return nil, fmt.Errorf("extractDocument :: extract: %w", err)
+		}
+		if job.MimeType == extract.MimePDF {
+			if err := s.transcribeScannedPages(ctx, job, doc); err != nil {
+				return nil, err
+			}
+		}
+		pages = doc.Pages
+	}
+
+	return s.chunkAndDescribe(ctx, pages)
+}
+
+// transcribeScannedPages replaces the text of scanned pages with what the vision model reads
+// off a rendering of the page. Pages that cannot be rendered are skipped with a warning, so a
+// mostly digital PDF still ingests when pdftoppm is not installed.
+func (s *Service) transcribeScannedPages(ctx context.Context, job *repository.IngestionJob, doc *extract.Document) error {
+	for i, page := range doc.Pages {
+		if !page.Scanned {
+			continue
+		}
+
+		image, err := extract.RasterizePDFPage(ctx, s.cfg.PdftoppmPath, job.Payload, page.Number)
+		if err != nil {
+			log.Warn().Msgf("document %s: skipping scanned page %d: %s", job.DocumentID, page.Number, err.Error())
+			continue
+		}
+
+		text, err := s.llmClient.TranscribePage(ctx, "data:image/png;base64,"+base64.StdEncoding.EncodeToString(image))
+		if err != nil {
+			return fmt.Errorf("transcribeScannedPages :: transcribePage %d: %w", page.Number, err)
+		}
+		doc.Pages[i].Text = text
+	}
+	return nil
+}
+
+// chunkAndDescribe chunks each page separately, so every chunk keeps its page number, and
+// has the LLM title, categorise and summarise the document as a whole.
+func (s *Service) chunkAndDescribe(ctx context.Context, pages []extract.Page) (*extractedDocument, error) {
+	extracted := &extractedDocument{}
+	var texts []string
+	for _, page := range pages {
+		if strings.TrimSpace(page.Text) == "" {
+			continue
+		}
+		texts = append(texts, page.Text)
+
+		var number *int
+		if page.Number > 0 {
+			number = &page.Number
+		}
+		for _, text := range s.chunker.Split(page.Text) {
+			extracted.Chunks = append(extracted.Chunks, extractedChunk{Text: text, Page: number})
+		}
+	}
+	if len(extracted.Chunks) == 0 {
+		return nil, fmt.Errorf("chunkAndDescribe :: document has no extractable text")
+	}
+
+	description, err := s.llmClient.DescribeDocument(ctx, strings.Join(texts, "\n\n"))
+	if err != nil {
+		return nil, fmt.Errorf("chunkAndDescribe :: describeDocument: %w", err)
+	}
+	extracted.Title = description.Title
+	extracted.Category = description.Category
+	extracted.Summary = description.Summary
+	return extracted, nil
+}
@@ -0,0 +1,357 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"strings"
+	"time"
+
+	"patient-chatbot/internal/client/vectordb"
+	"patient-chatbot/internal/extract"
+	"patient-chatbot/internal/repository"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// ingestionTimeout bounds a single ingestion attempt.
+	ingestionTimeout = 9 * time.Minute
+	// ingestionLease is how long a claimed job is left to its worker before it is considered
+	// abandoned and picked up again. It outlasts ingestionTimeout so the worker can still
+	// record the outcome of an attempt that timed out.
+	ingestionLease = ingestionTimeout + time.Minute
+	// ingestionRetryDelay is multiplied by the attempt number to space out automatic retries.
+	ingestionRetryDelay = 30 * time.Second
+)
+
+var (
+	ErrDocumentNotFound    = errors.New("document not found")
+	ErrIngestionNotFailed  = errors.New("ingestion has not failed")
+	ErrUnsupportedFileType = errors.New("unsupported file type")
+
+	// errDocumentDeleted stops an ingestion whose document was deleted while it ran.
+	errDocumentDeleted = errors.New("document was deleted during ingestion")
+)
+
+// Upload stores the file and queues it for ingestion. The document becomes searchable once
+// its job reaches IngestionStatusReady. Files that are neither a supported document format
+// nor an image are rejected with ErrUnsupportedFileType.
+func (s *Service) Upload(ctx context.Context, orgID uuid.UUID, file *multipart.FileHeader) (*repository.IngestionJob, error) {
+	f, err := file.Open()
+	if err != nil {
+		return nil, fmt.Errorf("upload :: open file: %w", err)
+	}
+	defer f.Close()
+
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return nil, fmt.Errorf("upload :: read file: %w", err)
+	}
+
+	filename, ext := sanitizeFilename(file.Filename)
+	mimeType := extract.DetectMimeType(ext, data)
+	if !extract.Supported(mimeType) && !strings.HasPrefix(mimeType, "image/") {
+		return nil, fmt.Errorf("upload :: %w: %s", ErrUnsupportedFileType, mimeType)
+	}
+
+	document := &repository.Document{
+		BaseModel: repository.BaseModel{
+			ID: uuid.New(),
+		},
+		OrganizationID: orgID,
+		Title:          filename,
+		Path:           filename,
+		Extension:      ext,
+	}
+	job := &repository.IngestionJob{
+		BaseModel: repository.BaseModel{
+			ID: uuid.New(),
+		},
+		OrganizationID: orgID,
+		DocumentID:     document.ID,
+		Status:         repository.IngestionStatusPending,
+		MimeType:       mimeType,
+		Payload:        data,
+		RunAt:          time.Now(),
+	}
+
+	err = s.repository.CreateDocumentWithIngestionJob(ctx, document, job)
+	if err != nil {
+		return nil, fmt.Errorf("upload :: createDocumentWithIngestionJob: %w", err)
+	}
+
+	s.wakeIngestionWorker()
+	return job, nil
+}
+
+func (s *Service) GetIngestionStatus(ctx context.Context, orgID uuid.UUID, id string) (*repository.IngestionJob, error) {
+	documentID, err := uuid.Parse(id)
+	if err != nil {
+		return nil, ErrDocumentNotFound
+	}
+
+	job, err := s.repository.GetIngestionJobByDocumentID(ctx, orgID, documentID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrDocumentNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("getIngestionStatus :: getIngestionJobByDocumentID: %w", err)
+	}
+	return job, nil
+}
+
+// RetryIngestion requeues a document whose ingestion failed.
+func (s *Service) RetryIngestion(ctx context.Context, orgID uuid.UUID, id string) (*repository.IngestionJob, error) {
+	job, err := s.GetIngestionStatus(ctx, orgID, id)
+	if err != nil {
+		return nil, err
+	}
+	if job.Status != repository.IngestionStatusFailed {
+		return nil, ErrIngestionNotFailed
+	}
+
+	err = s.repository.RetryIngestionJob(ctx, orgID, job.DocumentID)
+	if errors.Is(err, repository.ErrNotFound) {
+		// Another request retried it first.
+		return nil, ErrIngestionNotFailed
+	}
+	if err != nil {
+		return nil, fmt.Errorf("retryIngestion :: retryIngestionJob: %w", err)
+	}
+
+	s.wakeIngestionWorker()
+	return s.GetIngestionStatus(ctx, orgID, id)
+}
+
+// RunIngestionWorkers processes queued ingestion jobs with cfg.IngestionWorkers workers until
+// ctx is cancelled. Workers sleep until a job is queued or the poll interval elapses, so
+// jobs left behind by another instance or a crash are also picked up.
+func (s *Service) RunIngestionWorkers(ctx context.Context) {
+	workers := max(s.cfg.IngestionWorkers, 1)
+	done := make(chan struct{})
+	for range workers {
+		go func() {
+			defer func() { done <- struct{}{} }()
+			s.runIngestionWorker(ctx)
+		}()
+	}
+	for range workers {
+		<-done
+	}
+}
+
+func (s *Service) runIngestionWorker(ctx context.Context) {
+	ticker := time.NewTicker(s.cfg.IngestionPollInterval)
+	defer ticker.Stop()
+
+	for {
+		for s.ProcessNextIngestionJob(ctx) {
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.ingestionWake:
+		case <-ticker.C:
+		}
+	}
+}
+
+// ProcessNextIngestionJob claims and runs one job, reporting whether there was one.
+func (s *Service) ProcessNextIngestionJob(ctx context.Context) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+
+	job, err := s.repository.ClaimIngestionJob(ctx, ingestionLease, s.cfg.IngestionMaxAttempts)
+	if errors.Is(err, repository.ErrNotFound) {
+		return false
+	}
+	if err != nil {
+		log.Error().Msg("processNextIngestionJob :: claimIngestionJob: " + err.Error())
+		return false
+	}
+
+	jobCtx, cancel := context.WithTimeout(ctx, ingestionTimeout)
+	defer cancel()
+
+	err = s.ingest(jobCtx, job)
+	if errors.Is(err, errDocumentDeleted) {
+		// The job was deleted with the document, so there is nothing to record.
+		log.Info().Msgf("ingestion of document %s stopped: the document was deleted", job.DocumentID)
+		return true
+	}
+	if err != nil {
+		s.failIngestion(ctx, job, err)
+		return true
+	}
+
+	err = s.repository.CompleteIngestionJob(ctx, job)
+	if errors.Is(err, repository.ErrNotFound) {
+		log.Warn().Msgf("ingestion of document %s finished after its lease expired; the job was left to its new worker", job.DocumentID)
+	} else if err != nil {
+		log.Error().Msg("processNextIngestionJob :: completeIngestionJob: " + err.Error())
+	}
+	return true
+}
+
+func (s *Service) ingest(ctx context.Context, job *repository.IngestionJob) error {
+	if job.MimeType == "" {
+		return s.reindex(ctx, job)
+	}
+
+	extracted, err := s.extractDocument(ctx, job)
+	if err != nil {
+		return err
+	}
+
+	err = s.repository.UpdateDocumentMetadata(ctx, job.DocumentID, extracted.Title, extracted.Category, extracted.Summary)
+	if err != nil {
+		return fmt.Errorf("ingest :: updateDocumentMetadata: %w", err)
+	}
+
+	err = s.repository.SetIngestionJobStatus(ctx, job, repository.IngestionStatusEmbedding)
+	if err != nil {
+		return fmt.Errorf("ingest :: setIngestionJobStatus: %w", err)
+	}
+
+	records := make([]vectordb.Record, len(extracted.Chunks))
+	chunks := make([]*repository.Chunk, len(extracted.Chunks))
+	for i, chunk := range extracted.Chunks {
+		chunkId := uuid.New()
+		records[i] = vectordb.Record{
+			ID:   chunkId.String(),
+			Text: chunk.Text,
+			Fields: map[string]string{
+				"document_id": job.DocumentID.String(),
+				"category":    extracted.Category,
+			},
+		}
+		chunks[i] = &repository.Chunk{
+			BaseModel: repository.BaseModel{
+				ID: chunkId,
+			},
+			OrganizationID: job.OrganizationID,
+			Content:        chunk.Text,
+			DocumentID:     job.DocumentID,
+			Page:           chunk.Page,
+		}
+	}
+
+	removed, err := s.repository.ReplaceDocumentChunks(ctx, job.DocumentID, chunks)
+	if errors.Is(err, repository.ErrNotFound) {
+		return errDocumentDeleted
+	}
+	if err != nil {
+		return fmt.Errorf("ingest :: replaceDocumentChunks: %w", err)
+	}
+	if len(removed) > 0 {
+		ids := make([]string, len(removed))
+		for i, id := range removed {
+			ids[i] = id.String()
+		}
+		if err := s.vectorStore.Delete(ctx, s.namespace(job.OrganizationID), ids); err != nil {
+			return fmt.Errorf("ingest :: delete stale vectors: %w", err)
+		}
+	}
+
+	err = s.vectorStore.Upsert(ctx, s.namespace(job.OrganizationID), records)
+	if err != nil {
+		return fmt.Errorf("ingest :: upsert: %w", err)
+	}
+
+	return s.dropVectorsOfDeletedDocument(ctx, job, records)
+}
+
+// dropVectorsOfDeletedDocument deletes the records just upserted for job if its document was
+// deleted in the meantime: the delete removed the chunks, but not the vectors upserted since.
+func (s *Service) dropVectorsOfDeletedDocument(ctx context.Context, job *repository.IngestionJob, records []vectordb.Record) error {
+	_, err := s.repository.GetDocumentByID(ctx, job.OrganizationID, job.DocumentID)
+	if errors.Is(err, repository.ErrNotFound) {
+		ids := make([]string, len(records))
+		for i, record := range records {
+			ids[i] = record.ID
+		}
+		if err := s.vectorStore.Delete(ctx, s.namespace(job.OrganizationID), ids); err != nil {
+			return fmt.Errorf("dropVectorsOfDeletedDocument :: delete: %w", err)
+		}
+		return errDocumentDeleted
+	}
+	if err != nil {
+		return fmt.Errorf("dropVectorsOfDeletedDocument :: getDocumentByID: %w", err)
+	}
+	return nil
+}
+
+// reindex upserts a document's stored chunks into its organization's namespace and removes
+// them from the namespace shared before organizations existed, where the migration to
+// organizations left them.
+func (s *Service) reindex(ctx context.Context, job *repository.IngestionJob) error {
+	document, err := s.repository.GetDocumentByID(ctx, job.OrganizationID, job.DocumentID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return errDocumentDeleted
+	}
+	if err != nil {
+		return fmt.Errorf("reindex :: getDocumentByID: %w", err)
+	}
+
+	err = s.repository.SetIngestionJobStatus(ctx, job, repository.IngestionStatusEmbedding)
+	if err != nil {
+		return fmt.Errorf("reindex :: setIngestionJobStatus: %w", err)
+	}
+
+	records := make([]vectordb.Record, len(document.Chunks))
+	ids := make([]string, len(document.Chunks))
+	for i, chunk := range document.Chunks {
+		records[i] = vectordb.Record{
+			ID:   chunk.ID.String(),
+			Text: chunk.Content,
+			Fields: map[string]string{
+				"document_id": document.ID.String(),
+				"category":    document.Category,
+			},
+		}
+		ids[i] = chunk.ID.String()
+	}
+	if len(records) == 0 {
+		return nil
+	}
+
+	if err := s.vectorStore.Upsert(ctx, s.namespace(job.OrganizationID), records); err != nil {
+		return fmt.Errorf("reindex :: upsert: %w", err)
+	}
+	if err := s.vectorStore.Delete(ctx, s.cfg.PineconeNamespace, ids); err != nil {
+		return fmt.Errorf("reindex :: delete shared vectors: %w", err)
+	}
+	return s.dropVectorsOfDeletedDocument(ctx, job, records)
+}
+
+// failIngestion records a failed attempt, scheduling an automatic retry while the job has
+// attempts left.
+func (s *Service) failIngestion(ctx context.Context, job *repository.IngestionJob, cause error) {
+	log.Error().Msgf("ingestion of document %s failed (attempt %d): %s", job.DocumentID, job.Attempts, cause.Error())
+
+	var retryAt *time.Time
+	if job.Attempts < s.cfg.IngestionMaxAttempts {
+		at := time.Now().Add(time.Duration(job.Attempts) * ingestionRetryDelay)
+		retryAt = &at
+	}
+
+	err := s.repository.FailIngestionJob(ctx, job, cause.Error(), retryAt)
+	if errors.Is(err, repository.ErrNotFound) {
+		log.Warn().Msgf("ingestion of document %s failed after its lease expired; the job was left to its new worker", job.DocumentID)
+	} else if err != nil {
+		log.Error().Msg("failIngestion :: failIngestionJob: " + err.Error())
+	}
+}
+
+func (s *Service) wakeIngestionWorker() {
+	select {
+	case s.ingestionWake <- struct{}{}:
+	default:
+	}
+}
@@ -0,0 +1,313 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"patient-chatbot/internal/dto"
+	"patient-chatbot/internal/repository"
+
+	"github.com/google/uuid"
+)
+
+const (
+	minutesPerDay = 24 * 60
+	// maxQuitDateLead is how far ahead a patient may plan their quit date.
+	maxQuitDateLead = 90 * 24 * time.Hour
+)
+
+// defaultMilestones seed every organization's catalogue; admins can edit or remove them
+// afterwards. Timings follow the commonly cited WHO timeline.
+var defaultMilestones = []repository.MilestoneDefinition{
+	{
+		Key:           "heart_rate",
+		TitleEN:       "Heart Rate Normalized",
+		TitleAR:       "عودة معدل ضربات القلب إلى طبيعته",
+		DescriptionEN: "Your heart rate and blood pressure drop towards normal levels.",
+		DescriptionAR: "ينخفض معدل ضربات قلبك وضغط دمك نحو المستويات الطبيعية.",
+		Category:      repository.MilestoneCategoryImmediate,
+		AfterMinutes:  20,
+	},
+	{
+		Key:           "carbon_monoxide",
+		TitleEN:       "Carbon Monoxide Cleared",
+		TitleAR:       "التخلص من أول أكسيد الكربون",
+		DescriptionEN: "The carbon monoxide level in your blood returns to normal.",
+		DescriptionAR: "يعود مستوى أول أكسيد الكربون في دمك إلى طبيعته.",
+		Category:      repository.MilestoneCategoryImmediate,
+		AfterMinutes:  12 * 60,
+	},
+	{
+		Key:           "taste_smell",
+		TitleEN:       "Taste & Smell Enhanced",
+		TitleAR:       "تحسّن حاستي التذوق والشم",
+		DescriptionEN: "Damaged nerve endings start to regrow, sharpening your sense of taste and smell.",
+		DescriptionAR: "تبدأ النهايات العصبية التالفة بالتجدد، فتصبح حاستا التذوق والشم أقوى.",
+		Category:      repository.MilestoneCategoryShort,
+		AfterMinutes:  2 * minutesPerDay,
+	},
+	{
+		Key:           "circulation",
+		TitleEN:       "Circulation Improved",
+		TitleAR:       "تحسّن الدورة الدموية",
+		DescriptionEN: "Blood circulation improves throughout your body.",
+		DescriptionAR: "تتحسن الدورة الدموية في جميع أنحاء جسمك.",
+		Category:      repository.MilestoneCategoryShort,
+		AfterMinutes:  14 * minutesPerDay,
+	},
+	{
+		Key:           "lung_function",
+		TitleEN:       "Lung Function Boost",
+		TitleAR:       "تحسّن وظائف الرئة",
+		DescriptionEN: "Coughing and shortness of breath decrease as your lungs recover.",
+		DescriptionAR: "يقل السعال وضيق التنفس مع تعافي رئتيك.",
+		Category:      repository.MilestoneCategoryMedium,
+		AfterMinutes:  30 * minutesPerDay,
+	},
+	{
+		Key:           "heart_disease_risk",
+		TitleEN:       "Heart Disease Risk Halved",
+		TitleAR:       "انخفاض خطر أمراض القلب إلى النصف",
+		DescriptionEN: "Your risk of coronary heart disease is about half that of a smoker.",
+		DescriptionAR: "يصبح خطر إصابتك بأمراض القلب التاجية نحو نصف خطرها لدى المدخن.",
+		Category:      repository.MilestoneCategoryLong,
+		AfterMinutes:  365 * minutesPerDay,
+	},
+	{
+		Key:           "stroke_risk",
+		TitleEN:       "Stroke Risk Reduced",
+		TitleAR:       "انخفاض خطر السكتة الدماغية",
+		DescriptionEN: "Your risk of stroke falls to that of a non-smoker.",
+		DescriptionAR: "ينخفض خطر إصابتك بالسكتة الدماغية إلى مستواه لدى غير المدخن.",
+		Category:      repository.MilestoneCategoryLong,
+		AfterMinutes:  5 * 365 * minutesPerDay,
+	},
+}
+
+var (
+	ErrInvalidQuitDate   = errors.New("invalid quit date")
+	ErrMilestoneNotFound = errors.New("milestone not found")
+	ErrMilestoneKeyTaken = errors.New("milestone key already in use")
+	ErrInvalidMilestone  = errors.New("invalid milestone")
+)
+
+var milestoneCategories = []repository.MilestoneCategory{
+	repository.MilestoneCategoryImmediate,
+	repository.MilestoneCategoryShort,
+	repository.MilestoneCategoryMedium,
+	repository.MilestoneCategoryLong,
+}
+
+// MilestoneInput is an admin's version of a milestone. Key is only used when creating one;
+// if it is empty, it is derived from the English title.
+type MilestoneInput struct {
+	Key           string
+	TitleEN       string
+	TitleAR       string
+	DescriptionEN string
+	DescriptionAR string
+	Category      repository.MilestoneCategory
+	AfterMinutes  int
+	Active        bool
+}
+
+// SetQuitDate records when the user stopped smoking. It may be up to maxQuitDateLead ahead,
+// for patients planning their quit date.
+func (s *Service) SetQuitDate(ctx context.Context, userID uuid.UUID, quitDate time.Time) error {
+	if quitDate.After(time.Now().Add(maxQuitDateLead)) {
+		return fmt.Errorf("%w: it can be at most %d days ahead", ErrInvalidQuitDate, int(maxQuitDateLead.Hours()/24))
+	}
+	if err := s.repository.UpdateUserQuitDate(ctx, userID, quitDate); err != nil {
+		return fmt.Errorf("setQuitDate :: updateUserQuitDate: %w", err)
+	}
+	return nil
+}
+
+// GetMilestones returns the organization's active milestones in lang and where the user
+// stands on each, counted from their quit date.
+func (s *Service) GetMilestones(ctx context.Context, userID uuid.UUID, orgID uuid.UUID, lang string) (*dto.MilestoneTimeline, error) {
+	user, err := s.repository.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("getMilestones :: getUserByID: %w", err)
+	}
+	definitions, err := s.organizationMilestones(ctx, orgID, true)
+	if err != nil {
+		return nil, err
+	}
+
+	return &dto.MilestoneTimeline{
+		QuitDate:   user.QuitDate,
+		Milestones: buildMilestones(definitions, user.QuitDate, time.Now(), lang),
+	}, nil
+}
+
+// GetOrganizationMilestones returns the whole catalogue, inactive milestones included, for
+// admins to edit.
+func (s *Service) GetOrganizationMilestones(ctx context.Context, orgID uuid.UUID) ([]repository.MilestoneDefinition, error) {
+	return s.organizationMilestones(ctx, orgID, false)
+}
+
+func (s *Service) CreateMilestone(ctx context.Context, orgID uuid.UUID, input MilestoneInput) (*repository.MilestoneDefinition, error) {
+	key := input.Key
+	if key == "" {
+		key = catalogueKey(input.TitleEN)
+	}
+	if !validCatalogueKey(key) {
+		return nil, fmt.Errorf("%w: key must be lower-case letters, digits and underscores", ErrInvalidMilestone)
+	}
+	if err := validateMilestoneInput(input); err != nil {
+		return nil, err
+	}
+	// Seed first, so that the defaults are not added on top of the organization's own
+	// catalogue later.
+	if _, err := s.organizationMilestones(ctx, orgID, false); err != nil {
+		return nil, err
+	}
+
+	milestone := &repository.MilestoneDefinition{
+		BaseModel:      repository.BaseModel{ID: uuid.New()},
+		OrganizationID: orgID,
+		Key:            key,
+	}
+	applyMilestoneInput(milestone, input)
+	err := s.repository.CreateMilestoneDefinition(ctx, milestone)
+	if errors.Is(err, repository.ErrDuplicate) {
+		return nil, ErrMilestoneKeyTaken
+	}
+	if err != nil {
+		return nil, fmt.Errorf("createMilestone :: createMilestoneDefinition: %w", err)
+	}
+	return milestone, nil
+}
+
+func (s *Service) UpdateMilestone(ctx context.Context, orgID uuid.UUID, id string, input MilestoneInput) (*repository.MilestoneDefinition, error) {
+	if err := validateMilestoneInput(input); err != nil {
+		return nil, err
+	}
+	milestoneID, err := uuid.Parse(id)
+	if err != nil {
+		return nil, ErrMilestoneNotFound
+	}
+	milestone, err := s.repository.GetMilestoneDefinitionByID(ctx, orgID, milestoneID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrMilestoneNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("updateMilestone :: getMilestoneDefinitionByID: %w", err)
+	}
+
+	applyMilestoneInput(milestone, input)
+	if err := s.repository.UpdateMilestoneDefinition(ctx, milestone); err != nil {
+		return nil, fmt.Errorf("updateMilestone :: updateMilestoneDefinition: %w", err)
+	}
+	return milestone, nil
+}
+
+func (s *Service) DeleteMilestone(ctx context.Context, orgID uuid.UUID, id string) error {
+	milestoneID, err := uuid.Parse(id)
+	if err != nil {
+		return ErrMilestoneNotFound
+	}
+
+	err = s.repository.SoftDeleteMilestoneDefinition(ctx, orgID, milestoneID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return ErrMilestoneNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("deleteMilestone :: softDeleteMilestoneDefinition: %w", err)
+	}
+	return nil
+}
+
+// organizationMilestones returns the organization's catalogue, seeding it with
+// defaultMilestones the first time. An organization whose admins deleted every milestone
+// is not seeded again.
+func (s *Service) organizationMilestones(ctx context.Context, orgID uuid.UUID, activeOnly bool) ([]repository.MilestoneDefinition, error) {
+	total, err := s.repository.CountMilestoneDefinitions(ctx, orgID)
+	if err != nil {
+		return nil, fmt.Errorf("organizationMilestones :: countMilestoneDefinitions: %w", err)
+	}
+	if total == 0 {
+		seed := make([]*repository.MilestoneDefinition, len(defaultMilestones))
+		for i, milestone := range defaultMilestones {
+			milestone.ID = uuid.New()
+			milestone.OrganizationID = orgID
+			milestone.Active = true
+			seed[i] = &milestone
+		}
+		if err := s.repository.SeedMilestoneDefinitions(ctx, seed); err != nil {
+			return nil, fmt.Errorf("organizationMilestones :: seedMilestoneDefinitions: %w", err)
+		}
+	}
+
+	milestones, err := s.repository.GetMilestoneDefinitions(ctx, orgID, activeOnly)
+	if err != nil {
+		return nil, fmt.Errorf("organizationMilestones :: getMilestoneDefinitions: %w", err)
+	}
+	return milestones, nil
+}
+
+func validateMilestoneInput(input MilestoneInput) error {
+	if input.AfterMinutes <= 0 {
+		return fmt.Errorf("%w: afterMinutes must be positive", ErrInvalidMilestone)
+	}
+	for _, category := range milestoneCategories {
+		if input.Category == category {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: unknown category %q", ErrInvalidMilestone, input.Category)
+}
+
+func applyMilestoneInput(milestone *repository.MilestoneDefinition, input MilestoneInput) {
+	milestone.TitleEN = strings.TrimSpace(input.TitleEN)
+	milestone.TitleAR = strings.TrimSpace(input.TitleAR)
+	milestone.DescriptionEN = strings.TrimSpace(input.DescriptionEN)
+	milestone.DescriptionAR = strings.TrimSpace(input.DescriptionAR)
+	milestone.Category = input.Category
+	milestone.AfterMinutes = input.AfterMinutes
+	milestone.Active = input.Active
+}
+
+// buildMilestones works out, as of now, which milestones a user who quit at quitDate has
+// reached and how far along they are with the rest. Without a quit date nothing is reached.
+func buildMilestones(definitions []repository.MilestoneDefinition, quitDate *time.Time, now time.Time, lang string) []dto.Milestone {
+	milestones := make([]dto.Milestone, len(definitions))
+	for i, definition := range definitions {
+		after := time.Duration(definition.AfterMinutes) * time.Minute
+		milestone := dto.Milestone{
+			Key:              definition.Key,
+			Title:            definition.TitleEN,
+			Description:      definition.DescriptionEN,
+			Category:         string(definition.Category),
+			AfterMinutes:     definition.AfterMinutes,
+			RemainingSeconds: int64(after.Seconds()),
+		}
+		if lang != "en" {
+			milestone.Title = orText(definition.TitleAR, milestone.Title)
+			milestone.Description = orText(definition.DescriptionAR, milestone.Description)
+		}
+
+		if quitDate != nil {
+			reachedAt := quitDate.Add(after)
+			elapsed := now.Sub(*quitDate)
+			switch {
+			case !now.Before(reachedAt):
+				milestone.Achieved = true
+				milestone.AchievedAt = &reachedAt
+				milestone.RemainingSeconds = 0
+				milestone.Percentage = 100
+			case elapsed > 0:
+				milestone.RemainingSeconds = int64(reachedAt.Sub(now).Seconds())
+				milestone.Percentage = int(100 * elapsed.Seconds() / after.Seconds())
+			default:
+				milestone.RemainingSeconds = int64(reachedAt.Sub(now).Seconds())
+			}
+		}
+		milestones[i] = milestone
+	}
+	return milestones
+}
@@ -0,0 +1,729 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/netip"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"patient-chatbot/internal/client/notify"
+	"patient-chatbot/internal/repository"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	notificationLease      = 2 * time.Minute
+	notificationRetryDelay = time.Minute
+	// notificationHorizon is how far ahead each planning run schedules notifications.
+	notificationHorizon     = 24 * time.Hour
+	notificationPlanBatch   = 200
+	appointmentReminderLead = 24 * time.Hour
+	// checkInMinute and streakNudgeMinute are minutes after local midnight.
+	checkInMinute     = 9 * 60
+	streakNudgeMinute = 20 * 60
+	// defaultNotificationLanguage matches the API's default locale.
+	defaultNotificationLanguage = "ar"
+)
+
+var (
+	ErrInvalidNotificationPreferences = errors.New("invalid notification preferences")
+	ErrNotificationWebhookNotFound    = errors.New("notification webhook not found")
+	ErrInvalidNotificationWebhook     = errors.New("invalid notification webhook")
+
+	// errNotificationNotApplicable means a scheduled notification should no longer be sent,
+	// e.g. because the appointment was cancelled. The job is skipped, not failed.
+	errNotificationNotApplicable = errors.New("notification no longer applies")
+)
+
+// NotificationPreferencesInput replaces a patient's preferences. Quiet hours are "HH:MM" in
+// the patient's timezone; both are empty when there are none.
+type NotificationPreferencesInput struct {
+	Timezone             string
+	Language             string
+	QuietHoursStart      string
+	QuietHoursEnd        string
+	EmailEnabled         bool
+	AppointmentReminders bool
+	DailyCheckIns        bool
+	MilestoneAlerts      bool
+	StreakNudges         bool
+}
+
+// NotificationWebhookInput configures an organization's webhook. An empty Secret keeps the
+// stored one.
+type NotificationWebhookInput struct {
+	URL     string
+	Secret  string
+	Enabled bool
+}
+
+// GetNotificationPreferences returns the user's preferences, or the defaults in lang if they
+// have not saved any.
+func (s *Service) GetNotificationPreferences(ctx context.Context, userID uuid.UUID, lang string) (*repository.NotificationPreferences, error) {
+	preferences, err := s.repository.GetNotificationPreferences(ctx, userID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return defaultNotificationPreferences(userID, lang), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("getNotificationPreferences :: getNotificationPreferences: %w", err)
+	}
+	return preferences, nil
+}
+
+func (s *Service) SetNotificationPreferences(ctx context.Context, userID uuid.UUID, input NotificationPreferencesInput) (*repository.NotificationPreferences, error) {
+	timezone := strings.TrimSpace(input.Timezone)
+	if _, err := time.LoadLocation(timezone); err != nil || timezone == "" {
+		return nil, fmt.Errorf("%w: unknown timezone %q", ErrInvalidNotificationPreferences, input.Timezone)
+	}
+	if input.Language != "en" && input.Language != "ar" {
+		return nil, fmt.Errorf("%w: language must be en or ar", ErrInvalidNotificationPreferences)
+	}
+	if (input.QuietHoursStart == "") != (input.QuietHoursEnd == "") {
+		return nil, fmt.Errorf("%w: set both ends of the quiet hours or neither", ErrInvalidNotificationPreferences)
+	}
+	var quietStart, quietEnd *int
+	if input.QuietHoursStart != "" {
+		start, err := parseClock(input.QuietHoursStart)
+		if err != nil {
+			return nil, err
+		}
+		end, err := parseClock(input.QuietHoursEnd)
+		if err != nil {
+			return nil, err
+		}
+		if start != end {
+			quietStart, quietEnd = &start, &end
+		}
+	}
+
+	preferences, err := s.repository.GetNotificationPreferences(ctx, userID)
+	if errors.Is(err, repository.ErrNotFound) {
+		preferences = &repository.NotificationPreferences{
+			BaseModel: repository.BaseModel{ID: uuid.New()},
+			UserID:    userID,
+		}
+	} else if err != nil {
+		return nil, fmt.Errorf("setNotificationPreferences :: getNotificationPreferences: %w", err)
+	}
+
+	preferences.Timezone = timezone
+	preferences.Language = input.Language
+	preferences.QuietHoursStart = quietStart
+	preferences.QuietHoursEnd = quietEnd
+	preferences.EmailEnabled = input.EmailEnabled
+	preferences.AppointmentReminders = input.AppointmentReminders
+	preferences.DailyCheckIns = input.DailyCheckIns
+	preferences.MilestoneAlerts = input.MilestoneAlerts
+	preferences.StreakNudges = input.StreakNudges
+	if err := s.repository.SaveNotificationPreferences(ctx, preferences); err != nil {
+		return nil, fmt.Errorf("setNotificationPreferences :: saveNotificationPreferences: %w", err)
+	}
+	return preferences, nil
+}
+
+func (s *Service) GetNotificationWebhook(ctx context.Context, orgID uuid.UUID) (*repository.NotificationWebhook, error) {
+	webhook, err := s.repository.GetNotificationWebhook(ctx, orgID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrNotificationWebhookNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("getNotificationWebhook :: getNotificationWebhook: %w", err)
+	}
+	return webhook, nil
+}
+
+// SetNotificationWebhook creates or replaces the organization's webhook. Every notification
+// to the organization's patients is posted to it, signed with the secret.
+func (s *Service) SetNotificationWebhook(ctx context.Context, orgID uuid.UUID, input NotificationWebhookInput) (*repository.NotificationWebhook, error) {
+	webhookURL := strings.TrimSpace(input.URL)
+	parsed, err := url.Parse(webhookURL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return nil, fmt.Errorf("%w: url must be an http(s) URL", ErrInvalidNotificationWebhook)
+	}
+	// Hostnames are checked when the webhook is called, once they are resolved.
+	if addr, err := netip.ParseAddr(parsed.Hostname()); err == nil && !s.egress.Allows(addr) {
+		return nil, fmt.Errorf("%w: url must not point at an internal address", ErrInvalidNotificationWebhook)
+	}
+	sealer, err := s.credentialSealer()
+	if err != nil {
+		return nil, err
+	}
+
+	webhook, err := s.repository.GetNotificationWebhook(ctx, orgID)
+	if errors.Is(err, repository.ErrNotFound) {
+		webhook = &repository.NotificationWebhook{
+			BaseModel:      repository.BaseModel{ID: uuid.New()},
+			OrganizationID: orgID,
+		}
+	} else if err != nil {
+		return nil, fmt.Errorf("setNotificationWebhook :: getNotificationWebhook: %w", err)
+	}
+
+	if input.Secret != "" {
+		webhook.SecretCiphertext, err = sealer.Seal(input.Secret)
+		if err != nil {
+			return nil, fmt.Errorf("setNotificationWebhook :: seal: %w", err)
+		}
+	} else if webhook.SecretCiphertext == "" {
+		return nil, fmt.Errorf("%w: secret is required", ErrInvalidNotificationWebhook)
+	}
+	webhook.URL = webhookURL
+	webhook.Enabled = input.Enabled
+	if err := s.repository.SaveNotificationWebhook(ctx, webhook); err != nil {
+		return nil, fmt.Errorf("setNotificationWebhook :: saveNotificationWebhook: %w", err)
+	}
+	return webhook, nil
+}
+
+func (s *Service) DeleteNotificationWebhook(ctx context.Context, orgID uuid.UUID) error {
+	err := s.repository.SoftDeleteNotificationWebhook(ctx, orgID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return ErrNotificationWebhookNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("deleteNotificationWebhook :: softDeleteNotificationWebhook: %w", err)
+	}
+	return nil
+}
+
+// RunNotificationScheduler plans notifications every NotificationPlanInterval and sends the
+// due ones until ctx is done. Several servers may run it at once: planning is idempotent and
+// each job is claimed by one of them.
+func (s *Service) RunNotificationScheduler(ctx context.Context) {
+	plan := time.NewTicker(s.cfg.NotificationPlanInterval)
+	defer plan.Stop()
+	poll := time.NewTicker(s.cfg.NotificationPollInterval)
+	defer poll.Stop()
+
+	s.planNotifications(ctx)
+	for {
+		for s.ProcessNextNotificationJob(ctx) {
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-plan.C:
+			s.planNotifications(ctx)
+		case <-poll.C:
+		}
+	}
+}
+
+func (s *Service) planNotifications(ctx context.Context) {
+	if err := s.PlanNotifications(ctx, time.Now()); err != nil {
+		log.Error().Msg("planNotifications :: " + err.Error())
+	}
+}
+
+// PlanNotifications schedules the notifications falling due within notificationHorizon of
+// now: reminders a day before appointments, the morning check-in, the evening streak nudge
+// and milestone congratulations. Every notification has a dedupe key, so planning again only
+// adds what is new. Kinds the patient has turned off are not scheduled.
+func (s *Service) PlanNotifications(ctx context.Context, now time.Time) error {
+	// Look back one planning interval, so an occurrence is not lost when a run is late.
+	from := now.Add(-s.cfg.NotificationPlanInterval)
+	to := now.Add(notificationHorizon)
+
+	appointments, err := s.repository.GetAppointmentsStartingBetween(ctx, now, to.Add(appointmentReminderLead))
+	if err != nil {
+		return fmt.Errorf("planNotifications :: getAppointmentsStartingBetween: %w", err)
+	}
+	reminders := make(map[uuid.UUID][]*repository.NotificationJob)
+	for _, appointment := range appointments {
+		runAt := appointment.StartsAt.Add(-appointmentReminderLead)
+		reminders[appointment.UserID] = append(reminders[appointment.UserID], &repository.NotificationJob{
+			Kind:      repository.NotificationKindAppointmentReminder,
+			DedupeKey: fmt.Sprintf("appointment_reminder:%s:%d", appointment.ID, appointment.StartsAt.Unix()),
+			Reference: appointment.ID.String(),
+			EventAt:   appointment.StartsAt,
+			RunAt:     later(runAt, now),
+		})
+	}
+
+	milestones := make(map[uuid.UUID][]repository.MilestoneDefinition)
+	afterID := uuid.Nil
+	for {
+		patients, err := s.repository.GetPatients(ctx, afterID, notificationPlanBatch)
+		if err != nil {
+			return fmt.Errorf("planNotifications :: getPatients: %w", err)
+		}
+		if len(patients) == 0 {
+			return nil
+		}
+		userIDs := make([]uuid.UUID, len(patients))
+		for i, patient := range patients {
+			userIDs[i] = patient.ID
+		}
+		saved, err := s.repository.GetNotificationPreferencesByUserIDs(ctx, userIDs)
+		if err != nil {
+			return fmt.Errorf("planNotifications :: getNotificationPreferencesByUserIDs: %w", err)
+		}
+
+		var jobs []*repository.NotificationJob
+		for i := range patients {
+			patient := &patients[i]
+			preferences, ok := saved[patient.ID]
+			if !ok {
+				preferences = *defaultNotificationPreferences(patient.ID, defaultNotificationLanguage)
+			}
+			if patient.QuitDate != nil && preferences.MilestoneAlerts {
+				if _, ok := milestones[patient.OrganizationID]; !ok {
+					milestones[patient.OrganizationID], err = s.organizationMilestones(ctx, patient.OrganizationID, true)
+					if err != nil {
+						return err
+					}
+				}
+			}
+
+			patientJobs := planPatientNotifications(patient, &preferences, milestones[patient.OrganizationID], from, to)
+			if preferences.AppointmentReminders {
+				patientJobs = append(patientJobs, reminders[patient.ID]...)
+			}
+			for _, job := range patientJobs {
+				job.ID = uuid.New()
+				job.OrganizationID = patient.OrganizationID
+				job.UserID = patient.ID
+				job.Status = repository.NotificationStatusPending
+			}
+			jobs = append(jobs, patientJobs...)
+		}
+		if err := s.repository.CreateNotificationJobs(ctx, jobs); err != nil {
+			return fmt.Errorf("planNotifications :: createNotificationJobs: %w", err)
+		}
+		afterID = patients[len(patients)-1].ID
+	}
+}
+
+// planPatientNotifications returns the patient's check-ins, streak nudges and milestones that
+// fall in (from, to]. Check-ins and nudges happen at fixed local times.
+func planPatientNotifications(patient *repository.User, preferences *repository.NotificationPreferences, milestones []repository.MilestoneDefinition, from time.Time, to time.Time) []*repository.NotificationJob {
+	loc := preferencesLocation(preferences)
+	var jobs []*repository.NotificationJob
+	if preferences.DailyCheckIns {
+		for _, at := range dailyOccurrences(checkInMinute, loc, from, to) {
+			jobs = append(jobs, &repository.NotificationJob{
+				Kind:      repository.NotificationKindDailyCheckIn,
+				DedupeKey: fmt.Sprintf("daily_check_in:%s:%s", patient.ID, at.Format(time.DateOnly)),
+				Reference: at.AddDate(0, 0, -1).Format(time.DateOnly),
+				EventAt:   at,
+				RunAt:     at,
+			})
+		}
+	}
+	if preferences.StreakNudges {
+		for _, at := range dailyOccurrences(streakNudgeMinute, loc, from, to) {
+			jobs = append(jobs, &repository.NotificationJob{
+				Kind:      repository.NotificationKindStreakAtRisk,
+				DedupeKey: fmt.Sprintf("streak_at_risk:%s:%s", patient.ID, at.Format(time.DateOnly)),
+				Reference: at.Format(time.DateOnly),
+				EventAt:   at,
+				RunAt:     at,
+			})
+		}
+	}
+	if preferences.MilestoneAlerts && patient.QuitDate != nil {
+		for _, milestone := range milestones {
+			reachedAt := patient.QuitDate.Add(time.Duration(milestone.AfterMinutes) * time.Minute)
+			if !reachedAt.After(from) || reachedAt.After(to) {
+				continue
+			}
+			jobs = append(jobs, &repository.NotificationJob{
+				Kind:      repository.NotificationKindMilestone,
+				DedupeKey: fmt.Sprintf("milestone:%s:%s:%d", patient.ID, milestone.Key, patient.QuitDate.Unix()),
+				Reference: milestone.Key,
+				EventAt:   reachedAt,
+				RunAt:     reachedAt,
+			})
+		}
+	}
+	return jobs
+}
+
+// ProcessNextNotificationJob claims and handles one due notification, reporting whether there
+// was one.
+func (s *Service) ProcessNextNotificationJob(ctx context.Context) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+
+	job, err := s.repository.ClaimNotificationJob(ctx, notificationLease)
+	if errors.Is(err, repository.ErrNotFound) {
+		return false
+	}
+	if err != nil {
+		log.Error().Msg("processNextNotificationJob :: claimNotificationJob: " + err.Error())
+		return false
+	}
+
+	jobCtx, cancel := context.WithTimeout(ctx, notificationLease)
+	defer cancel()
+
+	deferUntil, err := s.deliverNotification(jobCtx, job, time.Now())
+	switch {
+	case errors.Is(err, errNotificationNotApplicable):
+		reason := err.Error()
+		err = s.repository.FinishNotificationJob(ctx, job.ID, repository.NotificationStatusSkipped, &reason)
+	case err != nil:
+		s.failNotification(ctx, job, err)
+		return true
+	case deferUntil != nil:
+		err = s.repository.DeferNotificationJob(ctx, job.ID, *deferUntil)
+	default:
+		err = s.repository.FinishNotificationJob(ctx, job.ID, repository.NotificationStatusSent, nil)
+	}
+	if err != nil {
+		log.Error().Msg("processNextNotificationJob :: " + err.Error())
+	}
+	return true
+}
+
+// deliverNotification sends job through the patient's channels. It returns the end of the
+// patient's quiet hours instead when now falls within them, and an error wrapping
+// errNotificationNotApplicable when the notification should be dropped.
+func (s *Service) deliverNotification(ctx context.Context, job *repository.NotificationJob, now time.Time) (*time.Time, error) {
+	user, err := s.repository.GetUserByID(ctx, job.UserID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, fmt.Errorf("%w: user was deleted", errNotificationNotApplicable)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("deliverNotification :: getUserByID: %w", err)
+	}
+	preferences, err := s.GetNotificationPreferences(ctx, user.ID, defaultNotificationLanguage)
+	if err != nil {
+		return nil, err
+	}
+	if !notificationEnabled(preferences, job.Kind) {
+		return nil, fmt.Errorf("%w: turned off by the user", errNotificationNotApplicable)
+	}
+	if now.After(notificationExpiry(job)) {
+		return nil, fmt.Errorf("%w: expired", errNotificationNotApplicable)
+	}
+	loc := preferencesLocation(preferences)
+	if until := quietHoursEnd(preferences, now.In(loc)); until != nil {
+		return until, nil
+	}
+
+	message, err := s.notificationMessage(ctx, job, user, loc, preferences.Language)
+	if err != nil {
+		return nil, err
+	}
+	channels, err := s.notificationChannels(ctx, user, preferences)
+	if err != nil {
+		return nil, err
+	}
+	if len(channels) == 0 {
+		return nil, fmt.Errorf("%w: no channel is configured", errNotificationNotApplicable)
+	}
+
+	message.ID = job.ID.String()
+	message.Kind = string(job.Kind)
+	message.OrganizationID = job.OrganizationID.String()
+	message.UserID = user.ID.String()
+	message.Email = user.Email
+	message.Lang = preferences.Language
+	message.SentAt = now
+	// Each channel that takes the message is recorded on the job straight away, so a retry
+	// after another channel failed does not send it twice, e.g. the same email while the
+	// webhook is down.
+	var errs []error
+	for _, channel := range channels {
+		if slices.Contains(job.DeliveredChannels, channel.Name()) {
+			continue
+		}
+		if err := channel.Send(ctx, *message); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", channel.Name(), err))
+			continue
+		}
+		if err := s.repository.AddNotificationDeliveredChannel(ctx, job.ID, channel.Name()); err != nil {
+			errs = append(errs, fmt.Errorf("deliverNotification :: addNotificationDeliveredChannel: %w", err))
+		}
+	}
+	return nil, errors.Join(errs...)
+}
+
+// notificationChannels returns where the user's notifications go: email, if configured and
+// the user wants it, and the organization's webhook.
+func (s *Service) notificationChannels(ctx context.Context, user *repository.User, preferences *repository.NotificationPreferences) ([]notify.Channel, error) {
+	var channels []notify.Channel
+	if s.email != nil && preferences.EmailEnabled {
+		channels = append(channels, s.email)
+	}
+
+	webhook, err := s.repository.GetNotificationWebhook(ctx, user.OrganizationID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return channels, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("notificationChannels :: getNotificationWebhook: %w", err)
+	}
+	if !webhook.Enabled {
+		return channels, nil
+	}
+	sealer, err := s.credentialSealer()
+	if err != nil {
+		return nil, err
+	}
+	secret, err := sealer.Open(webhook.SecretCiphertext)
+	if err != nil {
+		return nil, fmt.Errorf("notificationChannels :: open: %w", err)
+	}
+	return append(channels, notify.NewWebhookChannel(webhook.URL, secret, s.webhookHTTP)), nil
+}
+
+// notificationMessage writes the notification in lang, checking first that it still
+// applies: the appointment is still on, the day is not logged yet, and so on.
+func (s *Service) notificationMessage(ctx context.Context, job *repository.NotificationJob, user *repository.User, loc *time.Location, lang string) (*notify.Message, error) {
+	switch job.Kind {
+	case repository.NotificationKindAppointmentReminder:
+		appointmentID, err := uuid.Parse(job.Reference)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid appointment reference", errNotificationNotApplicable)
+		}
+		appointment, err := s.repository.GetAppointmentByID(ctx, user.ID, appointmentID)
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, fmt.Errorf("%w: appointment was deleted", errNotificationNotApplicable)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("notificationMessage :: getAppointmentByID: %w", err)
+		}
+		if appointment.Status != repository.AppointmentStatusScheduled || !appointment.StartsAt.Equal(job.EventAt) {
+			return nil, fmt.Errorf("%w: appointment was cancelled or moved", errNotificationNotApplicable)
+		}
+		return appointmentReminderMessage(appointment, loc, lang), nil
+
+	case repository.NotificationKindDailyCheckIn:
+		logged, err := s.repository.HasProgressEvent(ctx, user.ID, job.Reference)
+		if err != nil {
+			return nil, fmt.Errorf("notificationMessage :: hasProgressEvent: %w", err)
+		}
+		if logged {
+			return nil, fmt.Errorf("%w: day already logged", errNotificationNotApplicable)
+		}
+		return dailyCheckInMessage(job.Reference, lang), nil
+
+	case repository.NotificationKindStreakAtRisk:
+		logged, err := s.repository.HasProgressEvent(ctx, user.ID, job.Reference)
+		if err != nil {
+			return nil, fmt.Errorf("notificationMessage :: hasProgressEvent: %w", err)
+		}
+		if logged {
+			return nil, fmt.Errorf("%w: day already logged", errNotificationNotApplicable)
+		}
+		dashboard, err := s.GetDashboardData(ctx, user.ID)
+		if err != nil {
+			return nil, err
+		}
+		if dashboard.StreakDaysSmokeFree == 0 {
+			return nil, fmt.Errorf("%w: no streak to keep", errNotificationNotApplicable)
+		}
+		return streakAtRiskMessage(dashboard.StreakDaysSmokeFree, job.Reference, lang), nil
+
+	case repository.NotificationKindMilestone:
+		definitions, err := s.organizationMilestones(ctx, user.OrganizationID, true)
+		if err != nil {
+			return nil, err
+		}
+		for _, definition := range definitions {
+			if definition.Key != job.Reference {
+				continue
+			}
+			if user.QuitDate == nil || !user.QuitDate.Add(time.Duration(definition.AfterMinutes)*time.Minute).Equal(job.EventAt) {
+				return nil, fmt.Errorf("%w: quit date or milestone changed", errNotificationNotApplicable)
+			}
+			return milestoneMessage(&definition, job.EventAt, lang), nil
+		}
+		return nil, fmt.Errorf("%w: milestone was removed", errNotificationNotApplicable)
+	}
+	return nil, fmt.Errorf("%w: unknown kind %q", errNotificationNotApplicable, job.Kind)
+}
+
+func (s *Service) failNotification(ctx context.Context, job *repository.NotificationJob, cause error) {
+	log.Warn().Msgf("notification %s to user %s failed (attempt %d): %s", job.Kind, job.UserID, job.Attempts, cause.Error())
+
+	var retryAt *time.Time
+	if job.Attempts < s.cfg.NotificationMaxAttempts && !errors.Is(cause, notify.ErrNoRecipient) {
+		at := time.Now().Add(time.Duration(job.Attempts) * notificationRetryDelay)
+		retryAt = &at
+	}
+
+	if err := s.repository.FailNotificationJob(ctx, job.ID, cause.Error(), retryAt); err != nil {
+		log.Error().Msg("failNotification :: failNotificationJob: " + err.Error())
+	}
+}
+
+func appointmentReminderMessage(appointment *repository.Appointment, loc *time.Location, lang string) *notify.Message {
+	startsAt := appointment.StartsAt.In(loc)
+	day, clock := startsAt.Format(time.DateOnly), startsAt.Format("15:04")
+	message := &notify.Message{
+		Subject: "Appointment reminder: " + appointment.Title,
+		Body:    fmt.Sprintf("You have an appointment with %s on %s at %s.", appointment.Clinician.Name, day, clock),
+		Data: map[string]interface{}{
+			"appointment_id": appointment.ID.String(),
+			"starts_at":      appointment.StartsAt,
+		},
+	}
+	if lang != "en" {
+		message.Subject = "تذكير بموعدك: " + appointment.Title
+		message.Body = fmt.Sprintf("لديك موعد مع %s يوم %s الساعة %s.", appointment.Clinician.Name, day, clock)
+	}
+	if appointment.Location != "" {
+		message.Body += "\n\n" + appointment.Location
+	}
+	return message
+}
+
+func dailyCheckInMessage(day string, lang string) *notify.Message {
+	message := &notify.Message{
+		Subject: "Were you smoke-free yesterday?",
+		Body:    "Good morning! Were you smoke-free yesterday? Open the app to log your day and keep your progress up to date.",
+		Data:    map[string]interface{}{"date": day},
+	}
+	if lang != "en" {
+		message.Subject = "هل كنت خالياً من التدخين أمس؟"
+		message.Body = "صباح الخير! هل كنت خالياً من التدخين أمس؟ افتح التطبيق لتسجيل يومك ومتابعة تقدمك."
+	}
+	return message
+}
+
+func streakAtRiskMessage(streak int, day string, lang string) *notify.Message {
+	message := &notify.Message{
+		Subject: fmt.Sprintf("Keep your %d-day streak going", streak),
+		Body:    fmt.Sprintf("You have been smoke-free for %d days in a row, but today is not logged yet. Take a moment to check in and protect your streak.", streak),
+		Data:    map[string]interface{}{"date": day, "streak_days": streak},
+	}
+	if lang != "en" {
+		message.Subject = fmt.Sprintf("حافظ على سلسلتك من %d يوماً", streak)
+		message.Body = fmt.Sprintf("أنت خالٍ من التدخين منذ %d يوماً متتالياً، لكنك لم تسجّل يومك اليوم بعد. خصص لحظة لتسجيله وحافظ على سلسلتك.", streak)
+	}
+	return message
+}
+
+func milestoneMessage(definition *repository.MilestoneDefinition, reachedAt time.Time, lang string) *notify.Message {
+	message := &notify.Message{
+		Subject: "Milestone reached: " + definition.TitleEN,
+		Body:    "Congratulations! " + definition.DescriptionEN + "\n\nKeep going, every smoke-free day counts.",
+		Data:    map[string]interface{}{"milestone_key": definition.Key, "reached_at": reachedAt},
+	}
+	if lang != "en" {
+		message.Subject = "إنجاز جديد: " + orText(definition.TitleAR, definition.TitleEN)
+		message.Body = "تهانينا! " + orText(definition.DescriptionAR, definition.DescriptionEN) + "\n\nواصل التقدم، فكل يوم بلا تدخين يُحتسب."
+	}
+	return message
+}
+
+// defaultNotificationPreferences apply until the user saves their own: everything on, in
+// UTC, with no quiet hours.
+func defaultNotificationPreferences(userID uuid.UUID, lang string) *repository.NotificationPreferences {
+	if lang != "en" {
+		lang = defaultNotificationLanguage
+	}
+	return &repository.NotificationPreferences{
+		UserID:               userID,
+		Timezone:             "UTC",
+		Language:             lang,
+		EmailEnabled:         true,
+		AppointmentReminders: true,
+		DailyCheckIns:        true,
+		MilestoneAlerts:      true,
+		StreakNudges:         true,
+	}
+}
+
+func notificationEnabled(preferences *repository.NotificationPreferences, kind repository.NotificationKind) bool {
+	switch kind {
+	case repository.NotificationKindAppointmentReminder:
+		return preferences.AppointmentReminders
+	case repository.NotificationKindDailyCheckIn:
+		return preferences.DailyCheckIns
+	case repository.NotificationKindMilestone:
+		return preferences.MilestoneAlerts
+	case repository.NotificationKindStreakAtRisk:
+		return preferences.StreakNudges
+	}
+	return false
+}
+
+// notificationExpiry is when a notification is too late to be worth sending, e.g. after
+// being held back by quiet hours or failing for a while.
+func notificationExpiry(job *repository.NotificationJob) time.Time {
+	switch job.Kind {
+	case repository.NotificationKindAppointmentReminder:
+		return job.EventAt
+	case repository.NotificationKindDailyCheckIn:
+		return job.EventAt.Add(12 * time.Hour)
+	case repository.NotificationKindStreakAtRisk:
+		return job.EventAt.Add(4 * time.Hour)
+	}
+	return job.EventAt.Add(7 * 24 * time.Hour)
+}
+
+// preferencesLocation returns the user's timezone. Timezones are checked when saved, so
+// falling back to UTC only happens if the tz database changes under us.
+func preferencesLocation(preferences *repository.NotificationPreferences) *time.Location {
+	loc, err := time.LoadLocation(preferences.Timezone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// quietHoursEnd returns when the user's quiet hours end if local time t falls within them,
+// and nil otherwise. Quiet hours may span midnight, e.g. 22:00 to 07:00.
+func quietHoursEnd(preferences *repository.NotificationPreferences, t time.Time) *time.Time {
+	if preferences.QuietHoursStart == nil || preferences.QuietHoursEnd == nil {
+		return nil
+	}
+	start, end := *preferences.QuietHoursStart, *preferences.QuietHoursEnd
+	minute := t.Hour()*60 + t.Minute()
+
+	var days int
+	switch {
+	case start < end && minute >= start && minute < end:
+		days = 0
+	case start > end && minute >= start:
+		days = 1
+	case start > end && minute < end:
+		days = 0
+	default:
+		return nil
+	}
+	until := time.Date(t.Year(), t.Month(), t.Day()+days, end/60, end%60, 0, 0, t.Location())
+	return &until
+}
+
+// dailyOccurrences returns the times at minute past local midnight in loc that fall in
+// (from, to].
+func dailyOccurrences(minute int, loc *time.Location, from time.Time, to time.Time) []time.Time {
+	local := from.In(loc)
+	var occurrences []time.Time
+	for day := 0; day <= 2; day++ {
+		at := time.Date(local.Year(), local.Month(), local.Day()+day, minute/60, minute%60, 0, 0, loc)
+		if at.After(from) && !at.After(to) {
+			occurrences = append(occurrences, at)
+		}
+	}
+	return occurrences
+}
+
+// parseClock parses "HH:MM" into minutes after midnight.
+func parseClock(clock string) (int, error) {
+	t, err := time.Parse("15:04", clock)
+	if err != nil {
+		return 0, fmt.Errorf("%w: times must be HH:MM", ErrInvalidNotificationPreferences)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+func later(a time.Time, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
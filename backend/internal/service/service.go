@@ -2,146 +2,282 @@ package service
 
 import (
 	"context"
-	"encoding/base64"
 	"fmt"
-	"io"
-	"mime/multipart"
 	"net/http"
 	"path/filepath"
+	"patient-chatbot/internal/auth"
+	"patient-chatbot/internal/chunker"
+	"patient-chatbot/internal/client/egress"
 	"patient-chatbot/internal/client/llm"
+	"patient-chatbot/internal/client/notify"
 	"patient-chatbot/internal/client/vectordb"
 	"patient-chatbot/internal/config"
 	"patient-chatbot/internal/dto"
 	"patient-chatbot/internal/repository"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/google/uuid"
-	"github.com/pinecone-io/go-pinecone/v4/pinecone"
+	"github.com/rs/zerolog/log"
 	"golang.org/x/sync/errgroup"
 )
 
 type Service struct {
-	cfg            *config.Config
-	llmClient      *llm.LLMClient
-	vectordbClient *vectordb.VectordbClient
-	repository     *repository.Repository
+	cfg         *config.Config
+	llmClient   llm.Client
+	vectorStore vectordb.VectorStore
+	repository  *repository.Repository
+	tokens      *auth.TokenManager
+	chunker     *chunker.Chunker
+	// sealer is nil unless CREDENTIALS_KEY is configured; use credentialSealer.
+	sealer *auth.Sealer
+	// email is nil unless SMTP is configured.
+	email notify.Channel
+	// egress refuses internal addresses that are not on EGRESS_ALLOWLIST. calendarHTTP and
+	// webhookHTTP reach tenants' CalDAV servers and webhooks through it.
+	egress       *egress.Guard
+	calendarHTTP *http.Client
+	webhookHTTP  *http.Client
+
+	// ingestionWake nudges an idle ingestion worker when a job is queued.
+	ingestionWake chan struct{}
+	// calendarSyncs tracks appointment syncs still running in the background.
+	calendarSyncs sync.WaitGroup
+	// calendarSyncLocks serialize the calendar writes for an appointment, striped by its ID.
+	calendarSyncLocks [32]sync.Mutex
 }
 
 func NewService(
 	cfg *config.Config,
-	llmClient *llm.LLMClient,
-	vectordbClient *vectordb.VectordbClient,
+	llmClient llm.Client,
+	vectorStore vectordb.VectorStore,
 	repository *repository.Repository,
+	tokens *auth.TokenManager,
 ) *Service {
-	return &Service{
-		cfg:            cfg,
-		llmClient:      llmClient,
-		vectordbClient: vectordbClient,
-		repository:     repository,
+	s := &Service{
+		cfg:         cfg,
+		llmClient:   llmClient,
+		vectorStore: vectorStore,
+		repository:  repository,
+		tokens:      tokens,
+		chunker:     chunker.New(chunker.Options{Size: cfg.ChunkSize, Overlap: cfg.ChunkOverlap}),
+
+		egress:        egress.NewGuard(cfg.EgressAllowlist),
+		ingestionWake: make(chan struct{}, 1),
+	}
+	s.calendarHTTP = s.egress.HTTPClient(cfg.CalendarTimeout)
+	s.webhookHTTP = s.egress.HTTPClient(cfg.NotificationTimeout)
+	if cfg.CredentialsKey != "" {
+		s.sealer = auth.NewSealer(cfg.CredentialsKey)
+	} else {
+		log.Warn().Msg("CREDENTIALS_KEY is not set: calendar integrations and notification webhooks cannot be configured or used")
+	}
+	if cfg.SMTPHost != "" {
+		email, err := notify.NewSMTPChannel(cfg.SMTPHost, cfg.SMTPPort, cfg.SMTPUsername, cfg.SMTPPassword, cfg.SMTPFrom, cfg.NotificationTimeout)
+		if err != nil {
+			log.Error().Msg("Failed to create email channel: " + err.Error())
+		} else {
+			s.email = email
+		}
 	}
+	return s
 }
 
-func (s *Service) Chat(ctx context.Context, messages []dto.Message, lang string) (string, error) {
-	chunks, err := s.vectordbClient.Search(ctx, messages[len(messages)-1].Content)
+// Chat answers the last message in messages. When conversationID is set, earlier turns are
+// loaded from the stored conversation instead of the request, and the new turn is persisted.
+func (s *Service) Chat(ctx context.Context, userID uuid.UUID, orgID uuid.UUID, conversationID *uuid.UUID, messages []dto.Message, lang string) (*dto.ChatAnswer, error) {
+	conversation, history, err := s.loadHistory(ctx, userID, conversationID, messages)
 	if err != nil {
-		return "", err
+		return nil, err
+	}
+
+	chunks, err := s.retrieveContext(ctx, orgID, history)
+	if err != nil {
+		return nil, err
 	}
 
-	chunksText := make([]string, len(chunks.Result.Hits))
-	for i, chunk := range chunks.Result.Hits {
-		chunksText[i] = chunk.Fields["chunk_text"].(string)
+	options, err := s.chatOptions(ctx, userID, orgID, chunkTexts(chunks), lang)
+	if err != nil {
+		return nil, err
 	}
 
-	if len(messages) > 50 {
-		messages = messages[len(messages)-50:]
+	degraded := false
+	result, err := s.llmClient.Chat(ctx, history, chunkTexts(chunks), lang, s.coachTools(userID, orgID, lang), options)
+	if report := savedChangesReport(result, lang); err != nil && report != "" {
+		log.Error().Msg("chat :: llm failed after saving changes, reporting them instead: " + err.Error())
+		result, err = &llm.ChatResult{Answer: strings.TrimSpace(result.Answer + "\n\n" + report)}, nil
+		// The request may have been cancelled; the turn is saved regardless.
+		ctx = context.WithoutCancel(ctx)
+	}
+	if llmUnavailable(err) {
+		log.Warn().Msg("chat :: llm unavailable, answering from retrieved context: " + err.Error())
+		result, degraded = degradedAnswer(chunks, lang), true
+	} else if err != nil {
+		return nil, err
 	}
 
-	response, err := s.llmClient.Chat(ctx, messages, chunksText, lang)
-	if err != nil {
-		return "", err
+	if conversation != nil {
+		if err := s.saveTurn(ctx, conversation, history[len(history)-1], result.Answer, chunks); err != nil {
+			return nil, err
+		}
 	}
 
-	return response, nil
+	return &dto.ChatAnswer{Answer: result.Answer, Sources: toSources(chunks), Degraded: degraded}, nil
 }
 
-func (s *Service) Upload(ctx context.Context, file *multipart.FileHeader) error {
-	f, err := file.Open()
+// ChatStream behaves like Chat but relays the answer to onDelta as it is generated.
+func (s *Service) ChatStream(ctx context.Context, userID uuid.UUID, orgID uuid.UUID, conversationID *uuid.UUID, messages []dto.Message, lang string, onDelta func(string) error) (*dto.ChatAnswer, error) {
+	conversation, history, err := s.loadHistory(ctx, userID, conversationID, messages)
 	if err != nil {
-		return fmt.Errorf("upload :: open file: %w", err)
+		return nil, err
 	}
-	defer f.Close()
-
-	buf := make([]byte, 512)
-	n, _ := f.Read(buf)
-	mimeType := http.DetectContentType(buf[:n])
-	isText := strings.HasPrefix(mimeType, "text/")
 
-	if _, err := f.Seek(0, io.SeekStart); err != nil {
-		return fmt.Errorf("upload :: seek: %w", err)
+	chunks, err := s.retrieveContext(ctx, orgID, history)
+	if err != nil {
+		return nil, err
 	}
-	data, err := io.ReadAll(f)
+
+	options, err := s.chatOptions(ctx, userID, orgID, chunkTexts(chunks), lang)
 	if err != nil {
-		return fmt.Errorf("upload :: read file: %w", err)
+		return nil, err
 	}
 
-	var payload string
-	if isText {
-		payload = string(data)
-	} else {
-		b64 := base64.StdEncoding.EncodeToString(data)
-		payload = fmt.Sprintf("data:%s;base64,%s", mimeType, b64)
+	streamed, degraded := false, false
+	result, err := s.llmClient.ChatStream(ctx, history, chunkTexts(chunks), lang, s.coachTools(userID, orgID, lang), options, func(delta string) error {
+		streamed = true
+		return onDelta(delta)
+	})
+	if report := savedChangesReport(result, lang); err != nil && report != "" {
+		log.Error().Msg("chatStream :: llm failed after saving changes, reporting them instead: " + err.Error())
+		delta := report
+		if streamed {
+			delta = "\n\n" + report
+		}
+		if err := onDelta(delta); err != nil {
+			log.Warn().Msg("chatStream :: could not relay the saved changes: " + err.Error())
+		}
+		result, err = &llm.ChatResult{Answer: strings.TrimSpace(result.Answer + "\n\n" + report)}, nil
+		// The request may have been cancelled; the turn is saved regardless.
+		ctx = context.WithoutCancel(ctx)
+	}
+	if !streamed && llmUnavailable(err) {
+		log.Warn().Msg("chatStream :: llm unavailable, answering from retrieved context: " + err.Error())
+		result, degraded = degradedAnswer(chunks, lang), true
+		if err := onDelta(result.Answer); err != nil {
+			return nil, err
+		}
+	} else if err != nil {
+		return nil, err
 	}
 
-	extractedText, err := s.llmClient.ExtractText(ctx, payload, isText)
+	if conversation != nil {
+		if err := s.saveTurn(ctx, conversation, history[len(history)-1], result.Answer, chunks); err != nil {
+			return nil, err
+		}
+	}
+
+	return &dto.ChatAnswer{Answer: result.Answer, Sources: toSources(chunks), Degraded: degraded}, nil
+}
+
+const snippetLength = 200
+
+type retrievedChunk struct {
+	ID            uuid.UUID
+	Text          string
+	Score         float32
+	DocumentID    uuid.UUID
+	DocumentTitle string
+	Category      string
+	Page          *int
+}
+
+// retrieveContext searches the organization's knowledge base and joins each hit with its
+// document. Hits whose chunk no longer exists in the database are dropped, so every chunk
+// handed to the model can be cited.
+func (s *Service) retrieveContext(ctx context.Context, orgID uuid.UUID, messages []dto.Message) ([]retrievedChunk, error) {
+	hits, err := s.vectorStore.Search(ctx, s.namespace(orgID), messages[len(messages)-1].Content, vectordb.SearchOptions{
+		TopK: 5,
+		TopN: 2,
+	})
 	if err != nil {
-		return fmt.Errorf("upload :: extractText: %w", err)
+		return nil, err
 	}
 
-	filename, ext := sanitizeFilename(file.Filename)
-	docId := uuid.New()
-	doc := &repository.Document{
-		BaseModel: repository.BaseModel{
-			ID: docId,
-		},
-		Title:     extractedText.Title,
-		Category:  extractedText.Category,
-		Path:      filename,
-		Extension: ext,
+	ids := make([]uuid.UUID, 0, len(hits))
+	for _, hit := range hits {
+		id, err := uuid.Parse(hit.ID)
+		if err != nil {
+			return nil, fmt.Errorf("retrieveContext :: parseChunkID: %w", err)
+		}
+		ids = append(ids, id)
 	}
 
-	err = s.repository.CreateDocument(ctx, doc)
+	stored, err := s.repository.GetChunksByIDs(ctx, orgID, ids)
 	if err != nil {
-		return fmt.Errorf("upload :: createDocument: %w", err)
+		return nil, fmt.Errorf("retrieveContext :: getChunksByIDs: %w", err)
+	}
+	storedByID := make(map[uuid.UUID]repository.Chunk, len(stored))
+	for _, chunk := range stored {
+		storedByID[chunk.ID] = chunk
 	}
 
-	records := make([]*pinecone.IntegratedRecord, len(extractedText.Chunks))
-	chunks := make([]*repository.Chunk, len(extractedText.Chunks))
-	for i, chunk := range extractedText.Chunks {
-		chunkId := uuid.New()
-		records[i] = &pinecone.IntegratedRecord{
-			"id":         chunkId,
-			"chunk_text": chunk,
-		}
-		chunks[i] = &repository.Chunk{
-			BaseModel: repository.BaseModel{
-				ID: chunkId,
-			},
-			Content:    chunk,
-			DocumentID: docId,
+	chunks := make([]retrievedChunk, 0, len(ids))
+	for i, hit := range hits {
+		chunk, ok := storedByID[ids[i]]
+		if !ok {
+			log.Warn().Msgf("retrieveContext :: chunk %s found in vector store but not in database", hit.ID)
+			continue
 		}
+		chunks = append(chunks, retrievedChunk{
+			ID:            chunk.ID,
+			Text:          chunk.Content,
+			Score:         hit.Score,
+			DocumentID:    chunk.DocumentID,
+			DocumentTitle: chunk.Document.Title,
+			Category:      chunk.Document.Category,
+			Page:          chunk.Page,
+		})
+	}
+	return chunks, nil
+}
+
+func chunkTexts(chunks []retrievedChunk) []string {
+	texts := make([]string, len(chunks))
+	for i, chunk := range chunks {
+		texts[i] = chunk.Text
 	}
+	return texts
+}
 
-	err = s.repository.CreateChunks(ctx, chunks)
-	if err != nil {
-		return fmt.Errorf("upload :: createChunks: %w", err)
+func toSources(chunks []retrievedChunk) []dto.Source {
+	sources := make([]dto.Source, len(chunks))
+	for i, chunk := range chunks {
+		sources[i] = dto.Source{
+			ChunkID:       chunk.ID.String(),
+			DocumentID:    chunk.DocumentID.String(),
+			DocumentTitle: chunk.DocumentTitle,
+			Category:      chunk.Category,
+			Page:          chunk.Page,
+			Score:         chunk.Score,
+			Snippet:       snippet(chunk.Text, snippetLength),
+		}
 	}
+	return sources
+}
 
-	err = s.vectordbClient.CreateChunks(ctx, records)
-	if err != nil {
-		return fmt.Errorf("upload :: createChunks: %w", err)
+// snippet shortens text to at most n runes, cutting at the last word boundary.
+func snippet(text string, n int) string {
+	text = strings.Join(strings.Fields(text), " ")
+	runes := []rune(text)
+	if len(runes) <= n {
+		return text
 	}
-	return nil
+	cut := string(runes[:n])
+	if i := strings.LastIndex(cut, " "); i > 0 {
+		cut = cut[:i]
+	}
+	return cut + "…"
 }
 
 func sanitizeFilename(filename string) (string, string) {
@@ -154,22 +290,22 @@ func sanitizeFilename(filename string) (string, string) {
 	return filename, ext
 }
 
-func (s *Service) GetDocuments(ctx context.Context, page int, pageSize int) ([]repository.Document, int, error) {
+func (s *Service) GetDocuments(ctx context.Context, orgID uuid.UUID, page int, pageSize int) ([]repository.Document, int, error) {
 	offset := (page - 1) * pageSize
-	documents, total, err := s.repository.GetAllDocuments(ctx, offset, pageSize)
+	documents, total, err := s.repository.GetAllDocuments(ctx, orgID, offset, pageSize)
 	if err != nil {
 		return nil, 0, fmt.Errorf("getDocuments :: getAllDocuments: %w", err)
 	}
 	return documents, total, nil
 }
 
-func (s *Service) DeleteDocument(ctx context.Context, id string) error {
+func (s *Service) DeleteDocument(ctx context.Context, orgID uuid.UUID, id string) error {
 	uuid, err := uuid.Parse(id)
 	if err != nil {
 		return fmt.Errorf("deleteDocument :: uuid.Parse: %w", err)
 	}
 
-	document, err := s.repository.GetDocumentByID(ctx, uuid)
+	document, err := s.repository.GetDocumentByID(ctx, orgID, uuid)
 	if err != nil {
 		return fmt.Errorf("deleteDocument :: GetDocumentByID: %w", err)
 	}
@@ -179,60 +315,64 @@ func (s *Service) DeleteDocument(ctx context.Context, id string) error {
 		chunksIds[i] = chunk.ID.String()
 	}
 
-	err = s.vectordbClient.DeleteChunks(ctx, chunksIds)
-	if err != nil {
-		return fmt.Errorf("deleteDocument :: DeleteChunks: %w", err)
-	}
-
-	err = s.repository.SoftDeleteDocumentAndChunks(ctx, uuid)
-	if err != nil {
-		err = s.RecoverChunks(ctx, document.Chunks)
-		if err != nil {
-			return fmt.Errorf("deleteDocument :: recoverChunks: %w", err)
-		}
-		return fmt.Errorf("deleteDocument :: softDeleteDocumentAndChunks: %w", err)
-	}
-	return nil
+	return s.deleteRecords(ctx, orgID, chunksIds, func() error {
+		return s.repository.SoftDeleteDocumentAndChunks(ctx, orgID, uuid)
+	})
 }
 
-func (s *Service) DeleteChunk(ctx context.Context, id string) error {
+func (s *Service) DeleteChunk(ctx context.Context, orgID uuid.UUID, id string) error {
 	uuid, err := uuid.Parse(id)
 	if err != nil {
 		return fmt.Errorf("deleteChunk :: uuid.Parse: %w", err)
 	}
 
-	chunk, err := s.repository.GetChunkByID(ctx, uuid)
+	_, err = s.repository.GetChunkByID(ctx, orgID, uuid)
 	if err != nil {
 		return fmt.Errorf("deleteChunk :: getChunkByID: %w", err)
 	}
 
-	err = s.vectordbClient.DeleteChunks(ctx, []string{id})
+	return s.deleteRecords(ctx, orgID, []string{id}, func() error {
+		return s.repository.SoftDeleteChunk(ctx, orgID, uuid)
+	})
+}
+
+// deleteRecords removes records from the vector store and then runs deleteRows against the
+// primary database. If deleteRows fails, the records are restored from a snapshot taken
+// before deletion so both stores stay consistent.
+func (s *Service) deleteRecords(ctx context.Context, orgID uuid.UUID, ids []string, deleteRows func() error) error {
+	namespace := s.namespace(orgID)
+
+	snapshot, err := s.vectorStore.Fetch(ctx, namespace, ids)
 	if err != nil {
-		return fmt.Errorf("deleteChunk :: deleteChunks: %w", err)
+		return fmt.Errorf("deleteRecords :: fetch: %w", err)
 	}
 
-	err = s.repository.SoftDeleteChunk(ctx, uuid)
+	err = s.vectorStore.Delete(ctx, namespace, ids)
 	if err != nil {
-		err = s.RecoverChunks(ctx, []repository.Chunk{*chunk})
-		if err != nil {
-			return fmt.Errorf("deleteChunk :: recoverChunks: %w", err)
-		}
-		return fmt.Errorf("deleteChunk :: softDeleteChunk: %w", err)
+		return fmt.Errorf("deleteRecords :: delete: %w", err)
 	}
 
+	err = deleteRows()
+	if err != nil {
+		if recoverErr := s.RecoverChunks(ctx, orgID, snapshot); recoverErr != nil {
+			return fmt.Errorf("deleteRecords :: recoverChunks: %w", recoverErr)
+		}
+		return fmt.Errorf("deleteRecords :: deleteRows: %w", err)
+	}
 	return nil
 }
 
-// RecoverChunks re-inserts chunks into Pinecone after a failed delete from the primary database.
-func (s *Service) RecoverChunks(ctx context.Context, chunks []repository.Chunk) error {
-	records := make([]*pinecone.IntegratedRecord, len(chunks))
-	for i, chunk := range chunks {
-		records[i] = &pinecone.IntegratedRecord{
-			"id":         chunk.ID,
-			"chunk_text": chunk.Content,
-		}
+// RecoverChunks re-inserts records into the vector store after a failed delete from the primary database.
+func (s *Service) RecoverChunks(ctx context.Context, orgID uuid.UUID, records []vectordb.Record) error {
+	if len(records) == 0 {
+		return nil
 	}
-	return s.vectordbClient.CreateChunks(ctx, records)
+	return s.vectorStore.Upsert(ctx, s.namespace(orgID), records)
+}
+
+// namespace returns the vector store namespace holding an organization's knowledge base.
+func (s *Service) namespace(orgID uuid.UUID) string {
+	return vectordb.Namespace(s.cfg.PineconeNamespace, orgID)
 }
 
 func (s *Service) GetDashboardCalendar(ctx context.Context, userID uuid.UUID, date string) ([]dto.FullMonthProgressEvents, error) {
@@ -381,9 +521,10 @@ func BuildFullMonthEvents(events []repository.ProgressEvent, month time.Time) []
 }
 
 func (s *Service) ReportSlip(ctx context.Context, userID uuid.UUID) error {
-	err := s.repository.UpsertProgressStatus(ctx, uuid.MustParse("d1fc8771-bac7-4080-913b-2b25e4ab4957"), repository.ProgressEventStatusSlip) // @TODO: get user id from context or drill from handler
+	err := s.repository.UpsertProgressStatus(ctx, userID, time.Now(), repository.ProgressEventStatusSlip)
 	if err != nil {
 		return fmt.Errorf("reportSlip :: createProgressEvent: %w", err)
 	}
+	s.checkAchievements(ctx, userID)
 	return nil
 }
@@ -0,0 +1,311 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"patient-chatbot/internal/client/llm"
+	"patient-chatbot/internal/dto"
+	"patient-chatbot/internal/repository"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	minChatMaxTokens = 64
+	maxChatMaxTokens = 4096
+	maxTemperature   = 2
+)
+
+var (
+	ErrInvalidAssistantSettings = errors.New("invalid assistant settings")
+	ErrSettingsVersionNotFound  = errors.New("settings version not found")
+	ErrSettingsChanged          = errors.New("settings were changed since they were loaded")
+)
+
+// AssistantSettingsInput is an admin's version of the assistant settings. Empty text fields
+// use the built-in defaults. When BaseVersion is set, saving fails with ErrSettingsChanged if
+// another version was saved after it.
+type AssistantSettingsInput struct {
+	PersonaName         string
+	Tone                string
+	MaxCompletionTokens int
+	Temperature         float32
+	OutOfScopeRefusalEN string
+	OutOfScopeRefusalAR string
+	MedicalRefusalEN    string
+	MedicalRefusalAR    string
+	PromptTemplateEN    string
+	PromptTemplateAR    string
+	BaseVersion         *int
+}
+
+// AssistantPreview is what the assistant would be sent with a set of settings.
+type AssistantPreview struct {
+	SystemPrompt string
+	Temperature  float32
+	MaxTokens    int
+	Context      []string
+}
+
+// GetAssistantSettings returns the settings in effect for the organization: its latest
+// version, or the defaults as version 0 if it has never saved any.
+func (s *Service) GetAssistantSettings(ctx context.Context, orgID uuid.UUID) (*repository.OrganizationSettings, error) {
+	settings, err := s.repository.GetLatestOrganizationSettings(ctx, orgID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return defaultAssistantSettings(orgID), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("getAssistantSettings :: getLatestOrganizationSettings: %w", err)
+	}
+	return settings, nil
+}
+
+func (s *Service) GetAssistantSettingsVersions(ctx context.Context, orgID uuid.UUID, page int, pageSize int) ([]repository.OrganizationSettings, int, error) {
+	offset := (page - 1) * pageSize
+	versions, total, err := s.repository.GetOrganizationSettingsVersions(ctx, orgID, offset, pageSize)
+	if err != nil {
+		return nil, 0, fmt.Errorf("getAssistantSettingsVersions :: getOrganizationSettingsVersions: %w", err)
+	}
+	return versions, total, nil
+}
+
+// SaveAssistantSettings stores input as the organization's next settings version, recording
+// who saved it. Prompt templates are rendered with sample data first, so a template that
+// would fail in a chat is rejected here.
+func (s *Service) SaveAssistantSettings(ctx context.Context, orgID uuid.UUID, userID uuid.UUID, input AssistantSettingsInput) (*repository.OrganizationSettings, error) {
+	settings, err := assistantSettingsFromInput(orgID, input)
+	if err != nil {
+		return nil, err
+	}
+	for _, lang := range []string{"en", "ar"} {
+		if _, err := renderAssistantPrompt(settings, lang, samplePromptContext(lang), previewProgress()); err != nil {
+			return nil, fmt.Errorf("%w: %s prompt template: %v", ErrInvalidAssistantSettings, lang, err)
+		}
+	}
+
+	latest, err := s.GetAssistantSettings(ctx, orgID)
+	if err != nil {
+		return nil, err
+	}
+	if input.BaseVersion != nil && *input.BaseVersion != latest.Version {
+		return nil, ErrSettingsChanged
+	}
+
+	settings.ID = uuid.New()
+	settings.Version = latest.Version + 1
+	settings.CreatedByID = &userID
+	err = s.repository.CreateOrganizationSettings(ctx, settings)
+	if errors.Is(err, repository.ErrDuplicate) {
+		return nil, ErrSettingsChanged
+	}
+	if err != nil {
+		return nil, fmt.Errorf("saveAssistantSettings :: createOrganizationSettings: %w", err)
+	}
+	return settings, nil
+}
+
+// RestoreAssistantSettings saves a copy of an earlier version as the newest one.
+func (s *Service) RestoreAssistantSettings(ctx context.Context, orgID uuid.UUID, userID uuid.UUID, version string) (*repository.OrganizationSettings, error) {
+	number, err := strconv.Atoi(version)
+	if err != nil {
+		return nil, ErrSettingsVersionNotFound
+	}
+	earlier, err := s.repository.GetOrganizationSettingsVersion(ctx, orgID, number)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrSettingsVersionNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("restoreAssistantSettings :: getOrganizationSettingsVersion: %w", err)
+	}
+
+	return s.SaveAssistantSettings(ctx, orgID, userID, AssistantSettingsInput{
+		PersonaName:         earlier.PersonaName,
+		Tone:                earlier.Tone,
+		MaxCompletionTokens: earlier.MaxCompletionTokens,
+		Temperature:         earlier.Temperature,
+		OutOfScopeRefusalEN: earlier.OutOfScopeRefusalEN,
+		OutOfScopeRefusalAR: earlier.OutOfScopeRefusalAR,
+		MedicalRefusalEN:    earlier.MedicalRefusalEN,
+		MedicalRefusalAR:    earlier.MedicalRefusalAR,
+		PromptTemplateEN:    earlier.PromptTemplateEN,
+		PromptTemplateAR:    earlier.PromptTemplateAR,
+	})
+}
+
+// PreviewAssistantSettings renders the system prompt input would produce in lang, without
+// saving anything. The context is retrieved from the organization's documents for message,
+// or sample snippets if message is empty; the progress is made up.
+func (s *Service) PreviewAssistantSettings(ctx context.Context, orgID uuid.UUID, input AssistantSettingsInput, lang string, message string) (*AssistantPreview, error) {
+	settings, err := assistantSettingsFromInput(orgID, input)
+	if err != nil {
+		return nil, err
+	}
+
+	snippets := samplePromptContext(lang)
+	if strings.TrimSpace(message) != "" {
+		chunks, err := s.retrieveContext(ctx, orgID, []dto.Message{{Role: dto.UserRole, Content: message}})
+		if err != nil {
+			return nil, err
+		}
+		snippets = chunkTexts(chunks)
+	}
+
+	prompt, err := renderAssistantPrompt(settings, lang, snippets, previewProgress())
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s prompt template: %v", ErrInvalidAssistantSettings, lang, err)
+	}
+	return &AssistantPreview{
+		SystemPrompt: prompt,
+		Temperature:  settings.Temperature,
+		MaxTokens:    settings.MaxCompletionTokens,
+		Context:      snippets,
+	}, nil
+}
+
+// chatOptions renders the organization's prompt for a chat with the user. If a custom
+// template fails on this user's data, the built-in one is used instead so the chat still
+// works.
+func (s *Service) chatOptions(ctx context.Context, userID uuid.UUID, orgID uuid.UUID, chunks []string, lang string) (llm.ChatOptions, error) {
+	settings, err := s.GetAssistantSettings(ctx, orgID)
+	if err != nil {
+		return llm.ChatOptions{}, err
+	}
+	progress, err := s.promptProgress(ctx, userID)
+	if err != nil {
+		return llm.ChatOptions{}, err
+	}
+
+	prompt, err := renderAssistantPrompt(settings, lang, chunks, progress)
+	if err != nil {
+		log.Warn().Msgf("chatOptions :: settings version %d of organization %s: %s", settings.Version, orgID, err.Error())
+		fallback := *settings
+		fallback.PromptTemplateEN, fallback.PromptTemplateAR = "", ""
+		if prompt, err = renderAssistantPrompt(&fallback, lang, chunks, progress); err != nil {
+			return llm.ChatOptions{}, err
+		}
+	}
+	return llm.ChatOptions{
+		SystemPrompt: prompt,
+		Temperature:  settings.Temperature,
+		MaxTokens:    settings.MaxCompletionTokens,
+	}, nil
+}
+
+func (s *Service) promptProgress(ctx context.Context, userID uuid.UUID) (llm.PromptProgress, error) {
+	dashboard, err := s.GetDashboardData(ctx, userID)
+	if err != nil {
+		return llm.PromptProgress{}, err
+	}
+	progress := llm.PromptProgress{
+		TotalDaysSmokeFree: dashboard.TotalDaysSmokeFree,
+		StreakDays:         dashboard.StreakDaysSmokeFree,
+		MoneySaved:         dashboard.TotalMoneySaved,
+	}
+
+	user, err := s.repository.GetUserByID(ctx, userID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return progress, nil
+	}
+	if err != nil {
+		return llm.PromptProgress{}, fmt.Errorf("promptProgress :: getUserByID: %w", err)
+	}
+	if user.QuitDate != nil {
+		progress.HasQuitDate = true
+		progress.QuitDate = user.QuitDate.Format(time.DateOnly)
+		progress.DaysSinceQuit = max(int(time.Since(*user.QuitDate).Hours()/24), 0)
+	}
+	return progress, nil
+}
+
+// renderAssistantPrompt executes the settings' template for lang, or the built-in one, with
+// the settings' persona, tone and refusals filled in over the defaults.
+func renderAssistantPrompt(settings *repository.OrganizationSettings, lang string, snippets []string, progress llm.PromptProgress) (string, error) {
+	data := llm.DefaultPromptData(lang)
+	data.Tone = llm.ToneDescription(settings.Tone, lang)
+	data.PersonaName = orText(settings.PersonaName, data.PersonaName)
+	data.Context = snippets
+	data.Progress = progress
+
+	template := llm.DefaultPromptTemplate(lang)
+	if lang == "en" {
+		data.OutOfScopeRefusal = orText(settings.OutOfScopeRefusalEN, data.OutOfScopeRefusal)
+		data.MedicalRefusal = orText(settings.MedicalRefusalEN, data.MedicalRefusal)
+		template = orText(settings.PromptTemplateEN, template)
+	} else {
+		data.OutOfScopeRefusal = orText(settings.OutOfScopeRefusalAR, data.OutOfScopeRefusal)
+		data.MedicalRefusal = orText(settings.MedicalRefusalAR, data.MedicalRefusal)
+		template = orText(settings.PromptTemplateAR, template)
+	}
+	return llm.RenderPrompt(template, data)
+}
+
+func assistantSettingsFromInput(orgID uuid.UUID, input AssistantSettingsInput) (*repository.OrganizationSettings, error) {
+	tone := input.Tone
+	if tone == "" {
+		tone = llm.ToneEmpathetic
+	}
+	if !slices.Contains(llm.Tones, tone) {
+		return nil, fmt.Errorf("%w: unknown tone %q", ErrInvalidAssistantSettings, input.Tone)
+	}
+	maxTokens := input.MaxCompletionTokens
+	if maxTokens == 0 {
+		maxTokens = llm.DefaultChatMaxTokens
+	}
+	if maxTokens < minChatMaxTokens || maxTokens > maxChatMaxTokens {
+		return nil, fmt.Errorf("%w: maxCompletionTokens must be between %d and %d", ErrInvalidAssistantSettings, minChatMaxTokens, maxChatMaxTokens)
+	}
+	if input.Temperature < 0 || input.Temperature > maxTemperature {
+		return nil, fmt.Errorf("%w: temperature must be between 0 and %d", ErrInvalidAssistantSettings, maxTemperature)
+	}
+
+	return &repository.OrganizationSettings{
+		OrganizationID:      orgID,
+		PersonaName:         strings.TrimSpace(input.PersonaName),
+		Tone:                tone,
+		MaxCompletionTokens: maxTokens,
+		Temperature:         input.Temperature,
+		OutOfScopeRefusalEN: strings.TrimSpace(input.OutOfScopeRefusalEN),
+		OutOfScopeRefusalAR: strings.TrimSpace(input.OutOfScopeRefusalAR),
+		MedicalRefusalEN:    strings.TrimSpace(input.MedicalRefusalEN),
+		MedicalRefusalAR:    strings.TrimSpace(input.MedicalRefusalAR),
+		PromptTemplateEN:    strings.TrimSpace(input.PromptTemplateEN),
+		PromptTemplateAR:    strings.TrimSpace(input.PromptTemplateAR),
+	}, nil
+}
+
+// defaultAssistantSettings apply until an organization saves its own.
+func defaultAssistantSettings(orgID uuid.UUID) *repository.OrganizationSettings {
+	return &repository.OrganizationSettings{
+		OrganizationID:      orgID,
+		Tone:                llm.ToneEmpathetic,
+		MaxCompletionTokens: llm.DefaultChatMaxTokens,
+	}
+}
+
+// previewProgress stands in for a patient's progress when checking or previewing a template.
+func previewProgress() llm.PromptProgress {
+	return llm.PromptProgress{
+		HasQuitDate:        true,
+		QuitDate:           time.Now().AddDate(0, 0, -30).Format(time.DateOnly),
+		DaysSinceQuit:      30,
+		TotalDaysSmokeFree: 28,
+		StreakDays:         12,
+		MoneySaved:         600,
+	}
+}
+
+// samplePromptContext stands in for retrieved snippets when checking or previewing a
+// template.
+func samplePromptContext(lang string) []string {
+	if lang == "en" {
+		return []string{"Cravings usually pass within 5 to 10 minutes; delay, drink water and take a short walk."}
+	}
+	return []string{"تزول الرغبة في التدخين عادةً خلال 5 إلى 10 دقائق؛ أجّلها واشرب الماء وتمشَّ قليلًا."}
+}